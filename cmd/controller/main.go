@@ -46,6 +46,7 @@ func main() {
 		op.EventRecorder,
 		op.InstanceTypeProvider,
 		op.SubnetProvider,
+		op.UnavailableOfferings,
 	)
 	if err != nil {
 		log.Error(err, "failed creating yandex provider")
@@ -76,6 +77,7 @@ func main() {
 			cloudProvider,
 			op.Clock,
 			op.SDK,
+			op.InstanceTypeProvider,
 		)...).
 		Start(ctx)
 }
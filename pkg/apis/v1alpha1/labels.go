@@ -18,6 +18,7 @@ package v1alpha1
 
 import (
 	"github.com/tufitko/karpenter-provider-yandex/pkg/apis"
+	corev1 "k8s.io/api/core/v1"
 
 	v1 "sigs.k8s.io/karpenter/pkg/apis/v1"
 )
@@ -25,15 +26,36 @@ import (
 const (
 	TerminationFinalizer = apis.Group + "/termination"
 	// Labels that can be selected on and are propagated to the node
-	LabelInstanceCPUPlatform = apis.Group + "/instance-cpu-platform" // intel-cascade-lake, intel-ice-lake, etc
-	LabelInstanceCPU         = apis.Group + "/instance-cpu"          // 2, 4, 8, 16, 32, 64, 128
-	LabelInstanceMemory      = apis.Group + "/instance-memory"       // 1Gi, 2Gi, 4Gi, 8Gi, 16Gi, 32Gi, 64Gi, 128Gi
-	LabelInstanceType        = apis.Group + "/instance-type"
-	LabelInstanceCPUFraction = apis.Group + "/instance-cpu-fraction"
+	LabelInstanceCPUPlatform  = apis.Group + "/instance-cpu-platform" // intel-cascade-lake, intel-ice-lake, etc
+	LabelInstanceCPU          = apis.Group + "/instance-cpu"          // 2, 4, 8, 16, 32, 64, 128
+	LabelInstanceMemory       = apis.Group + "/instance-memory"       // 1Gi, 2Gi, 4Gi, 8Gi, 16Gi, 32Gi, 64Gi, 128Gi
+	LabelInstanceType         = apis.Group + "/instance-type"
+	LabelInstanceCPUFraction  = apis.Group + "/instance-cpu-fraction"
+	LabelInstancePlatformName = apis.Group + "/instance-platform-name" // intel-ice-lake, amd-zen-4, etc
+	LabelInstanceGPUCount     = apis.Group + "/instance-gpu-count"     // 1, 2, 4, 8, only on GPU platforms
+	LabelInstanceGPUType      = apis.Group + "/instance-gpu-type"      // nvidia-tesla-v100, nvidia-ampere-a100, etc, only on GPU platforms
 
 	LabelYandexPCITopology    = "yandex.cloud/pci-topology"
+	LabelYandexNodeGroupID    = "yandex.cloud/node-group-id"
 	LabelYandexMasqAgentReady = "node.kubernetes.io/masq-agent-ds-ready"
 	LabelYandexNPDReady       = "node.kubernetes.io/node-problem-detector-ds-ready"
+
+	// LabelNUMANodes is the number of NUMA nodes of the instance, only on instance types with a known NUMA topology,
+	// which the built-in configurations do not have until they are regenerated
+	LabelNUMANodes = "topology.yandex.cloud/numa-nodes"
+
+	// ResourceNVIDIAGPU is the extended resource the NVIDIA device plugin advertises the GPUs of a node as
+	ResourceNVIDIAGPU corev1.ResourceName = "nvidia.com/gpu"
+
+	// AnnotationYandexNodeClassHash is the hash of the nodeclass spec a NodeClaim was launched with
+	AnnotationYandexNodeClassHash = apis.Group + "/yandexnodeclass-hash"
+	// AnnotationCreateOperationID is the id of the Yandex Cloud operation that created the node group of a NodeClaim
+	AnnotationCreateOperationID = apis.Group + "/create-operation-id"
+	// AnnotationSpotSavingsPercent is by how many percent a spot NodeClaim is cheaper than an on-demand one would be
+	AnnotationSpotSavingsPercent = apis.Group + "/spot-savings-percent"
+	// AnnotationNodePrice is the hourly price of the offering the node runs on, e.g. 0.0305, an annotation since it
+	// changes with the prices and is no scheduling key
+	AnnotationNodePrice = apis.Group + "/node-price"
 )
 
 func init() {
@@ -44,8 +66,12 @@ func init() {
 		LabelInstanceMemory,
 		LabelInstanceType,
 		LabelInstanceCPUFraction,
+		LabelInstancePlatformName,
+		LabelInstanceGPUCount,
+		LabelInstanceGPUType,
 		LabelYandexPCITopology,
 		LabelYandexMasqAgentReady,
 		LabelYandexNPDReady,
+		LabelNUMANodes,
 	)
 }
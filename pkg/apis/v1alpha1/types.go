@@ -18,29 +18,46 @@ package v1alpha1
 
 import (
 	"github.com/awslabs/operatorpkg/status"
+	"github.com/mitchellh/hashstructure/v2"
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
 )
 
 const (
 	ConditionTypeSubnetsReady        = "SubnetsReady"
 	ConditionTypeSecurityGroupsReady = "SecurityGroupsReady"
 	ConditionTypeValidationSucceeded = "ValidationSucceeded"
+	// ConditionTypeLaunchSucceeded is False while Yandex Cloud rejects node groups of the nodeclass permanently, e.g.
+	// for an invalid configuration or a missing permission
+	ConditionTypeLaunchSucceeded = "LaunchSucceeded"
 )
 
 // YandexNodeClassSpec is the specification for a YandexNodeClass
 type YandexNodeClassSpec struct {
-	// Platform is the platform of the nodes
-	// Default is "standard-v3"
-	// +kubebuilder:validation:Enum:=standard-v1;standard-v2;standard-v3
-	// +kubebuilder:default=standard-v3
+	// Platform is the platform of the nodes.
+	// Instance types of every platform are offered when neither Platform nor Platforms is set. Only platforms with
+	// instance configurations in the region are allowed
+	// +kubebuilder:validation:Enum:=standard-v1;standard-v2;standard-v3;highfreq-v3;gpu-standard-v1;gpu-standard-v2;gpu-standard-v3;gpu-standard-v3i;standard-v3-t4;standard-v3-t4i
 	// +optional
-	Platform string `json:"platform"`
+	Platform string `json:"platform,omitempty"`
+
+	// Platforms are further platforms of the nodes, in addition to Platform
+	// +kubebuilder:validation:items:Enum:=standard-v1;standard-v2;standard-v3;highfreq-v3;gpu-standard-v1;gpu-standard-v2;gpu-standard-v3;gpu-standard-v3i;standard-v3-t4;standard-v3-t4i
+	// +optional
+	Platforms []string `json:"platforms,omitempty"`
 
 	// CoreFractions is the list of core fractions to use for the nodes
-	// If not specified, the default core fraction of 100% will be used
+	// If not specified, the default core fraction of the operator will be used, 100% unless configured otherwise
+	// +optional
+	CoreFractions []CoreFraction `json:"core_fractions,omitempty" hash:"ignore"`
+
+	// PlatformPreference is an ordered list of platforms to prefer when instance types are priced
+	// within a small tolerance of each other. Earlier platforms win such ties
 	// +optional
-	CoreFractions []CoreFraction `json:"core_fractions,omitempty"`
+	PlatformPreference []string `json:"platformPreference,omitempty" hash:"ignore"`
 
 	// SubnetSelectorTerms is a list of subnet selector terms. The terms are ORed.
 	// +kubebuilder:validation:XValidation:message="subnetSelectorTerms cannot be empty",rule="self.size() != 0"
@@ -50,6 +67,33 @@ type YandexNodeClassSpec struct {
 	// +required
 	SubnetSelectorTerms []SubnetSelectorTerm `json:"subnetSelectorTerms" hash:"ignore"`
 
+	// ZoneSubnets is an explicit mapping of zone to subnet ID.
+	// When set, nodes are launched only into these subnets instead of the ones matched by SubnetSelectorTerms
+	// +optional
+	ZoneSubnets map[string]string `json:"zoneSubnets,omitempty" hash:"ignore"`
+
+	// AutoDiscoverSubnets falls back to the subnets of the cluster network when SubnetSelectorTerms match no subnet,
+	// picking the subnet with the most free IPs in every zone
+	// +optional
+	AutoDiscoverSubnets bool `json:"autoDiscoverSubnets,omitempty" hash:"ignore"`
+
+	// PlacementStrategy is how the zones of new nodes are chosen among the zones they can be launched in
+	// +optional
+	PlacementStrategy *PlacementStrategy `json:"placementStrategy,omitempty" hash:"ignore"`
+
+	// ReleaseChannel is the managed Kubernetes release channel the nodes are expected to follow.
+	// Node groups always run the version of the cluster, so it must match the release channel of the cluster
+	// +kubebuilder:validation:Enum:=rapid;regular;stable
+	// +optional
+	ReleaseChannel string `json:"releaseChannel,omitempty"`
+
+	// ContainerRuntime is the container runtime of the nodes.
+	// Docker is only supported by clusters running Kubernetes older than 1.24
+	// +kubebuilder:validation:Enum:=containerd;docker
+	// +kubebuilder:default=containerd
+	// +optional
+	ContainerRuntime string `json:"containerRuntime,omitempty"`
+
 	// DiskType is the type of disk to create
 	// Valid values are:
 	// - "network-hdd"
@@ -61,10 +105,20 @@ type YandexNodeClassSpec struct {
 	// +kubebuilder:default=network-ssd
 	DiskType string `json:"diskType,omitempty"`
 
+	// SpotDiskType is the type of disk to create for spot nodes, DiskType is used when it is not specified
+	// +optional
+	// +kubebuilder:validation:Enum=network-hdd;network-ssd;network-ssd-nonreplicated;network-ssd-io-m3
+	SpotDiskType string `json:"spotDiskType,omitempty"`
+
+	// OnDemandDiskType is the type of disk to create for on-demand nodes, DiskType is used when it is not specified
+	// +optional
+	// +kubebuilder:validation:Enum=network-hdd;network-ssd;network-ssd-nonreplicated;network-ssd-io-m3
+	OnDemandDiskType string `json:"onDemandDiskType,omitempty"`
+
 	// DiskSize is the size of the booted disk
 	// +optional
 	// +kubebuilder:default="30Gi"
-	DiskSize resource.Quantity `json:"diskSize,omitempty"`
+	DiskSize resource.Quantity `json:"diskSize,omitempty" hash:"ignore"`
 
 	// Labels to apply to the VMs
 	// +optional
@@ -74,6 +128,20 @@ type YandexNodeClassSpec struct {
 	// +optional
 	NodeLabels map[string]string `json:"nodeLabels,omitempty"`
 
+	// NodeAnnotations are additional annotations on the nodes. Node groups cannot annotate their nodes, so they are
+	// set on the NodeClaims of the nodes, which Karpenter copies to the nodes when they register
+	// +optional
+	NodeAnnotations map[string]string `json:"nodeAnnotations,omitempty"`
+
+	// StartupTaints are applied to the nodes at launch, in addition to the taints of the NodePool, for a controller
+	// to remove once it has initialized the node, such as node.cilium.io/agent-not-ready.
+	// Karpenter does not take them into account when scheduling pods, taints that stay on the nodes belong in the
+	// NodePool template
+	// +kubebuilder:validation:XValidation:message="startupTaints must have the effect NoSchedule or NoExecute",rule="self.all(t, t.effect in ['NoSchedule', 'NoExecute'])"
+	// +kubebuilder:validation:MaxItems:=30
+	// +optional
+	StartupTaints []corev1.Taint `json:"startupTaints,omitempty"`
+
 	// SecurityGroups to apply to the VMs
 	// +optional
 	SecurityGroups []string `json:"securityGroups,omitempty"`
@@ -82,6 +150,88 @@ type YandexNodeClassSpec struct {
 	// +optional
 	// +kubebuilder:default=false
 	SoftwareAcceleratedNetworkSettings bool `json:"softwareAcceleratedNetworkSettings,omitempty"`
+
+	// AssignPublicIP assigns a public IPv4 address to the nodes through one-to-one NAT, so that they reach the internet
+	// without a NAT gateway in their subnet
+	// +optional
+	// +kubebuilder:default=false
+	AssignPublicIP bool `json:"assignPublicIP,omitempty"`
+
+	// UserDataTemplate is a cloud-init user-data Go template rendered for every node at launch.
+	// The template can reference {{ .Zone }}, {{ .InstanceType }} and {{ .NodeName }}
+	// +optional
+	UserDataTemplate string `json:"userDataTemplate,omitempty"`
+
+	// MetadataOptions are the metadata of the node VMs
+	// +optional
+	MetadataOptions *MetadataOptions `json:"metadataOptions,omitempty"`
+
+	// GPUDriverVersion is the NVIDIA driver version the bootstrap of the nodes installs, e.g. 535.104.05, passed on
+	// in the gpu-driver-version metadata key. A nodeclass with a driver version only launches GPU instance types
+	// +kubebuilder:validation:Pattern:=`^[0-9]+(\.[0-9]+){0,2}$`
+	// +optional
+	GPUDriverVersion string `json:"gpuDriverVersion,omitempty"`
+
+	// AutoRepair enables automatic repair (VM replacement) of the nodes by Yandex Cloud.
+	// Disable it for stateful workloads to let Karpenter handle node repair instead
+	// +optional
+	// +kubebuilder:default=true
+	AutoRepair *bool `json:"autoRepair,omitempty"`
+
+	// MaintenancePolicy is the maintenance policy of the node groups of the nodes
+	// +optional
+	MaintenancePolicy *MaintenancePolicy `json:"maintenancePolicy,omitempty"`
+
+	// MaxNodeAge is the maximum lifetime of the nodes, older nodes are reported as drifted and replaced.
+	// Nodes are not expired when it is not specified
+	// +optional
+	MaxNodeAge *metav1.Duration `json:"maxNodeAge,omitempty" hash:"ignore"`
+}
+
+// AutoRepairEnabled returns whether Yandex Cloud auto-repair is enabled, defaulting to true.
+func (in *YandexNodeClassSpec) AutoRepairEnabled() bool {
+	return in.AutoRepair == nil || *in.AutoRepair
+}
+
+// AutoUpgradeEnabled returns whether Yandex Cloud auto-upgrade is enabled, defaulting to false
+func (in *YandexNodeClassSpec) AutoUpgradeEnabled() bool {
+	return in.MaintenancePolicy != nil && in.MaintenancePolicy.AutoUpgrade != nil && *in.MaintenancePolicy.AutoUpgrade
+}
+
+// MaintenanceWindow returns the maintenance window of the nodes, nil when they can be maintained at any time
+func (in *YandexNodeClassSpec) MaintenanceWindow() *MaintenanceWindow {
+	if in.MaintenancePolicy == nil {
+		return nil
+	}
+	return in.MaintenancePolicy.MaintenanceWindow
+}
+
+// PlatformsOrAll returns the platforms of the nodes, Platform and then Platforms, or nil when instance types of every
+// platform are offered
+func (in *YandexNodeClassSpec) PlatformsOrAll() []string {
+	if in.Platform == "" {
+		return lo.Uniq(in.Platforms)
+	}
+	return lo.Uniq(append([]string{in.Platform}, in.Platforms...))
+}
+
+// DiskTypeFor returns the type of disk to create for nodes of the capacity type
+func (in *YandexNodeClassSpec) DiskTypeFor(capacityType string) string {
+	switch {
+	case capacityType == karpv1.CapacityTypeSpot && in.SpotDiskType != "":
+		return in.SpotDiskType
+	case capacityType == karpv1.CapacityTypeOnDemand && in.OnDemandDiskType != "":
+		return in.OnDemandDiskType
+	}
+	return in.DiskType
+}
+
+// CoreFractionsOrDefault returns the core fractions of the nodes, falling back to defaultCoreFraction when none are specified
+func (in *YandexNodeClassSpec) CoreFractionsOrDefault(defaultCoreFraction CoreFraction) []CoreFraction {
+	if len(in.CoreFractions) == 0 {
+		return []CoreFraction{defaultCoreFraction}
+	}
+	return in.CoreFractions
 }
 
 // CoreFraction is a string representation of a core fraction
@@ -130,11 +280,67 @@ type PlacementStrategy struct {
 	ZoneBalance string `json:"zoneBalance,omitempty"`
 }
 
+const (
+	// ZoneBalanceBalanced spreads nodes evenly across zones by picking one of them at random
+	ZoneBalanceBalanced = "Balanced"
+	// ZoneBalanceAvailabilityFirst prefers zones where nodes were recently created and then zones with the most free
+	// IPs in their subnet
+	ZoneBalanceAvailabilityFirst = "AvailabilityFirst"
+)
+
+// ZoneBalanceOrDefault returns how nodes are distributed across zones, defaulting to Balanced
+func (in *PlacementStrategy) ZoneBalanceOrDefault() string {
+	if in == nil || in.ZoneBalance == "" {
+		return ZoneBalanceBalanced
+	}
+	return in.ZoneBalance
+}
+
 // MetadataOptions contains parameters for specifying VM metadata
 type MetadataOptions struct {
-	// UserData is base64-encoded user-data to be made available to the instance
+	// UserData is base64-encoded user-data to be made available to the instance.
+	// It cannot be combined with UserDataTemplate
 	// +optional
 	UserData string `json:"userData,omitempty"`
+
+	// EnableOSLogin enables OS Login on the instance
+	// +optional
+	// +kubebuilder:default=true
+	EnableOSLogin *bool `json:"enableOSLogin,omitempty"`
+}
+
+// OSLoginEnabled returns whether OS Login is enabled, defaulting to true
+func (in *MetadataOptions) OSLoginEnabled() bool {
+	return in == nil || in.EnableOSLogin == nil || *in.EnableOSLogin
+}
+
+// MaintenancePolicy contains parameters for the maintenance of the nodes by Yandex Cloud
+type MaintenancePolicy struct {
+	// AutoUpgrade enables automatic upgrades of the nodes to the version of the cluster by Yandex Cloud
+	// +optional
+	// +kubebuilder:default=false
+	AutoUpgrade *bool `json:"autoUpgrade,omitempty"`
+
+	// MaintenanceWindow is when Yandex Cloud may upgrade the nodes, at any time when it is not specified
+	// +optional
+	MaintenanceWindow *MaintenanceWindow `json:"maintenanceWindow,omitempty"`
+}
+
+// MaintenanceWindow is a daily or weekly window for the maintenance of the nodes
+type MaintenanceWindow struct {
+	// Days are the days of the week the window opens on, every day when none are specified
+	// +kubebuilder:validation:items:Enum=monday;tuesday;wednesday;thursday;friday;saturday;sunday
+	// +optional
+	Days []string `json:"days,omitempty"`
+
+	// StartTime is the UTC time of day the window opens at, in HH:MM format
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	// +required
+	StartTime string `json:"startTime"`
+
+	// Duration is how long the window stays open, from 1h to 24h
+	// +required
+	Duration metav1.Duration `json:"duration"`
 }
 
 // YandexNodeClassStatus defines the observed state of YandexNodeClass
@@ -144,6 +350,11 @@ type YandexNodeClassStatus struct {
 	// +optional
 	Subnets []Subnet `json:"subnets,omitempty"`
 
+	// SecurityGroups contains the ids of spec.securityGroups that were found
+	// in the cluster network.
+	// +optional
+	SecurityGroups []string `json:"securityGroups,omitempty"`
+
 	// SpecHash is a hash of the YandexNodeClass spec
 	// +optional
 	SpecHash uint64 `json:"specHash,omitempty"`
@@ -166,6 +377,16 @@ type YandexNodeClassStatus struct {
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+// Hash returns a hash of the spec fields the nodes of the nodeclass are launched with. Fields that only steer the
+// instance type and subnet selection are left out, so that changing them does not replace existing nodes
+func (in *YandexNodeClass) Hash() uint64 {
+	// quantities keep their value in unexported fields, which are not hashed
+	return lo.Must(hashstructure.Hash([]interface{}{
+		in.Spec,
+		in.Spec.DiskSize.String(),
+	}, hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true, IgnoreZeroValue: true, ZeroNil: true}))
+}
+
 // StatusConditions returns the condition set for the status.Object interface
 func (in *YandexNodeClass) StatusConditions() status.ConditionSet {
 	return status.NewReadyConditions().For(in)
@@ -21,13 +21,65 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenancePolicy) DeepCopyInto(out *MaintenancePolicy) {
+	*out = *in
+	if in.AutoUpgrade != nil {
+		in, out := &in.AutoUpgrade, &out.AutoUpgrade
+		*out = new(bool)
+		**out = **in
+	}
+	if in.MaintenanceWindow != nil {
+		in, out := &in.MaintenanceWindow, &out.MaintenanceWindow
+		*out = new(MaintenanceWindow)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenancePolicy.
+func (in *MaintenancePolicy) DeepCopy() *MaintenancePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenancePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindow) DeepCopyInto(out *MaintenanceWindow) {
+	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	out.Duration = in.Duration
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindow.
+func (in *MaintenanceWindow) DeepCopy() *MaintenanceWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MetadataOptions) DeepCopyInto(out *MetadataOptions) {
 	*out = *in
+	if in.EnableOSLogin != nil {
+		in, out := &in.EnableOSLogin, &out.EnableOSLogin
+		*out = new(bool)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetadataOptions.
@@ -159,6 +211,16 @@ func (in *YandexNodeClassSpec) DeepCopyInto(out *YandexNodeClassSpec) {
 		*out = make([]CoreFraction, len(*in))
 		copy(*out, *in)
 	}
+	if in.Platforms != nil {
+		in, out := &in.Platforms, &out.Platforms
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PlatformPreference != nil {
+		in, out := &in.PlatformPreference, &out.PlatformPreference
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.SubnetSelectorTerms != nil {
 		in, out := &in.SubnetSelectorTerms, &out.SubnetSelectorTerms
 		*out = make([]SubnetSelectorTerm, len(*in))
@@ -166,6 +228,18 @@ func (in *YandexNodeClassSpec) DeepCopyInto(out *YandexNodeClassSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.ZoneSubnets != nil {
+		in, out := &in.ZoneSubnets, &out.ZoneSubnets
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.PlacementStrategy != nil {
+		in, out := &in.PlacementStrategy, &out.PlacementStrategy
+		*out = new(PlacementStrategy)
+		**out = **in
+	}
 	out.DiskSize = in.DiskSize.DeepCopy()
 	if in.Labels != nil {
 		in, out := &in.Labels, &out.Labels
@@ -181,11 +255,45 @@ func (in *YandexNodeClassSpec) DeepCopyInto(out *YandexNodeClassSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.NodeAnnotations != nil {
+		in, out := &in.NodeAnnotations, &out.NodeAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.StartupTaints != nil {
+		in, out := &in.StartupTaints, &out.StartupTaints
+		*out = make([]corev1.Taint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.SecurityGroups != nil {
 		in, out := &in.SecurityGroups, &out.SecurityGroups
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.MetadataOptions != nil {
+		in, out := &in.MetadataOptions, &out.MetadataOptions
+		*out = new(MetadataOptions)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AutoRepair != nil {
+		in, out := &in.AutoRepair, &out.AutoRepair
+		*out = new(bool)
+		**out = **in
+	}
+	if in.MaintenancePolicy != nil {
+		in, out := &in.MaintenancePolicy, &out.MaintenancePolicy
+		*out = new(MaintenancePolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaxNodeAge != nil {
+		in, out := &in.MaxNodeAge, &out.MaxNodeAge
+		*out = new(v1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new YandexNodeClassSpec.
@@ -206,6 +314,11 @@ func (in *YandexNodeClassStatus) DeepCopyInto(out *YandexNodeClassStatus) {
 		*out = make([]Subnet, len(*in))
 		copy(*out, *in)
 	}
+	if in.SecurityGroups != nil {
+		in, out := &in.SecurityGroups, &out.SecurityGroups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	in.LastValidationTime.DeepCopyInto(&out.LastValidationTime)
 	if in.SelectedInstanceTypes != nil {
 		in, out := &in.SelectedInstanceTypes, &out.SelectedInstanceTypes
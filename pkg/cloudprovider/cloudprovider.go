@@ -20,15 +20,17 @@ import (
 	"context"
 	_ "embed"
 	"fmt"
-	"maps"
 	"math/rand"
-	"sort"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/tufitko/karpenter-provider-yandex/pkg/apis"
 	"github.com/tufitko/karpenter-provider-yandex/pkg/providers/instancetype"
+	instancetypeoffering "github.com/tufitko/karpenter-provider-yandex/pkg/providers/instancetype/offering"
 	"github.com/tufitko/karpenter-provider-yandex/pkg/providers/subnet"
+	nodeclaimutils "github.com/tufitko/karpenter-provider-yandex/pkg/utils/nodeclaim"
 	"github.com/tufitko/karpenter-provider-yandex/pkg/yandex"
 	"github.com/yandex-cloud/go-genproto/yandex/cloud/k8s/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -38,19 +40,26 @@ import (
 
 	"github.com/awslabs/operatorpkg/status"
 	"github.com/go-logr/logr"
+	"github.com/google/uuid"
 	"github.com/samber/lo"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/clock"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	"github.com/tufitko/karpenter-provider-yandex/pkg/apis/v1alpha1"
 	cloudproviderevents "github.com/tufitko/karpenter-provider-yandex/pkg/cloudprovider/events"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/operator/options"
 	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
 	"sigs.k8s.io/karpenter/pkg/cloudprovider"
 	"sigs.k8s.io/karpenter/pkg/scheduling"
+	nodeutils "sigs.k8s.io/karpenter/pkg/utils/node"
+	podutils "sigs.k8s.io/karpenter/pkg/utils/pod"
 	"sigs.k8s.io/karpenter/pkg/utils/resources"
 )
 
@@ -65,11 +74,31 @@ type CloudProvider struct {
 	kubeClient client.Client
 	recorder   events.Recorder
 	log        logr.Logger
+	// ctx is the operator context the cloud provider was created with, for the methods of the cloud provider
+	// interface that are not passed one
+	ctx context.Context
 
-	instanceTypes instancetype.Provider
-	subnets       subnet.Provider
+	instanceTypes        instancetype.Provider
+	subnets              subnet.Provider
+	unavailableOfferings *instancetypeoffering.UnavailableOfferings
 
 	sdk yandex.SDK
+
+	repairToleration           time.Duration
+	autoRepairRepairToleration time.Duration
+	safeDelete                 bool
+	defaultNodeLabels          map[string]string
+	createLimiter              *createLimiter
+	// rand picks the zone of new nodes, it is a field rather than the global source so that tests can seed it
+	rand *rand.Rand
+	// zoneOutcomes are the outcomes of recent creates by zone, the AvailabilityFirst placement strategy prefers
+	// zones where creates succeed
+	zoneOutcomes *zoneOutcomes
+	// providerIDRetryTimeout is how long a running node group is waited on to report the provider id of its node
+	providerIDRetryTimeout time.Duration
+	// providerIDRetryInterval is the pause between attempts to resolve the provider id of a node group
+	providerIDRetryInterval time.Duration
+	clk                     clock.Clock
 }
 
 func NewCloudProvider(ctx context.Context,
@@ -78,16 +107,29 @@ func NewCloudProvider(ctx context.Context,
 	recorder events.Recorder,
 	instanceTypes instancetype.Provider,
 	subnets subnet.Provider,
+	unavailableOfferings *instancetypeoffering.UnavailableOfferings,
 ) (*CloudProvider, error) {
 	log := log.FromContext(ctx).WithName(CloudProviderName)
 	log.WithName("NewCloudProvider()")
 	provider := &CloudProvider{
-		kubeClient:    kubeClient,
-		sdk:           sdk,
-		log:           log,
-		recorder:      recorder,
-		instanceTypes: instanceTypes,
-		subnets:       subnets,
+		kubeClient:                 kubeClient,
+		sdk:                        sdk,
+		log:                        log,
+		ctx:                        ctx,
+		recorder:                   recorder,
+		instanceTypes:              instanceTypes,
+		subnets:                    subnets,
+		unavailableOfferings:       unavailableOfferings,
+		repairToleration:           options.FromContext(ctx).NodeRepairToleration,
+		autoRepairRepairToleration: options.FromContext(ctx).AutoRepairRepairToleration,
+		safeDelete:                 options.FromContext(ctx).SafeDelete,
+		defaultNodeLabels:          options.FromContext(ctx).DefaultNodeLabels,
+		createLimiter:              newCreateLimiter(options.FromContext(ctx).MaxConcurrentCreates),
+		rand:                       newRand(time.Now().UnixNano()),
+		zoneOutcomes:               newZoneOutcomes(),
+		providerIDRetryTimeout:     options.FromContext(ctx).ProviderIDRetryTimeout,
+		providerIDRetryInterval:    time.Second,
+		clk:                        clock.RealClock{},
 	}
 	return provider, nil
 }
@@ -119,6 +161,9 @@ func (c CloudProvider) Create(ctx context.Context, nodeClaim *karpv1.NodeClaim)
 	if nodeClassReady != nil && nodeClassReady.ObservedGeneration != nodeClass.Generation {
 		return nil, cloudprovider.NewNodeClassNotReadyError(fmt.Errorf("nodeclass status has not been reconciled against the latest spec"))
 	}
+	if launch := nodeClass.StatusConditions().Get(v1alpha1.ConditionTypeLaunchSucceeded); c.launchRejected(launch, nodeClass) {
+		return nil, cloudprovider.NewNodeClassNotReadyError(fmt.Errorf("%s", launch.Message))
+	}
 
 	instanceTypes, err := c.resolveInstanceTypes(ctx, nodeClaim, nodeClass)
 	if err != nil {
@@ -136,25 +181,41 @@ func (c CloudProvider) Create(ctx context.Context, nodeClaim *karpv1.NodeClaim)
 	if err != nil {
 		return nil, fmt.Errorf("listing subnets, %w", err)
 	}
-	zoneToSubnet := lo.SliceToMap(subnets, func(s subnet.Subnet) (string, subnet.Subnet) {
-		return s.ZoneID, s
-	})
+	zoneToSubnet := mostFreeSubnets(subnets)
+	if len(nodeClass.Spec.ZoneSubnets) > 0 {
+		// explicit zone to subnet mapping overrides the subnets resolved by selector terms
+		zoneToSubnet = lo.MapValues(nodeClass.Spec.ZoneSubnets, func(subnetID string, zone string) subnet.Subnet {
+			return subnet.Subnet{ID: subnetID, ZoneID: zone}
+		})
+	}
 
-	instanceTypes = lo.Filter(instanceTypes, func(it *cloudprovider.InstanceType, _ int) bool {
+	// instance types may be shared with the instance type provider cache, so offering requirements are narrowed
+	// on copies instead of accumulating on the shared offerings across calls
+	resolvedInstanceTypes := instanceTypes
+	instanceTypes = lo.FilterMap(instanceTypes, func(it *cloudprovider.InstanceType, _ int) (*cloudprovider.InstanceType, bool) {
+		it = it.DeepCopy()
 		offerings := lo.Filter(it.Offerings, func(off *cloudprovider.Offering, _ int) bool {
+			if _, ok := zoneToSubnet[off.Zone()]; !ok {
+				// there is no subnet to launch the node group in
+				return false
+			}
 			off.Requirements.Add(it.Requirements.Values()...)
 			off.Requirements.Add(
 				scheduling.NewRequirement(karpv1.NodePoolLabelKey, corev1.NodeSelectorOpIn, nodeClaim.Labels[karpv1.NodePoolLabelKey]),
-				scheduling.NewRequirement("karpenter.yandex.cloud/yandexnodeclass", corev1.NodeSelectorOpIn, nodeClaim.Labels["karpenter.yandex.cloud/yandexnodeclass"]),
+				scheduling.NewRequirement(nodeClassLabelKey, corev1.NodeSelectorOpIn, nodeClaim.Labels[nodeClassLabelKey]),
 			)
 			return off.Requirements.IsCompatible(reqs)
 		})
 
 		it.Offerings = offerings
-		return len(offerings) > 0
+		return it, len(offerings) > 0
 	})
 
-	it := instanceTypes[0]
+	it, ok := selectInstanceType(instanceTypes, nodeClaim.Spec.Resources.Requests)
+	if !ok {
+		c.publishZoneCapacityExhausted(ctx, nodeClaim, resolvedInstanceTypes)
+		return nil, cloudprovider.NewInsufficientCapacityError(fmt.Errorf("no compatible instance type with available offerings fits the nodeclaim resources"))
+	}
 
 	availableOfferings := it.Offerings.Available()
 
@@ -162,44 +223,80 @@ func (c CloudProvider) Create(ctx context.Context, nodeClaim *karpv1.NodeClaim)
 		return off.CapacityType() == karpv1.CapacityTypeSpot
 	})
 
-	// This is very bad, but at the moment there is no normal way to check the availability of a zone to raise a node,
-	// so in order to avoid constantly raising nodes in an inaccessible zone,
-	// we will choose offering with a random zone.
-	var offering *cloudprovider.Offering
-
-	if len(spotOfferings) > 0 {
-		offering = spotOfferings[rand.Intn(len(spotOfferings))]
-	} else {
-		offering = availableOfferings[rand.Intn(len(availableOfferings))]
-	}
+	// there is no way to check the capacity of a zone before launching a node there, so the zone is chosen by the
+	// placement strategy of the nodeclass
+	candidates := lo.Ternary(len(spotOfferings) > 0, spotOfferings, availableOfferings)
+	offering := selectOffering(candidates, c.zoneScorers(nodeClass.Spec.PlacementStrategy, zoneToSubnet), c.rand)
 
 	var yait yandex.InstanceType
 	if err = yait.FromString(it.Name); err != nil {
 		return nil, fmt.Errorf("parse instance type name: %w", err)
 	}
+	if err = yait.Validate(); err != nil {
+		return nil, cloudprovider.NewCreateError(err, "InvalidInstanceType", "Instance type cannot be launched")
+	}
 
-	labels := maps.Clone(nodeClass.Spec.Labels)
+	labels := lo.Assign(nodeClass.Spec.Labels)
 	labels[karpv1.NodePoolLabelKey] = nodeClaim.Labels[karpv1.NodePoolLabelKey]
-	labels["karpenter.yandex.cloud/yandexnodeclass"] = nodeClaim.Labels["karpenter.yandex.cloud/yandexnodeclass"]
+	labels[nodeClassLabelKey] = nodeClaim.Labels[nodeClassLabelKey]
 
-	nodeLabels := maps.Clone(nodeClass.Spec.NodeLabels)
+	nodeLabels := lo.Assign(c.defaultNodeLabels, nodeClass.Spec.NodeLabels)
 	nodeLabels[karpv1.NodePoolLabelKey] = nodeClaim.Labels[karpv1.NodePoolLabelKey]
-	labels["karpenter.yandex.cloud/yandexnodeclass"] = nodeClaim.Labels["karpenter.yandex.cloud/yandexnodeclass"]
+	nodeLabels[nodeClassLabelKey] = nodeClaim.Labels[nodeClassLabelKey]
 	nodeLabels[v1alpha1.LabelInstanceCPUPlatform] = string(yait.Platform)
 	nodeLabels[v1alpha1.LabelInstanceCPU] = yait.CPU.String()
 	nodeLabels[v1alpha1.LabelInstanceMemory] = yait.Memory.String()
 	nodeLabels[v1alpha1.LabelInstanceCPUFraction] = fmt.Sprintf("%d", yait.CoreFraction)
+	if gpus := yait.GPUs(); gpus > 0 {
+		nodeLabels[v1alpha1.LabelInstanceGPUCount] = fmt.Sprint(gpus)
+		nodeLabels[v1alpha1.LabelInstanceGPUType] = yait.Platform.GPUType()
+	}
 	labels[karpv1.CapacityTypeLabelKey] = offering.CapacityType()
 	nodeLabels[karpv1.CapacityTypeLabelKey] = offering.CapacityType()
 
-	diskType := nodeClass.Spec.DiskType
+	diskType := nodeClass.Spec.DiskTypeFor(offering.CapacityType())
 	diskSize := nodeClass.Spec.DiskSize.Value()
 
-	nodeGroupId, err := c.sdk.CreateFixedNodeGroup(
+	var userData string
+	if nodeClass.Spec.UserDataTemplate != "" {
+		userData, err = yandex.RenderUserData(nodeClass.Spec.UserDataTemplate, yandex.UserDataVariables{
+			Zone:         offering.Zone(),
+			InstanceType: it.Name,
+			NodeName:     nodeClaim.Name,
+		})
+		if err != nil {
+			return nil, cloudprovider.NewCreateError(err, "InvalidUserDataTemplate", "Error rendering user-data template")
+		}
+	} else if nodeClass.Spec.MetadataOptions != nil {
+		userData = nodeClass.Spec.MetadataOptions.UserData
+	}
+
+	// NodePool taints and nodeclass startup taints are set on the node group so that they are present before the node
+	// registers, a NodeClaim without a NodePool launches with the nodeclass startup taints only
+	var nodePoolTaints []corev1.Taint
+	nodePool, err := c.resolveNodePoolFromNodeClaim(ctx, nodeClaim)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, fmt.Errorf("resolving nodepool, %w", err)
+	}
+	if nodePool != nil {
+		nodePoolTaints = nodePool.Spec.Template.Spec.Taints
+	}
+	// a nodeclass startup taint with the key and effect of a NodePool taint is left out
+	taints := lo.UniqBy(slices.Concat(nodePoolTaints, nodeClass.Spec.StartupTaints), func(taint corev1.Taint) string {
+		return taint.Key + ":" + string(taint.Effect)
+	})
+
+	release, err := c.createLimiter.acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("waiting to create instance, %w", err)
+	}
+	nodeGroupId, operationId, err := c.sdk.CreateFixedNodeGroup(
 		ctx,
 		nodeClaim.Name,
+		idempotencyKey(nodeClaim),
 		labels,
 		nodeLabels,
+		taints,
 		yait.Platform,
 		yait.CoreFraction,
 		yait.CPU,
@@ -210,19 +307,52 @@ func (c CloudProvider) Create(ctx context.Context, nodeClaim *karpv1.NodeClaim)
 		nodeClass,
 		diskType,
 		diskSize,
+		userData,
 	)
+	release()
 	if err != nil {
-		return nil, fmt.Errorf("creating instance, %w", err)
+		switch {
+		case yandex.IsInsufficientCapacity(err):
+			c.zoneOutcomes.record(offering.Zone(), false)
+			// the offering is not offered again for a while, so that the next NodeClaim is launched with another one
+			c.unavailableOfferings.MarkUnavailable(it.Name, offering.Zone(), offering.CapacityType())
+			return nil, cloudprovider.NewInsufficientCapacityError(fmt.Errorf("creating instance, operation %q, %w", operationId, err))
+		case yandex.IsPermanent(err):
+			// retrying would be rejected the same way, the NodeClaim is given up on instead
+			c.recorder.Publish(cloudproviderevents.NodeClaimCreationRejected(nodeClaim, err.Error()))
+			c.setLaunchSucceeded(ctx, nodeClass, err)
+			return nil, cloudprovider.NewNodeClassNotReadyError(fmt.Errorf("creating instance, operation %q, %w", operationId, err))
+		}
+		return nil, fmt.Errorf("creating instance, operation %q, %w", operationId, err)
 	}
 
-	log.Info("Successfully created instance", "providerID", nodeGroupId)
+	log.Info("Successfully created instance", "providerID", nodeGroupId, "operationId", operationId)
+	c.zoneOutcomes.record(offering.Zone(), true)
+	if nodeClass.StatusConditions().Get(v1alpha1.ConditionTypeLaunchSucceeded).IsFalse() {
+		c.setLaunchSucceeded(ctx, nodeClass, nil)
+	}
 
 	ng, err := c.sdk.GetNodeGroup(ctx, nodeGroupId)
 	if err != nil {
 		return nil, fmt.Errorf("getting node group, %w", err)
 	}
 
-	return c.nodeGroupToNodeClaim(ctx, ng, it)
+	created, err := c.nodeGroupToNodeClaim(ctx, ng, it)
+	if err != nil {
+		return nil, err
+	}
+	// the annotations of the provider take precedence over the ones of the nodeclass
+	created.Annotations = lo.Assign(nodeClass.Spec.NodeAnnotations, created.Annotations)
+	if operationId != "" {
+		created.Annotations[v1alpha1.AnnotationCreateOperationID] = operationId
+	}
+	created.Annotations[v1alpha1.AnnotationYandexNodeClassHash] = strconv.FormatUint(nodeClass.Hash(), 10)
+	if offering.CapacityType() == karpv1.CapacityTypeSpot {
+		if savings, ok := instancetypeoffering.SpotSavingsPercent(it, offering.Zone()); ok {
+			created.Annotations[v1alpha1.AnnotationSpotSavingsPercent] = strconv.FormatFloat(savings, 'f', 1, 64)
+		}
+	}
+	return created, nil
 }
 
 // Delete removes a NodeClaim from the cloudprovider by its provider id. Delete should return
@@ -232,13 +362,34 @@ func (c CloudProvider) Delete(ctx context.Context, nodeClaim *karpv1.NodeClaim)
 	log := c.log.WithName("Delete()")
 	log.Info("Executed with params", "nodeClaim", nodeClaim.Name)
 
-	nodeGroupId := nodeClaim.Labels["yandex.cloud/node-group-id"]
+	nodeGroupId := nodeClaim.Labels[v1alpha1.LabelYandexNodeGroupID]
 	if nodeGroupId == "" {
 		log.Info("nodeGroupId is empty")
 		return cloudprovider.NewNodeClaimNotFoundError(fmt.Errorf("nodeGroupId is empty for nodeclaim %s", nodeClaim.Name))
 	}
 
-	err := c.sdk.DeleteNodeGroup(ctx, nodeGroupId)
+	if c.safeDelete {
+		if err := c.ensureDrained(ctx, nodeClaim); err != nil {
+			log.Info("Postponing NodeGroup deletion", "nodeGroupId", nodeGroupId, "reason", err.Error())
+			// Return the error as-is for retry
+			return err
+		}
+	}
+
+	// a node group is only deleted with the last NodeClaim it backs
+	nodeClaims, err := nodeclaimutils.ListByNodeGroupID(ctx, c.kubeClient, nodeGroupId)
+	if err != nil {
+		return fmt.Errorf("listing nodeclaims of node group, %w", err)
+	}
+	if other, ok := lo.Find(nodeClaims, func(nc karpv1.NodeClaim) bool {
+		return nc.Name != nodeClaim.Name && nc.DeletionTimestamp.IsZero()
+	}); ok {
+		log.Info("NodeGroup is still used by another NodeClaim", "nodeGroupId", nodeGroupId, "nodeClaim", other.Name)
+		return cloudprovider.NewNodeClaimNotFoundError(fmt.Errorf("nodegroup %s is used by nodeclaim %s", nodeGroupId, other.Name))
+	}
+
+	// the node group is deleted rather than scaled to zero first, see the DeployPolicy of YCSDK.newCreateNodeGroupRequest
+	err = c.sdk.DeleteNodeGroup(ctx, nodeGroupId)
 	if err != nil {
 		// Check if this is a NotFound error (NodeGroup already deleted by another NodeClaim)
 		if strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "NotFound") {
@@ -255,6 +406,49 @@ func (c CloudProvider) Delete(ctx context.Context, nodeClaim *karpv1.NodeClaim)
 	return nil
 }
 
+// ensureDrained returns an error unless the node of the NodeClaim is cordoned and runs no pods other than daemonset
+// and static ones that still wait on eviction. NodeClaims whose node never registered or is already gone have nothing
+// to drain
+func (c CloudProvider) ensureDrained(ctx context.Context, nodeClaim *karpv1.NodeClaim) error {
+	if nodeClaim.Status.NodeName == "" {
+		return nil
+	}
+	// the termination grace period of the NodePool bounds the drain, the node goes once it has elapsed
+	if tgp := nodeClaim.Spec.TerminationGracePeriod; tgp != nil && !nodeClaim.DeletionTimestamp.IsZero() &&
+		c.clk.Since(nodeClaim.DeletionTimestamp.Time) >= tgp.Duration {
+		return nil
+	}
+	node := &corev1.Node{}
+	if err := c.kubeClient.Get(ctx, types.NamespacedName{Name: nodeClaim.Status.NodeName}, node); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("getting node, %w", err)
+	}
+
+	cordoned := node.Spec.Unschedulable || lo.ContainsBy(node.Spec.Taints, func(t corev1.Taint) bool {
+		return t.MatchTaint(&karpv1.DisruptedNoScheduleTaint)
+	})
+	if !cordoned {
+		return fmt.Errorf("node %s is not cordoned", node.Name)
+	}
+
+	pods, err := nodeutils.GetPods(ctx, c.kubeClient, node)
+	if err != nil {
+		return fmt.Errorf("listing pods, %w", err)
+	}
+	// pods that are already terminating, e.g. stuck on finalizers, and pods Karpenter does not evict do not hold
+	// the node back
+	remaining := lo.Filter(pods, func(p *corev1.Pod, _ int) bool {
+		return p.DeletionTimestamp.IsZero() && podutils.IsWaitingEviction(p, c.clk) &&
+			!podutils.IsOwnedByDaemonSet(p) && !podutils.IsOwnedByNode(p)
+	})
+	if len(remaining) > 0 {
+		return fmt.Errorf("node %s is not drained, %d pods remaining", node.Name, len(remaining))
+	}
+	return nil
+}
+
 // Get retrieves a NodeClaim from the cloudprovider by its provider id
 func (c CloudProvider) Get(ctx context.Context, providerID string) (*karpv1.NodeClaim, error) {
 	log := c.log.WithName("Get()")
@@ -271,7 +465,7 @@ func (c CloudProvider) Get(ctx context.Context, providerID string) (*karpv1.Node
 	ng, err := c.sdk.GetNodeGroupByProviderId(ctx, providerID)
 	if err != nil {
 		// Check if this is a NotFound error (instance/nodegroup not found)
-		if strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "NotFound") {
+		if yandex.IsNotFound(err) || strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "NotFound") {
 			log.Info("NodeGroup/Instance not found", "providerID", providerID)
 			// Return NodeClaimNotFoundError to signal that the instance is already terminated
 			return nil, cloudprovider.NewNodeClaimNotFoundError(fmt.Errorf("instance %s not found", providerID))
@@ -280,7 +474,7 @@ func (c CloudProvider) Get(ctx context.Context, providerID string) (*karpv1.Node
 		return nil, fmt.Errorf("getting node group, %w", err)
 	}
 
-	nodeClass, err := c.resolveNodeClassFromNodeGroup(ctx, ng)
+	nodePool, nodeClass, err := c.resolveNodeClassFromNodeGroup(ctx, ng)
 	if err != nil {
 		return nil, fmt.Errorf("getting node class, %w", err)
 	}
@@ -290,45 +484,49 @@ func (c CloudProvider) Get(ctx context.Context, providerID string) (*karpv1.Node
 		return nil, fmt.Errorf("getting instance type, %w", err)
 	}
 
-	return c.nodeGroupToNodeClaim(ctx, ng, it)
+	nodeClaim, err := c.nodeGroupToNodeClaim(ctx, ng, it)
+	if err != nil {
+		return nil, err
+	}
+	setNodePoolLabel(nodeClaim, nodePool)
+	return nodeClaim, nil
 }
 
 // List retrieves all NodeClaims from the cloudprovider
 func (c CloudProvider) List(ctx context.Context) ([]*karpv1.NodeClaim, error) {
 	log := c.log.WithName("List()")
 
-	// todo: do it better and faster
-
 	ngs, err := c.sdk.ListNodeGroups(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("listing nodes, %w", err)
 	}
 
-	var nodeClaims []*karpv1.NodeClaim
-	for _, ng := range ngs {
-		var nodeClass *v1alpha1.YandexNodeClass
-		nodeClass, err = c.resolveNodeClassFromNodeGroup(ctx, ng)
+	// resolving a node group may wait for the provider id of its node, node groups are resolved concurrently so that
+	// a slow one does not hold up the others. A node group that fails to resolve is left out
+	resolved := make([]*karpv1.NodeClaim, len(ngs))
+	workqueue.ParallelizeUntil(ctx, listNodeGroupWorkers, len(ngs), func(i int) {
+		ng := ngs[i]
+		nodePool, nodeClass, err := c.resolveNodeClassFromNodeGroup(ctx, ng)
 		if err != nil {
 			log.Error(err, "failed to resolve yandex node class", "nodeGroup", ng.GetName())
-			continue
+			return
 		}
 
-		var it *cloudprovider.InstanceType
-		it, err = c.nodeGroupToInstanceType(ctx, ng, nodeClass)
+		it, err := c.nodeGroupToInstanceType(ctx, ng, nodeClass)
 		if err != nil {
 			log.Error(err, "failed to resolve instance type", "nodeGroup", ng.GetName(), "nodeClass", nodeClass.Name)
-			continue
+			return
 		}
 
-		var nc *karpv1.NodeClaim
-		nc, err = c.nodeGroupToNodeClaim(ctx, ng, it)
+		nc, err := c.nodeGroupToNodeClaim(ctx, ng, it)
 		if err != nil {
 			log.Error(err, "failed to find node group", "nodeGroup", ng.Name)
-			continue
+			return
 		}
-
-		nodeClaims = append(nodeClaims, nc)
-	}
+		setNodePoolLabel(nc, nodePool)
+		resolved[i] = nc
+	})
+	nodeClaims := lo.Compact(resolved)
 
 	log.V(1).Info("Successfully retrieved node claims list", "count", len(nodeClaims))
 	return nodeClaims, nil
@@ -357,14 +555,126 @@ func (c CloudProvider) GetInstanceTypes(ctx context.Context, nodePool *karpv1.No
 
 // IsDrifted returns whether a NodeClaim has drifted from the provisioning requirements
 // it is tied to.
-func (c CloudProvider) IsDrifted(_ context.Context, _ *karpv1.NodeClaim) (cloudprovider.DriftReason, error) {
-	return "", nil
+func (c CloudProvider) IsDrifted(ctx context.Context, nodeClaim *karpv1.NodeClaim) (cloudprovider.DriftReason, error) {
+	nodeClass, err := c.resolveNodeClassFromNodeClaim(ctx, nodeClaim)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			// a nodeclass that is gone or terminating has no spec to drift from
+			return "", nil
+		}
+		return "", fmt.Errorf("resolving nodeclass, %w", err)
+	}
+	if reason := nodeClassHashDrifted(nodeClaim, nodeClass); reason != "" {
+		return reason, nil
+	}
+
+	if nodeClaim.Status.ProviderID == "" {
+		return "", nil
+	}
+	ng, err := c.sdk.GetNodeGroupByProviderId(ctx, nodeClaim.Status.ProviderID)
+	if err != nil {
+		if yandex.IsNotFound(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("getting node group, %w", err)
+	}
+	if reason := c.instanceTypeDrifted(nodeClaim, ng); reason != "" {
+		return reason, nil
+	}
+	if reason := securityGroupsDrifted(ng, nodeClass); reason != "" {
+		return reason, nil
+	}
+	return c.nodeGroupExpired(ng, nodeClass), nil
+}
+
+// nodeGroupExpired returns whether the node group outlived the maximum node age of its nodeclass
+func (c CloudProvider) nodeGroupExpired(ng *k8s.NodeGroup, nodeClass *v1alpha1.YandexNodeClass) cloudprovider.DriftReason {
+	if nodeClass.Spec.MaxNodeAge == nil || ng.GetCreatedAt() == nil {
+		return ""
+	}
+	if c.clk.Since(ng.GetCreatedAt().AsTime()) > nodeClass.Spec.MaxNodeAge.Duration {
+		return ExpiredNodeDrift
+	}
+	return ""
+}
+
+// nodeClassHashDrifted returns whether the nodeclass spec changed since the NodeClaim was launched. NodeClaims
+// launched before their nodeclass hash was recorded and nodeclasses not hashed yet are not considered drifted
+func nodeClassHashDrifted(nodeClaim *karpv1.NodeClaim, nodeClass *v1alpha1.YandexNodeClass) cloudprovider.DriftReason {
+	hash, ok := nodeClaim.Annotations[v1alpha1.AnnotationYandexNodeClassHash]
+	if !ok || nodeClass.Status.SpecHash == 0 {
+		return ""
+	}
+	if hash != strconv.FormatUint(nodeClass.Status.SpecHash, 10) {
+		return NodeClassHashChangedDrift
+	}
+	return ""
+}
+
+// instanceTypeDrifted returns whether the node group runs on another platform or core fraction than the instance
+// type of its NodeClaim, e.g. after it was changed in Yandex Cloud
+func (c CloudProvider) instanceTypeDrifted(nodeClaim *karpv1.NodeClaim, ng *k8s.NodeGroup) cloudprovider.DriftReason {
+	var expected yandex.InstanceType
+	if err := expected.FromString(nodeClaim.Labels[corev1.LabelInstanceTypeStable]); err != nil {
+		return ""
+	}
+	actual := c.nodeGroupToYandexInstanceType(ng)
+	if actual.Platform != expected.Platform || actual.CoreFraction != expected.CoreFraction {
+		return PlatformDrift
+	}
+	return ""
+}
+
+// securityGroupsDrifted returns whether the security groups of the node group differ from those of its nodeclass,
+// e.g. after they were edited in the Yandex Cloud console. Nodeclasses without security groups leave the choice to
+// Yandex Cloud and are not compared
+func securityGroupsDrifted(ng *k8s.NodeGroup, nodeClass *v1alpha1.YandexNodeClass) cloudprovider.DriftReason {
+	if len(nodeClass.Spec.SecurityGroups) == 0 {
+		return ""
+	}
+	var actual []string
+	for _, spec := range ng.GetNodeTemplate().GetNetworkInterfaceSpecs() {
+		actual = append(actual, spec.GetSecurityGroupIds()...)
+	}
+	if !sets.New(actual...).Equal(sets.New(nodeClass.Spec.SecurityGroups...)) {
+		return SecurityGroupDrift
+	}
+	return ""
 }
 
 // RepairPolicy is for CloudProviders to define a set Unhealthy condition for Karpenter
 // to monitor on the node.
 func (c CloudProvider) RepairPolicies() []cloudprovider.RepairPolicy {
-	return []cloudprovider.RepairPolicy{}
+	toleration := c.nodeRepairToleration(c.ctx)
+	return []cloudprovider.RepairPolicy{
+		{
+			ConditionType:      corev1.NodeReady,
+			ConditionStatus:    corev1.ConditionFalse,
+			TolerationDuration: toleration,
+		},
+		{
+			ConditionType:      corev1.NodeReady,
+			ConditionStatus:    corev1.ConditionUnknown,
+			TolerationDuration: toleration,
+		},
+	}
+}
+
+// nodeRepairToleration returns how long Karpenter waits before repairing an unhealthy node. While Yandex Cloud
+// auto-repair is enabled for any nodeclass, the longer toleration is used so that Yandex Cloud gets the chance
+// to replace the VM first and the node isn't repaired twice
+func (c CloudProvider) nodeRepairToleration(ctx context.Context) time.Duration {
+	nodeClasses := &v1alpha1.YandexNodeClassList{}
+	if err := c.kubeClient.List(ctx, nodeClasses); err != nil {
+		c.log.Error(err, "failed to list nodeclasses, assuming auto-repair is enabled")
+		return c.autoRepairRepairToleration
+	}
+	if lo.ContainsBy(nodeClasses.Items, func(nc v1alpha1.YandexNodeClass) bool {
+		return nc.Spec.AutoRepairEnabled()
+	}) {
+		return c.autoRepairRepairToleration
+	}
+	return c.repairToleration
 }
 
 // Name returns the CloudProvider implementation name.
@@ -391,7 +701,7 @@ func (c CloudProvider) resolveNodeClassFromNodeClaim(ctx context.Context, nodeCl
 }
 
 func (c CloudProvider) resolveInstanceTypes(ctx context.Context, nodeClaim *karpv1.NodeClaim, class *v1alpha1.YandexNodeClass) ([]*cloudprovider.InstanceType, error) {
-	types, err := c.instanceTypes.List(ctx, class)
+	types, err := c.instanceTypes.ListAvailable(ctx, class)
 	if err != nil {
 		return nil, err
 	}
@@ -403,15 +713,66 @@ func (c CloudProvider) resolveInstanceTypes(ctx context.Context, nodeClaim *karp
 			resources.Fits(nodeClaim.Spec.Resources.Requests, i.Allocatable())
 	})
 
-	sort.Slice(types, func(i, j int) bool {
-		return types[i].Offerings.Compatible(reqs).Available().Cheapest().Price < types[j].Offerings.Compatible(reqs).Available().Cheapest().Price
-	})
+	instancetype.SortByPrice(types, func(it *cloudprovider.InstanceType) float64 {
+		return cheapestPrice(it, reqs)
+	}, class.Spec.PlatformPreference)
 
 	return types, nil
 }
 
+// publishZoneCapacityExhausted records on the NodePool of the NodeClaim that none of the zones of the resolved
+// instance types was left to launch the node in
+func (c CloudProvider) publishZoneCapacityExhausted(ctx context.Context, nodeClaim *karpv1.NodeClaim, instanceTypes []*cloudprovider.InstanceType) {
+	nodePool, err := c.resolveNodePoolFromNodeClaim(ctx, nodeClaim)
+	if err != nil {
+		c.log.Error(err, "resolving nodepool to record exhausted zone capacity", "nodeClaim", nodeClaim.Name)
+		return
+	}
+	zones := sets.New[string]()
+	for _, it := range instanceTypes {
+		for _, off := range it.Offerings.Available() {
+			zones.Insert(off.Zone())
+		}
+	}
+	names := lo.Map(instanceTypes, func(it *cloudprovider.InstanceType, _ int) string { return it.Name })
+	c.recorder.Publish(cloudproviderevents.NodePoolZoneCapacityExhausted(nodePool, names, sets.List(zones)))
+}
+
+// cheapestPrice returns the price of the cheapest compatible available offering of an instance type, whichever its
+// capacity type and zone. Offering prices already include the boot disk configured on the nodeclass
+func cheapestPrice(it *cloudprovider.InstanceType, reqs scheduling.Requirements) float64 {
+	return instancetype.CheapestPrice(it.Offerings.Compatible(reqs).Available())
+}
+
+// idempotencyKey derives a stable key for creating the node group of a NodeClaim, so that retried creates of the
+// same NodeClaim are deduplicated by Yandex Cloud while a NodeClaim recreated under the same name is not
+func idempotencyKey(nodeClaim *karpv1.NodeClaim) string {
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte(string(nodeClaim.UID)+"/"+nodeClaim.Name)).String()
+}
+
+// selectInstanceType returns the first instance type of the price-sorted list that still has available offerings
+// and whose allocatable resources fit the NodeClaim requests
+func selectInstanceType(instanceTypes []*cloudprovider.InstanceType, requests corev1.ResourceList) (*cloudprovider.InstanceType, bool) {
+	return lo.Find(instanceTypes, func(it *cloudprovider.InstanceType) bool {
+		if it == nil || len(it.Offerings.Available()) == 0 {
+			return false
+		}
+		return resources.Fits(requests, it.Allocatable())
+	})
+}
+
 const waitForProviderIDTTL = 5 * time.Minute
 
+// launchRejectedTTL is how long a nodeclass whose node group Yandex Cloud rejected permanently is not launched again
+// with the same spec, so that e.g. a permission granted meanwhile is picked up without changing the nodeclass
+const launchRejectedTTL = 5 * time.Minute
+
+// listNodeGroupWorkers is the number of node groups List resolves at the same time
+const listNodeGroupWorkers = 20
+
+// nodeClassLabelKey is the label holding the nodeclass name on NodeClaims and on the node groups launched for them
+const nodeClassLabelKey = "karpenter.yandex.cloud/yandexnodeclass"
+
 func (c CloudProvider) nodeGroupToNodeClaim(ctx context.Context, ng *k8s.NodeGroup, instanceType *cloudprovider.InstanceType) (*karpv1.NodeClaim, error) {
 	nodeClaim := &karpv1.NodeClaim{}
 	labels := map[string]string{}
@@ -453,32 +814,67 @@ func (c CloudProvider) nodeGroupToNodeClaim(ctx context.Context, ng *k8s.NodeGro
 		},
 		ng.Status,
 	) {
-		nodeClaim.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+		nodeClaim.DeletionTimestamp = &metav1.Time{Time: c.clk.Now()}
 	}
 
+	// we need to wait while getting providerID, which required to return in Create. A group that just became running
+	// may not have reported the cloud status of its node yet either, it is waited on for a shorter while. The node of
+	// a group in any other status may be gone for good, it is not waited on
+	var retryTimeout time.Duration
+	switch ng.Status {
+	case k8s.NodeGroup_RUNNING:
+		retryTimeout = c.providerIDRetryTimeout
+	case k8s.NodeGroup_PROVISIONING, k8s.NodeGroup_STARTING:
+		retryTimeout = waitForProviderIDTTL
+	}
 	var lastErr error
 	nodeClaim.Status.ProviderID, lastErr = c.sdk.ProviderIdFor(ctx, ng.Id)
-	if (ng.Status == k8s.NodeGroup_PROVISIONING || ng.Status == k8s.NodeGroup_STARTING) && lastErr != nil {
-		start := time.Now()
-		var providerID string
-
-		// we need to wait while getting providerID, which required to return in Create
-		for ; time.Since(start) < waitForProviderIDTTL; time.Sleep(time.Second) {
-			providerID, lastErr = c.sdk.ProviderIdFor(ctx, ng.Id)
-			if lastErr == nil {
-				nodeClaim.Status.ProviderID = providerID
-				break
-			}
+	for start := c.clk.Now(); lastErr != nil && c.clk.Since(start) < retryTimeout; {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to determine provider id: %w", ctx.Err())
+		case <-c.clk.After(c.providerIDRetryInterval):
 		}
+		nodeClaim.Status.ProviderID, lastErr = c.sdk.ProviderIdFor(ctx, ng.Id)
 	}
 
 	if nodeClaim.Status.ProviderID == "" {
 		return nil, fmt.Errorf("failed to determine provider id: %w", lastErr)
 	}
 
+	// the zone labels come from the first location of the group, a group spanning zones may run the node in another
+	if len(ng.GetAllocationPolicy().GetLocations()) > 1 {
+		zoneID, err := c.sdk.InstanceZone(ctx, nodeClaim.Status.ProviderID)
+		if err != nil {
+			return nil, fmt.Errorf("getting instance zone, %w", err)
+		}
+		nodeClaim.Labels[corev1.LabelZoneFailureDomain] = zoneID
+		nodeClaim.Labels[corev1.LabelTopologyZone] = zoneID
+	}
+
+	if instanceType != nil {
+		if price, ok := nodePrice(instanceType, nodeClaim.Labels); ok {
+			nodeClaim.Annotations = lo.Assign(nodeClaim.Annotations, map[string]string{
+				v1alpha1.AnnotationNodePrice: strconv.FormatFloat(price, 'f', -1, 64),
+			})
+		}
+	}
+
 	return nodeClaim, nil
 }
 
+// nodePrice returns the price of the cheapest available offering of the instance type in the zone and capacity type
+// of the node
+func nodePrice(instanceType *cloudprovider.InstanceType, labels map[string]string) (float64, bool) {
+	offerings := lo.Filter(instanceType.Offerings.Available(), func(off *cloudprovider.Offering, _ int) bool {
+		return off.Zone() == labels[corev1.LabelTopologyZone] && off.CapacityType() == labels[karpv1.CapacityTypeLabelKey]
+	})
+	if len(offerings) == 0 {
+		return 0, false
+	}
+	return instancetype.CheapestPrice(offerings), true
+}
+
 func (c CloudProvider) nodeGroupToYandexInstanceType(ng *k8s.NodeGroup) yandex.InstanceType {
 	var yait yandex.InstanceType
 	yait.Platform = yandex.PlatformId(ng.GetNodeTemplate().GetPlatformId())
@@ -489,8 +885,8 @@ func (c CloudProvider) nodeGroupToYandexInstanceType(ng *k8s.NodeGroup) yandex.I
 }
 
 func (c CloudProvider) nodeGroupLabels(ng *k8s.NodeGroup) map[string]string {
-	labels := make(map[string]string)
-	labels = lo.Assign(labels, ng.GetNodeLabels())
+	// operator default node labels are part of the node labels of the node group, as they were when it was created
+	labels := lo.Assign(ng.GetNodeLabels())
 
 	var zoneID string
 	if len(ng.GetAllocationPolicy().GetLocations()) > 0 {
@@ -500,14 +896,24 @@ func (c CloudProvider) nodeGroupLabels(ng *k8s.NodeGroup) map[string]string {
 	// yandex-provided labels
 	labels["beta.kubernetes.io/arch"] = "amd64"
 	labels[corev1.LabelArchStable] = "amd64"
-	labels[corev1.LabelInstanceType] = ng.GetNodeTemplate().GetPlatformId()
-	labels[corev1.LabelInstanceTypeStable] = ng.GetNodeTemplate().GetPlatformId()
+	// the canonical instance type name agrees with the instance type requirements, unlike the platform id
+	// Yandex Cloud labels the node with
+	yait := c.nodeGroupToYandexInstanceType(ng)
+	labels[corev1.LabelInstanceType] = yait.String()
+	labels[corev1.LabelInstanceTypeStable] = yait.String()
+	if name := yait.Platform.Name(); name != "" {
+		labels[v1alpha1.LabelInstancePlatformName] = name
+	}
+	if gpus := yait.GPUs(); gpus > 0 {
+		labels[v1alpha1.LabelInstanceGPUCount] = fmt.Sprint(gpus)
+		labels[v1alpha1.LabelInstanceGPUType] = yait.Platform.GPUType()
+	}
 	labels["beta.kubernetes.io/os"] = "linux"
 	labels[corev1.LabelOSStable] = "linux"
 	labels[corev1.LabelZoneFailureDomain] = zoneID
 	labels[corev1.LabelTopologyZone] = zoneID
 	labels[corev1.LabelHostname] = ng.Name + "-1"
-	labels["yandex.cloud/node-group-id"] = ng.GetId()
+	labels[v1alpha1.LabelYandexNodeGroupID] = ng.GetId()
 	labels["yandex.cloud/pci-topology"] = "k8s"
 	labels["yandex.cloud/preemptible"] = fmt.Sprintf("%t", ng.GetNodeTemplate().GetSchedulingPolicy().GetPreemptible())
 
@@ -520,11 +926,28 @@ func (c CloudProvider) nodeGroupLabels(ng *k8s.NodeGroup) map[string]string {
 	return labels
 }
 
+// nodeGroupToInstanceType resolves the instance type of a node group. The nodeclass disk size may have changed since
+// the node group was created, so the instance type is resolved against the boot disk the node actually has
 func (c CloudProvider) nodeGroupToInstanceType(ctx context.Context, ng *k8s.NodeGroup, nodeClass *v1alpha1.YandexNodeClass) (*cloudprovider.InstanceType, error) {
 	yait := c.nodeGroupToYandexInstanceType(ng)
+	if diskSize := ng.GetNodeTemplate().GetBootDiskSpec().GetDiskSize(); diskSize > 0 {
+		nodeClass = nodeClass.DeepCopy()
+		nodeClass.Spec.DiskSize = *resource.NewQuantity(diskSize, resource.BinarySI)
+	}
 	return c.instanceTypes.GetInstanceType(ctx, nodeClass, yait.String())
 }
 
+func (c CloudProvider) resolveNodePoolFromNodeClaim(ctx context.Context, nodeClaim *karpv1.NodeClaim) (*karpv1.NodePool, error) {
+	if nodePoolName, ok := nodeClaim.Labels[karpv1.NodePoolLabelKey]; ok {
+		nodePool := &karpv1.NodePool{}
+		if err := c.kubeClient.Get(ctx, types.NamespacedName{Name: nodePoolName}, nodePool); err != nil {
+			return nil, err
+		}
+		return nodePool, nil
+	}
+	return nil, errors.NewNotFound(schema.GroupResource{Group: apis.Group, Resource: "nodepools"}, "")
+}
+
 func (c CloudProvider) resolveNodePoolFromNodeGroup(ctx context.Context, ng *k8s.NodeGroup) (*karpv1.NodePool, error) {
 	if nodePoolName, ok := ng.Labels[karpv1.NodePoolLabelKey]; ok {
 		nodePool := &karpv1.NodePool{}
@@ -549,12 +972,67 @@ func (c CloudProvider) resolveNodeClassFromNodePool(ctx context.Context, nodePoo
 	return nodeClass, nil
 }
 
-func (c CloudProvider) resolveNodeClassFromNodeGroup(ctx context.Context, ng *k8s.NodeGroup) (*v1alpha1.YandexNodeClass, error) {
+// resolveNodeClassFromNodeGroup resolves the nodepool and nodeclass of a node group. When the nodepool label of the
+// group is missing or stale, the nodeclass is resolved from the nodeclass label karpenter puts on every group it
+// launches, and the nodepool is a best-effort guess among the nodepools referencing that nodeclass, nil if none does
+func (c CloudProvider) resolveNodeClassFromNodeGroup(ctx context.Context, ng *k8s.NodeGroup) (*karpv1.NodePool, *v1alpha1.YandexNodeClass, error) {
 	np, err := c.resolveNodePoolFromNodeGroup(ctx, ng)
+	if err == nil {
+		nodeClass, err := c.resolveNodeClassFromNodePool(ctx, np)
+		return np, nodeClass, err
+	}
+	nodeClassName, ok := ng.Labels[nodeClassLabelKey]
+	if !errors.IsNotFound(err) || !ok {
+		return nil, nil, err
+	}
+
+	nodeClass, err := c.resolveNodeClassByName(ctx, nodeClassName)
+	if err != nil {
+		return nil, nil, err
+	}
+	np, err = c.guessNodePoolForNodeClass(ctx, nodeClass)
 	if err != nil {
+		return nil, nil, err
+	}
+	c.log.Info("node group has no resolvable nodepool, resolved it through its nodeclass",
+		"nodeGroup", ng.GetName(), "nodeClass", nodeClass.Name, "nodePool", lo.FromPtr(np).Name)
+	return np, nodeClass, nil
+}
+
+func (c CloudProvider) resolveNodeClassByName(ctx context.Context, name string) (*v1alpha1.YandexNodeClass, error) {
+	nodeClass := &v1alpha1.YandexNodeClass{}
+	if err := c.kubeClient.Get(ctx, types.NamespacedName{Name: name}, nodeClass); err != nil {
 		return nil, err
 	}
-	return c.resolveNodeClassFromNodePool(ctx, np)
+	if !nodeClass.DeletionTimestamp.IsZero() {
+		return nil, newTerminatingNodeClassError(nodeClass.Name)
+	}
+	return nodeClass, nil
+}
+
+// guessNodePoolForNodeClass returns the first nodepool by name referencing the nodeclass, nil if there is none
+func (c CloudProvider) guessNodePoolForNodeClass(ctx context.Context, nodeClass *v1alpha1.YandexNodeClass) (*karpv1.NodePool, error) {
+	nodePools := &karpv1.NodePoolList{}
+	if err := c.kubeClient.List(ctx, nodePools); err != nil {
+		return nil, fmt.Errorf("listing nodepools, %w", err)
+	}
+	candidates := lo.Filter(nodePools.Items, func(np karpv1.NodePool, _ int) bool {
+		ref := np.Spec.Template.Spec.NodeClassRef
+		return ref != nil && ref.Name == nodeClass.Name
+	})
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	nodePool := lo.MinBy(candidates, func(a, b karpv1.NodePool) bool { return a.Name < b.Name })
+	return &nodePool, nil
+}
+
+// setNodePoolLabel associates the NodeClaim with the nodepool it was resolved to, replacing a stale label
+func setNodePoolLabel(nodeClaim *karpv1.NodeClaim, nodePool *karpv1.NodePool) {
+	if nodePool == nil {
+		return
+	}
+	nodeClaim.Labels[karpv1.NodePoolLabelKey] = nodePool.Name
 }
 
 // newTerminatingNodeClassError returns a NotFound error for handling by
@@ -564,3 +1042,25 @@ func newTerminatingNodeClassError(name string) *errors.StatusError {
 	err.ErrStatus.Message = fmt.Sprintf("%s %q is terminating, treating as not found", qualifiedResource.String(), name)
 	return err
 }
+
+// launchRejected returns whether Yandex Cloud rejected a node group of the current nodeclass spec permanently within
+// launchRejectedTTL
+func (c CloudProvider) launchRejected(launch *status.Condition, nodeClass *v1alpha1.YandexNodeClass) bool {
+	return launch.IsFalse() &&
+		launch.ObservedGeneration == nodeClass.Generation &&
+		c.clk.Since(launch.LastTransitionTime.Time) < launchRejectedTTL
+}
+
+// setLaunchSucceeded sets the LaunchSucceeded condition of the nodeclass to False with the permanent create error, or
+// back to True without one. Failing to patch the status is only logged, the create error is reported either way
+func (c CloudProvider) setLaunchSucceeded(ctx context.Context, nodeClass *v1alpha1.YandexNodeClass, createErr error) {
+	stored := nodeClass.DeepCopy()
+	if createErr != nil {
+		nodeClass.StatusConditions().SetFalse(v1alpha1.ConditionTypeLaunchSucceeded, "CreationRejected", createErr.Error())
+	} else {
+		nodeClass.StatusConditions().SetTrue(v1alpha1.ConditionTypeLaunchSucceeded)
+	}
+	if err := c.kubeClient.Status().Patch(ctx, nodeClass, client.MergeFromWithOptions(stored, client.MergeFromWithOptimisticLock{})); err != nil {
+		log.FromContext(ctx).Error(err, "failed to set nodeclass launch condition", "nodeClass", nodeClass.Name)
+	}
+}
@@ -0,0 +1,2005 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yandex
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/awslabs/operatorpkg/status"
+	"github.com/samber/lo"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/apis/v1alpha1"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/fake"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/operator/options"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/providers/instancetype"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/providers/instancetype/offering"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/providers/pricing"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/providers/subnet"
+	nodeclaimutils "github.com/tufitko/karpenter-provider-yandex/pkg/utils/nodeclaim"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/yandex"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/k8s/v1"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clocktesting "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/cloudprovider"
+	"sigs.k8s.io/karpenter/pkg/events"
+	"sigs.k8s.io/karpenter/pkg/scheduling"
+)
+
+const (
+	testNodePool  = "default"
+	testNodeClass = "default"
+)
+
+var testZones = []string{"ru-central1-a", "ru-central1-b"}
+
+// testInstanceTypeProvider returns a fixed set of instance types regardless of the nodeclass
+type testInstanceTypeProvider struct {
+	instanceTypes []*cloudprovider.InstanceType
+}
+
+func (p *testInstanceTypeProvider) List(_ context.Context, _ *v1alpha1.YandexNodeClass) ([]*cloudprovider.InstanceType, error) {
+	// hand out the same instance types on every call, like a cached provider would
+	return append([]*cloudprovider.InstanceType{}, p.instanceTypes...), nil
+}
+
+func (p *testInstanceTypeProvider) ListAvailable(_ context.Context, _ *v1alpha1.YandexNodeClass) ([]*cloudprovider.InstanceType, error) {
+	return lo.Filter(p.instanceTypes, func(it *cloudprovider.InstanceType, _ int) bool {
+		return len(it.Offerings.Available()) > 0
+	}), nil
+}
+
+func (p *testInstanceTypeProvider) GetInstanceType(_ context.Context, _ *v1alpha1.YandexNodeClass, name string) (*cloudprovider.InstanceType, error) {
+	it, ok := lo.Find(p.instanceTypes, func(it *cloudprovider.InstanceType) bool {
+		return it.Name == name
+	})
+	if !ok {
+		return nil, fmt.Errorf("instance type %s not found", name)
+	}
+	return it, nil
+}
+
+// testSubnetProvider returns a fixed set of subnets regardless of the nodeclass
+type testSubnetProvider struct {
+	subnets []subnet.Subnet
+}
+
+func (p *testSubnetProvider) List(_ context.Context, _ *v1alpha1.YandexNodeClass) ([]subnet.Subnet, error) {
+	return append([]subnet.Subnet{}, p.subnets...), nil
+}
+
+func newTestScheme(t testing.TB) *runtime.Scheme {
+	// karpenter registers its types into the client-go scheme on init
+	if err := v1alpha1.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatalf("Failed to register yandex types: %v", err)
+	}
+	return scheme.Scheme
+}
+
+func newTestContext() context.Context {
+	return options.ToContext(context.Background(), &options.Options{
+		ClusterID:                  "test-cluster",
+		IPsPerNode:                 1,
+		NodeRepairToleration:       10 * time.Minute,
+		AutoRepairRepairToleration: 30 * time.Minute,
+	})
+}
+
+func newTestNodeClass() *v1alpha1.YandexNodeClass {
+	nodeClass := &v1alpha1.YandexNodeClass{
+		ObjectMeta: metav1.ObjectMeta{Name: testNodeClass, Generation: 1, CreationTimestamp: metav1.Now()},
+		Spec: v1alpha1.YandexNodeClassSpec{
+			DiskType: string(yandex.SSD),
+			DiskSize: resource.MustParse("30Gi"),
+		},
+	}
+	nodeClass.StatusConditions().SetTrue(v1alpha1.ConditionTypeSubnetsReady)
+	nodeClass.StatusConditions().SetTrue(v1alpha1.ConditionTypeSecurityGroupsReady)
+	nodeClass.StatusConditions().SetTrue(v1alpha1.ConditionTypeValidationSucceeded)
+	return nodeClass
+}
+
+func newTestNodePool() *karpv1.NodePool {
+	return &karpv1.NodePool{
+		ObjectMeta: metav1.ObjectMeta{Name: testNodePool},
+		Spec: karpv1.NodePoolSpec{
+			Template: karpv1.NodeClaimTemplate{
+				Spec: karpv1.NodeClaimTemplateSpec{
+					NodeClassRef: &karpv1.NodeClassReference{Group: "karpenter.yandex.cloud", Kind: "YandexNodeClass", Name: testNodeClass},
+				},
+			},
+		},
+	}
+}
+
+func newTestNodeClaim(requests corev1.ResourceList) *karpv1.NodeClaim {
+	return &karpv1.NodeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default-abcde",
+			Labels: map[string]string{
+				karpv1.NodePoolLabelKey:                  testNodePool,
+				"karpenter.yandex.cloud/yandexnodeclass": testNodeClass,
+			},
+		},
+		Spec: karpv1.NodeClaimSpec{
+			NodeClassRef: &karpv1.NodeClassReference{Group: "karpenter.yandex.cloud", Kind: "YandexNodeClass", Name: testNodeClass},
+			Resources:    karpv1.ResourceRequirements{Requests: requests},
+		},
+	}
+}
+
+// newTestInstanceType builds an instance type with on-demand offerings in every test zone
+func newTestInstanceType(info yandex.InstanceType, price float64) *cloudprovider.InstanceType {
+	var offerings cloudprovider.Offerings
+	for _, zone := range testZones {
+		offerings = append(offerings, &cloudprovider.Offering{
+			Requirements: scheduling.NewRequirements(
+				scheduling.NewRequirement(karpv1.CapacityTypeLabelKey, corev1.NodeSelectorOpIn, karpv1.CapacityTypeOnDemand),
+				scheduling.NewRequirement(corev1.LabelTopologyZone, corev1.NodeSelectorOpIn, zone),
+			),
+			Price:     price,
+			Available: true,
+		})
+	}
+	return &cloudprovider.InstanceType{
+		Name: info.String(),
+		Requirements: scheduling.NewRequirements(
+			scheduling.NewRequirement(corev1.LabelInstanceTypeStable, corev1.NodeSelectorOpIn, info.String()),
+			scheduling.NewRequirement(v1alpha1.LabelInstanceCPUPlatform, corev1.NodeSelectorOpIn, string(info.Platform)),
+			scheduling.NewRequirement(karpv1.CapacityTypeLabelKey, corev1.NodeSelectorOpIn, karpv1.CapacityTypeOnDemand),
+			scheduling.NewRequirement(corev1.LabelTopologyZone, corev1.NodeSelectorOpIn, testZones...),
+		),
+		Offerings: offerings,
+		Capacity: corev1.ResourceList{
+			corev1.ResourceCPU:    info.CPU,
+			corev1.ResourceMemory: info.Memory,
+			corev1.ResourcePods:   resource.MustParse("110"),
+		},
+		Overhead: &cloudprovider.InstanceTypeOverhead{
+			KubeReserved: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("100m"),
+				corev1.ResourceMemory: resource.MustParse("512Mi"),
+			},
+		},
+	}
+}
+
+func newTestInstanceTypeInfo(cpu, memory string) yandex.InstanceType {
+	return yandex.InstanceType{
+		Platform:     yandex.PlatformIntelIceLake,
+		CPU:          resource.MustParse(cpu),
+		Memory:       resource.MustParse(memory),
+		CoreFraction: yandex.CoreFraction100,
+	}
+}
+
+func newTestCloudProvider(t testing.TB, instanceTypes []*cloudprovider.InstanceType, objects ...client.Object) (*CloudProvider, *fake.SDK) {
+	t.Helper()
+	return newTestCloudProviderWith(t, &testInstanceTypeProvider{instanceTypes: instanceTypes}, objects...)
+}
+
+func newTestCloudProviderWith(t testing.TB, instanceTypes instancetype.Provider, objects ...client.Object) (*CloudProvider, *fake.SDK) {
+	t.Helper()
+	sdk := fake.NewSDK()
+	return newTestCloudProviderWithSDK(t, sdk, instanceTypes, objects...), sdk
+}
+
+func newTestCloudProviderWithSDK(t testing.TB, sdk yandex.SDK, instanceTypes instancetype.Provider, objects ...client.Object) *CloudProvider {
+	t.Helper()
+
+	kubeClient := fakeclient.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(objects...).
+		// karpenter indexes pods by node on the manager cache
+		WithIndex(&corev1.Pod{}, "spec.nodeName", func(o client.Object) []string {
+			return []string{o.(*corev1.Pod).Spec.NodeName}
+		}).
+		WithIndex(&karpv1.NodeClaim{}, nodeclaimutils.NodeGroupIDIndex, nodeclaimutils.NodeGroupIDIndexFunc).
+		WithStatusSubresource(&v1alpha1.YandexNodeClass{}).
+		Build()
+	subnets := &testSubnetProvider{subnets: []subnet.Subnet{
+		{ID: "subnet-a", ZoneID: "ru-central1-a", AvailableIPAddressCount: 100, AvailableNodeSlots: 100},
+		{ID: "subnet-b", ZoneID: "ru-central1-b", AvailableIPAddressCount: 100, AvailableNodeSlots: 100},
+	}}
+
+	cp, err := NewCloudProvider(
+		newTestContext(),
+		kubeClient,
+		sdk,
+		events.NewRecorder(record.NewFakeRecorder(100)),
+		instanceTypes,
+		subnets,
+		offering.NewUnavailableOfferings(),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create cloud provider: %v", err)
+	}
+	return cp
+}
+
+func TestSelectInstanceType(t *testing.T) {
+	small := newTestInstanceType(newTestInstanceTypeInfo("2", "2Gi"), 1)
+	large := newTestInstanceType(newTestInstanceTypeInfo("4", "8Gi"), 2)
+	unavailable := newTestInstanceType(newTestInstanceTypeInfo("8", "16Gi"), 0.5)
+	for _, o := range unavailable.Offerings {
+		o.Available = false
+	}
+	noOfferings := newTestInstanceType(newTestInstanceTypeInfo("8", "32Gi"), 0.5)
+	noOfferings.Offerings = nil
+
+	requests := corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4Gi")}
+
+	testCases := []struct {
+		name          string
+		instanceTypes []*cloudprovider.InstanceType
+		expected      *cloudprovider.InstanceType
+	}{
+		{
+			name:          "First-sorted type no longer fits",
+			instanceTypes: []*cloudprovider.InstanceType{small, large},
+			expected:      large,
+		},
+		{
+			name:          "Types without available offerings are skipped",
+			instanceTypes: []*cloudprovider.InstanceType{unavailable, noOfferings, large},
+			expected:      large,
+		},
+		{
+			name:          "Nothing fits",
+			instanceTypes: []*cloudprovider.InstanceType{small, unavailable, noOfferings},
+			expected:      nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			it, ok := selectInstanceType(tc.instanceTypes, requests)
+			if ok != (tc.expected != nil) {
+				t.Fatalf("Expected found=%v, got %v", tc.expected != nil, ok)
+			}
+			if tc.expected != nil && it.Name != tc.expected.Name {
+				t.Errorf("Expected instance type %s, got %s", tc.expected.Name, it.Name)
+			}
+		})
+	}
+}
+
+func TestCreate_SkipsInstanceTypesThatDoNotFit(t *testing.T) {
+	smallInfo := newTestInstanceTypeInfo("2", "2Gi")
+	largeInfo := newTestInstanceTypeInfo("4", "8Gi")
+	cp, sdk := newTestCloudProvider(t,
+		[]*cloudprovider.InstanceType{
+			newTestInstanceType(smallInfo, 1),
+			newTestInstanceType(largeInfo, 2),
+		},
+		newTestNodeClass(), newTestNodePool(),
+	)
+
+	nodeClaim := newTestNodeClaim(corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4Gi")})
+	if _, err := cp.Create(context.Background(), nodeClaim); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(sdk.CreateFixedNodeGroupInputs) != 1 {
+		t.Fatalf("Expected 1 node group to be created, got %d", len(sdk.CreateFixedNodeGroupInputs))
+	}
+	created := sdk.CreateFixedNodeGroupInputs[0]
+	if !created.Memory.Equal(largeInfo.Memory) || !created.CPU.Equal(largeInfo.CPU) {
+		t.Errorf("Expected %s to be created, got %s/%s", largeInfo.String(), created.CPU.String(), created.Memory.String())
+	}
+}
+
+func TestCreate_NodeLabels(t *testing.T) {
+	nodeClass := newTestNodeClass()
+	nodeClass.Spec.NodeLabels = map[string]string{"example.com/owner": "Team-A"}
+	cp, sdk := newTestCloudProvider(t,
+		[]*cloudprovider.InstanceType{newTestInstanceType(newTestInstanceTypeInfo("2", "2Gi"), 1)},
+		nodeClass, newTestNodePool(),
+	)
+
+	if _, err := cp.Create(context.Background(), newTestNodeClaim(corev1.ResourceList{})); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(sdk.CreateFixedNodeGroupInputs) != 1 {
+		t.Fatalf("Expected 1 node group to be created, got %d", len(sdk.CreateFixedNodeGroupInputs))
+	}
+	nodeLabels := sdk.CreateFixedNodeGroupInputs[0].NodeLabels
+	if nodeLabels["example.com/owner"] != "Team-A" {
+		t.Errorf("Expected node label example.com/owner=Team-A, got %q", nodeLabels["example.com/owner"])
+	}
+	if nodeLabels[nodeClassLabelKey] != testNodeClass {
+		t.Errorf("Expected node label %s=%s, got %q", nodeClassLabelKey, testNodeClass, nodeLabels[nodeClassLabelKey])
+	}
+}
+
+func TestCreate_NoInstanceTypeFits(t *testing.T) {
+	cp, sdk := newTestCloudProvider(t,
+		[]*cloudprovider.InstanceType{newTestInstanceType(newTestInstanceTypeInfo("2", "2Gi"), 1)},
+		newTestNodeClass(), newTestNodePool(),
+	)
+
+	nodeClaim := newTestNodeClaim(corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4Gi")})
+	_, err := cp.Create(context.Background(), nodeClaim)
+	if !cloudprovider.IsInsufficientCapacityError(err) {
+		t.Fatalf("Expected insufficient capacity error, got %v", err)
+	}
+	if len(sdk.CreateFixedNodeGroupInputs) != 0 {
+		t.Errorf("Expected no node groups to be created, got %d", len(sdk.CreateFixedNodeGroupInputs))
+	}
+}
+
+func TestCreate_ZoneCapacityExhaustedEvent(t *testing.T) {
+	info := newTestInstanceTypeInfo("2", "4Gi")
+	nodePool := newTestNodePool()
+	nodePool.UID = "nodepool-uid"
+	cp, sdk := newTestCloudProvider(t, []*cloudprovider.InstanceType{newTestInstanceType(info, 1)}, newTestNodeClass(), nodePool)
+	recorder := record.NewFakeRecorder(10)
+	cp.recorder = events.NewRecorder(recorder)
+	// there is no subnet in any of the zones the instance type is offered in
+	cp.subnets = &testSubnetProvider{}
+
+	for i := 0; i < 2; i++ {
+		_, err := cp.Create(context.Background(), newTestNodeClaim(corev1.ResourceList{}))
+		if !cloudprovider.IsInsufficientCapacityError(err) {
+			t.Fatalf("Expected insufficient capacity error, got %v", err)
+		}
+	}
+	if len(sdk.CreateFixedNodeGroupInputs) != 0 {
+		t.Errorf("Expected no node groups to be created, got %d", len(sdk.CreateFixedNodeGroupInputs))
+	}
+
+	// the second create is deduplicated by the NodePool
+	if len(recorder.Events) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(recorder.Events))
+	}
+	event := <-recorder.Events
+	for _, expected := range []string{"ZoneCapacityExhausted", info.String(), "ru-central1-a, ru-central1-b"} {
+		if !strings.Contains(event, expected) {
+			t.Errorf("Expected event %q to contain %q", event, expected)
+		}
+	}
+}
+
+func TestRepairPolicies(t *testing.T) {
+	testCases := []struct {
+		name        string
+		autoRepairs []*bool
+		expected    time.Duration
+	}{
+		{
+			name:     "No nodeclasses",
+			expected: 10 * time.Minute,
+		},
+		{
+			name:        "Auto-repair defaulted to enabled",
+			autoRepairs: []*bool{nil},
+			expected:    30 * time.Minute,
+		},
+		{
+			name:        "Auto-repair enabled",
+			autoRepairs: []*bool{lo.ToPtr(true)},
+			expected:    30 * time.Minute,
+		},
+		{
+			name:        "Auto-repair disabled",
+			autoRepairs: []*bool{lo.ToPtr(false)},
+			expected:    10 * time.Minute,
+		},
+		{
+			name:        "Auto-repair enabled for one of the nodeclasses",
+			autoRepairs: []*bool{lo.ToPtr(false), lo.ToPtr(true)},
+			expected:    30 * time.Minute,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var objects []client.Object
+			for i, autoRepair := range tc.autoRepairs {
+				nodeClass := newTestNodeClass()
+				nodeClass.Name = fmt.Sprintf("nodeclass-%d", i)
+				nodeClass.Spec.AutoRepair = autoRepair
+				objects = append(objects, nodeClass)
+			}
+			cp, _ := newTestCloudProvider(t, nil, objects...)
+
+			policies := cp.RepairPolicies()
+			if len(policies) == 0 {
+				t.Fatalf("Expected repair policies")
+			}
+			for _, policy := range policies {
+				if policy.ConditionType != corev1.NodeReady {
+					t.Errorf("Expected policy for %s, got %s", corev1.NodeReady, policy.ConditionType)
+				}
+				if policy.TolerationDuration != tc.expected {
+					t.Errorf("Expected toleration %s for %s=%s, got %s", tc.expected, policy.ConditionType, policy.ConditionStatus, policy.TolerationDuration)
+				}
+			}
+		})
+	}
+}
+
+func TestCreate_ZoneSubnetsOverrideSelectedSubnets(t *testing.T) {
+	nodeClass := newTestNodeClass()
+	nodeClass.Spec.ZoneSubnets = map[string]string{"ru-central1-b": "subnet-explicit-b"}
+	cp, sdk := newTestCloudProvider(t,
+		[]*cloudprovider.InstanceType{newTestInstanceType(newTestInstanceTypeInfo("2", "4Gi"), 1)},
+		nodeClass, newTestNodePool(),
+	)
+
+	for i := 0; i < 5; i++ {
+		nodeClaim := newTestNodeClaim(corev1.ResourceList{})
+		nodeClaim.Name = fmt.Sprintf("default-%d", i)
+		if _, err := cp.Create(context.Background(), nodeClaim); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	for _, created := range sdk.CreateFixedNodeGroupInputs {
+		if created.ZoneId != "ru-central1-b" || created.SubnetId != "subnet-explicit-b" {
+			t.Errorf("Expected node group in ru-central1-b/subnet-explicit-b, got %s/%s", created.ZoneId, created.SubnetId)
+		}
+	}
+}
+
+func TestCreate_UsesSubnetWithMostFreeNodeSlots(t *testing.T) {
+	cp, sdk := newTestCloudProvider(t,
+		[]*cloudprovider.InstanceType{newTestInstanceType(newTestInstanceTypeInfo("2", "4Gi"), 1)},
+		newTestNodeClass(), newTestNodePool(),
+	)
+	// a subnet with more free IPs may still fit fewer nodes once pod CIDRs are accounted for
+	cp.subnets = &testSubnetProvider{subnets: []subnet.Subnet{
+		{ID: "subnet-a-small", ZoneID: "ru-central1-a", AvailableIPAddressCount: 500, AvailableNodeSlots: 10},
+		{ID: "subnet-a-large", ZoneID: "ru-central1-a", AvailableIPAddressCount: 200, AvailableNodeSlots: 200},
+	}}
+
+	if _, err := cp.Create(context.Background(), newTestNodeClaim(corev1.ResourceList{})); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(sdk.CreateFixedNodeGroupInputs) != 1 {
+		t.Fatalf("Expected 1 node group to be created, got %d", len(sdk.CreateFixedNodeGroupInputs))
+	}
+	if created := sdk.CreateFixedNodeGroupInputs[0]; created.ZoneId != "ru-central1-a" || created.SubnetId != "subnet-a-large" {
+		t.Errorf("Expected node group in ru-central1-a/subnet-a-large, got %s/%s", created.ZoneId, created.SubnetId)
+	}
+}
+
+func TestCreate_DoesNotMutateSharedOfferings(t *testing.T) {
+	it := newTestInstanceType(newTestInstanceTypeInfo("2", "4Gi"), 1)
+	cp, _ := newTestCloudProvider(t, []*cloudprovider.InstanceType{it}, newTestNodeClass(), newTestNodePool())
+	expected := lo.Map(it.Offerings, func(o *cloudprovider.Offering, _ int) int { return len(o.Requirements) })
+
+	for i := 0; i < 2; i++ {
+		nodeClaim := newTestNodeClaim(corev1.ResourceList{})
+		nodeClaim.Name = fmt.Sprintf("default-%d", i)
+		if _, err := cp.Create(context.Background(), nodeClaim); err != nil {
+			t.Fatalf("Create #%d: unexpected error: %v", i+1, err)
+		}
+	}
+
+	if len(it.Offerings) != len(expected) {
+		t.Fatalf("Expected %d offerings, got %d", len(expected), len(it.Offerings))
+	}
+	for i, o := range it.Offerings {
+		if len(o.Requirements) != expected[i] {
+			t.Errorf("Offering %d: expected %d requirements, got %d: %s", i, expected[i], len(o.Requirements), o.Requirements)
+		}
+	}
+}
+
+func TestResolveInstanceTypes_PlatformPreference(t *testing.T) {
+	older := newTestInstanceTypeInfo("2", "4Gi")
+	older.Platform = yandex.PlatformIntelCascadeLake
+	newer := newTestInstanceTypeInfo("2", "4Gi")
+
+	nodeClass := newTestNodeClass()
+	nodeClass.Spec.PlatformPreference = []string{string(yandex.PlatformIntelIceLake)}
+	cp, _ := newTestCloudProvider(t, []*cloudprovider.InstanceType{
+		newTestInstanceType(older, 1.00),
+		newTestInstanceType(newer, 1.01),
+	})
+
+	types, err := cp.resolveInstanceTypes(context.Background(), newTestNodeClaim(corev1.ResourceList{}), nodeClass)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(types) != 2 || types[0].Name != newer.String() {
+		t.Errorf("Expected the preferred platform %s to sort first, got %v", newer.Platform, lo.Map(types, func(it *cloudprovider.InstanceType, _ int) string { return it.Name }))
+	}
+}
+
+func TestResolveInstanceTypes_CheapestOfferingAcrossCapacityTypes(t *testing.T) {
+	// offering prices include the nodeclass disk, priced 0.1 here
+	withOfferings := func(it *cloudprovider.InstanceType, onDemand float64, spot float64, spotAvailable bool) *cloudprovider.InstanceType {
+		it.Requirements.Add(scheduling.NewRequirement(karpv1.CapacityTypeLabelKey, corev1.NodeSelectorOpIn, karpv1.CapacityTypeOnDemand, karpv1.CapacityTypeSpot))
+		it.Offerings = nil
+		for _, zone := range testZones {
+			it.Offerings = append(it.Offerings,
+				&cloudprovider.Offering{
+					Requirements: scheduling.NewRequirements(
+						scheduling.NewRequirement(karpv1.CapacityTypeLabelKey, corev1.NodeSelectorOpIn, karpv1.CapacityTypeOnDemand),
+						scheduling.NewRequirement(corev1.LabelTopologyZone, corev1.NodeSelectorOpIn, zone),
+					),
+					Price:     onDemand + 0.1,
+					Available: true,
+				},
+				&cloudprovider.Offering{
+					Requirements: scheduling.NewRequirements(
+						scheduling.NewRequirement(karpv1.CapacityTypeLabelKey, corev1.NodeSelectorOpIn, karpv1.CapacityTypeSpot),
+						scheduling.NewRequirement(corev1.LabelTopologyZone, corev1.NodeSelectorOpIn, zone),
+					),
+					Price:     spot + 0.1,
+					Available: spotAvailable,
+				},
+			)
+		}
+		return it
+	}
+	onDemandOnly := newTestInstanceTypeInfo("2", "4Gi")
+	cheapSpot := newTestInstanceTypeInfo("4", "8Gi")
+	// equally priced instance types are ranked by name, whatever their listing order
+	cheapest := newTestInstanceTypeInfo("2", "6Gi")
+	tied := newTestInstanceTypeInfo("2", "8Gi")
+
+	testCases := []struct {
+		name         string
+		requirements []karpv1.NodeSelectorRequirementWithMinValues
+		expected     []string
+	}{
+		{
+			name:     "Cheapest offering of any capacity type",
+			expected: []string{cheapest.String(), tied.String(), cheapSpot.String(), onDemandOnly.String()},
+		},
+		{
+			name: "Cheapest on-demand offering when spot is not allowed",
+			requirements: []karpv1.NodeSelectorRequirementWithMinValues{{NodeSelectorRequirement: corev1.NodeSelectorRequirement{
+				Key: karpv1.CapacityTypeLabelKey, Operator: corev1.NodeSelectorOpIn, Values: []string{karpv1.CapacityTypeOnDemand},
+			}}},
+			expected: []string{cheapest.String(), tied.String(), onDemandOnly.String(), cheapSpot.String()},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cp, _ := newTestCloudProvider(t, []*cloudprovider.InstanceType{
+				withOfferings(newTestInstanceType(onDemandOnly, 0), 1.0, 0.3, false),
+				withOfferings(newTestInstanceType(cheapSpot, 0), 2.0, 0.5, true),
+				withOfferings(newTestInstanceType(tied, 0), 0.9, 0.4, true),
+				withOfferings(newTestInstanceType(cheapest, 0), 0.9, 0.4, true),
+			})
+			nodeClaim := newTestNodeClaim(corev1.ResourceList{})
+			nodeClaim.Spec.Requirements = tc.requirements
+
+			types, err := cp.resolveInstanceTypes(context.Background(), nodeClaim, newTestNodeClass())
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			got := lo.Map(types, func(it *cloudprovider.InstanceType, _ int) string { return it.Name })
+			if !slices.Equal(got, tc.expected) {
+				t.Errorf("Expected order %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestCreate_ForwardsNodePoolTaints(t *testing.T) {
+	taints := []corev1.Taint{
+		{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule},
+		{Key: "spot", Effect: corev1.TaintEffectPreferNoSchedule},
+	}
+	nodePool := newTestNodePool()
+	nodePool.Spec.Template.Spec.Taints = taints
+	cp, sdk := newTestCloudProvider(t,
+		[]*cloudprovider.InstanceType{newTestInstanceType(newTestInstanceTypeInfo("2", "4Gi"), 1)},
+		newTestNodeClass(), nodePool,
+	)
+
+	if _, err := cp.Create(context.Background(), newTestNodeClaim(corev1.ResourceList{})); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(sdk.CreateFixedNodeGroupInputs) != 1 {
+		t.Fatalf("Expected 1 node group to be created, got %d", len(sdk.CreateFixedNodeGroupInputs))
+	}
+	if got := sdk.CreateFixedNodeGroupInputs[0].Taints; !equality.Semantic.DeepEqual(got, taints) {
+		t.Errorf("Expected taints %v, got %v", taints, got)
+	}
+}
+
+func TestCreate_MergesNodeClassStartupTaints(t *testing.T) {
+	nodePool := newTestNodePool()
+	nodePool.Spec.Template.Spec.Taints = []corev1.Taint{{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule}}
+	nodeClass := newTestNodeClass()
+	nodeClass.Spec.StartupTaints = []corev1.Taint{
+		{Key: "dedicated", Value: "other", Effect: corev1.TaintEffectNoSchedule},
+		{Key: "node.cilium.io/agent-not-ready", Value: "true", Effect: corev1.TaintEffectNoExecute},
+	}
+	cp, sdk := newTestCloudProvider(t,
+		[]*cloudprovider.InstanceType{newTestInstanceType(newTestInstanceTypeInfo("2", "4Gi"), 1)},
+		nodeClass, nodePool,
+	)
+
+	if _, err := cp.Create(context.Background(), newTestNodeClaim(corev1.ResourceList{})); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(sdk.CreateFixedNodeGroupInputs) != 1 {
+		t.Fatalf("Expected 1 node group to be created, got %d", len(sdk.CreateFixedNodeGroupInputs))
+	}
+	// the NodePool taint wins over the nodeclass startup taint with the same key and effect
+	expected := []corev1.Taint{
+		{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule},
+		{Key: "node.cilium.io/agent-not-ready", Value: "true", Effect: corev1.TaintEffectNoExecute},
+	}
+	if got := sdk.CreateFixedNodeGroupInputs[0].Taints; !equality.Semantic.DeepEqual(got, expected) {
+		t.Errorf("Expected taints %v, got %v", expected, got)
+	}
+}
+
+func TestCreate_WithoutNodePool(t *testing.T) {
+	testCases := []struct {
+		name   string
+		mutate func(*karpv1.NodeClaim)
+	}{
+		{
+			name:   "No nodepool label",
+			mutate: func(nc *karpv1.NodeClaim) { delete(nc.Labels, karpv1.NodePoolLabelKey) },
+		},
+		{
+			name: "Nodepool deleted",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeClass := newTestNodeClass()
+			nodeClass.Spec.StartupTaints = []corev1.Taint{{Key: "node.cilium.io/agent-not-ready", Value: "true", Effect: corev1.TaintEffectNoExecute}}
+			cp, sdk := newTestCloudProvider(t,
+				[]*cloudprovider.InstanceType{newTestInstanceType(newTestInstanceTypeInfo("2", "4Gi"), 1)},
+				nodeClass,
+			)
+			nodeClaim := newTestNodeClaim(corev1.ResourceList{})
+			if tc.mutate != nil {
+				tc.mutate(nodeClaim)
+			}
+
+			if _, err := cp.Create(context.Background(), nodeClaim); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if len(sdk.CreateFixedNodeGroupInputs) != 1 {
+				t.Fatalf("Expected 1 node group to be created, got %d", len(sdk.CreateFixedNodeGroupInputs))
+			}
+			if got := sdk.CreateFixedNodeGroupInputs[0].Taints; !equality.Semantic.DeepEqual(got, nodeClass.Spec.StartupTaints) {
+				t.Errorf("Expected taints %v, got %v", nodeClass.Spec.StartupTaints, got)
+			}
+		})
+	}
+}
+
+func newStatusError(code codes.Code, msg string, details ...protoadapt.MessageV1) error {
+	st, err := grpcstatus.New(code, msg).WithDetails(details...)
+	if err != nil {
+		panic(err)
+	}
+	return st.Err()
+}
+
+func TestCreate_ClassifiesCreateErrors(t *testing.T) {
+	testCases := []struct {
+		name                  string
+		err                   error
+		expectICE             bool
+		expectNodeClassReject bool
+	}{
+		{
+			name: "Transient error",
+			err:  grpcstatus.Error(codes.Unavailable, "connection reset"),
+		},
+		{
+			name: "Unclassified error",
+			err:  fmt.Errorf("connection reset"),
+		},
+		{
+			name:      "Quota exceeded",
+			err:       grpcstatus.Error(codes.ResourceExhausted, "quota limit compute.instanceCores.count exceeded"),
+			expectICE: true,
+		},
+		{
+			name:      "Quota exceeded with quota failure details",
+			err:       newStatusError(codes.ResourceExhausted, "quota exceeded", &errdetails.QuotaFailure{}),
+			expectICE: true,
+		},
+		{
+			name: "Rate limited",
+			err:  newStatusError(codes.ResourceExhausted, "try again later", &errdetails.RetryInfo{RetryDelay: durationpb.New(time.Second)}),
+		},
+		{
+			name:                  "Invalid platform",
+			err:                   grpcstatus.Error(codes.InvalidArgument, "platform standard-v9 is not supported"),
+			expectNodeClassReject: true,
+		},
+		{
+			name:                  "Missing permission",
+			err:                   grpcstatus.Error(codes.PermissionDenied, "permission denied"),
+			expectNodeClassReject: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cp, sdk := newTestCloudProvider(t,
+				[]*cloudprovider.InstanceType{newTestInstanceType(newTestInstanceTypeInfo("2", "4Gi"), 1)},
+				newTestNodeClass(), newTestNodePool(),
+			)
+			sdk.CreateFixedNodeGroupError = tc.err
+
+			_, err := cp.Create(context.Background(), newTestNodeClaim(corev1.ResourceList{}))
+			if err == nil {
+				t.Fatalf("Expected an error")
+			}
+			if got := cloudprovider.IsInsufficientCapacityError(err); got != tc.expectICE {
+				t.Errorf("Expected InsufficientCapacityError=%t, got %v", tc.expectICE, err)
+			}
+			if got := cloudprovider.IsNodeClassNotReadyError(err); got != tc.expectNodeClassReject {
+				t.Errorf("Expected NodeClassNotReadyError=%t, got %v", tc.expectNodeClassReject, err)
+			}
+			// the offering that ran out of capacity is not offered again for a while
+			input := sdk.CreateFixedNodeGroupInputs[0]
+			it := yandex.InstanceType{Platform: input.PlatformId, CoreFraction: input.CoreFraction, CPU: input.CPU, Memory: input.Memory}
+			capacityType := lo.Ternary(input.Preemptible, karpv1.CapacityTypeSpot, karpv1.CapacityTypeOnDemand)
+			if got := cp.unavailableOfferings.IsUnavailable(it.String(), input.ZoneId, capacityType); got != tc.expectICE {
+				t.Errorf("Expected the offering to be unavailable=%t, got %t", tc.expectICE, got)
+			}
+		})
+	}
+}
+
+func TestCreate_PermanentErrorSetsLaunchCondition(t *testing.T) {
+	cp, sdk := newTestCloudProvider(t,
+		[]*cloudprovider.InstanceType{newTestInstanceType(newTestInstanceTypeInfo("2", "4Gi"), 1)},
+		newTestNodeClass(), newTestNodePool(),
+	)
+	clk := clocktesting.NewFakeClock(time.Now())
+	cp.clk = clk
+	launchCondition := func() *status.Condition {
+		nodeClass := &v1alpha1.YandexNodeClass{}
+		if err := cp.kubeClient.Get(context.Background(), client.ObjectKey{Name: testNodeClass}, nodeClass); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return nodeClass.StatusConditions().Get(v1alpha1.ConditionTypeLaunchSucceeded)
+	}
+
+	sdk.CreateFixedNodeGroupError = grpcstatus.Error(codes.PermissionDenied, "permission denied")
+	if _, err := cp.Create(context.Background(), newTestNodeClaim(corev1.ResourceList{})); !cloudprovider.IsNodeClassNotReadyError(err) {
+		t.Fatalf("Expected a NodeClassNotReadyError, got %v", err)
+	}
+	if cond := launchCondition(); !cond.IsFalse() || !strings.Contains(cond.Message, "permission denied") {
+		t.Fatalf("Expected %s=False with the create error, got %v", v1alpha1.ConditionTypeLaunchSucceeded, cond)
+	}
+
+	// the rejected nodeclass is not launched again for a while, however the next create would go
+	sdk.CreateFixedNodeGroupError = nil
+	if _, err := cp.Create(context.Background(), newTestNodeClaim(corev1.ResourceList{})); !cloudprovider.IsNodeClassNotReadyError(err) {
+		t.Fatalf("Expected a NodeClassNotReadyError, got %v", err)
+	}
+	if len(sdk.CreateFixedNodeGroupInputs) != 1 {
+		t.Fatalf("Expected the rejected nodeclass not to be launched again, got %d creates", len(sdk.CreateFixedNodeGroupInputs))
+	}
+
+	clk.Step(launchRejectedTTL + time.Second)
+	if _, err := cp.Create(context.Background(), newTestNodeClaim(corev1.ResourceList{})); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cond := launchCondition(); !cond.IsTrue() {
+		t.Errorf("Expected %s=True once a node group is created, got %v", v1alpha1.ConditionTypeLaunchSucceeded, cond)
+	}
+}
+
+func TestCreate_BoundsConcurrentCreates(t *testing.T) {
+	const limit = 2
+	cp, sdk := newTestCloudProvider(t,
+		[]*cloudprovider.InstanceType{newTestInstanceType(newTestInstanceTypeInfo("2", "4Gi"), 1)},
+		newTestNodeClass(), newTestNodePool(),
+	)
+	cp.createLimiter = newCreateLimiter(limit)
+
+	var inFlight, maxInFlight atomic.Int64
+	sdk.CreateFixedNodeGroupHook = func() {
+		n := inFlight.Add(1)
+		for {
+			current := maxInFlight.Load()
+			if n <= current || maxInFlight.CompareAndSwap(current, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		inFlight.Add(-1)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			nodeClaim := newTestNodeClaim(corev1.ResourceList{})
+			nodeClaim.Name = fmt.Sprintf("default-%d", i)
+			nodeClaim.UID = types.UID(nodeClaim.Name)
+			if _, err := cp.Create(context.Background(), nodeClaim); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if got := sdk.Calls("CreateFixedNodeGroup"); got != 10 {
+		t.Errorf("Expected 10 creates, got %d", got)
+	}
+	if got := maxInFlight.Load(); got > limit {
+		t.Errorf("Expected at most %d creates in flight, got %d", limit, got)
+	}
+}
+
+func TestCreate_RetriesReuseIdempotencyKey(t *testing.T) {
+	cp, sdk := newTestCloudProvider(t,
+		[]*cloudprovider.InstanceType{newTestInstanceType(newTestInstanceTypeInfo("2", "4Gi"), 1)},
+		newTestNodeClass(), newTestNodePool(),
+	)
+	nodeClaim := newTestNodeClaim(corev1.ResourceList{})
+	nodeClaim.UID = "3f2d1c7e-0000-4000-8000-000000000001"
+
+	sdk.CreateFixedNodeGroupError = fmt.Errorf("connection reset")
+	if _, err := cp.Create(context.Background(), nodeClaim); err == nil {
+		t.Fatalf("Expected the first create to fail")
+	}
+	sdk.CreateFixedNodeGroupError = nil
+	first, err := cp.Create(context.Background(), nodeClaim)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	second, err := cp.Create(context.Background(), nodeClaim)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(sdk.CreateFixedNodeGroupInputs) != 3 {
+		t.Fatalf("Expected 3 create calls, got %d", len(sdk.CreateFixedNodeGroupInputs))
+	}
+	key := sdk.CreateFixedNodeGroupInputs[0].IdempotencyKey
+	if key == "" {
+		t.Fatalf("Expected an idempotency key to be set")
+	}
+	for i, input := range sdk.CreateFixedNodeGroupInputs {
+		if input.IdempotencyKey != key {
+			t.Errorf("Create call %d: expected idempotency key %s, got %s", i+1, key, input.IdempotencyKey)
+		}
+	}
+	if first.Status.ProviderID != second.Status.ProviderID {
+		t.Errorf("Expected retried create to return the same instance, got %s and %s", first.Status.ProviderID, second.Status.ProviderID)
+	}
+
+	recreated := newTestNodeClaim(corev1.ResourceList{})
+	recreated.UID = "3f2d1c7e-0000-4000-8000-000000000002"
+	if idempotencyKey(recreated) == key {
+		t.Errorf("Expected a NodeClaim recreated under the same name to get a new idempotency key")
+	}
+}
+
+func TestGet_EphemeralStorageFromNodeGroupDisk(t *testing.T) {
+	nodeClass := newTestNodeClass()
+	// the nodeclass disk was resized after the node group had been created with a 30Gi disk
+	nodeClass.Spec.DiskSize = resource.MustParse("100Gi")
+	instanceTypes := instancetype.NewDefaultProvider(
+		yandex.RegionRU,
+		instancetype.NewDefaultResolver(110),
+		offering.NewDefaultProvider(pricing.NewDefaultProvider(yandex.RegionRU), offering.NewUnavailableOfferings()),
+		sets.New(testZones...),
+		yandex.CoreFraction100,
+		nil,
+	)
+	cp, sdk := newTestCloudProviderWith(t, instanceTypes, nodeClass, newTestNodePool())
+
+	info := newTestInstanceTypeInfo("2", "4Gi")
+	diskSize := resource.MustParse("30Gi")
+	if _, _, err := sdk.CreateFixedNodeGroup(context.Background(), "default-abcde", "", map[string]string{karpv1.NodePoolLabelKey: testNodePool}, nil, nil,
+		info.Platform, info.CoreFraction, info.CPU, info.Memory, false, "ru-central1-a", "subnet-a", nodeClass, nodeClass.Spec.DiskType, diskSize.Value(), ""); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// the node image takes up 6Gi of the disk
+	ephemeralStorage := resource.MustParse("24Gi")
+	nodeClaim, err := cp.Get(context.Background(), "yandex://instance-ng-1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := nodeClaim.Status.Capacity[corev1.ResourceEphemeralStorage]; !got.Equal(ephemeralStorage) {
+		t.Errorf("Expected ephemeral storage capacity %s, got %s", ephemeralStorage.String(), got.String())
+	}
+	if got := nodeClaim.Status.Allocatable[corev1.ResourceEphemeralStorage]; got.Cmp(diskSize) >= 0 || got.IsZero() {
+		t.Errorf("Expected ephemeral storage allocatable below the %s disk, got %s", diskSize.String(), got.String())
+	}
+
+	nodeClaims, err := cp.List(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(nodeClaims) != 1 {
+		t.Fatalf("Expected 1 nodeclaim, got %d", len(nodeClaims))
+	}
+	if got := nodeClaims[0].Status.Capacity[corev1.ResourceEphemeralStorage]; !got.Equal(ephemeralStorage) {
+		t.Errorf("Expected listed ephemeral storage capacity %s, got %s", ephemeralStorage.String(), got.String())
+	}
+}
+
+func TestList_NodeGroupWithoutNodePoolLabel(t *testing.T) {
+	info := newTestInstanceTypeInfo("2", "4Gi")
+	secondNodePool := newTestNodePool()
+	secondNodePool.Name = "second"
+	cp, sdk := newTestCloudProvider(t, []*cloudprovider.InstanceType{newTestInstanceType(info, 1)},
+		newTestNodeClass(), secondNodePool, newTestNodePool())
+	nodeClass := newTestNodeClass()
+
+	// a karpenter-managed group that lost its nodepool label
+	if _, _, err := sdk.CreateFixedNodeGroup(context.Background(), "default-abcde", "", map[string]string{nodeClassLabelKey: testNodeClass}, nil, nil,
+		info.Platform, info.CoreFraction, info.CPU, info.Memory, false, "ru-central1-a", "subnet-a", nodeClass, nodeClass.Spec.DiskType, nodeClass.Spec.DiskSize.Value(), ""); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// a group not launched by karpenter
+	if _, _, err := sdk.CreateFixedNodeGroup(context.Background(), "unmanaged", "", nil, nil, nil,
+		info.Platform, info.CoreFraction, info.CPU, info.Memory, false, "ru-central1-a", "subnet-a", nodeClass, nodeClass.Spec.DiskType, nodeClass.Spec.DiskSize.Value(), ""); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	nodeClaims, err := cp.List(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(nodeClaims) != 1 {
+		t.Fatalf("Expected 1 nodeclaim, got %d", len(nodeClaims))
+	}
+	if got := nodeClaims[0].Status.ProviderID; got != "yandex://instance-ng-1" {
+		t.Errorf("Expected provider id yandex://instance-ng-1, got %s", got)
+	}
+	if got := nodeClaims[0].Labels[karpv1.NodePoolLabelKey]; got != testNodePool {
+		t.Errorf("Expected nodeclaim associated with nodepool %s, got %q", testNodePool, got)
+	}
+
+	nodeClaim, err := cp.Get(context.Background(), "yandex://instance-ng-1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := nodeClaim.Labels[karpv1.NodePoolLabelKey]; got != testNodePool {
+		t.Errorf("Expected nodeclaim associated with nodepool %s, got %q", testNodePool, got)
+	}
+}
+
+// newTestNodeGroups creates n karpenter-managed node groups with ids ng-1 to ng-n
+func newTestNodeGroups(tb testing.TB, sdk *fake.SDK, n int) {
+	tb.Helper()
+	info := newTestInstanceTypeInfo("2", "4Gi")
+	nodeClass := newTestNodeClass()
+	labels := map[string]string{karpv1.NodePoolLabelKey: testNodePool, nodeClassLabelKey: testNodeClass}
+	for i := range n {
+		if _, _, err := sdk.CreateFixedNodeGroup(context.Background(), fmt.Sprintf("default-%d", i), "", labels, nil, nil,
+			info.Platform, info.CoreFraction, info.CPU, info.Memory, false, "ru-central1-a", "subnet-a", nodeClass, nodeClass.Spec.DiskType, nodeClass.Spec.DiskSize.Value(), ""); err != nil {
+			tb.Fatalf("Unexpected error: %v", err)
+		}
+	}
+}
+
+func TestList_ManyNodeGroups(t *testing.T) {
+	info := newTestInstanceTypeInfo("2", "4Gi")
+	cp, sdk := newTestCloudProvider(t, []*cloudprovider.InstanceType{newTestInstanceType(info, 1)}, newTestNodeClass(), newTestNodePool())
+	newTestNodeGroups(t, sdk, 50)
+
+	// every tenth node group has not reported the cloud status of its node, resolving a node group takes a while so
+	// that concurrently resolved ones overlap
+	var inFlight, maxInFlight atomic.Int32
+	sdk.ProviderIdForFn = func(nodeGroupId string) (string, error) {
+		maxInFlight.Store(max(maxInFlight.Load(), inFlight.Add(1)))
+		defer inFlight.Add(-1)
+		time.Sleep(10 * time.Millisecond)
+		if strings.HasSuffix(nodeGroupId, "0") {
+			return "", fmt.Errorf("not found")
+		}
+		return "yandex://instance-" + nodeGroupId, nil
+	}
+
+	nodeClaims, err := cp.List(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(nodeClaims) != 45 {
+		t.Fatalf("Expected the 45 node groups with a node to be listed, got %d", len(nodeClaims))
+	}
+	providerIDs := sets.New(lo.Map(nodeClaims, func(nc *karpv1.NodeClaim, _ int) string { return nc.Status.ProviderID })...)
+	for i := 1; i <= 50; i++ {
+		providerID := fmt.Sprintf("yandex://instance-ng-%d", i)
+		if expected := i%10 != 0; providerIDs.Has(providerID) != expected {
+			t.Errorf("Expected %s to be listed: %v", providerID, expected)
+		}
+	}
+	if maxInFlight.Load() < 2 {
+		t.Errorf("Expected node groups to be resolved concurrently, at most %d were", maxInFlight.Load())
+	}
+}
+
+func BenchmarkList(b *testing.B) {
+	info := newTestInstanceTypeInfo("2", "4Gi")
+	cp, sdk := newTestCloudProvider(b, []*cloudprovider.InstanceType{newTestInstanceType(info, 1)}, newTestNodeClass(), newTestNodePool())
+	newTestNodeGroups(b, sdk, 100)
+
+	b.ResetTimer()
+	for range b.N {
+		if _, err := cp.List(context.Background()); err != nil {
+			b.Fatalf("Unexpected error: %v", err)
+		}
+	}
+}
+
+func TestDelete_NodeGroupSharedWithAnotherNodeClaim(t *testing.T) {
+	newNodeClaim := func(name string) *karpv1.NodeClaim {
+		nodeClaim := newTestNodeClaim(nil)
+		nodeClaim.Name = name
+		nodeClaim.Labels[v1alpha1.LabelYandexNodeGroupID] = "ng-1"
+		return nodeClaim
+	}
+
+	testCases := []struct {
+		name          string
+		objects       []client.Object
+		expectDeleted bool
+	}{
+		{
+			name:          "Last NodeClaim of the node group",
+			objects:       []client.Object{newNodeClaim("default-abcde")},
+			expectDeleted: true,
+		},
+		{
+			name:    "Node group backs another NodeClaim",
+			objects: []client.Object{newNodeClaim("default-abcde"), newNodeClaim("default-fghij")},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cp, sdk := newTestCloudProvider(t, nil, tc.objects...)
+
+			err := cp.Delete(context.Background(), newNodeClaim("default-abcde"))
+			deleted := sdk.Calls("DeleteNodeGroup") > 0
+			if deleted != tc.expectDeleted {
+				t.Fatalf("Expected node group deleted=%t, got %t (err: %v)", tc.expectDeleted, deleted, err)
+			}
+			if tc.expectDeleted && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if !tc.expectDeleted && !cloudprovider.IsNodeClaimNotFoundError(err) {
+				t.Errorf("Expected NodeClaimNotFoundError, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDelete_SafeDelete(t *testing.T) {
+	const nodeName = "node-1"
+	newPod := func(name string, owner *metav1.OwnerReference) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       corev1.PodSpec{NodeName: nodeName},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+		if owner != nil {
+			pod.OwnerReferences = []metav1.OwnerReference{*owner}
+		}
+		return pod
+	}
+	daemonSetOwner := &metav1.OwnerReference{APIVersion: "apps/v1", Kind: "DaemonSet", Name: "agent", UID: "ds"}
+	cordoned := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeName}, Spec: corev1.NodeSpec{Taints: []corev1.Taint{karpv1.DisruptedNoScheduleTaint}}}
+
+	terminating := newPod("terminating", nil)
+	terminating.DeletionTimestamp = &metav1.Time{Time: time.Now().Add(-time.Minute)}
+	terminating.Finalizers = []string{"example.com/stuck"}
+
+	testCases := []struct {
+		name          string
+		objects       []client.Object
+		mutateClaim   func(*karpv1.NodeClaim)
+		expectDeleted bool
+	}{
+		{
+			name:          "Drained node",
+			objects:       []client.Object{cordoned, newPod("agent", daemonSetOwner)},
+			expectDeleted: true,
+		},
+		{
+			name:          "Node already gone",
+			expectDeleted: true,
+		},
+		{
+			name:    "Node not cordoned",
+			objects: []client.Object{&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeName}}},
+		},
+		{
+			name:    "Workload pods remaining",
+			objects: []client.Object{cordoned, newPod("agent", daemonSetOwner), newPod("app", nil)},
+		},
+		{
+			name:          "Pod stuck terminating",
+			objects:       []client.Object{cordoned, terminating},
+			expectDeleted: true,
+		},
+		{
+			name:    "Workload pods remaining within the termination grace period",
+			objects: []client.Object{cordoned, newPod("app", nil)},
+			mutateClaim: func(nc *karpv1.NodeClaim) {
+				nc.Spec.TerminationGracePeriod = &metav1.Duration{Duration: time.Hour}
+				nc.DeletionTimestamp = &metav1.Time{Time: time.Now().Add(-time.Minute)}
+			},
+		},
+		{
+			name:    "Workload pods remaining past the termination grace period",
+			objects: []client.Object{cordoned, newPod("app", nil)},
+			mutateClaim: func(nc *karpv1.NodeClaim) {
+				nc.Spec.TerminationGracePeriod = &metav1.Duration{Duration: time.Minute}
+				nc.DeletionTimestamp = &metav1.Time{Time: time.Now().Add(-time.Hour)}
+			},
+			expectDeleted: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cp, sdk := newTestCloudProvider(t, nil, tc.objects...)
+			cp.safeDelete = true
+			nodeClaim := newTestNodeClaim(nil)
+			nodeClaim.Labels[v1alpha1.LabelYandexNodeGroupID] = "ng-1"
+			nodeClaim.Status.NodeName = nodeName
+			if tc.mutateClaim != nil {
+				tc.mutateClaim(nodeClaim)
+			}
+
+			err := cp.Delete(context.Background(), nodeClaim)
+			deleted := sdk.Calls("DeleteNodeGroup") > 0
+			if deleted != tc.expectDeleted {
+				t.Fatalf("Expected node group deleted=%t, got %t (err: %v)", tc.expectDeleted, deleted, err)
+			}
+			if tc.expectDeleted && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if !tc.expectDeleted && (err == nil || cloudprovider.IsNodeClaimNotFoundError(err)) {
+				t.Errorf("Expected a retryable error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCreate_RendersUserDataTemplate(t *testing.T) {
+	info := newTestInstanceTypeInfo("2", "4Gi")
+	nodeClass := newTestNodeClass()
+	nodeClass.Spec.UserDataTemplate = "#cloud-config\nhostname: {{ .NodeName }}\nzone: {{ .Zone }}\ntype: {{ .InstanceType }}\n"
+	cp, sdk := newTestCloudProvider(t, []*cloudprovider.InstanceType{newTestInstanceType(info, 1)}, nodeClass, newTestNodePool())
+
+	if _, err := cp.Create(context.Background(), newTestNodeClaim(corev1.ResourceList{})); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(sdk.CreateFixedNodeGroupInputs) != 1 {
+		t.Fatalf("Expected 1 node group to be created, got %d", len(sdk.CreateFixedNodeGroupInputs))
+	}
+	created := sdk.CreateFixedNodeGroupInputs[0]
+	userData, err := base64.StdEncoding.DecodeString(created.UserData)
+	if err != nil {
+		t.Fatalf("Expected base64-encoded user-data, got %q: %v", created.UserData, err)
+	}
+	expected := fmt.Sprintf("#cloud-config\nhostname: default-abcde\nzone: %s\ntype: %s\n", created.ZoneId, info.String())
+	if string(userData) != expected {
+		t.Errorf("Expected user-data %q, got %q", expected, string(userData))
+	}
+}
+
+func TestGet_NonManagedInstanceIsNotFound(t *testing.T) {
+	cp, sdk := newTestCloudProvider(t, nil, newTestNodeClass(), newTestNodePool())
+	sdk.GetNodeGroupByProviderIdFn = func(providerId string) (*k8s.NodeGroup, error) {
+		return nil, grpcstatus.Errorf(codes.NotFound, "instance %s is not a managed kubernetes node", providerId)
+	}
+
+	if _, err := cp.Get(context.Background(), "yandex://instance-1"); !cloudprovider.IsNodeClaimNotFoundError(err) {
+		t.Errorf("Expected a NodeClaimNotFound error, got %v", err)
+	}
+}
+
+func TestCreate_AnnotatesOperationID(t *testing.T) {
+	cp, _ := newTestCloudProvider(t,
+		[]*cloudprovider.InstanceType{newTestInstanceType(newTestInstanceTypeInfo("2", "4Gi"), 1)},
+		newTestNodeClass(), newTestNodePool(),
+	)
+
+	created, err := cp.Create(context.Background(), newTestNodeClaim(corev1.ResourceList{}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := created.Annotations[v1alpha1.AnnotationCreateOperationID]; got != "op-create-ng-1" {
+		t.Errorf("Expected operation id annotation op-create-ng-1, got %q", got)
+	}
+}
+
+func TestGet_CanonicalInstanceTypeLabels(t *testing.T) {
+	info := newTestInstanceTypeInfo("2", "4Gi")
+	nodeClass := newTestNodeClass()
+	cp, sdk := newTestCloudProvider(t, []*cloudprovider.InstanceType{newTestInstanceType(info, 1)}, nodeClass, newTestNodePool())
+	if _, _, err := sdk.CreateFixedNodeGroup(context.Background(), "default-abcde", "", map[string]string{karpv1.NodePoolLabelKey: testNodePool}, nil, nil,
+		info.Platform, info.CoreFraction, info.CPU, info.Memory, false, "ru-central1-a", "subnet-a", nodeClass, nodeClass.Spec.DiskType, nodeClass.Spec.DiskSize.Value(), ""); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	nodeClaim, err := cp.Get(context.Background(), "yandex://instance-ng-1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, key := range []string{corev1.LabelInstanceTypeStable, corev1.LabelInstanceType} {
+		if got := nodeClaim.Labels[key]; got != info.String() {
+			t.Errorf("Expected %s=%s, got %q", key, info.String(), got)
+		}
+	}
+}
+
+func TestGet_NodePriceAnnotation(t *testing.T) {
+	info := newTestInstanceTypeInfo("2", "4Gi")
+	it := newTestInstanceType(info, 0.0305)
+	// the node price is the offering of the node zone and capacity type, not the cheapest offering anywhere
+	it.Offerings = append(it.Offerings,
+		&cloudprovider.Offering{
+			Requirements: scheduling.NewRequirements(
+				scheduling.NewRequirement(karpv1.CapacityTypeLabelKey, corev1.NodeSelectorOpIn, karpv1.CapacityTypeSpot),
+				scheduling.NewRequirement(corev1.LabelTopologyZone, corev1.NodeSelectorOpIn, "ru-central1-a"),
+			),
+			Price:     0.01,
+			Available: true,
+		},
+		&cloudprovider.Offering{
+			Requirements: scheduling.NewRequirements(
+				scheduling.NewRequirement(karpv1.CapacityTypeLabelKey, corev1.NodeSelectorOpIn, karpv1.CapacityTypeOnDemand),
+				scheduling.NewRequirement(corev1.LabelTopologyZone, corev1.NodeSelectorOpIn, "ru-central1-a"),
+			),
+			Price:     0.02,
+			Available: false,
+		},
+	)
+	nodeClass := newTestNodeClass()
+	cp, sdk := newTestCloudProvider(t, []*cloudprovider.InstanceType{it}, nodeClass, newTestNodePool())
+	if _, _, err := sdk.CreateFixedNodeGroup(context.Background(), "default-abcde", "", map[string]string{karpv1.NodePoolLabelKey: testNodePool}, nil, nil,
+		info.Platform, info.CoreFraction, info.CPU, info.Memory, false, "ru-central1-a", "subnet-a", nodeClass, nodeClass.Spec.DiskType, nodeClass.Spec.DiskSize.Value(), ""); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	nodeClaim, err := cp.Get(context.Background(), "yandex://instance-ng-1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := nodeClaim.Annotations[v1alpha1.AnnotationNodePrice]; got != "0.0305" {
+		t.Errorf("Expected %s=0.0305, got %q", v1alpha1.AnnotationNodePrice, got)
+	}
+	if _, ok := nodeClaim.Labels[v1alpha1.AnnotationNodePrice]; ok {
+		t.Errorf("Expected the price not to be a label")
+	}
+}
+
+func TestCreate_NodeAnnotations(t *testing.T) {
+	nodeClass := newTestNodeClass()
+	nodeClass.Spec.NodeAnnotations = map[string]string{
+		"example.com/owner":                    "ml",
+		v1alpha1.AnnotationYandexNodeClassHash: "overridden",
+	}
+	cp, _ := newTestCloudProvider(t,
+		[]*cloudprovider.InstanceType{newTestInstanceType(newTestInstanceTypeInfo("2", "4Gi"), 1)},
+		nodeClass, newTestNodePool(),
+	)
+
+	created, err := cp.Create(context.Background(), newTestNodeClaim(corev1.ResourceList{}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if owner := created.Annotations["example.com/owner"]; owner != "ml" {
+		t.Errorf("Expected annotation example.com/owner=ml on the NodeClaim, got %q", owner)
+	}
+	if hash := created.Annotations[v1alpha1.AnnotationYandexNodeClassHash]; hash != strconv.FormatUint(nodeClass.Hash(), 10) {
+		t.Errorf("Expected the nodeclass hash annotation of the provider to take precedence, got %q", hash)
+	}
+}
+
+func TestCreate_DefaultNodeLabels(t *testing.T) {
+	nodeClass := newTestNodeClass()
+	nodeClass.Spec.NodeLabels = map[string]string{"team": "ml"}
+	cp, sdk := newTestCloudProvider(t,
+		[]*cloudprovider.InstanceType{newTestInstanceType(newTestInstanceTypeInfo("2", "4Gi"), 1)},
+		nodeClass, newTestNodePool(),
+	)
+	cp.defaultNodeLabels = map[string]string{"team": "platform", "cost-center": "infra"}
+
+	created, err := cp.Create(context.Background(), newTestNodeClaim(corev1.ResourceList{}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(sdk.CreateFixedNodeGroupInputs) != 1 {
+		t.Fatalf("Expected 1 node group to be created, got %d", len(sdk.CreateFixedNodeGroupInputs))
+	}
+	nodeLabels := sdk.CreateFixedNodeGroupInputs[0].NodeLabels
+	if nodeLabels["cost-center"] != "infra" {
+		t.Errorf("Expected default label cost-center=infra on the node, got %q", nodeLabels["cost-center"])
+	}
+	if nodeLabels["team"] != "ml" {
+		t.Errorf("Expected the nodeclass to override team, got %q", nodeLabels["team"])
+	}
+
+	nodeClaim, err := cp.Get(context.Background(), created.Status.ProviderID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if nodeClaim.Labels["cost-center"] != "infra" || nodeClaim.Labels["team"] != "ml" {
+		t.Errorf("Expected Get to agree with the created node labels, got cost-center=%q team=%q", nodeClaim.Labels["cost-center"], nodeClaim.Labels["team"])
+	}
+
+	// reconfigured defaults only apply to node groups created from then on
+	cp.defaultNodeLabels = map[string]string{"cost-center": "research", "tier": "batch"}
+	nodeClaim, err = cp.Get(context.Background(), created.Status.ProviderID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if nodeClaim.Labels["cost-center"] != "infra" {
+		t.Errorf("Expected the label cost-center=infra the node group was created with, got %q", nodeClaim.Labels["cost-center"])
+	}
+	if tier, ok := nodeClaim.Labels["tier"]; ok {
+		t.Errorf("Expected no label tier on a node group created before it was configured, got %q", tier)
+	}
+}
+
+func TestInstanceTypeWithoutOfferings(t *testing.T) {
+	info := newTestInstanceTypeInfo("2", "4Gi")
+	it := newTestInstanceType(info, 1)
+	it.Offerings = cloudprovider.Offerings{}
+	nodeClass := newTestNodeClass()
+	cp, sdk := newTestCloudProvider(t, []*cloudprovider.InstanceType{it}, nodeClass, newTestNodePool())
+	if _, _, err := sdk.CreateFixedNodeGroup(context.Background(), "default-abcde", "", map[string]string{karpv1.NodePoolLabelKey: testNodePool}, nil, nil,
+		info.Platform, info.CoreFraction, info.CPU, info.Memory, false, "ru-central1-a", "subnet-a", nodeClass, nodeClass.Spec.DiskType, nodeClass.Spec.DiskSize.Value(), ""); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if price := cheapestPrice(it, scheduling.NewRequirements()); price != math.MaxFloat64 {
+		t.Errorf("Expected an instance type without offerings to be priced the highest, got %v", price)
+	}
+	if price, ok := nodePrice(it, map[string]string{corev1.LabelTopologyZone: "ru-central1-a", karpv1.CapacityTypeLabelKey: karpv1.CapacityTypeOnDemand}); ok {
+		t.Errorf("Expected no node price, got %v", price)
+	}
+	nodeClaim, err := cp.nodeGroupToNodeClaim(context.Background(), sdk.NodeGroups["ng-1"], it)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := nodeClaim.Annotations[v1alpha1.AnnotationNodePrice]; ok {
+		t.Errorf("Expected no %s annotation, got %q", v1alpha1.AnnotationNodePrice, nodeClaim.Annotations[v1alpha1.AnnotationNodePrice])
+	}
+	if got := nodeClaim.Status.Allocatable[corev1.ResourceCPU]; got.IsZero() {
+		t.Errorf("Expected allocatable CPU, got %s", got.String())
+	}
+}
+
+func TestGet_ZoneOfMultiZoneNodeGroup(t *testing.T) {
+	info := newTestInstanceTypeInfo("2", "4Gi")
+	nodeClass := newTestNodeClass()
+	cp, sdk := newTestCloudProvider(t, []*cloudprovider.InstanceType{newTestInstanceType(info, 1)}, nodeClass, newTestNodePool())
+	for i, zone := range []string{"ru-central1-a", "ru-central1-b"} {
+		name := fmt.Sprintf("default-%d", i)
+		if _, _, err := sdk.CreateFixedNodeGroup(context.Background(), name, "", map[string]string{karpv1.NodePoolLabelKey: testNodePool}, nil, nil,
+			info.Platform, info.CoreFraction, info.CPU, info.Memory, false, zone, "subnet-"+zone, nodeClass, nodeClass.Spec.DiskType, nodeClass.Spec.DiskSize.Value(), ""); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	// both groups span both zones, listing ru-central1-a first
+	for _, ng := range sdk.NodeGroups {
+		ng.AllocationPolicy.Locations = []*k8s.NodeGroupLocation{{ZoneId: "ru-central1-a"}, {ZoneId: "ru-central1-b"}}
+	}
+
+	for providerID, expected := range map[string]string{"yandex://instance-ng-1": "ru-central1-a", "yandex://instance-ng-2": "ru-central1-b"} {
+		nodeClaim, err := cp.Get(context.Background(), providerID)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got := nodeClaim.Labels[corev1.LabelTopologyZone]; got != expected {
+			t.Errorf("Expected %s in zone %s, got %q", providerID, expected, got)
+		}
+	}
+}
+
+func TestIsDrifted(t *testing.T) {
+	testCases := []struct {
+		name           string
+		mutateClass    func(*v1alpha1.YandexNodeClass)
+		mutateGroup    func(*k8s.NodeGroup)
+		expectedReason cloudprovider.DriftReason
+	}{
+		{
+			name: "Nothing changed",
+		},
+		{
+			name:           "Disk type changed",
+			mutateClass:    func(nc *v1alpha1.YandexNodeClass) { nc.Spec.DiskType = string(yandex.HDD) },
+			expectedReason: NodeClassHashChangedDrift,
+		},
+		{
+			name:           "Disk size changed",
+			mutateClass:    func(nc *v1alpha1.YandexNodeClass) { nc.Spec.DiskSize = resource.MustParse("64Gi") },
+			expectedReason: NodeClassHashChangedDrift,
+		},
+		{
+			name:           "Security groups changed",
+			mutateClass:    func(nc *v1alpha1.YandexNodeClass) { nc.Spec.SecurityGroups = []string{"sg-1"} },
+			expectedReason: NodeClassHashChangedDrift,
+		},
+		{
+			name:        "Platform preference changed",
+			mutateClass: func(nc *v1alpha1.YandexNodeClass) { nc.Spec.PlatformPreference = []string{"standard-v2"} },
+		},
+		{
+			name:           "Node group platform changed",
+			mutateGroup:    func(ng *k8s.NodeGroup) { ng.NodeTemplate.PlatformId = string(yandex.PlatformIntelCascadeLake) },
+			expectedReason: PlatformDrift,
+		},
+		{
+			name:           "Node group core fraction changed",
+			mutateGroup:    func(ng *k8s.NodeGroup) { ng.NodeTemplate.ResourcesSpec.CoreFraction = 50 },
+			expectedReason: PlatformDrift,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			nodeClass := newTestNodeClass()
+			nodeClass.Status.SpecHash = nodeClass.Hash()
+			cp, sdk := newTestCloudProvider(t,
+				[]*cloudprovider.InstanceType{newTestInstanceType(newTestInstanceTypeInfo("2", "4Gi"), 1)},
+				nodeClass, newTestNodePool(),
+			)
+			nodeClaim := newTestNodeClaim(corev1.ResourceList{})
+			created, err := cp.Create(ctx, nodeClaim)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			nodeClaim.Labels = lo.Assign(nodeClaim.Labels, created.Labels)
+			nodeClaim.Annotations = created.Annotations
+			nodeClaim.Status.ProviderID = created.Status.ProviderID
+
+			if tc.mutateClass != nil {
+				// the fake client cannot update the uint64 spec hash, so the changed nodeclass is served by a new
+				// cloud provider over the same node groups
+				changed := nodeClass.DeepCopy()
+				tc.mutateClass(changed)
+				changed.Status.SpecHash = changed.Hash()
+				cp = newTestCloudProviderWithSDK(t, sdk, cp.instanceTypes, changed, newTestNodePool())
+			}
+			if tc.mutateGroup != nil {
+				tc.mutateGroup(sdk.NodeGroups[created.Labels[v1alpha1.LabelYandexNodeGroupID]])
+			}
+
+			reason, err := cp.IsDrifted(ctx, nodeClaim)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if reason != tc.expectedReason {
+				t.Errorf("Expected drift reason %q, got %q", tc.expectedReason, reason)
+			}
+		})
+	}
+}
+
+func TestIsDrifted_WithoutRecordedHash(t *testing.T) {
+	nodeClass := newTestNodeClass()
+	nodeClass.Status.SpecHash = nodeClass.Hash() + 1
+	cp, _ := newTestCloudProvider(t, nil, nodeClass, newTestNodePool())
+
+	// NodeClaims launched before the nodeclass hash was recorded are left alone
+	reason, err := cp.IsDrifted(context.Background(), newTestNodeClaim(corev1.ResourceList{}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if reason != "" {
+		t.Errorf("Expected no drift, got %q", reason)
+	}
+}
+
+func TestIsDrifted_SecurityGroups(t *testing.T) {
+	testCases := []struct {
+		name           string
+		classGroups    []string
+		liveGroups     []string
+		expectedReason cloudprovider.DriftReason
+	}{
+		{
+			name:        "Same security groups",
+			classGroups: []string{"sg-1", "sg-2"},
+			liveGroups:  []string{"sg-2", "sg-1"},
+		},
+		{
+			name:           "Security group replaced",
+			classGroups:    []string{"sg-1", "sg-2"},
+			liveGroups:     []string{"sg-1", "sg-3"},
+			expectedReason: SecurityGroupDrift,
+		},
+		{
+			name:           "Security group removed",
+			classGroups:    []string{"sg-1", "sg-2"},
+			liveGroups:     []string{"sg-1"},
+			expectedReason: SecurityGroupDrift,
+		},
+		{
+			name:       "Nodeclass without security groups",
+			liveGroups: []string{"sg-default"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			nodeClass := newTestNodeClass()
+			nodeClass.Spec.SecurityGroups = tc.classGroups
+			cp, sdk := newTestCloudProvider(t,
+				[]*cloudprovider.InstanceType{newTestInstanceType(newTestInstanceTypeInfo("2", "4Gi"), 1)},
+				nodeClass, newTestNodePool(),
+			)
+			nodeClaim := newTestNodeClaim(corev1.ResourceList{})
+			created, err := cp.Create(ctx, nodeClaim)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			nodeClaim.Labels = lo.Assign(nodeClaim.Labels, created.Labels)
+			nodeClaim.Status.ProviderID = created.Status.ProviderID
+			// the security groups were edited in the Yandex Cloud console
+			ng := sdk.NodeGroups[created.Labels[v1alpha1.LabelYandexNodeGroupID]]
+			ng.NodeTemplate.NetworkInterfaceSpecs[0].SecurityGroupIds = tc.liveGroups
+
+			reason, err := cp.IsDrifted(ctx, nodeClaim)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if reason != tc.expectedReason {
+				t.Errorf("Expected drift reason %q, got %q", tc.expectedReason, reason)
+			}
+		})
+	}
+}
+
+func TestIsDrifted_MaxNodeAge(t *testing.T) {
+	testCases := []struct {
+		name           string
+		maxNodeAge     *metav1.Duration
+		age            time.Duration
+		expectedReason cloudprovider.DriftReason
+	}{
+		{
+			name:           "Older than the max age",
+			maxNodeAge:     &metav1.Duration{Duration: 7 * 24 * time.Hour},
+			age:            8 * 24 * time.Hour,
+			expectedReason: ExpiredNodeDrift,
+		},
+		{
+			name:       "Younger than the max age",
+			maxNodeAge: &metav1.Duration{Duration: 7 * 24 * time.Hour},
+			age:        6 * 24 * time.Hour,
+		},
+		{
+			name:       "Exactly the max age",
+			maxNodeAge: &metav1.Duration{Duration: 7 * 24 * time.Hour},
+			age:        7 * 24 * time.Hour,
+		},
+		{
+			name: "No max age",
+			age:  365 * 24 * time.Hour,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			nodeClass := newTestNodeClass()
+			nodeClass.Spec.MaxNodeAge = tc.maxNodeAge
+			cp, sdk := newTestCloudProvider(t,
+				[]*cloudprovider.InstanceType{newTestInstanceType(newTestInstanceTypeInfo("2", "4Gi"), 1)},
+				nodeClass, newTestNodePool(),
+			)
+			clk := clocktesting.NewFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+			cp.clk = clk
+			nodeClaim := newTestNodeClaim(corev1.ResourceList{})
+			created, err := cp.Create(ctx, nodeClaim)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			nodeClaim.Labels = lo.Assign(nodeClaim.Labels, created.Labels)
+			nodeClaim.Status.ProviderID = created.Status.ProviderID
+			sdk.NodeGroups[created.Labels[v1alpha1.LabelYandexNodeGroupID]].CreatedAt = timestamppb.New(clk.Now().Add(-tc.age))
+
+			reason, err := cp.IsDrifted(ctx, nodeClaim)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if reason != tc.expectedReason {
+				t.Errorf("Expected drift reason %q, got %q", tc.expectedReason, reason)
+			}
+		})
+	}
+}
+
+func TestCreate_PassesMetadataUserData(t *testing.T) {
+	nodeClass := newTestNodeClass()
+	nodeClass.Spec.MetadataOptions = &v1alpha1.MetadataOptions{UserData: base64.StdEncoding.EncodeToString([]byte("#cloud-config\n"))}
+	cp, sdk := newTestCloudProvider(t, []*cloudprovider.InstanceType{newTestInstanceType(newTestInstanceTypeInfo("2", "4Gi"), 1)}, nodeClass, newTestNodePool())
+
+	if _, err := cp.Create(context.Background(), newTestNodeClaim(corev1.ResourceList{})); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(sdk.CreateFixedNodeGroupInputs) != 1 {
+		t.Fatalf("Expected 1 node group to be created, got %d", len(sdk.CreateFixedNodeGroupInputs))
+	}
+	if userData := sdk.CreateFixedNodeGroupInputs[0].UserData; userData != nodeClass.Spec.MetadataOptions.UserData {
+		t.Errorf("Expected user-data %q, got %q", nodeClass.Spec.MetadataOptions.UserData, userData)
+	}
+}
+
+func TestCreate_SeededZoneSelection(t *testing.T) {
+	const seed = 42
+	cp, sdk := newTestCloudProvider(t, []*cloudprovider.InstanceType{newTestInstanceType(newTestInstanceTypeInfo("2", "4Gi"), 1)}, newTestNodeClass(), newTestNodePool())
+	cp.rand = newRand(seed)
+
+	// every test zone offers the same price, so the zone is picked by the random source alone
+	expected := rand.New(rand.NewSource(seed))
+	for i := range 10 {
+		nodeClaim := newTestNodeClaim(corev1.ResourceList{})
+		nodeClaim.Name = fmt.Sprintf("default-%d", i)
+		if _, err := cp.Create(context.Background(), nodeClaim); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		zone := testZones[expected.Intn(len(testZones))]
+		if created := sdk.CreateFixedNodeGroupInputs[i]; created.ZoneId != zone {
+			t.Errorf("Expected node group %d in zone %s, got %s", i, zone, created.ZoneId)
+		}
+	}
+}
+
+func TestGet_RetriesProviderIDOfRunningNodeGroup(t *testing.T) {
+	testCases := []struct {
+		name          string
+		retryTimeout  time.Duration
+		status        k8s.NodeGroup_Status
+		cancelled     bool
+		expectedErr   bool
+		expectedCalls int
+	}{
+		{name: "provider id on the second attempt", retryTimeout: time.Minute, status: k8s.NodeGroup_RUNNING, expectedCalls: 2},
+		{name: "retries disabled", retryTimeout: 0, status: k8s.NodeGroup_RUNNING, expectedErr: true, expectedCalls: 1},
+		{name: "deleting node group", retryTimeout: time.Minute, status: k8s.NodeGroup_DELETING, expectedErr: true, expectedCalls: 1},
+		{name: "reconciling node group", retryTimeout: time.Minute, status: k8s.NodeGroup_RECONCILING, expectedErr: true, expectedCalls: 1},
+		{name: "cancelled context", retryTimeout: time.Minute, status: k8s.NodeGroup_RUNNING, cancelled: true, expectedErr: true, expectedCalls: 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			info := newTestInstanceTypeInfo("2", "4Gi")
+			nodeClass := newTestNodeClass()
+			cp, sdk := newTestCloudProvider(t, []*cloudprovider.InstanceType{newTestInstanceType(info, 1)}, nodeClass, newTestNodePool())
+			cp.providerIDRetryTimeout = tc.retryTimeout
+			cp.providerIDRetryInterval = time.Millisecond
+			if _, _, err := sdk.CreateFixedNodeGroup(context.Background(), "default-abcde", "", map[string]string{karpv1.NodePoolLabelKey: testNodePool}, nil, nil,
+				info.Platform, info.CoreFraction, info.CPU, info.Memory, false, "ru-central1-a", "subnet-a", nodeClass, nodeClass.Spec.DiskType, nodeClass.Spec.DiskSize.Value(), ""); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			sdk.NodeGroups["ng-1"].Status = tc.status
+			// the node of the group has not reported its cloud status on the first attempt
+			attempts := 0
+			sdk.ProviderIdForFn = func(string) (string, error) {
+				if attempts++; attempts == 1 {
+					return "", fmt.Errorf("not found")
+				}
+				return "yandex://instance-ng-1", nil
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.cancelled {
+				cancel()
+			}
+			nodeClaim, err := cp.Get(ctx, "yandex://instance-ng-1")
+			if tc.expectedErr {
+				if err == nil {
+					t.Errorf("Expected an error, got none")
+				}
+			} else if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			} else if nodeClaim.Status.ProviderID != "yandex://instance-ng-1" {
+				t.Errorf("Expected provider id yandex://instance-ng-1, got %q", nodeClaim.Status.ProviderID)
+			}
+			if attempts != tc.expectedCalls {
+				t.Errorf("Expected %d attempts to resolve the provider id, got %d", tc.expectedCalls, attempts)
+			}
+		})
+	}
+}
+
+func TestGet_DeletingNodeGroup(t *testing.T) {
+	info := newTestInstanceTypeInfo("2", "4Gi")
+	nodeClass := newTestNodeClass()
+	cp, sdk := newTestCloudProvider(t, []*cloudprovider.InstanceType{newTestInstanceType(info, 1)}, nodeClass, newTestNodePool())
+	clk := clocktesting.NewFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	cp.clk = clk
+	if _, _, err := sdk.CreateFixedNodeGroup(context.Background(), "default-abcde", "", map[string]string{karpv1.NodePoolLabelKey: testNodePool}, nil, nil,
+		info.Platform, info.CoreFraction, info.CPU, info.Memory, false, "ru-central1-a", "subnet-a", nodeClass, nodeClass.Spec.DiskType, nodeClass.Spec.DiskSize.Value(), ""); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	sdk.NodeGroups["ng-1"].Status = k8s.NodeGroup_DELETING
+	sdk.ProviderIdForFn = func(string) (string, error) { return "yandex://instance-ng-1", nil }
+
+	nodeClaim, err := cp.Get(context.Background(), "yandex://instance-ng-1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if nodeClaim.DeletionTimestamp == nil || !nodeClaim.DeletionTimestamp.Time.Equal(clk.Now()) {
+		t.Errorf("Expected deletion timestamp %s, got %v", clk.Now(), nodeClaim.DeletionTimestamp)
+	}
+}
+
+func TestGet_GPUNodeGroup(t *testing.T) {
+	instanceTypes := instancetype.NewDefaultProvider(
+		yandex.RegionRU,
+		instancetype.NewDefaultResolver(110),
+		offering.NewDefaultProvider(pricing.NewDefaultProvider(yandex.RegionRU), offering.NewUnavailableOfferings()),
+		sets.New(testZones...),
+		yandex.CoreFraction100,
+		nil,
+	)
+	nodeClass := newTestNodeClass()
+	cp, sdk := newTestCloudProviderWith(t, instanceTypes, nodeClass, newTestNodePool())
+
+	info := yandex.InstanceType{
+		Platform:     yandex.PlatformAMDEPYCNVIDIAAmpereA100,
+		CPU:          resource.MustParse("56"),
+		Memory:       resource.MustParse("238Gi"),
+		CoreFraction: yandex.CoreFraction100,
+	}
+	if _, _, err := sdk.CreateFixedNodeGroup(context.Background(), "default-abcde", "", map[string]string{karpv1.NodePoolLabelKey: testNodePool}, nil, nil,
+		info.Platform, info.CoreFraction, info.CPU, info.Memory, false, "ru-central1-a", "subnet-a", nodeClass, nodeClass.Spec.DiskType, nodeClass.Spec.DiskSize.Value(), ""); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if gpus := sdk.NodeGroups["ng-1"].GetNodeTemplate().GetResourcesSpec().GetGpus(); gpus != 2 {
+		t.Fatalf("Expected the node group to have 2 GPUs, got %d", gpus)
+	}
+
+	nodeClaim, err := cp.Get(context.Background(), "yandex://instance-ng-1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := nodeClaim.Status.Capacity[v1alpha1.ResourceNVIDIAGPU]; got.Value() != 2 {
+		t.Errorf("Expected %s capacity 2, got %s", v1alpha1.ResourceNVIDIAGPU, got.String())
+	}
+	if got := nodeClaim.Labels[v1alpha1.LabelInstanceGPUCount]; got != "2" {
+		t.Errorf("Expected %s label 2, got %q", v1alpha1.LabelInstanceGPUCount, got)
+	}
+	if got := nodeClaim.Labels[v1alpha1.LabelInstanceGPUType]; got != "nvidia-ampere-a100" {
+		t.Errorf("Expected %s label nvidia-ampere-a100, got %q", v1alpha1.LabelInstanceGPUType, got)
+	}
+}
+
+func TestCreate_AnnotatesSpotSavings(t *testing.T) {
+	it := newTestInstanceType(newTestInstanceTypeInfo("2", "4Gi"), 10)
+	// adding a requirement intersects it with the on-demand one
+	it.Requirements[karpv1.CapacityTypeLabelKey] = scheduling.NewRequirement(karpv1.CapacityTypeLabelKey, corev1.NodeSelectorOpIn, karpv1.CapacityTypeOnDemand, karpv1.CapacityTypeSpot)
+	for _, zone := range testZones {
+		it.Offerings = append(it.Offerings, &cloudprovider.Offering{
+			Requirements: scheduling.NewRequirements(
+				scheduling.NewRequirement(karpv1.CapacityTypeLabelKey, corev1.NodeSelectorOpIn, karpv1.CapacityTypeSpot),
+				scheduling.NewRequirement(corev1.LabelTopologyZone, corev1.NodeSelectorOpIn, zone),
+			),
+			Price:     3,
+			Available: true,
+		})
+	}
+	cp, _ := newTestCloudProvider(t, []*cloudprovider.InstanceType{it}, newTestNodeClass(), newTestNodePool())
+
+	created, err := cp.Create(context.Background(), newTestNodeClaim(corev1.ResourceList{}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if capacityType := created.Labels[karpv1.CapacityTypeLabelKey]; capacityType != karpv1.CapacityTypeSpot {
+		t.Fatalf("Expected a spot NodeClaim, got %s", capacityType)
+	}
+	if savings := created.Annotations[v1alpha1.AnnotationSpotSavingsPercent]; savings != "70.0" {
+		t.Errorf("Expected spot savings of 70.0 percent, got %q", savings)
+	}
+}
+
+func TestCreate_DiskTypePerCapacityType(t *testing.T) {
+	testCases := []struct {
+		name             string
+		spot             bool
+		expectedDiskType string
+	}{
+		{name: "Spot", spot: true, expectedDiskType: string(yandex.HDD)},
+		{name: "On-demand", spot: false, expectedDiskType: string(yandex.SSDNonreplicated)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			it := newTestInstanceType(newTestInstanceTypeInfo("2", "4Gi"), 10)
+			if tc.spot {
+				// adding a requirement intersects it with the on-demand one
+				it.Requirements[karpv1.CapacityTypeLabelKey] = scheduling.NewRequirement(karpv1.CapacityTypeLabelKey, corev1.NodeSelectorOpIn, karpv1.CapacityTypeOnDemand, karpv1.CapacityTypeSpot)
+				for _, zone := range testZones {
+					it.Offerings = append(it.Offerings, &cloudprovider.Offering{
+						Requirements: scheduling.NewRequirements(
+							scheduling.NewRequirement(karpv1.CapacityTypeLabelKey, corev1.NodeSelectorOpIn, karpv1.CapacityTypeSpot),
+							scheduling.NewRequirement(corev1.LabelTopologyZone, corev1.NodeSelectorOpIn, zone),
+						),
+						Price:     3,
+						Available: true,
+					})
+				}
+			}
+			nodeClass := newTestNodeClass()
+			nodeClass.Spec.SpotDiskType = string(yandex.HDD)
+			nodeClass.Spec.OnDemandDiskType = string(yandex.SSDNonreplicated)
+			cp, sdk := newTestCloudProvider(t, []*cloudprovider.InstanceType{it}, nodeClass, newTestNodePool())
+
+			if _, err := cp.Create(context.Background(), newTestNodeClaim(corev1.ResourceList{})); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if diskType := sdk.CreateFixedNodeGroupInputs[0].DiskType; diskType != tc.expectedDiskType {
+				t.Errorf("Expected disk type %s, got %s", tc.expectedDiskType, diskType)
+			}
+		})
+	}
+}
+
+func TestSelectOffering(t *testing.T) {
+	zones := []string{"ru-central1-a", "ru-central1-b", "ru-central1-d"}
+	offerings := lo.Map(zones, func(zone string, _ int) *cloudprovider.Offering {
+		return &cloudprovider.Offering{
+			Requirements: scheduling.NewRequirements(
+				scheduling.NewRequirement(karpv1.CapacityTypeLabelKey, corev1.NodeSelectorOpIn, karpv1.CapacityTypeOnDemand),
+				scheduling.NewRequirement(corev1.LabelTopologyZone, corev1.NodeSelectorOpIn, zone),
+			),
+			Available: true,
+		}
+	})
+	zoneToSubnet := map[string]subnet.Subnet{
+		"ru-central1-a": {ID: "subnet-a", ZoneID: "ru-central1-a", AvailableIPAddressCount: 10, AvailableNodeSlots: 10},
+		"ru-central1-b": {ID: "subnet-b", ZoneID: "ru-central1-b", AvailableIPAddressCount: 200, AvailableNodeSlots: 200},
+		"ru-central1-d": {ID: "subnet-d", ZoneID: "ru-central1-d", AvailableIPAddressCount: 1000, AvailableNodeSlots: 100},
+	}
+
+	testCases := []struct {
+		name          string
+		outcomes      map[string]bool
+		scorers       func(outcomes *zoneOutcomes) []zoneScorer
+		expectedZones []string
+	}{
+		{
+			name:          "Random without scorers",
+			scorers:       func(*zoneOutcomes) []zoneScorer { return nil },
+			expectedZones: zones,
+		},
+		{
+			name:          "Most free node slots",
+			scorers:       func(o *zoneOutcomes) []zoneScorer { return []zoneScorer{o.score, freeNodeSlots(zoneToSubnet)} },
+			expectedZones: []string{"ru-central1-b"},
+		},
+		{
+			name:          "Zone out of capacity is avoided",
+			outcomes:      map[string]bool{"ru-central1-b": false},
+			scorers:       func(o *zoneOutcomes) []zoneScorer { return []zoneScorer{o.score, freeNodeSlots(zoneToSubnet)} },
+			expectedZones: []string{"ru-central1-d"},
+		},
+		{
+			name:          "Zone with a recent create is preferred over free node slots",
+			outcomes:      map[string]bool{"ru-central1-a": true, "ru-central1-b": false},
+			scorers:       func(o *zoneOutcomes) []zoneScorer { return []zoneScorer{o.score, freeNodeSlots(zoneToSubnet)} },
+			expectedZones: []string{"ru-central1-a"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			outcomes := newZoneOutcomes()
+			for zone, succeeded := range tc.outcomes {
+				outcomes.record(zone, succeeded)
+			}
+			r := newRand(1)
+			picked := sets.New[string]()
+			for range 50 {
+				picked.Insert(selectOffering(offerings, tc.scorers(outcomes), r).Zone())
+			}
+			if !picked.Equal(sets.New(tc.expectedZones...)) {
+				t.Errorf("Expected offerings in zones %v, got %v", tc.expectedZones, sets.List(picked))
+			}
+		})
+	}
+}
+
+func TestCreate_AvailabilityFirst(t *testing.T) {
+	nodeClass := newTestNodeClass()
+	nodeClass.Spec.PlacementStrategy = &v1alpha1.PlacementStrategy{ZoneBalance: v1alpha1.ZoneBalanceAvailabilityFirst}
+	cp, sdk := newTestCloudProvider(t, []*cloudprovider.InstanceType{newTestInstanceType(newTestInstanceTypeInfo("2", "4Gi"), 1)}, nodeClass, newTestNodePool())
+	cp.subnets = &testSubnetProvider{subnets: []subnet.Subnet{
+		{ID: "subnet-a", ZoneID: "ru-central1-a", AvailableIPAddressCount: 50, AvailableNodeSlots: 50},
+		{ID: "subnet-b", ZoneID: "ru-central1-b", AvailableIPAddressCount: 200, AvailableNodeSlots: 200},
+	}}
+	create := func(name string) error {
+		t.Helper()
+		nodeClaim := newTestNodeClaim(corev1.ResourceList{})
+		nodeClaim.Name = name
+		_, err := cp.Create(context.Background(), nodeClaim)
+		return err
+	}
+
+	for i := range 5 {
+		if err := create(fmt.Sprintf("default-%d", i)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if zone := sdk.CreateFixedNodeGroupInputs[i].ZoneId; zone != "ru-central1-b" {
+			t.Fatalf("Expected node group %d in the zone with the most free IPs, got %s", i, zone)
+		}
+	}
+
+	// the zone runs out of capacity, the next node goes to the other zone despite its fewer free IPs
+	sdk.CreateFixedNodeGroupError = grpcstatus.Error(codes.ResourceExhausted, "not enough resources in zone")
+	if err := create("default-exhausted"); !cloudprovider.IsInsufficientCapacityError(err) {
+		t.Fatalf("Expected an insufficient capacity error, got %v", err)
+	}
+	sdk.CreateFixedNodeGroupError = nil
+	if err := create("default-retry"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if zone := sdk.CreateFixedNodeGroupInputs[len(sdk.CreateFixedNodeGroupInputs)-1].ZoneId; zone != "ru-central1-a" {
+		t.Errorf("Expected the node group in the zone without a capacity failure, got %s", zone)
+	}
+}
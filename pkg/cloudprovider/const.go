@@ -24,4 +24,10 @@ const (
 	ImageVersionDrift cloudprovider.DriftReason = "ImageVersionDrift"
 	PlatformDrift     cloudprovider.DriftReason = "PlatformDrift"
 	CapacityDrift     cloudprovider.DriftReason = "CapacityDrift"
-)
\ No newline at end of file
+	// NodeClassHashChangedDrift is reported for NodeClaims launched with an older nodeclass spec
+	NodeClassHashChangedDrift cloudprovider.DriftReason = "NodeClassHashChanged"
+	// ExpiredNodeDrift is reported for nodes older than the maximum node age of their nodeclass
+	ExpiredNodeDrift cloudprovider.DriftReason = "ExpiredNode"
+	// SecurityGroupDrift is reported for node groups whose security groups were changed outside of their nodeclass
+	SecurityGroupDrift cloudprovider.DriftReason = "SecurityGroupDrift"
+)
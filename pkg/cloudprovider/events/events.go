@@ -15,6 +15,9 @@ limitations under the License.
 package events
 
 import (
+	"fmt"
+	"strings"
+
 	corev1 "k8s.io/api/core/v1"
 
 	v1 "sigs.k8s.io/karpenter/pkg/apis/v1"
@@ -38,3 +41,28 @@ func NodeClaimFailedToResolveNodeClass(nodeClaim *v1.NodeClaim) events.Event {
 		DedupeValues:   []string{string(nodeClaim.UID)},
 	}
 }
+
+func NodeClaimCreationRejected(nodeClaim *v1.NodeClaim, message string) events.Event {
+	return events.Event{
+		InvolvedObject: nodeClaim,
+		Type:           corev1.EventTypeWarning,
+		Reason:         "CreationRejected",
+		Message:        "Yandex Cloud rejected the node group: " + message,
+		DedupeValues:   []string{string(nodeClaim.UID)},
+	}
+}
+
+// NodePoolZoneCapacityExhausted names the cheapest of the instance types that no zone was left to launch in
+func NodePoolZoneCapacityExhausted(nodePool *v1.NodePool, instanceTypes []string, zones []string) events.Event {
+	instanceType := instanceTypes[0]
+	if len(instanceTypes) > 1 {
+		instanceType = fmt.Sprintf("%s and %d other instance types", instanceType, len(instanceTypes)-1)
+	}
+	return events.Event{
+		InvolvedObject: nodePool,
+		Type:           corev1.EventTypeWarning,
+		Reason:         "ZoneCapacityExhausted",
+		Message:        fmt.Sprintf("No zone left to launch %s in, attempted zones %s", instanceType, strings.Join(zones, ", ")),
+		DedupeValues:   []string{string(nodePool.UID)},
+	}
+}
@@ -0,0 +1,173 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yandex
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tufitko/karpenter-provider-yandex/pkg/apis/v1alpha1"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/fake"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/yandex"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/k8s/v1"
+	ycsdk "github.com/yandex-cloud/go-sdk"
+	corev1 "k8s.io/api/core/v1"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/cloudprovider"
+)
+
+// newTestAPICloudProvider returns a cloud provider using the real SDK client against a local fake of the Yandex Cloud API
+func newTestAPICloudProvider(t *testing.T, instanceTypes []*cloudprovider.InstanceType) (*CloudProvider, *fake.API) {
+	t.Helper()
+
+	api := fake.NewAPI(&k8s.Cluster{
+		Id:       "test-cluster",
+		FolderId: "test-folder",
+		Status:   k8s.Cluster_RUNNING,
+	})
+	endpoint, err := api.Start()
+	if err != nil {
+		t.Fatalf("Failed to start the API: %v", err)
+	}
+	t.Cleanup(api.Stop)
+
+	sdk, err := yandex.NewSDKWithConfig(context.Background(), ycsdk.Config{
+		Credentials: ycsdk.NewIAMTokenCredentials("test-token"),
+		Endpoint:    endpoint,
+		Plaintext:   true,
+	}, "test-cluster", "")
+	if err != nil {
+		t.Fatalf("Failed to create the SDK: %v", err)
+	}
+
+	cp := newTestCloudProviderWithSDK(t, sdk, &testInstanceTypeProvider{instanceTypes: instanceTypes}, newTestNodeClass(), newTestNodePool())
+	return cp, api
+}
+
+func TestLifecycle(t *testing.T) {
+	info := newTestInstanceTypeInfo("2", "4Gi")
+	cp, api := newTestAPICloudProvider(t, []*cloudprovider.InstanceType{newTestInstanceType(info, 1)})
+	ctx := context.Background()
+
+	created, err := cp.Create(ctx, newTestNodeClaim(corev1.ResourceList{}))
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	if api.Calls("/yandex.cloud.k8s.v1.NodeGroupService/Create") != 1 {
+		t.Errorf("Create: expected 1 node group create call, got %d", api.Calls("/yandex.cloud.k8s.v1.NodeGroupService/Create"))
+	}
+	if len(api.NodeGroups) != 1 {
+		t.Fatalf("Create: expected 1 node group, got %d", len(api.NodeGroups))
+	}
+	nodeGroupId := created.Labels[v1alpha1.LabelYandexNodeGroupID]
+	ng, ok := api.NodeGroups[nodeGroupId]
+	if !ok {
+		t.Fatalf("Create: node group %q of the NodeClaim does not exist", nodeGroupId)
+	}
+	if ng.GetLabels()["managed-by"] != "karpenter" || ng.GetLabels()[karpv1.NodePoolLabelKey] != testNodePool {
+		t.Errorf("Create: expected the node group to be labeled for karpenter and the nodepool, got %v", ng.GetLabels())
+	}
+	if ng.GetNodeTemplate().GetResourcesSpec().GetCores() != 2 {
+		t.Errorf("Create: expected 2 cores, got %d", ng.GetNodeTemplate().GetResourcesSpec().GetCores())
+	}
+	if created.Status.ProviderID == "" {
+		t.Fatalf("Create: expected a provider id")
+	}
+
+	got, err := cp.Get(ctx, created.Status.ProviderID)
+	if err != nil {
+		t.Fatalf("Get: unexpected error: %v", err)
+	}
+	if got.Labels[corev1.LabelInstanceTypeStable] != info.String() {
+		t.Errorf("Get: expected instance type %s, got %q", info.String(), got.Labels[corev1.LabelInstanceTypeStable])
+	}
+	if got.Labels[v1alpha1.LabelInstancePlatformName] != info.Platform.Name() {
+		t.Errorf("Get: expected platform name %s, got %q", info.Platform.Name(), got.Labels[v1alpha1.LabelInstancePlatformName])
+	}
+	if got.Labels[karpv1.NodePoolLabelKey] != testNodePool {
+		t.Errorf("Get: expected nodepool %s, got %q", testNodePool, got.Labels[karpv1.NodePoolLabelKey])
+	}
+	if got.Labels[corev1.LabelTopologyZone] != ng.GetAllocationPolicy().GetLocations()[0].GetZoneId() {
+		t.Errorf("Get: expected the zone of the node group, got %q", got.Labels[corev1.LabelTopologyZone])
+	}
+
+	listed, err := cp.List(ctx)
+	if err != nil {
+		t.Fatalf("List: unexpected error: %v", err)
+	}
+	if len(listed) != 1 || listed[0].Status.ProviderID != created.Status.ProviderID {
+		t.Errorf("List: expected the created NodeClaim, got %v", listed)
+	}
+
+	if err := cp.Delete(ctx, created); err != nil {
+		t.Fatalf("Delete: unexpected error: %v", err)
+	}
+	if len(api.NodeGroups) != 0 {
+		t.Errorf("Delete: expected the node group to be deleted, got %d node groups", len(api.NodeGroups))
+	}
+
+	if _, err := cp.Get(ctx, created.Status.ProviderID); !cloudprovider.IsNodeClaimNotFoundError(err) {
+		t.Errorf("Get after delete: expected NodeClaimNotFoundError, got %v", err)
+	}
+	if err := cp.Delete(ctx, created); !cloudprovider.IsNodeClaimNotFoundError(err) {
+		t.Errorf("Delete after delete: expected NodeClaimNotFoundError, got %v", err)
+	}
+	listed, err = cp.List(ctx)
+	if err != nil {
+		t.Fatalf("List after delete: unexpected error: %v", err)
+	}
+	if len(listed) != 0 {
+		t.Errorf("List after delete: expected no NodeClaims, got %d", len(listed))
+	}
+}
+
+func TestLifecycle_SafeDelete(t *testing.T) {
+	info := newTestInstanceTypeInfo("2", "4Gi")
+	cp, api := newTestAPICloudProvider(t, []*cloudprovider.InstanceType{newTestInstanceType(info, 1)})
+	cp.safeDelete = true
+	ctx := context.Background()
+
+	created, err := cp.Create(ctx, newTestNodeClaim(corev1.ResourceList{}))
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	ng := api.NodeGroups[created.Labels[v1alpha1.LabelYandexNodeGroupID]]
+	if ng.GetDeployPolicy().GetMaxUnavailable() != 0 {
+		t.Fatalf("Create: expected MaxUnavailable 0, got %d", ng.GetDeployPolicy().GetMaxUnavailable())
+	}
+
+	// the node never registered, so there is nothing to drain and the node group goes with its only node
+	if err := cp.Delete(ctx, created); err != nil {
+		t.Fatalf("Delete: unexpected error: %v", err)
+	}
+	if len(api.NodeGroups) != 0 || len(api.Instances) != 0 {
+		t.Errorf("Delete: expected the node group and its instance to be deleted, got %d node groups and %d instances", len(api.NodeGroups), len(api.Instances))
+	}
+	if calls := api.Calls("/yandex.cloud.k8s.v1.NodeGroupService/Update"); calls != 0 {
+		t.Errorf("Delete: expected the node group not to be scaled down before deletion, got %d update calls", calls)
+	}
+}
+
+func TestLifecycle_ListWithoutFolder(t *testing.T) {
+	cp, api := newTestAPICloudProvider(t, nil)
+	api.Cluster.FolderId = ""
+
+	if _, err := cp.List(context.Background()); err == nil {
+		t.Fatal("List: expected an error for a cluster without a folder id")
+	}
+	if calls := api.Calls("/yandex.cloud.k8s.v1.NodeGroupService/List"); calls != 0 {
+		t.Errorf("List: expected node groups not to be listed without a folder, got %d list calls", calls)
+	}
+}
@@ -0,0 +1,50 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yandex
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// createLimiter bounds the number of node group creates in flight, so that a provisioning burst is spread out
+// instead of hitting the Yandex Cloud API rate limits. A limit below 1 does not bound creates
+type createLimiter struct {
+	slots   chan struct{}
+	waiting atomic.Int64
+}
+
+func newCreateLimiter(limit int) *createLimiter {
+	if limit < 1 {
+		return &createLimiter{}
+	}
+	return &createLimiter{slots: make(chan struct{}, limit)}
+}
+
+// acquire waits for a free slot and returns the function releasing it
+func (l *createLimiter) acquire(ctx context.Context) (func(), error) {
+	if l.slots == nil {
+		return func() {}, nil
+	}
+	NodeGroupCreateQueueDepth.Set(float64(l.waiting.Add(1)), nil)
+	defer func() { NodeGroupCreateQueueDepth.Set(float64(l.waiting.Add(-1)), nil) }()
+
+	select {
+	case l.slots <- struct{}{}:
+		return func() { <-l.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
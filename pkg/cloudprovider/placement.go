@@ -0,0 +1,102 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yandex
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/samber/lo"
+	"sigs.k8s.io/karpenter/pkg/cloudprovider"
+
+	"github.com/tufitko/karpenter-provider-yandex/pkg/apis/v1alpha1"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/providers/subnet"
+)
+
+// zoneOutcomeTTL is how long the outcome of a create is remembered for the zone it was launched in
+const zoneOutcomeTTL = 10 * time.Minute
+
+// zoneScorer scores a zone new nodes can be launched in, zones with a higher score are preferred
+type zoneScorer func(zone string) int
+
+// zoneOutcomes remembers whether the latest create in every zone succeeded or ran out of capacity
+type zoneOutcomes struct {
+	cache *cache.Cache
+}
+
+func newZoneOutcomes() *zoneOutcomes {
+	return &zoneOutcomes{cache: cache.New(zoneOutcomeTTL, time.Minute)}
+}
+
+func (z *zoneOutcomes) record(zone string, succeeded bool) {
+	z.cache.SetDefault(zone, succeeded)
+}
+
+// score prefers zones where the latest create succeeded over zones without a recent create, and those over zones
+// that recently ran out of capacity
+func (z *zoneOutcomes) score(zone string) int {
+	succeeded, ok := z.cache.Get(zone)
+	switch {
+	case !ok:
+		return 0
+	case succeeded.(bool):
+		return 1
+	default:
+		return -1
+	}
+}
+
+// mostFreeSubnets maps every zone to its subnet with room for the most nodes, the first one listed wins ties
+func mostFreeSubnets(subnets []subnet.Subnet) map[string]subnet.Subnet {
+	zoneToSubnet := make(map[string]subnet.Subnet)
+	for _, s := range subnets {
+		if current, ok := zoneToSubnet[s.ZoneID]; !ok || s.AvailableNodeSlots > current.AvailableNodeSlots {
+			zoneToSubnet[s.ZoneID] = s
+		}
+	}
+	return zoneToSubnet
+}
+
+// freeNodeSlots scores zones by the nodes that still fit into the subnet nodes are launched into there
+func freeNodeSlots(zoneToSubnet map[string]subnet.Subnet) zoneScorer {
+	return func(zone string) int {
+		return zoneToSubnet[zone].AvailableNodeSlots
+	}
+}
+
+// zoneScorers returns the scorers of the placement strategy in order of precedence
+func (c CloudProvider) zoneScorers(strategy *v1alpha1.PlacementStrategy, zoneToSubnet map[string]subnet.Subnet) []zoneScorer {
+	switch strategy.ZoneBalanceOrDefault() {
+	case v1alpha1.ZoneBalanceAvailabilityFirst:
+		return []zoneScorer{c.zoneOutcomes.score, freeNodeSlots(zoneToSubnet)}
+	default:
+		return nil
+	}
+}
+
+// selectOffering picks the offering to launch a node with. Zones are narrowed down by every scorer in turn and the
+// offering is picked at random among the best ones left, so that a random zone is only the last resort
+func selectOffering(offerings cloudprovider.Offerings, scorers []zoneScorer, r *rand.Rand) *cloudprovider.Offering {
+	for _, score := range scorers {
+		best := lo.Max(lo.Map(offerings, func(off *cloudprovider.Offering, _ int) int {
+			return score(off.Zone())
+		}))
+		offerings = lo.Filter(offerings, func(off *cloudprovider.Offering, _ int) bool {
+			return score(off.Zone()) == best
+		})
+	}
+	return offerings[r.Intn(len(offerings))]
+}
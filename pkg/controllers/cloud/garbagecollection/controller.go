@@ -8,28 +8,49 @@ import (
 
 	"github.com/awslabs/operatorpkg/reconciler"
 	"github.com/awslabs/operatorpkg/singleton"
+	"github.com/samber/lo"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/apis/v1alpha1"
+	nodeclaimutils "github.com/tufitko/karpenter-provider-yandex/pkg/utils/nodeclaim"
 	"github.com/tufitko/karpenter-provider-yandex/pkg/yandex"
 	"github.com/yandex-cloud/go-genproto/yandex/cloud/k8s/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/utils/clock"
 	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
 	"sigs.k8s.io/karpenter/pkg/operator/injection"
 )
 
-// Controller deletes duplicated node groups from cloudprovider
+// Controller deletes duplicated node groups, and node groups whose NodeClaim is gone, from cloudprovider
 type Controller struct {
-	clk clock.Clock
-	sdk yandex.SDK
+	clk        clock.Clock
+	kubeClient client.Client
+	sdk        yandex.SDK
+	// gracePeriod is how old a node group must be before it is deleted as a duplicate, a node group that was just
+	// created can report ALREADY_EXISTS for a moment
+	gracePeriod time.Duration
+	// orphanGracePeriod is how old a node group without a NodeClaim must be before it is deleted, the NodeClaim of a
+	// node group always exists before the node group is created, the grace period covers a NodeClaim cache that lags
+	// behind
+	orphanGracePeriod time.Duration
 }
 
 func NewController(
 	clk clock.Clock,
+	kubeClient client.Client,
 	sdk yandex.SDK,
+	gracePeriod time.Duration,
+	orphanGracePeriod time.Duration,
 ) *Controller {
 	return &Controller{
-		clk: clk,
-		sdk: sdk,
+		clk:               clk,
+		kubeClient:        kubeClient,
+		sdk:               sdk,
+		gracePeriod:       gracePeriod,
+		orphanGracePeriod: orphanGracePeriod,
 	}
 }
 
@@ -43,11 +64,26 @@ func (c *Controller) Reconcile(ctx context.Context) (reconciler.Result, error) {
 		return reconciler.Result{}, fmt.Errorf("listing node groups: %w", err)
 	}
 
+	// node groups are named after their NodeClaims, or carry the NodeClaim that reuses them in its node group id label
+	nodeClaims := &karpv1.NodeClaimList{}
+	if err = c.kubeClient.List(ctx, nodeClaims); err != nil {
+		return reconciler.Result{}, fmt.Errorf("listing nodeclaims: %w", err)
+	}
+	nodeClaimNames := sets.New(lo.Map(nodeClaims.Items, func(nc karpv1.NodeClaim, _ int) string { return nc.Name })...)
+	nodeGroupIDs := sets.New(lo.FilterMap(nodeClaims.Items, func(nc karpv1.NodeClaim, _ int) (string, bool) {
+		id := nc.Labels[v1alpha1.LabelYandexNodeGroupID]
+		return id, id != ""
+	})...)
+
 	for _, nodeGroup := range nodeGroups {
 		ctx2 := log.IntoContext(ctx, log.FromContext(ctx).WithValues(
 			"nodeGroupId", nodeGroup.Id,
 			"nodeGroupName", nodeGroup.Name,
 		))
+		if !nodeClaimNames.Has(nodeGroup.Name) && !nodeGroupIDs.Has(nodeGroup.Id) {
+			c.deleteOrphan(ctx2, nodeGroup)
+			continue
+		}
 		node, err2 := c.sdk.GetNodeFromNodeGroup(ctx2, nodeGroup.Id)
 		if err2 != nil {
 			log.FromContext(ctx2).Error(err2, "failed to get node from node group")
@@ -63,6 +99,10 @@ func (c *Controller) Reconcile(ctx context.Context) (reconciler.Result, error) {
 		if !strings.Contains(node.CloudStatus.GetStatusMessage(), "ALREADY_EXISTS") {
 			continue
 		}
+		if age := c.clk.Since(nodeGroup.GetCreatedAt().AsTime()); age < c.gracePeriod {
+			log.FromContext(ctx2).V(1).Info("skipping duplicated node group within the grace period", "age", age)
+			continue
+		}
 
 		err2 = c.sdk.DeleteNodeGroup(ctx2, nodeGroup.Id)
 		if err2 != nil {
@@ -76,6 +116,34 @@ func (c *Controller) Reconcile(ctx context.Context) (reconciler.Result, error) {
 	return reconciler.Result{RequeueAfter: time.Minute * 10}, nil
 }
 
+// deleteOrphan deletes a node group without a NodeClaim once it is older than orphanGracePeriod. The NodeClaims are read
+// again right before, so that a NodeClaim created or labelled after the NodeClaims were listed keeps its node group
+func (c *Controller) deleteOrphan(ctx context.Context, nodeGroup *k8s.NodeGroup) {
+	if nodeGroup.Status == k8s.NodeGroup_DELETING || c.clk.Since(nodeGroup.GetCreatedAt().AsTime()) < c.orphanGracePeriod {
+		return
+	}
+	err := c.kubeClient.Get(ctx, client.ObjectKey{Name: nodeGroup.Name}, &karpv1.NodeClaim{})
+	if !errors.IsNotFound(err) {
+		if err != nil {
+			log.FromContext(ctx).Error(err, "failed to get nodeclaim of node group")
+		}
+		return
+	}
+	owners, err := nodeclaimutils.ListByNodeGroupID(ctx, c.kubeClient, nodeGroup.Id)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "failed to list nodeclaims of node group")
+		return
+	}
+	if len(owners) > 0 {
+		return
+	}
+	if err = c.sdk.DeleteNodeGroup(ctx, nodeGroup.Id); err != nil {
+		log.FromContext(ctx).Error(err, "failed to delete node group")
+		return
+	}
+	log.FromContext(ctx).Info("delete node group without nodeclaim")
+}
+
 func (c *Controller) Register(_ context.Context, m manager.Manager) error {
 	return controllerruntime.NewControllerManagedBy(m).
 		Named("cloud.garbagecollection").
@@ -0,0 +1,153 @@
+package garbagecollection
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tufitko/karpenter-provider-yandex/pkg/apis/v1alpha1"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/fake"
+	nodeclaimutils "github.com/tufitko/karpenter-provider-yandex/pkg/utils/nodeclaim"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/k8s/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	clocktesting "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+const orphanGracePeriod = 5 * time.Minute
+
+func newTestController(clk *clocktesting.FakeClock, sdk *fake.SDK, objects ...client.Object) (*Controller, client.Client) {
+	// karpenter registers its types into the client-go scheme on init
+	kubeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objects...).
+		WithIndex(&karpv1.NodeClaim{}, nodeclaimutils.NodeGroupIDIndex, nodeclaimutils.NodeGroupIDIndexFunc).
+		Build()
+	return NewController(clk, kubeClient, sdk, 2*time.Minute, orphanGracePeriod), kubeClient
+}
+
+func newTestNodeClaim(name string) *karpv1.NodeClaim {
+	return &karpv1.NodeClaim{ObjectMeta: metav1.ObjectMeta{Name: name}}
+}
+
+func TestReconcile_DuplicateGracePeriod(t *testing.T) {
+	clk := clocktesting.NewFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	sdk := fake.NewSDK()
+	sdk.NodeGroups["ng-duplicate"] = &k8s.NodeGroup{
+		Id:        "ng-duplicate",
+		Name:      "default-duplicate",
+		Status:    k8s.NodeGroup_PROVISIONING,
+		CreatedAt: timestamppb.New(clk.Now().Add(-30 * time.Second)),
+	}
+	sdk.Nodes["ng-duplicate"] = &k8s.Node{
+		CloudStatus: &k8s.Node_CloudStatus{
+			Status:        "CREATING_INSTANCE",
+			StatusMessage: "rpc error: code = AlreadyExists desc = ALREADY_EXISTS",
+		},
+	}
+	c, _ := newTestController(clk, sdk, newTestNodeClaim("default-duplicate"))
+
+	if _, err := c.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(sdk.DeletedNodeGroups) != 0 {
+		t.Fatalf("Expected the young node group to be kept, deleted %v", sdk.DeletedNodeGroups)
+	}
+
+	clk.Step(2 * time.Minute)
+	if _, err := c.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(sdk.DeletedNodeGroups) != 1 || sdk.DeletedNodeGroups[0] != "ng-duplicate" {
+		t.Errorf("Expected the node group to be deleted once past the grace period, deleted %v", sdk.DeletedNodeGroups)
+	}
+}
+
+func TestReconcile_OrphanedNodeGroups(t *testing.T) {
+	clk := clocktesting.NewFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	sdk := fake.NewSDK()
+	for _, name := range []string{"default-owned", "default-orphan"} {
+		sdk.NodeGroups["ng-"+name] = &k8s.NodeGroup{
+			Id:        "ng-" + name,
+			Name:      name,
+			Status:    k8s.NodeGroup_RUNNING,
+			CreatedAt: timestamppb.New(clk.Now()),
+		}
+		sdk.Nodes["ng-"+name] = &k8s.Node{CloudStatus: &k8s.Node_CloudStatus{Id: "instance-" + name, Status: "RUNNING"}}
+	}
+	c, kubeClient := newTestController(clk, sdk, newTestNodeClaim("default-owned"))
+
+	// a crashed create may have left the node group behind, it is only deleted once past the grace period
+	clk.Step(time.Minute)
+	if _, err := c.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(sdk.DeletedNodeGroups) != 0 {
+		t.Fatalf("Expected the young orphan to be kept, deleted %v", sdk.DeletedNodeGroups)
+	}
+
+	clk.Step(orphanGracePeriod)
+	if _, err := c.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(sdk.DeletedNodeGroups) != 1 || sdk.DeletedNodeGroups[0] != "ng-default-orphan" {
+		t.Fatalf("Expected only the orphaned node group to be deleted, deleted %v", sdk.DeletedNodeGroups)
+	}
+
+	// the NodeClaim of an in-flight create keeps its node group
+	if err := kubeClient.Create(context.Background(), newTestNodeClaim("default-inflight")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	sdk.NodeGroups["ng-default-inflight"] = &k8s.NodeGroup{
+		Id:        "ng-default-inflight",
+		Name:      "default-inflight",
+		Status:    k8s.NodeGroup_PROVISIONING,
+		CreatedAt: timestamppb.New(clk.Now().Add(-orphanGracePeriod)),
+	}
+	sdk.Nodes["ng-default-inflight"] = &k8s.Node{CloudStatus: &k8s.Node_CloudStatus{Status: "PROVISIONING"}}
+	if _, err := c.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(sdk.DeletedNodeGroups) != 1 {
+		t.Errorf("Expected node groups with NodeClaims to be kept, deleted %v", sdk.DeletedNodeGroups)
+	}
+}
+
+func TestReconcile_NodeGroupOwnedByID(t *testing.T) {
+	clk := clocktesting.NewFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	sdk := fake.NewSDK()
+	for _, name := range []string{"default-original", "default-listed-late"} {
+		sdk.NodeGroups["ng-"+name] = &k8s.NodeGroup{
+			Id:        "ng-" + name,
+			Name:      name,
+			Status:    k8s.NodeGroup_RUNNING,
+			CreatedAt: timestamppb.New(clk.Now().Add(-2 * orphanGracePeriod)),
+		}
+		sdk.Nodes["ng-"+name] = &k8s.Node{CloudStatus: &k8s.Node_CloudStatus{Id: "instance-" + name, Status: "RUNNING"}}
+	}
+	// the NodeClaim reusing the node group of another name carries its id
+	reusing := newTestNodeClaim("default-reusing")
+	reusing.Labels = map[string]string{v1alpha1.LabelYandexNodeGroupID: "ng-default-original"}
+	c, kubeClient := newTestController(clk, sdk, reusing)
+
+	// a NodeClaim labelled between listing and deleting keeps its node group too
+	lateListed := newTestNodeClaim("default-late")
+	lateListed.Labels = map[string]string{v1alpha1.LabelYandexNodeGroupID: "ng-default-listed-late"}
+	nodeGroup := sdk.NodeGroups["ng-default-listed-late"]
+	if err := kubeClient.Create(context.Background(), lateListed); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	c.deleteOrphan(context.Background(), nodeGroup)
+	if len(sdk.DeletedNodeGroups) != 0 {
+		t.Fatalf("Expected the node group of the late NodeClaim to be kept, deleted %v", sdk.DeletedNodeGroups)
+	}
+
+	if _, err := c.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(sdk.DeletedNodeGroups) != 0 {
+		t.Errorf("Expected node groups owned by id to be kept, deleted %v", sdk.DeletedNodeGroups)
+	}
+}
@@ -24,6 +24,9 @@ import (
 	cloudgarbagecollection "github.com/tufitko/karpenter-provider-yandex/pkg/controllers/cloud/garbagecollection"
 	"github.com/tufitko/karpenter-provider-yandex/pkg/controllers/nodeclaim/garbagecollection"
 	"github.com/tufitko/karpenter-provider-yandex/pkg/controllers/nodeclass"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/controllers/pricing"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/operator/options"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/providers/instancetype"
 	"github.com/tufitko/karpenter-provider-yandex/pkg/providers/subnet"
 	"github.com/tufitko/karpenter-provider-yandex/pkg/yandex"
 	"k8s.io/utils/clock"
@@ -39,12 +42,20 @@ func NewControllers(ctx context.Context,
 	cloudProvider cloudprovider.CloudProvider,
 	clk clock.Clock,
 	sdk yandex.SDK,
+	instanceTypeProvider instancetype.Provider,
 ) []controller.Controller {
 
 	controllers := []controller.Controller{
-		nodeclass.NewController(kubeClient, recorder, subnetProvider, validationCache, sdk, false),
-		garbagecollection.NewController(kubeClient, cloudProvider),
-		cloudgarbagecollection.NewController(clk, sdk),
+		nodeclass.NewController(kubeClient, recorder, subnetProvider, validationCache, sdk, clk, false, options.FromContext(ctx).Region),
+		garbagecollection.NewController(clk, kubeClient, cloudProvider),
+		cloudgarbagecollection.NewController(
+			clk,
+			kubeClient,
+			sdk,
+			options.FromContext(ctx).DuplicateGCGracePeriod,
+			options.FromContext(ctx).OrphanGCGracePeriod,
+		),
+		pricing.NewController(kubeClient, instanceTypeProvider),
 	}
 
 	return controllers
@@ -0,0 +1,125 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package garbagecollection
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	clocktesting "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/cloudprovider/fake"
+)
+
+const testProviderID = "yandex://instance-1"
+
+// testHarness wires the controller to a fake clock, a fake kube client and a fake cloudprovider,
+// so that time-based decisions can be driven by stepping the clock
+type testHarness struct {
+	clk           *clocktesting.FakeClock
+	kubeClient    client.Client
+	cloudProvider *fake.CloudProvider
+	controller    *Controller
+}
+
+func newTestHarness(t *testing.T, objects ...client.Object) *testHarness {
+	t.Helper()
+
+	clk := clocktesting.NewFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	kubeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objects...).Build()
+	cloudProvider := fake.NewCloudProvider()
+	return &testHarness{
+		clk:           clk,
+		kubeClient:    kubeClient,
+		cloudProvider: cloudProvider,
+		controller:    NewController(clk, kubeClient, cloudProvider),
+	}
+}
+
+// launch registers an instance in the fake cloudprovider as created at the current fake time
+func (h *testHarness) launch(providerID string) {
+	h.cloudProvider.CreatedNodeClaims[providerID] = &karpv1.NodeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "cloud-nodeclaim", CreationTimestamp: metav1.NewTime(h.clk.Now())},
+		Status:     karpv1.NodeClaimStatus{ProviderID: providerID},
+	}
+}
+
+func (h *testHarness) reconcile(t *testing.T) {
+	t.Helper()
+
+	if _, err := h.controller.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestGarbageCollection_OrphanGracePeriod(t *testing.T) {
+	testCases := []struct {
+		name          string
+		elapsed       time.Duration
+		expectDeleted bool
+	}{
+		{name: "Within grace period", elapsed: orphanGracePeriod - time.Second, expectDeleted: false},
+		{name: "At grace period", elapsed: orphanGracePeriod, expectDeleted: false},
+		{name: "Past grace period", elapsed: orphanGracePeriod + time.Second, expectDeleted: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+				Spec:       corev1.NodeSpec{ProviderID: testProviderID},
+			}
+			h := newTestHarness(t, node)
+			h.launch(testProviderID)
+
+			h.clk.Step(tc.elapsed)
+			h.reconcile(t)
+
+			if deleted := len(h.cloudProvider.DeleteCalls) > 0; deleted != tc.expectDeleted {
+				t.Errorf("Expected instance deleted=%v, got %v", tc.expectDeleted, deleted)
+			}
+			err := h.kubeClient.Get(context.Background(), client.ObjectKeyFromObject(node), &corev1.Node{})
+			if nodeDeleted := errors.IsNotFound(err); nodeDeleted != tc.expectDeleted {
+				t.Errorf("Expected node deleted=%v, got %v (%v)", tc.expectDeleted, nodeDeleted, err)
+			}
+		})
+	}
+}
+
+func TestGarbageCollection_KeepsInstancesWithNodeClaims(t *testing.T) {
+	nodeClaim := &karpv1.NodeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "default-abcde"},
+		Spec: karpv1.NodeClaimSpec{
+			NodeClassRef: &karpv1.NodeClassReference{Group: "karpenter.test.sh", Kind: "TestNodeClass", Name: "default"},
+		},
+		Status: karpv1.NodeClaimStatus{ProviderID: testProviderID},
+	}
+	h := newTestHarness(t, nodeClaim)
+	h.launch(testProviderID)
+
+	h.clk.Step(time.Hour)
+	h.reconcile(t)
+
+	if len(h.cloudProvider.DeleteCalls) != 0 {
+		t.Errorf("Expected instance with a NodeClaim to be kept, got %d delete calls", len(h.cloudProvider.DeleteCalls))
+	}
+}
@@ -32,6 +32,7 @@ import (
 	"github.com/samber/lo"
 	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/clock"
 	controllerruntime "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -62,15 +63,19 @@ func NewController(
 	subnetProvider subnet.Provider,
 	validationCache *cache.Cache,
 	sdk yandex.SDK,
+	clk clock.Clock,
 	disableDryRun bool,
+	region string,
 ) *Controller {
-	validation := NewValidationReconciler(kubeClient, validationCache, sdk, disableDryRun)
+	validation := NewValidationReconciler(kubeClient, recorder, validationCache, sdk, clk, disableDryRun, region)
 	return &Controller{
 		kubeClient: kubeClient,
 		recorder:   recorder,
 		validation: validation,
 		reconcilers: []reconcile.TypedReconciler[*v1alpha1.YandexNodeClass]{
+			NewHashReconciler(),
 			NewSubnetReconciler(subnetProvider),
+			NewSecurityGroupReconciler(sdk),
 			validation,
 		},
 	}
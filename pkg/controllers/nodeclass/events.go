@@ -34,6 +34,26 @@ func WaitingOnNodeClaimTerminationEvent(nodeClass *v1alpha1.YandexNodeClass, nam
 	}
 }
 
+func ValidationFailedEvent(nodeClass *v1alpha1.YandexNodeClass, reason, message string) events.Event {
+	return events.Event{
+		InvolvedObject: nodeClass,
+		Type:           corev1.EventTypeWarning,
+		Reason:         "ValidationFailed",
+		Message:        fmt.Sprintf("NodeClass failed validation, %s: %s", reason, message),
+		DedupeValues:   []string{string(nodeClass.UID), reason},
+	}
+}
+
+func ValidationSucceededEvent(nodeClass *v1alpha1.YandexNodeClass) events.Event {
+	return events.Event{
+		InvolvedObject: nodeClass,
+		Type:           corev1.EventTypeNormal,
+		Reason:         "ValidationSucceeded",
+		Message:        "NodeClass passed validation",
+		DedupeValues:   []string{string(nodeClass.UID)},
+	}
+}
+
 func PrettySlice[T any](s []T, maxItems int) string {
 	var sb strings.Builder
 	for i, elem := range s {
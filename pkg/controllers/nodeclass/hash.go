@@ -0,0 +1,34 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodeclass
+
+import (
+	"context"
+
+	"github.com/tufitko/karpenter-provider-yandex/pkg/apis/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Hash records the hash of the nodeclass spec, which NodeClaims launched with an older spec drift from
+type Hash struct{}
+
+func NewHashReconciler() *Hash {
+	return &Hash{}
+}
+
+func (h *Hash) Reconcile(_ context.Context, nodeClass *v1alpha1.YandexNodeClass) (reconcile.Result, error) {
+	nodeClass.Status.SpecHash = nodeClass.Hash()
+	return reconcile.Result{}, nil
+}
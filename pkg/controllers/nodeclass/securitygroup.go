@@ -0,0 +1,64 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodeclass
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tufitko/karpenter-provider-yandex/pkg/apis/v1alpha1"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/yandex"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+type SecurityGroup struct {
+	sdk yandex.SDK
+}
+
+func NewSecurityGroupReconciler(sdk yandex.SDK) *SecurityGroup {
+	return &SecurityGroup{
+		sdk: sdk,
+	}
+}
+
+func (s *SecurityGroup) Reconcile(ctx context.Context, nodeClass *v1alpha1.YandexNodeClass) (reconcile.Result, error) {
+	var found, missing []string
+	for _, sgID := range nodeClass.Spec.SecurityGroups {
+		ok, err := s.sdk.SecurityGroupExists(ctx, sgID)
+		if err != nil {
+			return reconcile.Result{}, fmt.Errorf("getting security group %s, %w", sgID, err)
+		}
+		if !ok {
+			missing = append(missing, sgID)
+			continue
+		}
+		found = append(found, sgID)
+	}
+	// the validation reads the resolved security groups from the status instead of looking them up again
+	nodeClass.Status.SecurityGroups = found
+	if len(missing) > 0 {
+		nodeClass.StatusConditions().SetFalse(
+			v1alpha1.ConditionTypeSecurityGroupsReady,
+			"SecurityGroupsNotFound",
+			fmt.Sprintf("SecurityGroups not found or not in the cluster network, %s", PrettySlice(missing, 5)),
+		)
+		// Security groups may be created or moved later, so we need to reprocess the information.
+		return reconcile.Result{RequeueAfter: time.Minute}, nil
+	}
+
+	nodeClass.StatusConditions().SetTrue(v1alpha1.ConditionTypeSecurityGroupsReady)
+	return reconcile.Result{RequeueAfter: time.Minute}, nil
+}
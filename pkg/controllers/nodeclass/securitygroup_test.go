@@ -0,0 +1,164 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodeclass
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/tufitko/karpenter-provider-yandex/pkg/apis/v1alpha1"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/fake"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/yandex"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/karpenter/pkg/events"
+)
+
+func TestSecurityGroupReconciler(t *testing.T) {
+	testCases := []struct {
+		name           string
+		securityGroups []string
+		existing       []string
+		expectReady    bool
+		expectReason   string
+		expectResolved []string
+	}{
+		{
+			name:        "No security groups",
+			expectReady: true,
+		},
+		{
+			name:           "All security groups present",
+			securityGroups: []string{"sg-1", "sg-2"},
+			existing:       []string{"sg-1", "sg-2"},
+			expectReady:    true,
+			expectResolved: []string{"sg-1", "sg-2"},
+		},
+		{
+			name:           "Missing security group",
+			securityGroups: []string{"sg-1", "sg-missing"},
+			existing:       []string{"sg-1"},
+			expectReady:    false,
+			expectReason:   "SecurityGroupsNotFound",
+			expectResolved: []string{"sg-1"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sdk := fake.NewSDK()
+			for _, sg := range tc.existing {
+				sdk.SecurityGroups[sg] = true
+			}
+			nodeClass := &v1alpha1.YandexNodeClass{
+				Spec: v1alpha1.YandexNodeClassSpec{SecurityGroups: tc.securityGroups},
+			}
+
+			if _, err := NewSecurityGroupReconciler(sdk).Reconcile(context.Background(), nodeClass); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			cond := nodeClass.StatusConditions().Get(v1alpha1.ConditionTypeSecurityGroupsReady)
+			if cond.IsTrue() != tc.expectReady {
+				t.Errorf("Expected SecurityGroupsReady=%v, got %s", tc.expectReady, cond.Status)
+			}
+			if !tc.expectReady && cond.Reason != tc.expectReason {
+				t.Errorf("Expected reason %q, got %q", tc.expectReason, cond.Reason)
+			}
+			if !slices.Equal(nodeClass.Status.SecurityGroups, tc.expectResolved) {
+				t.Errorf("Expected status security groups %v, got %v", tc.expectResolved, nodeClass.Status.SecurityGroups)
+			}
+		})
+	}
+}
+
+func TestSecurityGroupReconciler_LookupError(t *testing.T) {
+	sdk := fake.NewSDK()
+	sdk.SecurityGroupExistsError = fmt.Errorf("unavailable")
+	nodeClass := &v1alpha1.YandexNodeClass{
+		Spec: v1alpha1.YandexNodeClassSpec{SecurityGroups: []string{"sg-1"}},
+	}
+
+	if _, err := NewSecurityGroupReconciler(sdk).Reconcile(context.Background(), nodeClass); err == nil {
+		t.Fatalf("Expected an error when the security group lookup fails")
+	}
+}
+
+func TestValidation_AwaitsSecurityGroups(t *testing.T) {
+	nodeClass := newTestNodeClass()
+	nodeClass.StatusConditions().SetFalse(v1alpha1.ConditionTypeSecurityGroupsReady, "SecurityGroupsNotFound", "not found")
+
+	sdk := newTestSDK()
+	v := NewValidationReconciler(nil, events.NewRecorder(record.NewFakeRecorder(100)), nil, sdk, nil, false, yandex.RegionRU)
+	if _, err := v.Reconcile(context.Background(), nodeClass); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	cond := nodeClass.StatusConditions().Get(v1alpha1.ConditionTypeValidationSucceeded)
+	if !cond.IsFalse() || cond.Reason != ConditionReasonDependenciesNotReady {
+		t.Errorf("Expected ValidationSucceeded=False with reason %s, got %s/%s", ConditionReasonDependenciesNotReady, cond.Status, cond.Reason)
+	}
+	if sdk.Calls("SecurityGroupExists") != 0 {
+		t.Errorf("Expected validation not to look up security groups while they are not ready")
+	}
+}
+
+func TestValidation_ReadsResolvedSecurityGroups(t *testing.T) {
+	testCases := []struct {
+		name           string
+		securityGroups []string
+		resolved       []string
+		expectReason   string
+	}{
+		{
+			name:           "Resolved",
+			securityGroups: []string{"sg-1"},
+			resolved:       []string{"sg-1"},
+		},
+		{
+			name:           "Not resolved",
+			securityGroups: []string{"sg-1", "sg-2"},
+			resolved:       []string{"sg-1"},
+			expectReason:   "SecurityGroupNotFound",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeClass := newTestNodeClass()
+			nodeClass.Spec.SecurityGroups = tc.securityGroups
+			nodeClass.Status.SecurityGroups = tc.resolved
+
+			sdk := newTestSDK()
+			v := newTestValidation(sdk)
+			if _, err := v.Reconcile(context.Background(), nodeClass); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			cond := nodeClass.StatusConditions().Get(v1alpha1.ConditionTypeValidationSucceeded)
+			if tc.expectReason == "" && !cond.IsTrue() {
+				t.Errorf("Expected ValidationSucceeded=True, got %s/%s (%s)", cond.Status, cond.Reason, cond.Message)
+			}
+			if tc.expectReason != "" && cond.Reason != tc.expectReason {
+				t.Errorf("Expected reason %q, got %q", tc.expectReason, cond.Reason)
+			}
+			// the security groups are resolved once by the security group reconciler
+			if sdk.Calls("SecurityGroupExists") != 0 {
+				t.Errorf("Expected validation not to look up security groups, got %d calls", sdk.Calls("SecurityGroupExists"))
+			}
+		})
+	}
+}
@@ -16,7 +16,10 @@ package nodeclass
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 	"time"
 
@@ -24,14 +27,25 @@ import (
 	"github.com/patrickmn/go-cache"
 	"github.com/samber/lo"
 	"github.com/tufitko/karpenter-provider-yandex/pkg/apis/v1alpha1"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/providers/instancetype"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/providers/subnet"
 	"github.com/tufitko/karpenter-provider-yandex/pkg/yandex"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/utils/clock"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/karpenter/pkg/events"
 )
 
 const (
 	requeueAfterTime                          = 10 * time.Minute
+	validationFailureTTL                      = time.Minute
 	ConditionReasonDependenciesNotReady       = "DependenciesNotReady"
 	MB                                  int64 = 1 << 20
 	GB                                  int64 = 1 << 30
@@ -43,9 +57,13 @@ const (
 
 type Validation struct {
 	kubeClient     client.Client
+	recorder       events.Recorder
 	cache          *cache.Cache
 	sdk            yandex.SDK
+	clk            clock.Clock
 	dryRunDisabled bool
+	// region is the Yandex Cloud region whose instance configurations nodeclass platforms must have
+	region string
 }
 
 type diskRules struct {
@@ -56,20 +74,47 @@ type diskRules struct {
 
 func NewValidationReconciler(
 	kubeClient client.Client,
+	recorder events.Recorder,
 	cache *cache.Cache,
 	sdk yandex.SDK,
+	clk clock.Clock,
 	dryRunDisabled bool,
+	region string,
 ) *Validation {
 	return &Validation{
 		kubeClient:     kubeClient,
+		recorder:       recorder,
 		cache:          cache,
 		sdk:            sdk,
+		clk:            clk,
 		dryRunDisabled: dryRunDisabled,
+		region:         region,
 	}
 }
 
-// nolint:gocyclo
+// Reconcile validates the nodeclass and publishes an event whenever it turns from valid to invalid or back
 func (v *Validation) Reconcile(ctx context.Context, nodeClass *v1alpha1.YandexNodeClass) (reconcile.Result, error) {
+	wasValid := nodeClass.StatusConditions().Get(v1alpha1.ConditionTypeValidationSucceeded).IsTrue()
+	wasInvalid := nodeClass.StatusConditions().Get(v1alpha1.ConditionTypeValidationSucceeded).IsFalse()
+
+	res, err := v.reconcile(ctx, nodeClass)
+
+	cond := nodeClass.StatusConditions().Get(v1alpha1.ConditionTypeValidationSucceeded)
+	if cond.IsFalse() && res.RequeueAfter > validationFailureTTL {
+		// failures are cached for a shorter time, recheck as soon as the cached failure expires
+		res.RequeueAfter = validationFailureTTL
+	}
+	switch {
+	case wasValid && cond.IsFalse():
+		v.recorder.Publish(ValidationFailedEvent(nodeClass, cond.Reason, cond.Message))
+	case wasInvalid && cond.IsTrue():
+		v.recorder.Publish(ValidationSucceededEvent(nodeClass))
+	}
+	return res, err
+}
+
+// nolint:gocyclo
+func (v *Validation) reconcile(ctx context.Context, nodeClass *v1alpha1.YandexNodeClass) (reconcile.Result, error) {
 	if _, ok := lo.Find(v.requiredConditions(), func(cond string) bool {
 		return nodeClass.StatusConditions().Get(cond).IsFalse()
 	}); ok {
@@ -96,9 +141,13 @@ func (v *Validation) Reconcile(ctx context.Context, nodeClass *v1alpha1.YandexNo
 
 	if val, ok := v.cache.Get(v.cacheKey(nodeClass)); ok {
 		// We still update the status condition even if it's cached since we may have had a conflict error previously
-		if val == "" {
+		cond := nodeClass.StatusConditions().Get(v1alpha1.ConditionTypeValidationSucceeded)
+		switch {
+		case val == "":
 			nodeClass.StatusConditions().SetTrue(v1alpha1.ConditionTypeValidationSucceeded)
-		} else {
+		case cond.IsFalse() && cond.Reason == val:
+			// keep the message of the failed validation
+		default:
 			nodeClass.StatusConditions().SetFalse(
 				v1alpha1.ConditionTypeValidationSucceeded,
 				val.(string),
@@ -110,7 +159,7 @@ func (v *Validation) Reconcile(ctx context.Context, nodeClass *v1alpha1.YandexNo
 
 	if v.dryRunDisabled {
 		nodeClass.StatusConditions().SetTrue(v1alpha1.ConditionTypeValidationSucceeded)
-		v.cache.SetDefault(v.cacheKey(nodeClass), "")
+		v.cacheSuccess(nodeClass)
 		return reconcile.Result{RequeueAfter: requeueAfterTime}, nil
 	}
 
@@ -120,7 +169,7 @@ func (v *Validation) Reconcile(ctx context.Context, nodeClass *v1alpha1.YandexNo
 			reason,
 			msg,
 		)
-		v.cache.SetDefault(v.cacheKey(nodeClass), reason)
+		v.cacheFailure(nodeClass, reason)
 		return reconcile.Result{RequeueAfter: requeueAfterTime}, nil
 	}
 
@@ -130,27 +179,119 @@ func (v *Validation) Reconcile(ctx context.Context, nodeClass *v1alpha1.YandexNo
 			reason,
 			msg,
 		)
-		v.cache.SetDefault(v.cacheKey(nodeClass), reason)
+		v.cacheFailure(nodeClass, reason)
+		return reconcile.Result{RequeueAfter: requeueAfterTime}, nil
+	}
+
+	if reason, msg := validateUserDataTemplate(nodeClass.Spec); reason != "" {
+		nodeClass.StatusConditions().SetFalse(
+			v1alpha1.ConditionTypeValidationSucceeded,
+			reason,
+			msg,
+		)
+		v.cacheFailure(nodeClass, reason)
+		return reconcile.Result{RequeueAfter: requeueAfterTime}, nil
+	}
+
+	if reason, msg := validateMetadataOptions(nodeClass.Spec); reason != "" {
+		nodeClass.StatusConditions().SetFalse(
+			v1alpha1.ConditionTypeValidationSucceeded,
+			reason,
+			msg,
+		)
+		v.cacheFailure(nodeClass, reason)
+		return reconcile.Result{RequeueAfter: requeueAfterTime}, nil
+	}
+
+	if reason, msg := validateTaints(nodeClass.Spec); reason != "" {
+		nodeClass.StatusConditions().SetFalse(
+			v1alpha1.ConditionTypeValidationSucceeded,
+			reason,
+			msg,
+		)
+		v.cacheFailure(nodeClass, reason)
+		return reconcile.Result{RequeueAfter: requeueAfterTime}, nil
+	}
+
+	if reason, msg := validateNodeAnnotations(nodeClass.Spec); reason != "" {
+		nodeClass.StatusConditions().SetFalse(
+			v1alpha1.ConditionTypeValidationSucceeded,
+			reason,
+			msg,
+		)
+		v.cacheFailure(nodeClass, reason)
+		return reconcile.Result{RequeueAfter: requeueAfterTime}, nil
+	}
+
+	if reason, msg := validateMaintenancePolicy(nodeClass.Spec); reason != "" {
+		nodeClass.StatusConditions().SetFalse(
+			v1alpha1.ConditionTypeValidationSucceeded,
+			reason,
+			msg,
+		)
+		v.cacheFailure(nodeClass, reason)
+		return reconcile.Result{RequeueAfter: requeueAfterTime}, nil
+	}
+
+	if reason, msg := validatePlatform(nodeClass.Spec, v.region); reason != "" {
+		nodeClass.StatusConditions().SetFalse(
+			v1alpha1.ConditionTypeValidationSucceeded,
+			reason,
+			msg,
+		)
+		v.cacheFailure(nodeClass, reason)
+		return reconcile.Result{RequeueAfter: requeueAfterTime}, nil
+	}
+
+	if reason, msg := validateGPUDriverVersion(nodeClass.Spec); reason != "" {
+		nodeClass.StatusConditions().SetFalse(
+			v1alpha1.ConditionTypeValidationSucceeded,
+			reason,
+			msg,
+		)
+		v.cacheFailure(nodeClass, reason)
 		return reconcile.Result{RequeueAfter: requeueAfterTime}, nil
 	}
 
 	if reason, msg := validateSubnetsExist(ctx, v.sdk, nodeClass); reason != "" {
 		nodeClass.StatusConditions().SetFalse(v1alpha1.ConditionTypeValidationSucceeded, reason, msg)
 		if shouldCacheValidationFailure(reason) {
-			v.cache.SetDefault(v.cacheKey(nodeClass), reason)
+			v.cacheFailure(nodeClass, reason)
 		}
 		return reconcile.Result{RequeueAfter: requeueAfterTime}, nil
 	}
 
-	if reason, msg := validateSecurityGroupsExist(ctx, v.sdk, nodeClass); reason != "" {
+	if reason, msg := validateZoneSubnets(ctx, v.sdk, nodeClass); reason != "" {
 		nodeClass.StatusConditions().SetFalse(v1alpha1.ConditionTypeValidationSucceeded, reason, msg)
 		if shouldCacheValidationFailure(reason) {
-			v.cache.SetDefault(v.cacheKey(nodeClass), reason)
+			v.cacheFailure(nodeClass, reason)
 		}
 		return reconcile.Result{RequeueAfter: requeueAfterTime}, nil
 	}
 
-	v.cache.SetDefault(v.cacheKey(nodeClass), "")
+	if reason, msg := validateReleaseChannel(ctx, v.sdk, nodeClass); reason != "" {
+		nodeClass.StatusConditions().SetFalse(v1alpha1.ConditionTypeValidationSucceeded, reason, msg)
+		if shouldCacheValidationFailure(reason) {
+			v.cacheFailure(nodeClass, reason)
+		}
+		return reconcile.Result{RequeueAfter: requeueAfterTime}, nil
+	}
+
+	if reason, msg := validateContainerRuntime(ctx, v.sdk, nodeClass); reason != "" {
+		nodeClass.StatusConditions().SetFalse(v1alpha1.ConditionTypeValidationSucceeded, reason, msg)
+		if shouldCacheValidationFailure(reason) {
+			v.cacheFailure(nodeClass, reason)
+		}
+		return reconcile.Result{RequeueAfter: requeueAfterTime}, nil
+	}
+
+	if reason, msg := validateSecurityGroupsResolved(nodeClass); reason != "" {
+		nodeClass.StatusConditions().SetFalse(v1alpha1.ConditionTypeValidationSucceeded, reason, msg)
+		v.cacheFailure(nodeClass, reason)
+		return reconcile.Result{RequeueAfter: requeueAfterTime}, nil
+	}
+
+	v.cacheSuccess(nodeClass)
 	nodeClass.StatusConditions().SetTrue(v1alpha1.ConditionTypeValidationSucceeded)
 	return reconcile.Result{RequeueAfter: requeueAfterTime}, nil
 }
@@ -158,18 +299,49 @@ func (v *Validation) Reconcile(ctx context.Context, nodeClass *v1alpha1.YandexNo
 func (*Validation) requiredConditions() []string {
 	return []string{
 		v1alpha1.ConditionTypeSubnetsReady,
+		v1alpha1.ConditionTypeSecurityGroupsReady,
 	}
 }
 
+// cacheFailure caches the reason of a failed validation. Failures expire sooner than successes, so that a subnet or
+// security group created after a failed validation is noticed without waiting for the full validation TTL.
+// The validation time is only refreshed along with the cache, cache hits leave the status as is since every status
+// patch triggers another reconcile
+func (v *Validation) cacheFailure(nodeClass *v1alpha1.YandexNodeClass, reason string) {
+	v.cache.Set(v.cacheKey(nodeClass), reason, validationFailureTTL)
+	nodeClass.Status.LastValidationTime = metav1.NewTime(v.clk.Now())
+}
+
+// cacheSuccess caches a successful validation, see cacheFailure
+func (v *Validation) cacheSuccess(nodeClass *v1alpha1.YandexNodeClass) {
+	v.cache.SetDefault(v.cacheKey(nodeClass), "")
+	nodeClass.Status.LastValidationTime = metav1.NewTime(v.clk.Now())
+}
+
 func (*Validation) cacheKey(nodeClass *v1alpha1.YandexNodeClass) string {
 	hash := lo.Must(hashstructure.Hash([]interface{}{
 		nodeClass.Status.Subnets,
+		nodeClass.Status.SecurityGroups,
 		nodeClass.Spec.Labels,
 		nodeClass.Spec.DiskType,
+		nodeClass.Spec.SpotDiskType,
+		nodeClass.Spec.OnDemandDiskType,
 		nodeClass.Spec.DiskSize.String(),
 		nodeClass.Spec.SecurityGroups,
 		nodeClass.Spec.SoftwareAcceleratedNetworkSettings,
 		nodeClass.Spec.CoreFractions,
+		nodeClass.Spec.UserDataTemplate,
+		nodeClass.Spec.MetadataOptions,
+		nodeClass.Spec.StartupTaints,
+		nodeClass.Spec.MaintenancePolicy,
+		nodeClass.Spec.ZoneSubnets,
+		nodeClass.Spec.ReleaseChannel,
+		nodeClass.Spec.AutoDiscoverSubnets,
+		nodeClass.Spec.NodeAnnotations,
+		nodeClass.Spec.Platform,
+		nodeClass.Spec.Platforms,
+		nodeClass.Spec.ContainerRuntime,
+		nodeClass.Spec.GPUDriverVersion,
 	}, hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true}))
 	return fmt.Sprintf("%s:%016x", nodeClass.Name, hash)
 }
@@ -192,15 +364,15 @@ func (v *Validation) clearCacheEntries(nodeClass *v1alpha1.YandexNodeClass) {
 	}
 }
 
-func rulesForDiskType(t string) (diskRules, bool) {
+func rulesForDiskType(t yandex.DiskType) (diskRules, bool) {
 	switch t {
-	case "network-ssd", "network-hdd":
+	case yandex.SSD, yandex.HDD:
 		return diskRules{
 			minBytes:  stepNetworkDiskBytes,
 			stepBytes: stepNetworkDiskBytes,
 			maxBytes:  maxDefaultBytes,
 		}, true
-	case "network-ssd-nonreplicated", "network-ssd-io-m3":
+	case yandex.SSDNonreplicated, yandex.SSDIo:
 		return diskRules{
 			minBytes:  stepNonReplicated,
 			stepBytes: stepNonReplicated,
@@ -211,7 +383,8 @@ func rulesForDiskType(t string) (diskRules, bool) {
 	}
 }
 
-// validateDisk checks whether nodeClass.Spec.DiskType and nodeClass.Spec.DiskSize comply with Yandex Cloud restrictions.
+// validateDisk checks whether nodeClass.Spec.DiskType, the disk types of spot and on-demand nodes and
+// nodeClass.Spec.DiskSize comply with Yandex Cloud restrictions.
 // Returns an empty reason if everything is correct.
 func validateDisk(spec v1alpha1.YandexNodeClassSpec) (reason, msg string) {
 	sizeBytes := spec.DiskSize.Value()
@@ -219,53 +392,151 @@ func validateDisk(spec v1alpha1.YandexNodeClassSpec) (reason, msg string) {
 		return "InvalidDiskSize", "spec.diskSize must be > 0"
 	}
 
-	diskType := spec.DiskType
-	if diskType == "" {
-		diskType = "network-ssd"
+	if reason, msg := validateDiskType("diskType", spec.DiskType, sizeBytes); reason != "" {
+		return reason, msg
+	}
+	if spec.SpotDiskType != "" {
+		if reason, msg := validateDiskType("spotDiskType", spec.SpotDiskType, sizeBytes); reason != "" {
+			return reason, msg
+		}
+	}
+	if spec.OnDemandDiskType != "" {
+		if reason, msg := validateDiskType("onDemandDiskType", spec.OnDemandDiskType, sizeBytes); reason != "" {
+			return reason, msg
+		}
+	}
+	return "", ""
+}
+
+// validateDiskType checks whether the disk type in spec.<field> supports disks of sizeBytes
+func validateDiskType(field, crdDiskType string, sizeBytes int64) (reason, msg string) {
+	diskType, ok := yandex.DiskTypeFromCRD(crdDiskType)
+	if !ok {
+		return "InvalidDiskType", fmt.Sprintf("unsupported spec.%s=%q", field, crdDiskType)
 	}
 
 	r, ok := rulesForDiskType(diskType)
 	if !ok {
-		return "InvalidDiskType", fmt.Sprintf("unsupported spec.diskType=%q", diskType)
+		return "InvalidDiskType", fmt.Sprintf("unsupported spec.%s=%q", field, diskType)
 	}
 
 	if r.minBytes > 0 && sizeBytes < r.minBytes {
 		return "InvalidDiskSize", fmt.Sprintf(
-			"spec.diskSize must be >= %s for diskType=%s",
+			"spec.diskSize must be >= %s for %s=%s",
 			resource.NewQuantity(r.minBytes, resource.BinarySI).String(),
-			spec.DiskType,
+			field,
+			diskType,
 		)
 	}
 
+	// steps are binary units, so e.g. 93G is not a multiple of the 93Gi of non-replicated disks
 	if r.stepBytes > 0 && (sizeBytes%r.stepBytes) != 0 {
 		return "InvalidDiskSize", fmt.Sprintf(
-			"spec.diskSize must be a multiple of %s for diskType=%s",
+			"spec.diskSize must be a multiple of %s for %s=%s",
 			resource.NewQuantity(r.stepBytes, resource.BinarySI).String(),
-			spec.DiskType,
+			field,
+			diskType,
 		)
 	}
 
 	if r.maxBytes > 0 && sizeBytes > r.maxBytes {
-		if spec.DiskType == "" || spec.DiskType == "network-ssd" || spec.DiskType == "network-hdd" {
-			return "InvalidDiskSize", fmt.Sprintf(
-				"spec.diskSize must be <= %s for diskType=%s",
-				resource.NewQuantity(r.maxBytes, resource.BinarySI).String(),
-				lo.If(spec.DiskType == "", "network-ssd").Else(spec.DiskType),
-			)
-		}
 		return "InvalidDiskSize", fmt.Sprintf(
-			"spec.diskSize must be <= %s for diskType=%s",
+			"spec.diskSize must be <= %s for %s=%s",
 			resource.NewQuantity(r.maxBytes, resource.BinarySI).String(),
-			spec.DiskType,
+			field,
+			diskType,
 		)
 	}
 
 	return "", ""
 }
 
+// validateUserDataTemplate ensures that userDataTemplate parses and renders, so that template errors surface before any
+// launch. It is rendered against empty variables, which catches references to unknown variables and templates.
+func validateUserDataTemplate(spec v1alpha1.YandexNodeClassSpec) (reason, msg string) {
+	if spec.UserDataTemplate == "" {
+		return "", ""
+	}
+	if _, err := yandex.RenderUserData(spec.UserDataTemplate, yandex.UserDataVariables{}); err != nil {
+		return "InvalidUserDataTemplate", fmt.Sprintf("spec.userDataTemplate is invalid: %s", err)
+	}
+	return "", ""
+}
+
+// validateMetadataOptions ensures that metadataOptions.userData decodes and is not combined with userDataTemplate,
+// so that malformed user-data surfaces before any launch.
+func validateMetadataOptions(spec v1alpha1.YandexNodeClassSpec) (reason, msg string) {
+	if spec.MetadataOptions == nil || spec.MetadataOptions.UserData == "" {
+		return "", ""
+	}
+	if spec.UserDataTemplate != "" {
+		return "ConflictingUserData", "spec.metadataOptions.userData cannot be combined with spec.userDataTemplate"
+	}
+	if _, err := base64.StdEncoding.DecodeString(spec.MetadataOptions.UserData); err != nil {
+		return "InvalidUserData", fmt.Sprintf("spec.metadataOptions.userData is not valid base64: %s", err)
+	}
+	return "", ""
+}
+
+// validateNodeAnnotations ensures that the keys of nodeAnnotations are valid annotation keys, an invalid one would fail
+// the update of the NodeClaim after launch.
+func validateNodeAnnotations(spec v1alpha1.YandexNodeClassSpec) (reason, msg string) {
+	if errs := apivalidation.ValidateAnnotations(spec.NodeAnnotations, field.NewPath("spec", "nodeAnnotations")); len(errs) > 0 {
+		return "InvalidNodeAnnotations", errs.ToAggregate().Error()
+	}
+	return "", ""
+}
+
+// validateTaints ensures that every startup taint keeps pods off the node until it is removed. Karpenter does not
+// take startup taints into account when scheduling, so PreferNoSchedule, which does not hold pods off, and effects
+// node group taints do not support are rejected, taints that stay on the nodes belong in the NodePool template.
+func validateTaints(spec v1alpha1.YandexNodeClassSpec) (reason, msg string) {
+	for i, taint := range spec.StartupTaints {
+		if taint.Key == "" {
+			return "InvalidTaint", fmt.Sprintf("spec.startupTaints[%d] has no key", i)
+		}
+		if taint.Effect == corev1.TaintEffectPreferNoSchedule || !yandex.TaintEffectSupported(taint.Effect) {
+			return "InvalidTaint", fmt.Sprintf("spec.startupTaints[%d] has effect %q, startup taints must be NoSchedule or NoExecute", i, taint.Effect)
+		}
+	}
+	return "", ""
+}
+
+// validateMaintenancePolicy ensures that the maintenance window converts to a node group one, an invalid window
+// would fail every launch.
+func validateMaintenancePolicy(spec v1alpha1.YandexNodeClassSpec) (reason, msg string) {
+	if _, err := yandex.NewMaintenanceWindow(spec.MaintenanceWindow()); err != nil {
+		return "InvalidMaintenanceWindow", fmt.Sprintf("spec.maintenancePolicy.maintenanceWindow is invalid: %s", err)
+	}
+	return "", ""
+}
+
+// validatePlatform ensures that spec.platform and spec.platforms have instance configurations in the region.
+func validatePlatform(spec v1alpha1.YandexNodeClassSpec, region string) (reason, msg string) {
+	for _, platform := range spec.PlatformsOrAll() {
+		if !instancetype.PlatformAvailable(region, yandex.PlatformId(platform)) {
+			return "UnknownPlatform", "platform " + platform + " has no instance configurations in the region"
+		}
+	}
+	return "", ""
+}
+
+// validateGPUDriverVersion ensures that a GPU driver version is only set with a GPU platform, the instance type
+// provider would otherwise filter out every instance type of the nodeclass.
+func validateGPUDriverVersion(spec v1alpha1.YandexNodeClassSpec) (reason, msg string) {
+	platforms := spec.PlatformsOrAll()
+	if spec.GPUDriverVersion == "" || len(platforms) == 0 {
+		return "", ""
+	}
+	if !lo.SomeBy(platforms, func(platform string) bool { return yandex.PlatformId(platform).IsGPU() }) {
+		return "InvalidGPUDriverVersion", "spec.gpuDriverVersion requires a GPU platform, got " + strings.Join(platforms, ", ")
+	}
+	return "", ""
+}
+
 // validateSubnetsExist ensures subnetSelectorTerms matches at least one subnet and that resolved status.subnets (if any) still match it (including ZoneID when set).
 func validateSubnetsExist(ctx context.Context, yc yandex.SDK, nodeClass *v1alpha1.YandexNodeClass) (reason, msg string) {
-	if len(nodeClass.Spec.SubnetSelectorTerms) == 0 {
+	if len(nodeClass.Spec.SubnetSelectorTerms) == 0 && !nodeClass.Spec.AutoDiscoverSubnets {
 		return "InvalidSubnetSelector", "spec.subnetSelectorTerms must not be empty"
 	}
 
@@ -273,32 +544,18 @@ func validateSubnetsExist(ctx context.Context, yc yandex.SDK, nodeClass *v1alpha
 	if err != nil {
 		return "SubnetLookupFailed", "failed to list network subnets: " + err.Error()
 	}
-
-	matched := make(map[string]string)
-	for _, subnet := range subnets {
-		if subnet == nil || subnet.Id == "" {
-			continue
-		}
-
-		keep := false
-		for _, term := range nodeClass.Spec.SubnetSelectorTerms {
-			if term.ID != "" && subnet.Id == term.ID {
-				keep = true
-				break
-			}
-			if len(term.Labels) == 0 {
-				continue
-			}
-			if yandex.MatchLabels(subnet.Labels, term.Labels) {
-				keep = true
-				break
-			}
-		}
-		if !keep {
-			continue
-		}
-
-		matched[subnet.Id] = subnet.ZoneId
+	subnets = lo.Filter(subnets, func(s *vpc.Subnet, _ int) bool { return s != nil && s.Id != "" })
+
+	selected := lo.Filter(subnets, func(s *vpc.Subnet, _ int) bool {
+		return subnet.MatchesSelectorTerms(s, nodeClass.Spec.SubnetSelectorTerms)
+	})
+	// mirrors the subnet provider, which falls back to the subnets of the cluster network
+	if len(selected) == 0 && nodeClass.Spec.AutoDiscoverSubnets {
+		selected = subnets
+	}
+	matched := make(map[string]string, len(selected))
+	for _, s := range selected {
+		matched[s.Id] = s.ZoneId
 	}
 
 	if len(matched) == 0 {
@@ -332,28 +589,92 @@ func validateSubnetsExist(ctx context.Context, yc yandex.SDK, nodeClass *v1alpha
 	return "", ""
 }
 
-// validateSecurityGroupsExist verifies that every Security Group ID listed in nodeClass.Spec.SecurityGroups
-// exists in Yandex Cloud and belongs to the cluster network (same VPC network as the cluster).
-func validateSecurityGroupsExist(ctx context.Context, yc yandex.SDK, nodeClass *v1alpha1.YandexNodeClass) (reason, msg string) {
-	for _, sgID := range nodeClass.Spec.SecurityGroups {
-		ok, err := yc.SecurityGroupExists(ctx, sgID)
-		if err != nil {
-			return "SecurityGroupLookupFailed", "failed to get security group " + sgID + ": " + err.Error()
-		}
+// validateZoneSubnets ensures that every subnet of spec.zoneSubnets exists in the cluster network and lives in the zone it is mapped to.
+func validateZoneSubnets(ctx context.Context, yc yandex.SDK, nodeClass *v1alpha1.YandexNodeClass) (reason, msg string) {
+	if len(nodeClass.Spec.ZoneSubnets) == 0 {
+		return "", ""
+	}
+
+	subnets, err := yc.ListNetworkSubnets(ctx)
+	if err != nil {
+		return "SubnetLookupFailed", "failed to list network subnets: " + err.Error()
+	}
+	zoneBySubnet := make(map[string]string, len(subnets))
+	for _, subnet := range subnets {
+		zoneBySubnet[subnet.GetId()] = subnet.GetZoneId()
+	}
+
+	for _, zone := range slices.Sorted(maps.Keys(nodeClass.Spec.ZoneSubnets)) {
+		subnetID := nodeClass.Spec.ZoneSubnets[zone]
+		zoneID, ok := zoneBySubnet[subnetID]
 		if !ok {
+			return "ZoneSubnetNotFound", "subnet " + subnetID + " for zone " + zone + " is not found in the cluster network"
+		}
+		if zoneID != zone {
+			return "ZoneSubnetMismatch", "subnet " + subnetID + " is in zone " + zoneID + ", but is mapped to zone " + zone
+		}
+	}
+	return "", ""
+}
+
+// validateSecurityGroupsResolved verifies that every Security Group ID listed in nodeClass.Spec.SecurityGroups
+// was resolved in the cluster network by the security group reconciler into status.securityGroups.
+func validateSecurityGroupsResolved(nodeClass *v1alpha1.YandexNodeClass) (reason, msg string) {
+	for _, sgID := range nodeClass.Spec.SecurityGroups {
+		if !lo.Contains(nodeClass.Status.SecurityGroups, sgID) {
 			return "SecurityGroupNotFound", "security group not found (or not in cluster network): " + sgID
 		}
 	}
 	return "", ""
 }
 
-// validateSAN ensures that softwareAcceleratedNetworkSettings is only enabled when a 100% core fraction is possible.
+// validateReleaseChannel ensures that spec.releaseChannel, when set, matches the release channel of the cluster.
+func validateReleaseChannel(ctx context.Context, yc yandex.SDK, nodeClass *v1alpha1.YandexNodeClass) (reason, msg string) {
+	if nodeClass.Spec.ReleaseChannel == "" {
+		return "", ""
+	}
+
+	cluster, err := yc.GetCluster(ctx)
+	if err != nil {
+		return "ClusterLookupFailed", "failed to get cluster: " + err.Error()
+	}
+	clusterChannel := strings.ToLower(cluster.GetReleaseChannel().String())
+	if clusterChannel != nodeClass.Spec.ReleaseChannel {
+		return "ReleaseChannelMismatch", "release channel " + nodeClass.Spec.ReleaseChannel + " does not match the cluster release channel " + clusterChannel
+	}
+	return "", ""
+}
+
+// dockerRemovedVersion is the Kubernetes version that removed dockershim, node groups of newer clusters only run containerd
+var dockerRemovedVersion = version.MajorMinor(1, 24)
+
+// validateContainerRuntime ensures that spec.containerRuntime docker is only used with clusters still supporting it.
+func validateContainerRuntime(ctx context.Context, yc yandex.SDK, nodeClass *v1alpha1.YandexNodeClass) (reason, msg string) {
+	if nodeClass.Spec.ContainerRuntime != "docker" {
+		return "", ""
+	}
+
+	cluster, err := yc.GetCluster(ctx)
+	if err != nil {
+		return "ClusterLookupFailed", "failed to get cluster: " + err.Error()
+	}
+	clusterVersion, err := version.ParseGeneric(cluster.GetMaster().GetVersion())
+	if err != nil {
+		return "ClusterLookupFailed", "failed to parse cluster version: " + err.Error()
+	}
+	if clusterVersion.AtLeast(dockerRemovedVersion) {
+		return "UnsupportedContainerRuntime", "container runtime docker is not supported by Kubernetes " + clusterVersion.String() + ", use containerd"
+	}
+	return "", ""
+}
+
+// validateSAN ensures that softwareAcceleratedNetworkSettings is only enabled when core_fractions includes 100.
+// Nodeclasses without core_fractions are not checked, they use the operator default core fraction.
 func validateSAN(spec v1alpha1.YandexNodeClassSpec) (reason, msg string) {
 	if !spec.SoftwareAcceleratedNetworkSettings {
 		return "", ""
 	}
 
-	//If CoreFractions is not set, provider defaults to 100%
 	if len(spec.CoreFractions) == 0 {
 		return "", ""
 	}
@@ -370,7 +691,7 @@ func validateSAN(spec v1alpha1.YandexNodeClassSpec) (reason, msg string) {
 
 func shouldCacheValidationFailure(reason string) bool {
 	switch reason {
-	case "SubnetLookupFailed", "SecurityGroupLookupFailed":
+	case "SubnetLookupFailed", "ClusterLookupFailed":
 		return false
 	default:
 		return true
@@ -0,0 +1,944 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodeclass
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/apis/v1alpha1"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/fake"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/yandex"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/k8s/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	clocktesting "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/karpenter/pkg/events"
+)
+
+func newTestNodeClass() *v1alpha1.YandexNodeClass {
+	nodeClass := &v1alpha1.YandexNodeClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "default", Generation: 1},
+		Spec: v1alpha1.YandexNodeClassSpec{
+			SubnetSelectorTerms: []v1alpha1.SubnetSelectorTerm{{ID: "subnet-a"}},
+			DiskType:            "network-ssd",
+			DiskSize:            resource.MustParse("32Gi"),
+			SecurityGroups:      []string{"sg-1"},
+		},
+		Status: v1alpha1.YandexNodeClassStatus{
+			Subnets:        []v1alpha1.Subnet{{ID: "subnet-a", ZoneID: "ru-central1-a"}},
+			SecurityGroups: []string{"sg-1"},
+		},
+	}
+	nodeClass.StatusConditions().SetTrue(v1alpha1.ConditionTypeSubnetsReady)
+	nodeClass.StatusConditions().SetTrue(v1alpha1.ConditionTypeSecurityGroupsReady)
+	return nodeClass
+}
+
+func newTestSDK() *fake.SDK {
+	sdk := fake.NewSDK()
+	sdk.Subnets = []*vpc.Subnet{{Id: "subnet-a", ZoneId: "ru-central1-a"}}
+	sdk.SecurityGroups["sg-1"] = true
+	return sdk
+}
+
+func newTestValidation(sdk *fake.SDK) *Validation {
+	return NewValidationReconciler(nil, events.NewRecorder(record.NewFakeRecorder(100)), cache.New(time.Hour, time.Hour), sdk, clocktesting.NewFakeClock(time.Now()), false, yandex.RegionRU)
+}
+
+func TestValidation_CacheHitLeavesStatusUnchanged(t *testing.T) {
+	ctx := context.Background()
+	sdk := newTestSDK()
+	clk := clocktesting.NewFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	v := NewValidationReconciler(nil, events.NewRecorder(record.NewFakeRecorder(100)), cache.New(time.Hour, time.Hour), sdk, clk, false, yandex.RegionRU)
+	nodeClass := newTestNodeClass()
+
+	if _, err := v.Reconcile(ctx, nodeClass); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !nodeClass.Status.LastValidationTime.Time.Equal(clk.Now()) {
+		t.Fatalf("Expected LastValidationTime %v, got %v", clk.Now(), nodeClass.Status.LastValidationTime.Time)
+	}
+	subnetCalls := sdk.Calls("ListNetworkSubnets")
+
+	// the status patch of the first reconcile triggers the second one, which must not patch the status again
+	stored := nodeClass.DeepCopy()
+	clk.Step(time.Second)
+	if _, err := v.Reconcile(ctx, nodeClass); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !equality.Semantic.DeepEqual(stored, nodeClass) {
+		t.Errorf("Expected a cached reconcile to leave the nodeclass unchanged, got status %+v", nodeClass.Status)
+	}
+	if got := sdk.Calls("ListNetworkSubnets"); got != subnetCalls {
+		t.Errorf("Expected cached reconcile not to list subnets again, got %d calls (was %d)", got, subnetCalls)
+	}
+}
+
+func TestValidation_CachedFailureKeepsMessage(t *testing.T) {
+	ctx := context.Background()
+	sdk := newTestSDK()
+	sdk.Subnets = nil
+	v := newTestValidation(sdk)
+	nodeClass := newTestNodeClass()
+
+	if _, err := v.Reconcile(ctx, nodeClass); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	stored := nodeClass.DeepCopy()
+	if _, err := v.Reconcile(ctx, nodeClass); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !equality.Semantic.DeepEqual(stored, nodeClass) {
+		t.Errorf("Expected a cached failure to leave the nodeclass unchanged, got conditions %+v", nodeClass.Status.Conditions)
+	}
+}
+
+func TestValidation_CacheKey(t *testing.T) {
+	testCases := []struct {
+		name   string
+		mutate func(*v1alpha1.YandexNodeClass)
+	}{
+		{
+			name: "Node annotations",
+			mutate: func(nc *v1alpha1.YandexNodeClass) {
+				nc.Spec.NodeAnnotations = map[string]string{"example.com/team": "a"}
+			},
+		},
+		{
+			name:   "Platform",
+			mutate: func(nc *v1alpha1.YandexNodeClass) { nc.Spec.Platform = "standard-v2" },
+		},
+		{
+			name:   "Platforms",
+			mutate: func(nc *v1alpha1.YandexNodeClass) { nc.Spec.Platforms = []string{"standard-v2"} },
+		},
+		{
+			name:   "Container runtime",
+			mutate: func(nc *v1alpha1.YandexNodeClass) { nc.Spec.ContainerRuntime = "docker" },
+		},
+		{
+			name:   "GPU driver version",
+			mutate: func(nc *v1alpha1.YandexNodeClass) { nc.Spec.GPUDriverVersion = "535.104.05" },
+		},
+	}
+
+	v := newTestValidation(newTestSDK())
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeClass := newTestNodeClass()
+			changed := nodeClass.DeepCopy()
+			tc.mutate(changed)
+			// a changed field must not hit the cached result of the previous spec
+			if v.cacheKey(nodeClass) == v.cacheKey(changed) {
+				t.Errorf("Expected the cache key to change with the field")
+			}
+		})
+	}
+}
+
+func TestValidation_FailuresUseShorterTTL(t *testing.T) {
+	testCases := []struct {
+		name            string
+		subnets         []*vpc.Subnet
+		expectedTTL     time.Duration
+		expectedRequeue time.Duration
+	}{
+		{
+			name:            "Success",
+			subnets:         []*vpc.Subnet{{Id: "subnet-a", ZoneId: "ru-central1-a"}},
+			expectedTTL:     time.Hour,
+			expectedRequeue: requeueAfterTime,
+		},
+		{
+			name:            "No subnets matched",
+			expectedTTL:     validationFailureTTL,
+			expectedRequeue: validationFailureTTL,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sdk := newTestSDK()
+			sdk.Subnets = tc.subnets
+			c := cache.New(time.Hour, time.Hour)
+			v := NewValidationReconciler(nil, events.NewRecorder(record.NewFakeRecorder(100)), c, sdk, clocktesting.NewFakeClock(time.Now()), false, yandex.RegionRU)
+			nodeClass := newTestNodeClass()
+
+			before := time.Now()
+			res, err := v.Reconcile(context.Background(), nodeClass)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if res.RequeueAfter != tc.expectedRequeue {
+				t.Errorf("Expected requeue after %s, got %s", tc.expectedRequeue, res.RequeueAfter)
+			}
+			item, ok := c.Items()[v.cacheKey(nodeClass)]
+			if !ok {
+				t.Fatalf("Expected the validation result to be cached")
+			}
+			expires := time.Unix(0, item.Expiration)
+			if expires.Before(before.Add(tc.expectedTTL)) || expires.After(time.Now().Add(tc.expectedTTL)) {
+				t.Errorf("Expected the validation result to expire in %s, expires in %s", tc.expectedTTL, expires.Sub(before))
+			}
+		})
+	}
+}
+
+func TestValidateSubnetsExist(t *testing.T) {
+	testCases := []struct {
+		name           string
+		terms          []v1alpha1.SubnetSelectorTerm
+		autoDiscover   bool
+		statusSubnets  []v1alpha1.Subnet
+		expectedReason string
+	}{
+		{
+			name:          "Selected by id",
+			terms:         []v1alpha1.SubnetSelectorTerm{{ID: "subnet-a"}},
+			statusSubnets: []v1alpha1.Subnet{{ID: "subnet-a", ZoneID: "ru-central1-a"}},
+		},
+		{
+			name:           "No selector terms",
+			expectedReason: "InvalidSubnetSelector",
+		},
+		{
+			name:           "No subnet matched",
+			terms:          []v1alpha1.SubnetSelectorTerm{{ID: "subnet-z"}},
+			expectedReason: "NoSubnetsMatched",
+		},
+		{
+			name:          "Auto-discovered without selector terms",
+			autoDiscover:  true,
+			statusSubnets: []v1alpha1.Subnet{{ID: "subnet-b", ZoneID: "ru-central1-b"}},
+		},
+		{
+			name:          "Auto-discovered when no subnet matched",
+			terms:         []v1alpha1.SubnetSelectorTerm{{ID: "subnet-z"}},
+			autoDiscover:  true,
+			statusSubnets: []v1alpha1.Subnet{{ID: "subnet-a", ZoneID: "ru-central1-a"}},
+		},
+		{
+			name:           "Selected subnets take precedence over auto-discovery",
+			terms:          []v1alpha1.SubnetSelectorTerm{{ID: "subnet-a"}},
+			autoDiscover:   true,
+			statusSubnets:  []v1alpha1.Subnet{{ID: "subnet-b", ZoneID: "ru-central1-b"}},
+			expectedReason: "SubnetSelectorMismatch",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sdk := newTestSDK()
+			sdk.Subnets = []*vpc.Subnet{
+				{Id: "subnet-a", ZoneId: "ru-central1-a"},
+				{Id: "subnet-b", ZoneId: "ru-central1-b"},
+			}
+			nodeClass := newTestNodeClass()
+			nodeClass.Spec.SubnetSelectorTerms = tc.terms
+			nodeClass.Spec.AutoDiscoverSubnets = tc.autoDiscover
+			nodeClass.Status.Subnets = tc.statusSubnets
+
+			if reason, msg := validateSubnetsExist(context.Background(), sdk, nodeClass); reason != tc.expectedReason {
+				t.Errorf("Expected reason %q, got %q: %s", tc.expectedReason, reason, msg)
+			}
+		})
+	}
+}
+
+func TestValidateZoneSubnets(t *testing.T) {
+	sdk := newTestSDK()
+	sdk.Subnets = []*vpc.Subnet{
+		{Id: "subnet-a", ZoneId: "ru-central1-a"},
+		{Id: "subnet-b", ZoneId: "ru-central1-b"},
+	}
+
+	testCases := []struct {
+		name           string
+		zoneSubnets    map[string]string
+		expectedReason string
+	}{
+		{
+			name:           "No explicit mapping",
+			expectedReason: "",
+		},
+		{
+			name:           "Matching zones",
+			zoneSubnets:    map[string]string{"ru-central1-a": "subnet-a", "ru-central1-b": "subnet-b"},
+			expectedReason: "",
+		},
+		{
+			name:           "Subnet mapped to a different zone",
+			zoneSubnets:    map[string]string{"ru-central1-a": "subnet-b"},
+			expectedReason: "ZoneSubnetMismatch",
+		},
+		{
+			name:           "Unknown subnet",
+			zoneSubnets:    map[string]string{"ru-central1-a": "subnet-missing"},
+			expectedReason: "ZoneSubnetNotFound",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeClass := newTestNodeClass()
+			nodeClass.Spec.ZoneSubnets = tc.zoneSubnets
+
+			reason, msg := validateZoneSubnets(context.Background(), sdk, nodeClass)
+			if reason != tc.expectedReason {
+				t.Errorf("Expected reason %q, got %q (%s)", tc.expectedReason, reason, msg)
+			}
+		})
+	}
+}
+
+func TestValidation_ZoneSubnetMismatchFails(t *testing.T) {
+	sdk := newTestSDK()
+	sdk.Subnets = append(sdk.Subnets, &vpc.Subnet{Id: "subnet-b", ZoneId: "ru-central1-b"})
+	v := newTestValidation(sdk)
+	nodeClass := newTestNodeClass()
+	nodeClass.Spec.ZoneSubnets = map[string]string{"ru-central1-a": "subnet-b"}
+
+	if _, err := v.Reconcile(context.Background(), nodeClass); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cond := nodeClass.StatusConditions().Get(v1alpha1.ConditionTypeValidationSucceeded)
+	if !cond.IsFalse() || cond.Reason != "ZoneSubnetMismatch" {
+		t.Errorf("Expected ValidationSucceeded=False with reason ZoneSubnetMismatch, got %s/%s", cond.Status, cond.Reason)
+	}
+}
+
+func TestValidateNodeAnnotations(t *testing.T) {
+	testCases := []struct {
+		name           string
+		annotations    map[string]string
+		expectedReason string
+	}{
+		{
+			name: "No annotations",
+		},
+		{
+			name:        "Valid keys",
+			annotations: map[string]string{"example.com/owner": "ml", "team": "platform"},
+		},
+		{
+			name:           "Invalid key",
+			annotations:    map[string]string{"example.com/owner/team": "ml"},
+			expectedReason: "InvalidNodeAnnotations",
+		},
+		{
+			name:           "Empty key",
+			annotations:    map[string]string{"": "ml"},
+			expectedReason: "InvalidNodeAnnotations",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeClass := newTestNodeClass()
+			nodeClass.Spec.NodeAnnotations = tc.annotations
+
+			reason, msg := validateNodeAnnotations(nodeClass.Spec)
+			if reason != tc.expectedReason {
+				t.Errorf("Expected reason %q, got %q (%s)", tc.expectedReason, reason, msg)
+			}
+		})
+	}
+}
+
+func TestValidatePlatform(t *testing.T) {
+	testCases := []struct {
+		name           string
+		platform       string
+		platforms      []string
+		expectedReason string
+	}{
+		{
+			name: "No platform",
+		},
+		{
+			name:     "Intel",
+			platform: "standard-v3",
+		},
+		{
+			name:     "GPU",
+			platform: "gpu-standard-v3",
+		},
+		{
+			name:           "No configurations in the region",
+			platforms:      []string{"standard-v4a"},
+			expectedReason: "UnknownPlatform",
+		},
+		{
+			name:           "Unknown",
+			platform:       "standard-v9",
+			expectedReason: "UnknownPlatform",
+		},
+		{
+			name:      "Several platforms",
+			platform:  "standard-v3",
+			platforms: []string{"standard-v2", "highfreq-v3"},
+		},
+		{
+			name:           "Unknown among several platforms",
+			platforms:      []string{"standard-v3", "standard-v9"},
+			expectedReason: "UnknownPlatform",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeClass := newTestNodeClass()
+			nodeClass.Spec.Platform = tc.platform
+			nodeClass.Spec.Platforms = tc.platforms
+
+			reason, msg := validatePlatform(nodeClass.Spec, yandex.RegionRU)
+			if reason != tc.expectedReason {
+				t.Errorf("Expected reason %q, got %q (%s)", tc.expectedReason, reason, msg)
+			}
+		})
+	}
+}
+
+func TestValidation_UnknownPlatformFails(t *testing.T) {
+	v := newTestValidation(newTestSDK())
+	nodeClass := newTestNodeClass()
+	nodeClass.Spec.Platform = "standard-v4a"
+
+	if _, err := v.Reconcile(context.Background(), nodeClass); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cond := nodeClass.StatusConditions().Get(v1alpha1.ConditionTypeValidationSucceeded)
+	if !cond.IsFalse() || cond.Reason != "UnknownPlatform" {
+		t.Errorf("Expected ValidationSucceeded=False with reason UnknownPlatform, got %s/%s", cond.Status, cond.Reason)
+	}
+}
+
+func TestValidateGPUDriverVersion(t *testing.T) {
+	testCases := []struct {
+		name             string
+		gpuDriverVersion string
+		platform         string
+		platforms        []string
+		expectedReason   string
+	}{
+		{
+			name:     "No driver version",
+			platform: "standard-v3",
+		},
+		{
+			name:             "All platforms",
+			gpuDriverVersion: "535.104.05",
+		},
+		{
+			name:             "GPU platform",
+			gpuDriverVersion: "535.104.05",
+			platform:         "gpu-standard-v3",
+		},
+		{
+			name:             "GPU among several platforms",
+			gpuDriverVersion: "535.104.05",
+			platform:         "standard-v3",
+			platforms:        []string{"gpu-standard-v3"},
+		},
+		{
+			name:             "No GPU platform",
+			gpuDriverVersion: "535.104.05",
+			platform:         "standard-v3",
+			platforms:        []string{"standard-v2"},
+			expectedReason:   "InvalidGPUDriverVersion",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeClass := newTestNodeClass()
+			nodeClass.Spec.GPUDriverVersion = tc.gpuDriverVersion
+			nodeClass.Spec.Platform = tc.platform
+			nodeClass.Spec.Platforms = tc.platforms
+
+			reason, msg := validateGPUDriverVersion(nodeClass.Spec)
+			if reason != tc.expectedReason {
+				t.Errorf("Expected reason %q, got %q (%s)", tc.expectedReason, reason, msg)
+			}
+		})
+	}
+}
+
+func TestValidation_GPUDriverVersionWithoutGPUPlatformFails(t *testing.T) {
+	v := newTestValidation(newTestSDK())
+	nodeClass := newTestNodeClass()
+	nodeClass.Spec.Platform = "standard-v3"
+	nodeClass.Spec.GPUDriverVersion = "535.104.05"
+
+	if _, err := v.Reconcile(context.Background(), nodeClass); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cond := nodeClass.StatusConditions().Get(v1alpha1.ConditionTypeValidationSucceeded)
+	if !cond.IsFalse() || cond.Reason != "InvalidGPUDriverVersion" {
+		t.Errorf("Expected ValidationSucceeded=False with reason InvalidGPUDriverVersion, got %s/%s", cond.Status, cond.Reason)
+	}
+}
+
+func TestValidateReleaseChannel(t *testing.T) {
+	testCases := []struct {
+		name           string
+		releaseChannel string
+		clusterChannel k8s.ReleaseChannel
+		clusterErr     error
+		expectedReason string
+	}{
+		{
+			name:           "No release channel",
+			clusterChannel: k8s.ReleaseChannel_RAPID,
+		},
+		{
+			name:           "Rapid",
+			releaseChannel: "rapid",
+			clusterChannel: k8s.ReleaseChannel_RAPID,
+		},
+		{
+			name:           "Regular",
+			releaseChannel: "regular",
+			clusterChannel: k8s.ReleaseChannel_REGULAR,
+		},
+		{
+			name:           "Stable",
+			releaseChannel: "stable",
+			clusterChannel: k8s.ReleaseChannel_STABLE,
+		},
+		{
+			name:           "Incompatible with the cluster",
+			releaseChannel: "rapid",
+			clusterChannel: k8s.ReleaseChannel_STABLE,
+			expectedReason: "ReleaseChannelMismatch",
+		},
+		{
+			name:           "Cluster lookup failure",
+			releaseChannel: "stable",
+			clusterErr:     fmt.Errorf("unavailable"),
+			expectedReason: "ClusterLookupFailed",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sdk := newTestSDK()
+			sdk.Cluster.ReleaseChannel = tc.clusterChannel
+			sdk.GetClusterError = tc.clusterErr
+			nodeClass := newTestNodeClass()
+			nodeClass.Spec.ReleaseChannel = tc.releaseChannel
+
+			reason, msg := validateReleaseChannel(context.Background(), sdk, nodeClass)
+			if reason != tc.expectedReason {
+				t.Errorf("Expected reason %q, got %q (%s)", tc.expectedReason, reason, msg)
+			}
+		})
+	}
+}
+
+func TestValidateContainerRuntime(t *testing.T) {
+	testCases := []struct {
+		name           string
+		runtime        string
+		clusterVersion string
+		clusterErr     error
+		expectedReason string
+	}{
+		{
+			name:           "Default",
+			clusterVersion: "1.30",
+		},
+		{
+			name:           "Containerd",
+			runtime:        "containerd",
+			clusterVersion: "1.30",
+		},
+		{
+			name:           "Docker before dockershim removal",
+			runtime:        "docker",
+			clusterVersion: "1.23",
+		},
+		{
+			name:           "Docker after dockershim removal",
+			runtime:        "docker",
+			clusterVersion: "1.24",
+			expectedReason: "UnsupportedContainerRuntime",
+		},
+		{
+			name:           "Cluster lookup failure",
+			runtime:        "docker",
+			clusterErr:     fmt.Errorf("unavailable"),
+			expectedReason: "ClusterLookupFailed",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sdk := newTestSDK()
+			sdk.Cluster.Master = &k8s.Master{Version: tc.clusterVersion}
+			sdk.GetClusterError = tc.clusterErr
+			nodeClass := newTestNodeClass()
+			nodeClass.Spec.ContainerRuntime = tc.runtime
+
+			reason, msg := validateContainerRuntime(context.Background(), sdk, nodeClass)
+			if reason != tc.expectedReason {
+				t.Errorf("Expected reason %q, got %q (%s)", tc.expectedReason, reason, msg)
+			}
+		})
+	}
+}
+
+func TestValidation_ReleaseChannelMismatchFails(t *testing.T) {
+	sdk := newTestSDK()
+	sdk.Cluster.ReleaseChannel = k8s.ReleaseChannel_STABLE
+	v := newTestValidation(sdk)
+	nodeClass := newTestNodeClass()
+	nodeClass.Spec.ReleaseChannel = "rapid"
+
+	if _, err := v.Reconcile(context.Background(), nodeClass); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cond := nodeClass.StatusConditions().Get(v1alpha1.ConditionTypeValidationSucceeded)
+	if !cond.IsFalse() || cond.Reason != "ReleaseChannelMismatch" {
+		t.Errorf("Expected ValidationSucceeded=False with reason ReleaseChannelMismatch, got %s/%s", cond.Status, cond.Reason)
+	}
+}
+
+func TestValidateUserDataTemplate(t *testing.T) {
+	testCases := []struct {
+		name             string
+		userDataTemplate string
+		expectedReason   string
+	}{
+		{
+			name:             "Valid template",
+			userDataTemplate: "#cloud-config\nhostname: {{ .NodeName }}-{{ .Zone }}\n",
+		},
+		{
+			name:             "Malformed template",
+			userDataTemplate: "hostname: {{ .NodeName ",
+			expectedReason:   "InvalidUserDataTemplate",
+		},
+		{
+			name:             "Unknown variable",
+			userDataTemplate: "hostname: {{ .Hostname }}",
+			expectedReason:   "InvalidUserDataTemplate",
+		},
+		{
+			name:             "Undefined template",
+			userDataTemplate: `{{ template "bootstrap" . }}`,
+			expectedReason:   "InvalidUserDataTemplate",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sdk := newTestSDK()
+			v := newTestValidation(sdk)
+			nodeClass := newTestNodeClass()
+			nodeClass.Spec.UserDataTemplate = tc.userDataTemplate
+
+			if _, err := v.Reconcile(context.Background(), nodeClass); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			cond := nodeClass.StatusConditions().Get(v1alpha1.ConditionTypeValidationSucceeded)
+			if tc.expectedReason == "" {
+				if !cond.IsTrue() {
+					t.Errorf("Expected ValidationSucceeded=True, got %s/%s: %s", cond.Status, cond.Reason, cond.Message)
+				}
+				return
+			}
+			if !cond.IsFalse() || cond.Reason != tc.expectedReason {
+				t.Errorf("Expected ValidationSucceeded=False with reason %s, got %s/%s", tc.expectedReason, cond.Status, cond.Reason)
+			}
+		})
+	}
+}
+
+func TestValidateMetadataOptions(t *testing.T) {
+	testCases := []struct {
+		name             string
+		userDataTemplate string
+		userData         string
+		expectedReason   string
+	}{
+		{
+			name:     "Valid user-data",
+			userData: "I2Nsb3VkLWNvbmZpZwo=",
+		},
+		{
+			name:           "Malformed base64",
+			userData:       "#cloud-config",
+			expectedReason: "InvalidUserData",
+		},
+		{
+			name:             "Combined with a user-data template",
+			userDataTemplate: "#cloud-config\n",
+			userData:         "I2Nsb3VkLWNvbmZpZwo=",
+			expectedReason:   "ConflictingUserData",
+		},
+		{
+			name: "No user-data",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sdk := newTestSDK()
+			v := newTestValidation(sdk)
+			nodeClass := newTestNodeClass()
+			nodeClass.Spec.UserDataTemplate = tc.userDataTemplate
+			nodeClass.Spec.MetadataOptions = &v1alpha1.MetadataOptions{UserData: tc.userData}
+
+			if _, err := v.Reconcile(context.Background(), nodeClass); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			cond := nodeClass.StatusConditions().Get(v1alpha1.ConditionTypeValidationSucceeded)
+			if tc.expectedReason == "" {
+				if !cond.IsTrue() {
+					t.Errorf("Expected ValidationSucceeded=True, got %s/%s: %s", cond.Status, cond.Reason, cond.Message)
+				}
+				return
+			}
+			if !cond.IsFalse() || cond.Reason != tc.expectedReason {
+				t.Errorf("Expected ValidationSucceeded=False with reason %s, got %s/%s", tc.expectedReason, cond.Status, cond.Reason)
+			}
+		})
+	}
+}
+
+func TestValidateTaints(t *testing.T) {
+	testCases := []struct {
+		name           string
+		startupTaints  []corev1.Taint
+		expectedReason string
+	}{
+		{
+			name: "Supported effects",
+			startupTaints: []corev1.Taint{
+				{Key: "a", Effect: corev1.TaintEffectNoSchedule},
+				{Key: "node.cilium.io/agent-not-ready", Value: "true", Effect: corev1.TaintEffectNoExecute},
+			},
+		},
+		{
+			name:           "PreferNoSchedule does not hold pods off",
+			startupTaints:  []corev1.Taint{{Key: "example.com/warming-up", Effect: corev1.TaintEffectPreferNoSchedule}},
+			expectedReason: "InvalidTaint",
+		},
+		{
+			name:           "Unsupported effect",
+			startupTaints:  []corev1.Taint{{Key: "node.cilium.io/agent-not-ready", Effect: "NoRun"}},
+			expectedReason: "InvalidTaint",
+		},
+		{
+			name:           "No key",
+			startupTaints:  []corev1.Taint{{Effect: corev1.TaintEffectNoSchedule}},
+			expectedReason: "InvalidTaint",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sdk := newTestSDK()
+			v := newTestValidation(sdk)
+			nodeClass := newTestNodeClass()
+			nodeClass.Spec.StartupTaints = tc.startupTaints
+
+			if _, err := v.Reconcile(context.Background(), nodeClass); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			cond := nodeClass.StatusConditions().Get(v1alpha1.ConditionTypeValidationSucceeded)
+			if tc.expectedReason == "" {
+				if !cond.IsTrue() {
+					t.Errorf("Expected ValidationSucceeded=True, got %s/%s: %s", cond.Status, cond.Reason, cond.Message)
+				}
+				return
+			}
+			if !cond.IsFalse() || cond.Reason != tc.expectedReason {
+				t.Errorf("Expected ValidationSucceeded=False with reason %s, got %s/%s", tc.expectedReason, cond.Status, cond.Reason)
+			}
+		})
+	}
+}
+
+func TestValidateMaintenancePolicy(t *testing.T) {
+	testCases := []struct {
+		name           string
+		window         *v1alpha1.MaintenanceWindow
+		expectedReason string
+	}{
+		{
+			name: "No window",
+		},
+		{
+			name:   "Weekly window",
+			window: &v1alpha1.MaintenanceWindow{Days: []string{"saturday", "sunday"}, StartTime: "03:00", Duration: metav1.Duration{Duration: 4 * time.Hour}},
+		},
+		{
+			name:           "Malformed start time",
+			window:         &v1alpha1.MaintenanceWindow{StartTime: "3am", Duration: metav1.Duration{Duration: 4 * time.Hour}},
+			expectedReason: "InvalidMaintenanceWindow",
+		},
+		{
+			name:           "Too short",
+			window:         &v1alpha1.MaintenanceWindow{StartTime: "03:00", Duration: metav1.Duration{Duration: 30 * time.Minute}},
+			expectedReason: "InvalidMaintenanceWindow",
+		},
+		{
+			name:           "Unknown day",
+			window:         &v1alpha1.MaintenanceWindow{Days: []string{"caturday"}, StartTime: "03:00", Duration: metav1.Duration{Duration: 4 * time.Hour}},
+			expectedReason: "InvalidMaintenanceWindow",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sdk := newTestSDK()
+			v := newTestValidation(sdk)
+			nodeClass := newTestNodeClass()
+			nodeClass.Spec.MaintenancePolicy = &v1alpha1.MaintenancePolicy{MaintenanceWindow: tc.window}
+
+			if _, err := v.Reconcile(context.Background(), nodeClass); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			cond := nodeClass.StatusConditions().Get(v1alpha1.ConditionTypeValidationSucceeded)
+			if tc.expectedReason == "" {
+				if !cond.IsTrue() {
+					t.Errorf("Expected ValidationSucceeded=True, got %s/%s: %s", cond.Status, cond.Reason, cond.Message)
+				}
+				return
+			}
+			if !cond.IsFalse() || cond.Reason != tc.expectedReason {
+				t.Errorf("Expected ValidationSucceeded=False with reason %s, got %s/%s", tc.expectedReason, cond.Status, cond.Reason)
+			}
+		})
+	}
+}
+
+func TestValidateDisk(t *testing.T) {
+	testCases := []struct {
+		name           string
+		spec           v1alpha1.YandexNodeClassSpec
+		expectedReason string
+	}{
+		{
+			name: "Disk type of all nodes",
+			spec: v1alpha1.YandexNodeClassSpec{DiskType: "network-ssd", DiskSize: resource.MustParse("30Gi")},
+		},
+		{
+			name: "Disk types per capacity type",
+			spec: v1alpha1.YandexNodeClassSpec{DiskType: "network-ssd", SpotDiskType: "network-hdd", OnDemandDiskType: "network-ssd-io-m3", DiskSize: resource.MustParse("93Gi")},
+		},
+		{
+			name:           "Unsupported spot disk type",
+			spec:           v1alpha1.YandexNodeClassSpec{DiskType: "network-ssd", SpotDiskType: "network-floppy", DiskSize: resource.MustParse("30Gi")},
+			expectedReason: "InvalidDiskType",
+		},
+		{
+			name:           "Disk size not supported by the on-demand disk type",
+			spec:           v1alpha1.YandexNodeClassSpec{DiskType: "network-ssd", OnDemandDiskType: "network-ssd-nonreplicated", DiskSize: resource.MustParse("30Gi")},
+			expectedReason: "InvalidDiskSize",
+		},
+		{
+			name: "Empty disk type defaults to network-ssd",
+			spec: v1alpha1.YandexNodeClassSpec{DiskSize: resource.MustParse("30Gi")},
+		},
+		{
+			name:           "Empty disk type with a size network-ssd does not support",
+			spec:           v1alpha1.YandexNodeClassSpec{DiskSize: resource.MustParse("30001Ki")},
+			expectedReason: "InvalidDiskSize",
+		},
+		{
+			name: "Non-replicated disk of a multiple of 93Gi",
+			spec: v1alpha1.YandexNodeClassSpec{DiskType: "network-ssd-nonreplicated", DiskSize: resource.MustParse("186Gi")},
+		},
+		{
+			name: "Non-replicated disk of a multiple of 93Gi in bytes",
+			spec: v1alpha1.YandexNodeClassSpec{DiskType: "network-ssd-io-m3", DiskSize: resource.MustParse("99857989632")},
+		},
+		{
+			name:           "Non-replicated disk of no multiple of 93Gi",
+			spec:           v1alpha1.YandexNodeClassSpec{DiskType: "network-ssd-nonreplicated", DiskSize: resource.MustParse("100Gi")},
+			expectedReason: "InvalidDiskSize",
+		},
+		{
+			name:           "Non-replicated disk of 93G, not 93Gi",
+			spec:           v1alpha1.YandexNodeClassSpec{DiskType: "network-ssd-io-m3", DiskSize: resource.MustParse("93G")},
+			expectedReason: "InvalidDiskSize",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if reason, msg := validateDisk(tc.spec); reason != tc.expectedReason {
+				t.Errorf("Expected reason %q, got %q: %s", tc.expectedReason, reason, msg)
+			}
+		})
+	}
+}
+
+func TestValidation_TransitionEvents(t *testing.T) {
+	ctx := context.Background()
+	sdk := newTestSDK()
+	recorder := record.NewFakeRecorder(100)
+	v := NewValidationReconciler(nil, events.NewRecorder(recorder), cache.New(time.Hour, time.Hour), sdk, clocktesting.NewFakeClock(time.Now()), false, yandex.RegionRU)
+	nodeClass := newTestNodeClass()
+	reconcile := func() {
+		t.Helper()
+		if _, err := v.Reconcile(ctx, nodeClass); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	expectEvents := func(expected ...string) {
+		t.Helper()
+		var got []string
+		for len(recorder.Events) > 0 {
+			got = append(got, <-recorder.Events)
+		}
+		if len(got) != len(expected) {
+			t.Fatalf("Expected events %v, got %v", expected, got)
+		}
+		for i := range expected {
+			if !strings.HasPrefix(got[i], expected[i]) {
+				t.Errorf("Expected event %q, got %q", expected[i], got[i])
+			}
+		}
+	}
+
+	reconcile()
+	expectEvents()
+
+	// the subnet of the nodeclass is deleted and the cached validation result expires
+	sdk.Subnets = nil
+	v.cache.Flush()
+	reconcile()
+	expectEvents("Warning ValidationFailed NodeClass failed validation, NoSubnetsMatched")
+	reconcile()
+	expectEvents()
+
+	sdk.Subnets = []*vpc.Subnet{{Id: "subnet-a", ZoneId: "ru-central1-a"}}
+	v.cache.Flush()
+	reconcile()
+	expectEvents("Normal ValidationSucceeded")
+}
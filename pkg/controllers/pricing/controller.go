@@ -0,0 +1,150 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pricing
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/awslabs/operatorpkg/reconciler"
+	"github.com/awslabs/operatorpkg/singleton"
+	"github.com/samber/lo"
+	"go.uber.org/multierr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	controllerruntime "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/karpenter/pkg/cloudprovider"
+	"sigs.k8s.io/karpenter/pkg/operator/injection"
+
+	"github.com/tufitko/karpenter-provider-yandex/pkg/apis/v1alpha1"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/providers/instancetype"
+)
+
+// exportInterval is how often the prices of the nodeclasses are exported again, prices of the pricing provider are
+// refreshed on their own schedule
+const exportInterval = 5 * time.Minute
+
+// Controller exports the cheapest price of every platform and capacity type of every nodeclass, so that expected node
+// costs can be scraped without reading the offerings of every instance type, along with the offerings themselves.
+// Instance types are offered per platform, the metrics are exported here from the full list of a nodeclass so that the
+// series of instance types the nodeclass no longer lists are removed
+type Controller struct {
+	kubeClient           client.Client
+	instanceTypeProvider instancetype.Provider
+	// exported are the nodeclasses with exported metrics, so that the metrics of deleted nodeclasses are removed
+	exported sets.Set[string]
+}
+
+func NewController(kubeClient client.Client, instanceTypeProvider instancetype.Provider) *Controller {
+	return &Controller{
+		kubeClient:           kubeClient,
+		instanceTypeProvider: instanceTypeProvider,
+		exported:             sets.New[string](),
+	}
+}
+
+func (c *Controller) Reconcile(ctx context.Context) (reconciler.Result, error) {
+	ctx = injection.WithControllerName(ctx, "pricing.metrics")
+
+	nodeClasses := &v1alpha1.YandexNodeClassList{}
+	if err := c.kubeClient.List(ctx, nodeClasses); err != nil {
+		return reconciler.Result{}, fmt.Errorf("listing nodeclasses, %w", err)
+	}
+
+	var errs error
+	exported := sets.New[string]()
+	for i := range nodeClasses.Items {
+		nodeClass := &nodeClasses.Items[i]
+		instanceTypes, err := c.instanceTypeProvider.List(ctx, nodeClass)
+		if err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("listing instance types of nodeclass %s, %w", nodeClass.Name, err))
+			continue
+		}
+		deleteMetrics(nodeClass.Name)
+		for key, price := range cheapestPrices(instanceTypes) {
+			CheapestPriceEstimate.Set(price, map[string]string{
+				nodeClassLabel:    nodeClass.Name,
+				platformLabel:     key.platform,
+				capacityTypeLabel: key.capacityType,
+			})
+		}
+		exportOfferings(nodeClass.Name, instanceTypes)
+		exported.Insert(nodeClass.Name)
+	}
+	for name := range c.exported.Difference(exported) {
+		deleteMetrics(name)
+	}
+	c.exported = exported
+
+	return reconciler.Result{RequeueAfter: exportInterval}, errs
+}
+
+// deleteMetrics removes every series of the nodeclass
+func deleteMetrics(nodeClass string) {
+	labels := map[string]string{nodeClassLabel: nodeClass}
+	CheapestPriceEstimate.DeletePartialMatch(labels)
+	OfferingAvailable.DeletePartialMatch(labels)
+	OfferingPriceEstimate.DeletePartialMatch(labels)
+	InstanceTypesWithoutOfferings.DeletePartialMatch(labels)
+}
+
+// exportOfferings exports the availability and price of every offering of the instance types of the nodeclass
+func exportOfferings(nodeClass string, instanceTypes []*cloudprovider.InstanceType) {
+	for _, it := range instanceTypes {
+		for _, offering := range it.Offerings {
+			labels := map[string]string{
+				nodeClassLabel:    nodeClass,
+				platformLabel:     it.Requirements.Get(v1alpha1.LabelInstanceCPUPlatform).Any(),
+				instanceTypeLabel: it.Name,
+				capacityTypeLabel: offering.CapacityType(),
+				zoneLabel:         offering.Zone(),
+			}
+			OfferingAvailable.Set(float64(lo.Ternary(offering.Available, 1, 0)), labels)
+			OfferingPriceEstimate.Set(offering.Price, labels)
+		}
+	}
+	InstanceTypesWithoutOfferings.Set(float64(lo.CountBy(instanceTypes, func(it *cloudprovider.InstanceType) bool {
+		return len(it.Offerings.Available()) == 0
+	})), map[string]string{nodeClassLabel: nodeClass})
+}
+
+type priceKey struct {
+	platform     string
+	capacityType string
+}
+
+// cheapestPrices returns the price of the cheapest available offering of every platform and capacity type
+func cheapestPrices(instanceTypes []*cloudprovider.InstanceType) map[priceKey]float64 {
+	prices := map[priceKey]float64{}
+	for _, it := range instanceTypes {
+		platform := it.Requirements.Get(v1alpha1.LabelInstanceCPUPlatform).Any()
+		for _, offering := range it.Offerings.Available() {
+			key := priceKey{platform: platform, capacityType: offering.CapacityType()}
+			if price, ok := prices[key]; !ok || offering.Price < price {
+				prices[key] = offering.Price
+			}
+		}
+	}
+	return prices
+}
+
+func (c *Controller) Register(_ context.Context, m manager.Manager) error {
+	return controllerruntime.NewControllerManagedBy(m).
+		Named("pricing.metrics").
+		WatchesRawSource(singleton.Source()).
+		Complete(singleton.AsReconciler(c))
+}
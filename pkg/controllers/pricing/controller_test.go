@@ -0,0 +1,181 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pricing
+
+import (
+	"context"
+	"testing"
+
+	opmetrics "github.com/awslabs/operatorpkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/cloudprovider"
+
+	"github.com/tufitko/karpenter-provider-yandex/pkg/apis/v1alpha1"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/providers/instancetype"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/providers/instancetype/offering"
+	pricingprovider "github.com/tufitko/karpenter-provider-yandex/pkg/providers/pricing"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/yandex"
+)
+
+func newTestController(t *testing.T, objects ...client.Object) (*Controller, client.Client) {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed to register yandex types: %v", err)
+	}
+	kubeClient := fakeclient.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+	instanceTypeProvider := instancetype.NewDefaultProvider(
+		yandex.RegionRU,
+		instancetype.NewDefaultResolver(110),
+		offering.NewDefaultProvider(pricingprovider.NewDefaultProvider(yandex.RegionRU), offering.NewUnavailableOfferings()),
+		sets.New("ru-central1-a"),
+		yandex.CoreFraction100,
+		nil,
+	)
+	return NewController(kubeClient, instanceTypeProvider), kubeClient
+}
+
+func newTestNodeClass(name string, platforms ...string) *v1alpha1.YandexNodeClass {
+	nodeClass := &v1alpha1.YandexNodeClass{
+		Spec: v1alpha1.YandexNodeClassSpec{Platforms: platforms},
+		// instance types are offered in the zones of the nodeclass subnets
+		Status: v1alpha1.YandexNodeClassStatus{Subnets: []v1alpha1.Subnet{{ID: "subnet-a", ZoneID: "ru-central1-a"}}},
+	}
+	nodeClass.Name = name
+	return nodeClass
+}
+
+func TestReconcile_ExportsCheapestPrices(t *testing.T) {
+	platforms := []string{string(yandex.PlatformIntelCascadeLake), string(yandex.PlatformIntelIceLake)}
+	c, _ := newTestController(t, newTestNodeClass("prices", platforms...))
+
+	if _, err := c.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, platform := range platforms {
+		for _, capacityType := range []string{karpv1.CapacityTypeOnDemand, karpv1.CapacityTypeSpot} {
+			price := testutil.ToFloat64(CheapestPriceEstimate.(*opmetrics.PrometheusGauge).With(map[string]string{
+				nodeClassLabel:    "prices",
+				platformLabel:     platform,
+				capacityTypeLabel: capacityType,
+			}))
+			if price <= 0 {
+				t.Errorf("Expected a %s price of %s, got %v", capacityType, platform, price)
+			}
+		}
+	}
+	if series := testutil.CollectAndCount(CheapestPriceEstimate.(*opmetrics.PrometheusGauge).GaugeVec); series != 4 {
+		t.Errorf("Expected 4 prices of the configured platforms, got %d", series)
+	}
+	resetMetrics()
+}
+
+func resetMetrics() {
+	CheapestPriceEstimate.Reset()
+	OfferingAvailable.Reset()
+	OfferingPriceEstimate.Reset()
+	InstanceTypesWithoutOfferings.Reset()
+}
+
+func TestReconcile_ExportsOfferings(t *testing.T) {
+	ctx := context.Background()
+	nodeClass := newTestNodeClass("offerings", string(yandex.PlatformIntelIceLake))
+	c, kubeClient := newTestController(t, nodeClass)
+	defer resetMetrics()
+
+	if _, err := c.Reconcile(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	instanceTypes, err := c.instanceTypeProvider.List(ctx, nodeClass)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, it := range instanceTypes {
+		for _, o := range it.Offerings {
+			labels := map[string]string{
+				nodeClassLabel:    "offerings",
+				platformLabel:     string(yandex.PlatformIntelIceLake),
+				instanceTypeLabel: it.Name,
+				capacityTypeLabel: o.CapacityType(),
+				zoneLabel:         o.Zone(),
+			}
+			if available := testutil.ToFloat64(OfferingAvailable.(*opmetrics.PrometheusGauge).With(labels)); available != lo.Ternary(o.Available, 1.0, 0.0) {
+				t.Errorf("Offering %s/%s/%s: expected availability %v, got %v", it.Name, o.CapacityType(), o.Zone(), o.Available, available)
+			}
+			if price := testutil.ToFloat64(OfferingPriceEstimate.(*opmetrics.PrometheusGauge).With(labels)); price != o.Price {
+				t.Errorf("Offering %s/%s/%s: expected price %v, got %v", it.Name, o.CapacityType(), o.Zone(), o.Price, price)
+			}
+		}
+	}
+	withoutOfferings := lo.CountBy(instanceTypes, func(it *cloudprovider.InstanceType) bool { return len(it.Offerings.Available()) == 0 })
+	gauge := InstanceTypesWithoutOfferings.(*opmetrics.PrometheusGauge).With(map[string]string{nodeClassLabel: "offerings"})
+	if got := testutil.ToFloat64(gauge); got != float64(withoutOfferings) {
+		t.Errorf("Expected %d instance types without offerings, got %v", withoutOfferings, got)
+	}
+
+	// the instance types of a platform the nodeclass no longer lists must not keep their series
+	nodeClass.Spec.Platforms = []string{string(yandex.PlatformIntelCascadeLake)}
+	if err := kubeClient.Update(ctx, nodeClass); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := c.Reconcile(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	stale := map[string]string{nodeClassLabel: "offerings", platformLabel: string(yandex.PlatformIntelIceLake)}
+	if series := OfferingAvailable.(*opmetrics.PrometheusGauge).GaugeVec.DeletePartialMatch(stale); series != 0 {
+		t.Errorf("Expected no availability of the dropped platform, got %d series", series)
+	}
+	if series := OfferingPriceEstimate.(*opmetrics.PrometheusGauge).GaugeVec.DeletePartialMatch(stale); series != 0 {
+		t.Errorf("Expected no prices of the dropped platform, got %d series", series)
+	}
+	if series := testutil.CollectAndCount(OfferingAvailable.(*opmetrics.PrometheusGauge).GaugeVec); series == 0 {
+		t.Errorf("Expected the offerings of the listed platform to be exported")
+	}
+}
+
+func TestReconcile_RemovesPricesOfDeletedNodeClasses(t *testing.T) {
+	nodeClass := newTestNodeClass("deleted", string(yandex.PlatformIntelIceLake))
+	c, kubeClient := newTestController(t, nodeClass)
+
+	if _, err := c.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if series := testutil.CollectAndCount(CheapestPriceEstimate.(*opmetrics.PrometheusGauge).GaugeVec); series == 0 {
+		t.Fatalf("Expected the prices of the nodeclass to be exported")
+	}
+	if err := kubeClient.Delete(context.Background(), nodeClass); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := c.Reconcile(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if series := testutil.CollectAndCount(CheapestPriceEstimate.(*opmetrics.PrometheusGauge).GaugeVec); series != 0 {
+		t.Errorf("Expected the prices of the deleted nodeclass to be removed, got %d series", series)
+	}
+	if series := testutil.CollectAndCount(OfferingAvailable.(*opmetrics.PrometheusGauge).GaugeVec); series != 0 {
+		t.Errorf("Expected the offerings of the deleted nodeclass to be removed, got %d series", series)
+	}
+	if series := testutil.CollectAndCount(InstanceTypesWithoutOfferings.(*opmetrics.PrometheusGauge).GaugeVec); series != 0 {
+		t.Errorf("Expected the instance types without offerings of the deleted nodeclass to be removed, got %d series", series)
+	}
+}
@@ -0,0 +1,92 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pricing
+
+import (
+	opmetrics "github.com/awslabs/operatorpkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/karpenter/pkg/metrics"
+)
+
+const (
+	cloudProviderSubsystem = "cloudprovider"
+	nodeClassLabel         = "nodeclass"
+	platformLabel          = "platform"
+	instanceTypeLabel      = "instance_type"
+	capacityTypeLabel      = "capacity_type"
+	zoneLabel              = "zone"
+)
+
+var (
+	CheapestPriceEstimate = opmetrics.NewPrometheusGauge(
+		crmetrics.Registry,
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "nodeclass_cheapest_price_estimate",
+			Help:      "Estimated hourly price of the cheapest available instance type of a nodeclass, including the boot disk, based on nodeclass, platform and capacity type.",
+		},
+		[]string{
+			nodeClassLabel,
+			platformLabel,
+			capacityTypeLabel,
+		},
+	)
+	OfferingAvailable = opmetrics.NewPrometheusGauge(
+		crmetrics.Registry,
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "instance_type_offering_available",
+			Help:      "Instance type offering availability, based on nodeclass, platform, instance type, capacity type and zone. 1 if available, 0 otherwise.",
+		},
+		[]string{
+			nodeClassLabel,
+			platformLabel,
+			instanceTypeLabel,
+			capacityTypeLabel,
+			zoneLabel,
+		},
+	)
+	OfferingPriceEstimate = opmetrics.NewPrometheusGauge(
+		crmetrics.Registry,
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "instance_type_offering_price_estimate",
+			Help:      "Instance type offering estimated hourly price, including the boot disk, based on nodeclass, platform, instance type, capacity type and zone.",
+		},
+		[]string{
+			nodeClassLabel,
+			platformLabel,
+			instanceTypeLabel,
+			capacityTypeLabel,
+			zoneLabel,
+		},
+	)
+	InstanceTypesWithoutOfferings = opmetrics.NewPrometheusGauge(
+		crmetrics.Registry,
+		prometheus.GaugeOpts{
+			Namespace: metrics.Namespace,
+			Subsystem: cloudProviderSubsystem,
+			Name:      "instance_types_without_offerings",
+			Help:      "Number of instance types of a nodeclass without any available offering, based on nodeclass. Nodes are never launched with these instance types.",
+		},
+		[]string{
+			nodeClassLabel,
+		},
+	)
+)
@@ -0,0 +1,293 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/endpoint"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/k8s/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/operation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// API is an in-memory Yandex Cloud API serving the managed kubernetes and compute calls of the node group lifecycle
+// over gRPC. Unlike SDK it is used through the real SDK client, so the requests and responses the client builds and
+// parses are exercised too. Node groups come up with their instance running as soon as they are created
+type API struct {
+	mu sync.Mutex
+
+	Cluster    *k8s.Cluster
+	NodeGroups map[string]*k8s.NodeGroup
+	Instances  map[string]*compute.Instance
+	// Operations are the operations of every node group by node group id
+	Operations map[string][]*operation.Operation
+
+	server  *grpc.Server
+	calls   map[string]int
+	counter int
+}
+
+func NewAPI(cluster *k8s.Cluster) *API {
+	return &API{
+		Cluster:    cluster,
+		NodeGroups: map[string]*k8s.NodeGroup{},
+		Instances:  map[string]*compute.Instance{},
+		Operations: map[string][]*operation.Operation{},
+		calls:      map[string]int{},
+	}
+}
+
+// Start serves the API on a local port and returns its endpoint
+func (a *API) Start() (string, error) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	addr := lis.Addr().String()
+
+	a.server = grpc.NewServer(grpc.UnaryInterceptor(a.record))
+	endpoint.RegisterApiEndpointServiceServer(a.server, &apiEndpointServer{addr: addr})
+	k8s.RegisterClusterServiceServer(a.server, &clusterServer{api: a})
+	k8s.RegisterNodeGroupServiceServer(a.server, &nodeGroupServer{api: a})
+	compute.RegisterInstanceServiceServer(a.server, &instanceServer{api: a})
+	go func() { _ = a.server.Serve(lis) }()
+	return addr, nil
+}
+
+func (a *API) Stop() {
+	if a.server != nil {
+		a.server.Stop()
+	}
+}
+
+// Calls returns how many times the given gRPC method was called, e.g. "/yandex.cloud.k8s.v1.NodeGroupService/Create"
+func (a *API) Calls(method string) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.calls[method]
+}
+
+func (a *API) record(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	a.mu.Lock()
+	a.calls[info.FullMethod]++
+	a.mu.Unlock()
+	return handler(ctx, req)
+}
+
+func (a *API) nextID(prefix string) string {
+	a.counter++
+	return fmt.Sprintf("%s-%d", prefix, a.counter)
+}
+
+// newOperation returns a done operation with the given metadata and response
+func (a *API) newOperation(description string, metadata, response proto.Message) (*operation.Operation, error) {
+	md, err := anypb.New(metadata)
+	if err != nil {
+		return nil, err
+	}
+	op := &operation.Operation{
+		Id:          a.nextID("op"),
+		Description: description,
+		CreatedAt:   timestamppb.Now(),
+		Done:        true,
+		Metadata:    md,
+	}
+	if response != nil {
+		res, err := anypb.New(response)
+		if err != nil {
+			return nil, err
+		}
+		op.Result = &operation.Operation_Response{Response: res}
+	}
+	return op, nil
+}
+
+type apiEndpointServer struct {
+	addr string
+}
+
+func (s *apiEndpointServer) Get(_ context.Context, req *endpoint.GetApiEndpointRequest) (*endpoint.ApiEndpoint, error) {
+	return &endpoint.ApiEndpoint{Id: req.GetApiEndpointId(), Address: s.addr}, nil
+}
+
+func (s *apiEndpointServer) List(context.Context, *endpoint.ListApiEndpointsRequest) (*endpoint.ListApiEndpointsResponse, error) {
+	var endpoints []*endpoint.ApiEndpoint
+	for _, id := range []string{"managed-kubernetes", "compute", "operation"} {
+		endpoints = append(endpoints, &endpoint.ApiEndpoint{Id: id, Address: s.addr})
+	}
+	return &endpoint.ListApiEndpointsResponse{Endpoints: endpoints}, nil
+}
+
+type clusterServer struct {
+	k8s.UnimplementedClusterServiceServer
+	api *API
+}
+
+func (s *clusterServer) Get(_ context.Context, req *k8s.GetClusterRequest) (*k8s.Cluster, error) {
+	s.api.mu.Lock()
+	defer s.api.mu.Unlock()
+	if s.api.Cluster == nil || s.api.Cluster.GetId() != req.GetClusterId() {
+		return nil, grpcstatus.Errorf(codes.NotFound, "cluster %s not found", req.GetClusterId())
+	}
+	return s.api.Cluster, nil
+}
+
+type nodeGroupServer struct {
+	k8s.UnimplementedNodeGroupServiceServer
+	api *API
+}
+
+func (s *nodeGroupServer) Get(_ context.Context, req *k8s.GetNodeGroupRequest) (*k8s.NodeGroup, error) {
+	s.api.mu.Lock()
+	defer s.api.mu.Unlock()
+	ng, ok := s.api.NodeGroups[req.GetNodeGroupId()]
+	if !ok {
+		return nil, grpcstatus.Errorf(codes.NotFound, "node group %s not found", req.GetNodeGroupId())
+	}
+	return ng, nil
+}
+
+func (s *nodeGroupServer) List(_ context.Context, req *k8s.ListNodeGroupsRequest) (*k8s.ListNodeGroupsResponse, error) {
+	s.api.mu.Lock()
+	defer s.api.mu.Unlock()
+	var ngs []*k8s.NodeGroup
+	if req.GetFolderId() == s.api.Cluster.GetFolderId() {
+		for _, ng := range s.api.NodeGroups {
+			ngs = append(ngs, ng)
+		}
+	}
+	return &k8s.ListNodeGroupsResponse{NodeGroups: ngs}, nil
+}
+
+func (s *nodeGroupServer) Create(_ context.Context, req *k8s.CreateNodeGroupRequest) (*operation.Operation, error) {
+	s.api.mu.Lock()
+	defer s.api.mu.Unlock()
+	if req.GetClusterId() != s.api.Cluster.GetId() {
+		return nil, grpcstatus.Errorf(codes.NotFound, "cluster %s not found", req.GetClusterId())
+	}
+	locations := req.GetAllocationPolicy().GetLocations()
+	if len(locations) == 0 {
+		return nil, grpcstatus.Error(codes.InvalidArgument, "allocation policy has no locations")
+	}
+
+	ng := &k8s.NodeGroup{
+		Id:                s.api.nextID("ng"),
+		ClusterId:         req.GetClusterId(),
+		CreatedAt:         timestamppb.Now(),
+		Name:              req.GetName(),
+		Description:       req.GetDescription(),
+		Labels:            req.GetLabels(),
+		Status:            k8s.NodeGroup_RUNNING,
+		NodeTemplate:      req.GetNodeTemplate(),
+		ScalePolicy:       req.GetScalePolicy(),
+		AllocationPolicy:  req.GetAllocationPolicy(),
+		DeployPolicy:      req.GetDeployPolicy(),
+		MaintenancePolicy: req.GetMaintenancePolicy(),
+		NodeLabels:        req.GetNodeLabels(),
+		NodeTaints:        req.GetNodeTaints(),
+	}
+	s.api.NodeGroups[ng.Id] = ng
+	instance := &compute.Instance{
+		Id:         s.api.nextID("instance"),
+		FolderId:   s.api.Cluster.GetFolderId(),
+		CreatedAt:  timestamppb.Now(),
+		Name:       req.GetName(),
+		Labels:     map[string]string{"managed-kubernetes-node-group-id": ng.Id},
+		ZoneId:     locations[0].GetZoneId(),
+		PlatformId: req.GetNodeTemplate().GetPlatformId(),
+		Status:     compute.Instance_RUNNING,
+	}
+	s.api.Instances[instance.Id] = instance
+
+	op, err := s.api.newOperation("Create node group", &k8s.CreateNodeGroupMetadata{NodeGroupId: ng.Id}, ng)
+	if err != nil {
+		return nil, err
+	}
+	s.api.Operations[ng.Id] = append(s.api.Operations[ng.Id], op)
+	return op, nil
+}
+
+func (s *nodeGroupServer) Delete(_ context.Context, req *k8s.DeleteNodeGroupRequest) (*operation.Operation, error) {
+	s.api.mu.Lock()
+	defer s.api.mu.Unlock()
+	if _, ok := s.api.NodeGroups[req.GetNodeGroupId()]; !ok {
+		return nil, grpcstatus.Errorf(codes.NotFound, "node group %s not found", req.GetNodeGroupId())
+	}
+	delete(s.api.NodeGroups, req.GetNodeGroupId())
+	for id, instance := range s.api.Instances {
+		if instance.GetLabels()["managed-kubernetes-node-group-id"] == req.GetNodeGroupId() {
+			delete(s.api.Instances, id)
+		}
+	}
+	delete(s.api.Operations, req.GetNodeGroupId())
+
+	return s.api.newOperation("Delete node group", &k8s.DeleteNodeGroupMetadata{NodeGroupId: req.GetNodeGroupId()}, nil)
+}
+
+func (s *nodeGroupServer) ListOperations(_ context.Context, req *k8s.ListNodeGroupOperationsRequest) (*k8s.ListNodeGroupOperationsResponse, error) {
+	s.api.mu.Lock()
+	defer s.api.mu.Unlock()
+	if _, ok := s.api.NodeGroups[req.GetNodeGroupId()]; !ok {
+		return nil, grpcstatus.Errorf(codes.NotFound, "node group %s not found", req.GetNodeGroupId())
+	}
+	return &k8s.ListNodeGroupOperationsResponse{Operations: s.api.Operations[req.GetNodeGroupId()]}, nil
+}
+
+func (s *nodeGroupServer) ListNodes(_ context.Context, req *k8s.ListNodeGroupNodesRequest) (*k8s.ListNodeGroupNodesResponse, error) {
+	s.api.mu.Lock()
+	defer s.api.mu.Unlock()
+	if _, ok := s.api.NodeGroups[req.GetNodeGroupId()]; !ok {
+		return nil, grpcstatus.Errorf(codes.NotFound, "node group %s not found", req.GetNodeGroupId())
+	}
+	var nodes []*k8s.Node
+	for _, instance := range s.api.Instances {
+		if instance.GetLabels()["managed-kubernetes-node-group-id"] != req.GetNodeGroupId() {
+			continue
+		}
+		nodes = append(nodes, &k8s.Node{
+			Status: k8s.Node_READY,
+			CloudStatus: &k8s.Node_CloudStatus{
+				Id:     instance.GetId(),
+				Status: instance.GetStatus().String(),
+			},
+		})
+	}
+	return &k8s.ListNodeGroupNodesResponse{Nodes: nodes}, nil
+}
+
+type instanceServer struct {
+	compute.UnimplementedInstanceServiceServer
+	api *API
+}
+
+func (s *instanceServer) Get(_ context.Context, req *compute.GetInstanceRequest) (*compute.Instance, error) {
+	s.api.mu.Lock()
+	defer s.api.mu.Unlock()
+	instance, ok := s.api.Instances[req.GetInstanceId()]
+	if !ok {
+		return nil, grpcstatus.Errorf(codes.NotFound, "instance %s not found", req.GetInstanceId())
+	}
+	return instance, nil
+}
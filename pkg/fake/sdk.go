@@ -0,0 +1,382 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/tufitko/karpenter-provider-yandex/pkg/apis/v1alpha1"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/yandex"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/k8s/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var _ yandex.SDK = (*SDK)(nil)
+
+// CreateFixedNodeGroupInput records the arguments of a CreateFixedNodeGroup call
+type CreateFixedNodeGroupInput struct {
+	Name           string
+	IdempotencyKey string
+	Labels         map[string]string
+	NodeLabels     map[string]string
+	Taints         []corev1.Taint
+	PlatformId     yandex.PlatformId
+	CoreFraction   yandex.CoreFraction
+	CPU            resource.Quantity
+	Memory         resource.Quantity
+	Preemptible    bool
+	ZoneId         string
+	SubnetId       string
+	NodeClass      *v1alpha1.YandexNodeClass
+	DiskType       string
+	DiskSize       int64
+	UserData       string
+}
+
+// SDK is an in-memory implementation of yandex.SDK for tests
+type SDK struct {
+	mu sync.Mutex
+
+	Network        string
+	Subnets        []*vpc.Subnet
+	UsedIPs        map[string]int
+	MaxPods        int
+	NodeGroups     map[string]*k8s.NodeGroup
+	Nodes          map[string]*k8s.Node
+	SecurityGroups map[string]bool
+	Cluster        *k8s.Cluster
+	// InstanceZones is the zone of every instance by instance id
+	InstanceZones map[string]string
+	// QuotaLimits is returned by Quotas keyed by quota id
+	QuotaLimits map[string]yandex.Quota
+
+	CreateFixedNodeGroupInputs []CreateFixedNodeGroupInput
+	DeletedNodeGroups          []string
+
+	ListNetworkSubnetsError    error
+	CreateFixedNodeGroupError  error
+	DeleteNodeGroupError       error
+	SecurityGroupExistsError   error
+	ListNodeGroupsError        error
+	GetNodeFromNodeGroupError  error
+	GetClusterError            error
+	QuotasError                error
+	GetNodeGroupByProviderIdFn func(providerId string) (*k8s.NodeGroup, error)
+	ProviderIdForFn            func(nodeGroupId string) (string, error)
+	// CreateFixedNodeGroupHook is called at the start of every CreateFixedNodeGroup call, outside of any lock
+	CreateFixedNodeGroupHook func()
+
+	calls           map[string]int
+	idempotencyKeys map[string]string
+}
+
+func NewSDK() *SDK {
+	return &SDK{
+		Network:        "test-network",
+		UsedIPs:        map[string]int{},
+		MaxPods:        110,
+		NodeGroups:     map[string]*k8s.NodeGroup{},
+		Nodes:          map[string]*k8s.Node{},
+		SecurityGroups: map[string]bool{},
+		InstanceZones:  map[string]string{},
+		QuotaLimits:    map[string]yandex.Quota{},
+		Cluster: &k8s.Cluster{
+			Id:             "test-cluster",
+			Status:         k8s.Cluster_RUNNING,
+			ReleaseChannel: k8s.ReleaseChannel_REGULAR,
+		},
+		calls:           map[string]int{},
+		idempotencyKeys: map[string]string{},
+	}
+}
+
+// Calls returns how many times the given SDK method was called
+func (s *SDK) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+func (s *SDK) record(method string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.calls == nil {
+		s.calls = map[string]int{}
+	}
+	s.calls[method]++
+}
+
+func (s *SDK) NetworkID(_ context.Context) (string, error) {
+	s.record("NetworkID")
+	return s.Network, nil
+}
+
+func (s *SDK) ListNetworkSubnets(_ context.Context) ([]*vpc.Subnet, error) {
+	s.record("ListNetworkSubnets")
+	if s.ListNetworkSubnetsError != nil {
+		return nil, s.ListNetworkSubnetsError
+	}
+	return s.Subnets, nil
+}
+
+func (s *SDK) UsedIPsInSubnet(_ context.Context, subnetId string) (int, error) {
+	s.record("UsedIPsInSubnet")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.UsedIPs[subnetId], nil
+}
+
+func (s *SDK) MaxPodsPerNode(_ context.Context) (int, error) {
+	s.record("MaxPodsPerNode")
+	return s.MaxPods, nil
+}
+
+func (s *SDK) GetCluster(_ context.Context) (*k8s.Cluster, error) {
+	s.record("GetCluster")
+	if s.GetClusterError != nil {
+		return nil, s.GetClusterError
+	}
+	return s.Cluster, nil
+}
+
+func (s *SDK) CreateFixedNodeGroup(
+	_ context.Context,
+	name string,
+	idempotencyKey string,
+	labels map[string]string,
+	nodeLabels map[string]string,
+	taints []corev1.Taint,
+	platformId yandex.PlatformId,
+	coreFraction yandex.CoreFraction,
+	cpu resource.Quantity,
+	mem resource.Quantity,
+	preemptible bool,
+	zoneId string,
+	subnetId string,
+	nodeclass *v1alpha1.YandexNodeClass,
+	diskType string,
+	diskSize int64,
+	userData string,
+) (string, string, error) {
+	if s.CreateFixedNodeGroupHook != nil {
+		s.CreateFixedNodeGroupHook()
+	}
+	s.record("CreateFixedNodeGroup")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.CreateFixedNodeGroupInputs = append(s.CreateFixedNodeGroupInputs, CreateFixedNodeGroupInput{
+		Name:           name,
+		IdempotencyKey: idempotencyKey,
+		Labels:         labels,
+		NodeLabels:     nodeLabels,
+		Taints:         taints,
+		PlatformId:     platformId,
+		CoreFraction:   coreFraction,
+		CPU:            cpu,
+		Memory:         mem,
+		Preemptible:    preemptible,
+		ZoneId:         zoneId,
+		SubnetId:       subnetId,
+		NodeClass:      nodeclass,
+		DiskType:       diskType,
+		DiskSize:       diskSize,
+		UserData:       userData,
+	})
+	if s.CreateFixedNodeGroupError != nil {
+		return "", "", s.CreateFixedNodeGroupError
+	}
+	// mirror the server-side deduplication of retried creates
+	if id, ok := s.idempotencyKeys[idempotencyKey]; ok && idempotencyKey != "" {
+		return id, createOperationId(id), nil
+	}
+
+	id := fmt.Sprintf("ng-%d", len(s.CreateFixedNodeGroupInputs))
+	s.idempotencyKeys[idempotencyKey] = id
+	nodeGroupLabels := map[string]string{"managed-by": "karpenter"}
+	for k, v := range labels {
+		nodeGroupLabels[k] = v
+	}
+	for k, v := range nodeLabels {
+		nodeGroupLabels[k] = v
+	}
+	s.NodeGroups[id] = &k8s.NodeGroup{
+		Id:         id,
+		Name:       name,
+		Labels:     nodeGroupLabels,
+		NodeLabels: nodeLabels,
+		Status:     k8s.NodeGroup_RUNNING,
+		CreatedAt:  timestamppb.Now(),
+		NodeTemplate: &k8s.NodeTemplate{
+			PlatformId: string(platformId),
+			ResourcesSpec: &k8s.ResourcesSpec{
+				CoreFraction: int64(coreFraction),
+				Cores:        cpu.Value(),
+				Memory:       mem.Value(),
+				Gpus:         (&yandex.InstanceType{Platform: platformId, CPU: cpu}).GPUs(),
+			},
+			BootDiskSpec: &k8s.DiskSpec{
+				DiskTypeId: diskType,
+				DiskSize:   diskSize,
+			},
+			SchedulingPolicy: &k8s.SchedulingPolicy{
+				Preemptible: preemptible,
+			},
+			NetworkInterfaceSpecs: []*k8s.NetworkInterfaceSpec{
+				{SubnetIds: []string{subnetId}, SecurityGroupIds: nodeclass.Spec.SecurityGroups},
+			},
+		},
+		AllocationPolicy: &k8s.NodeGroupAllocationPolicy{
+			Locations: []*k8s.NodeGroupLocation{{ZoneId: zoneId, SubnetId: subnetId}},
+		},
+	}
+	s.Nodes[id] = &k8s.Node{
+		CloudStatus: &k8s.Node_CloudStatus{
+			Id:     "instance-" + id,
+			Status: "RUNNING",
+		},
+	}
+	s.InstanceZones["instance-"+id] = zoneId
+	return id, createOperationId(id), nil
+}
+
+// createOperationId returns the id of the operation that created a node group
+func createOperationId(nodeGroupId string) string {
+	return "op-create-" + nodeGroupId
+}
+
+func (s *SDK) DeleteNodeGroup(_ context.Context, nodeGroupId string) error {
+	s.record("DeleteNodeGroup")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.DeleteNodeGroupError != nil {
+		return s.DeleteNodeGroupError
+	}
+	s.DeletedNodeGroups = append(s.DeletedNodeGroups, nodeGroupId)
+	delete(s.NodeGroups, nodeGroupId)
+	delete(s.Nodes, nodeGroupId)
+	return nil
+}
+
+func (s *SDK) GetNodeGroup(_ context.Context, nodeGroupId string) (*k8s.NodeGroup, error) {
+	s.record("GetNodeGroup")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ng, ok := s.NodeGroups[nodeGroupId]
+	if !ok {
+		return nil, fmt.Errorf("node group %s not found", nodeGroupId)
+	}
+	return ng, nil
+}
+
+func (s *SDK) ProviderIdFor(_ context.Context, nodeGroupId string) (string, error) {
+	s.record("ProviderIdFor")
+	if s.ProviderIdForFn != nil {
+		return s.ProviderIdForFn(nodeGroupId)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	node, ok := s.Nodes[nodeGroupId]
+	if !ok || node.GetCloudStatus().GetId() == "" {
+		return "", fmt.Errorf("not found")
+	}
+	return "yandex://" + node.GetCloudStatus().GetId(), nil
+}
+
+func (s *SDK) InstanceZone(_ context.Context, providerId string) (string, error) {
+	s.record("InstanceZone")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	zone, ok := s.InstanceZones[strings.TrimPrefix(providerId, "yandex://")]
+	if !ok {
+		return "", fmt.Errorf("instance %s not found", providerId)
+	}
+	return zone, nil
+}
+
+func (s *SDK) GetNodeGroupByProviderId(_ context.Context, providerId string) (*k8s.NodeGroup, error) {
+	s.record("GetNodeGroupByProviderId")
+	if s.GetNodeGroupByProviderIdFn != nil {
+		return s.GetNodeGroupByProviderIdFn(providerId)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, node := range s.Nodes {
+		if "yandex://"+node.GetCloudStatus().GetId() == providerId {
+			if ng, ok := s.NodeGroups[id]; ok {
+				return ng, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("instance %s not found", providerId)
+}
+
+func (s *SDK) ListNodeGroups(_ context.Context) ([]*k8s.NodeGroup, error) {
+	s.record("ListNodeGroups")
+	if s.ListNodeGroupsError != nil {
+		return nil, s.ListNodeGroupsError
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ngs := make([]*k8s.NodeGroup, 0, len(s.NodeGroups))
+	for _, ng := range s.NodeGroups {
+		ngs = append(ngs, ng)
+	}
+	return ngs, nil
+}
+
+func (s *SDK) GetNodeFromNodeGroup(_ context.Context, nodeGroupId string) (*k8s.Node, error) {
+	s.record("GetNodeFromNodeGroup")
+	if s.GetNodeFromNodeGroupError != nil {
+		return nil, s.GetNodeFromNodeGroupError
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	node, ok := s.Nodes[nodeGroupId]
+	if !ok {
+		return nil, fmt.Errorf("nodes not found")
+	}
+	return node, nil
+}
+
+func (s *SDK) SecurityGroupExists(_ context.Context, securityGroupId string) (bool, error) {
+	s.record("SecurityGroupExists")
+	if s.SecurityGroupExistsError != nil {
+		return false, s.SecurityGroupExistsError
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.SecurityGroups[securityGroupId], nil
+}
+
+func (s *SDK) Quotas(_ context.Context) (map[string]yandex.Quota, error) {
+	s.record("Quotas")
+	if s.QuotasError != nil {
+		return nil, s.QuotasError
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	quotas := make(map[string]yandex.Quota, len(s.QuotaLimits))
+	for id, quota := range s.QuotaLimits {
+		quotas[id] = quota
+	}
+	return quotas, nil
+}
@@ -22,14 +22,19 @@ import (
 	"time"
 
 	"github.com/patrickmn/go-cache"
+	"github.com/samber/lo"
 	"github.com/tufitko/karpenter-provider-yandex/pkg/operator/options"
 	"github.com/tufitko/karpenter-provider-yandex/pkg/providers/instancetype/offering"
 	"github.com/tufitko/karpenter-provider-yandex/pkg/providers/pricing"
+	nodeclaimutils "github.com/tufitko/karpenter-provider-yandex/pkg/utils/nodeclaim"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/k8s/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/kubernetes"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
 	"sigs.k8s.io/karpenter/pkg/operator"
 
 	"github.com/tufitko/karpenter-provider-yandex/pkg/providers/instancetype"
@@ -50,6 +55,7 @@ type Operator struct {
 	ValidationCache      *cache.Cache
 	InstanceTypeProvider instancetype.Provider
 	SubnetProvider       subnet.Provider
+	UnavailableOfferings *offering.UnavailableOfferings
 }
 
 func NewOperator(ctx context.Context, operator *operator.Operator) (context.Context, *Operator) {
@@ -57,12 +63,22 @@ func NewOperator(ctx context.Context, operator *operator.Operator) (context.Cont
 
 	log.V(1).Info("initializing yandex cloud provider operator")
 
-	sdk, err := yandexsdk.NewSDK(ctx, options.FromContext(ctx).ClusterID)
+	if err := loadClusterConfig(ctx, operator.KubernetesInterface, options.FromContext(ctx)); err != nil {
+		log.Error(err, "failed to load cluster config")
+		os.Exit(1)
+	}
+
+	sdk, err := yandexsdk.NewSDK(ctx, options.FromContext(ctx).ClusterID, options.FromContext(ctx).FolderID)
 	if err != nil {
 		log.Error(err, "failed to build yandex sdk")
 		os.Exit(1)
 	}
 
+	if err := checkCluster(ctx, sdk, options.FromContext(ctx).ClusterID); err != nil {
+		log.Error(err, "failed to check cluster")
+		os.Exit(1)
+	}
+
 	cachedSdk := yandexsdk.NewCachedSDK(sdk)
 
 	maxPodsPerNode, err := sdk.MaxPodsPerNode(ctx)
@@ -71,15 +87,16 @@ func NewOperator(ctx context.Context, operator *operator.Operator) (context.Cont
 		os.Exit(1)
 	}
 
-	azs := sets.New[string]()
 	subnets, err := sdk.ListNetworkSubnets(ctx)
 	if err != nil {
 		log.Error(err, "failed to list network subnets")
 		os.Exit(1)
 	}
 
-	for _, s := range subnets {
-		azs.Insert(s.ZoneId)
+	azs, err := zonesFromSubnets(subnets)
+	if err != nil {
+		log.Error(err, "failed to discover availability zones")
+		os.Exit(1)
 	}
 
 	kubeDNSIP, err := KubeDNSIP(ctx, operator.KubernetesInterface)
@@ -89,13 +106,47 @@ func NewOperator(ctx context.Context, operator *operator.Operator) (context.Cont
 		log.WithValues("kube-dns-ip", kubeDNSIP).V(1).Info("discovered kube dns")
 	}
 
+	if err := operator.Manager.GetFieldIndexer().IndexField(ctx, &karpv1.NodeClaim{}, nodeclaimutils.NodeGroupIDIndex, nodeclaimutils.NodeGroupIDIndexFunc); err != nil {
+		log.Error(err, "failed to setup nodeclaim node group id indexer")
+		os.Exit(1)
+	}
+
 	validationCache := cache.New(ValidationCacheTTL, DefaultCleanupInterval)
 
-	subnetProvider := subnet.NewDefaultProvider(sdk, cache.New(DefaultCacheTTL, DefaultCleanupInterval))
-	pricingProvider := pricing.NewDefaultProvider()
+	subnetProvider := subnet.NewDefaultProvider(sdk, cache.New(DefaultCacheTTL, DefaultCleanupInterval), options.FromContext(ctx).IPsPerNode)
+	region := options.FromContext(ctx).Region
+	defaultPricingProvider := pricing.NewDefaultProvider(region)
+	if err := defaultPricingProvider.ValidateCurrencies(options.FromContext(ctx).MultiRegion); err != nil {
+		log.Error(err, "failed to load pricing")
+		os.Exit(1)
+	}
+	var pricingProvider pricing.Provider = defaultPricingProvider
+	if path := options.FromContext(ctx).PricingFile; path != "" {
+		if err := defaultPricingProvider.WatchFile(ctx, path); err != nil {
+			log.Error(err, "failed to watch price file")
+			os.Exit(1)
+		}
+	}
+	if endpoint := options.FromContext(ctx).PricingEndpoint; endpoint != "" {
+		httpPricingProvider := pricing.NewHTTPProvider(endpoint, region, options.FromContext(ctx).MultiRegion)
+		if err := httpPricingProvider.Start(ctx, options.FromContext(ctx).PricingRefreshInterval); err != nil {
+			log.Error(err, "failed to read prices")
+			os.Exit(1)
+		}
+		pricingProvider = httpPricingProvider
+	}
+	if discounts := options.FromContext(ctx).CommittedDiscounts; len(discounts) > 0 {
+		pricingProvider = pricing.NewCommittedProvider(pricingProvider, lo.MapKeys(discounts, func(_ float64, platform string) yandexsdk.PlatformId {
+			return yandexsdk.PlatformId(platform)
+		}))
+	}
 	itResolver := instancetype.NewDefaultResolver(maxPodsPerNode)
-	offeringProvider := offering.NewDefaultProvider(pricingProvider)
-	instanceTypeProvider := instancetype.NewDefaultProvider(itResolver, offeringProvider, azs)
+	unavailableOfferings := offering.NewUnavailableOfferings()
+	offeringProvider := offering.NewDefaultProvider(pricingProvider, unavailableOfferings)
+	spotDisabledPlatforms := sets.New(lo.Map(options.FromContext(ctx).SpotDisabledPlatforms, func(platform string, _ int) yandexsdk.PlatformId {
+		return yandexsdk.PlatformId(platform)
+	})...)
+	instanceTypeProvider := instancetype.NewDefaultProvider(region, itResolver, offeringProvider, azs, yandexsdk.CoreFraction(options.FromContext(ctx).DefaultCoreFraction), spotDisabledPlatforms)
 
 	log.V(1).Info("yandex cloud provider operator initialized")
 
@@ -105,7 +156,60 @@ func NewOperator(ctx context.Context, operator *operator.Operator) (context.Cont
 		ValidationCache:      validationCache,
 		InstanceTypeProvider: instanceTypeProvider,
 		SubnetProvider:       subnetProvider,
+		UnavailableOfferings: unavailableOfferings,
+	}
+}
+
+// loadClusterConfig fills the cluster id and folder id options left empty from the cluster config ConfigMap, if one
+// is configured. Explicit options take precedence over the ConfigMap
+func loadClusterConfig(ctx context.Context, kubernetesInterface kubernetes.Interface, opts *options.Options) error {
+	if opts.ClusterConfigConfigMap != "" {
+		if kubernetesInterface == nil {
+			return fmt.Errorf("no K8s client provided")
+		}
+		namespace, name, _ := opts.ClusterConfigConfigMapKey()
+		cm, err := kubernetesInterface.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("getting cluster config configmap %s, %w", opts.ClusterConfigConfigMap, err)
+		}
+		opts.ClusterID = lo.CoalesceOrEmpty(opts.ClusterID, cm.Data["clusterID"])
+		opts.FolderID = lo.CoalesceOrEmpty(opts.FolderID, cm.Data["folderID"])
+	}
+	if opts.ClusterID == "" {
+		return fmt.Errorf("missing cluster id, set cluster-name or the clusterID key of the cluster config configmap")
+	}
+	return nil
+}
+
+// checkCluster ensures the configured cluster exists and is running, so that a misconfigured cluster id fails the
+// startup rather than every later reconcile
+func checkCluster(ctx context.Context, sdk yandexsdk.SDK, clusterID string) error {
+	cluster, err := sdk.GetCluster(ctx)
+	if err != nil {
+		if yandexsdk.IsNotFound(err) {
+			return fmt.Errorf("cluster %s not found, check cluster-name and the credentials, %w", clusterID, err)
+		}
+		return fmt.Errorf("getting cluster %s, %w", clusterID, err)
+	}
+	if cluster.GetStatus() != k8s.Cluster_RUNNING {
+		return fmt.Errorf("cluster %s is %s, expected RUNNING", clusterID, cluster.GetStatus())
+	}
+	return nil
+}
+
+// zonesFromSubnets returns the availability zones covered by the cluster network subnets. Without any zone
+// every instance type would end up with no offerings, so an empty set is reported as an error
+func zonesFromSubnets(subnets []*vpc.Subnet) (sets.Set[string], error) {
+	azs := sets.New[string]()
+	for _, s := range subnets {
+		if s.GetZoneId() != "" {
+			azs.Insert(s.GetZoneId())
+		}
+	}
+	if azs.Len() == 0 {
+		return nil, fmt.Errorf("no availability zones found, the cluster network has no subnets or they are not visible with the current credentials")
 	}
+	return azs, nil
 }
 
 func KubeDNSIP(ctx context.Context, kubernetesInterface kubernetes.Interface) (net.IP, error) {
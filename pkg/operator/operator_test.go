@@ -0,0 +1,167 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package operator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tufitko/karpenter-provider-yandex/pkg/fake"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/operator/options"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/k8s/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekubernetes "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestZonesFromSubnets(t *testing.T) {
+	zones, err := zonesFromSubnets([]*vpc.Subnet{
+		{Id: "subnet-a", ZoneId: "ru-central1-a"},
+		{Id: "subnet-a2", ZoneId: "ru-central1-a"},
+		{Id: "subnet-b", ZoneId: "ru-central1-b"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if zones.Len() != 2 || !zones.HasAll("ru-central1-a", "ru-central1-b") {
+		t.Errorf("Expected zones ru-central1-a and ru-central1-b, got %v", zones.UnsortedList())
+	}
+}
+
+func TestZonesFromSubnets_Empty(t *testing.T) {
+	testCases := []struct {
+		name    string
+		subnets []*vpc.Subnet
+	}{
+		{name: "No subnets", subnets: nil},
+		{name: "Subnets without zones", subnets: []*vpc.Subnet{{Id: "subnet-a"}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := zonesFromSubnets(tc.subnets)
+			if err == nil {
+				t.Fatalf("Expected an error for an empty zone set")
+			}
+			if !strings.Contains(err.Error(), "no availability zones found") {
+				t.Errorf("Expected a clear error message, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadClusterConfig(t *testing.T) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "karpenter", Name: "cluster-config"},
+		Data:       map[string]string{"clusterID": "cluster-from-configmap", "folderID": "folder-from-configmap"},
+	}
+
+	testCases := []struct {
+		name              string
+		opts              options.Options
+		expectedClusterID string
+		expectedFolderID  string
+		expectError       bool
+	}{
+		{
+			name:              "ConfigMap fills empty options",
+			opts:              options.Options{ClusterConfigConfigMap: "karpenter/cluster-config"},
+			expectedClusterID: "cluster-from-configmap",
+			expectedFolderID:  "folder-from-configmap",
+		},
+		{
+			name:              "Explicit options take precedence",
+			opts:              options.Options{ClusterID: "explicit-cluster", ClusterConfigConfigMap: "karpenter/cluster-config"},
+			expectedClusterID: "explicit-cluster",
+			expectedFolderID:  "folder-from-configmap",
+		},
+		{
+			name:              "No ConfigMap",
+			opts:              options.Options{ClusterID: "explicit-cluster"},
+			expectedClusterID: "explicit-cluster",
+		},
+		{
+			name:        "Missing ConfigMap",
+			opts:        options.Options{ClusterConfigConfigMap: "karpenter/missing"},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := tc.opts
+			err := loadClusterConfig(context.Background(), fakekubernetes.NewSimpleClientset(configMap), &opts)
+			if (err != nil) != tc.expectError {
+				t.Fatalf("Expected error=%t, got %v", tc.expectError, err)
+			}
+			if tc.expectError {
+				return
+			}
+			if opts.ClusterID != tc.expectedClusterID {
+				t.Errorf("Expected cluster id %q, got %q", tc.expectedClusterID, opts.ClusterID)
+			}
+			if opts.FolderID != tc.expectedFolderID {
+				t.Errorf("Expected folder id %q, got %q", tc.expectedFolderID, opts.FolderID)
+			}
+		})
+	}
+}
+
+func TestCheckCluster(t *testing.T) {
+	testCases := []struct {
+		name          string
+		status        k8s.Cluster_Status
+		err           error
+		expectedError string
+	}{
+		{
+			name:   "Running cluster",
+			status: k8s.Cluster_RUNNING,
+		},
+		{
+			name:          "Missing cluster",
+			err:           grpcstatus.Error(codes.NotFound, "cluster not found"),
+			expectedError: "cluster test-cluster not found",
+		},
+		{
+			name:          "Stopped cluster",
+			status:        k8s.Cluster_STOPPED,
+			expectedError: "cluster test-cluster is STOPPED, expected RUNNING",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sdk := fake.NewSDK()
+			sdk.Cluster.Status = tc.status
+			sdk.GetClusterError = tc.err
+
+			err := checkCluster(context.Background(), sdk, "test-cluster")
+			if tc.expectedError == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.expectedError) {
+				t.Errorf("Expected error %q, got %v", tc.expectedError, err)
+			}
+		})
+	}
+}
@@ -20,7 +20,13 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/tufitko/karpenter-provider-yandex/pkg/yandex"
+	"k8s.io/apimachinery/pkg/util/validation"
 	coreoptions "sigs.k8s.io/karpenter/pkg/operator/options"
 	"sigs.k8s.io/karpenter/pkg/utils/env"
 )
@@ -32,11 +38,64 @@ func init() {
 type optionsKey struct{}
 
 type Options struct {
-	ClusterID string
+	ClusterID                  string
+	FolderID                   string
+	ClusterConfigConfigMap     string
+	IPsPerNode                 int
+	NodeRepairToleration       time.Duration
+	AutoRepairRepairToleration time.Duration
+	DefaultCoreFraction        int
+	SafeDelete                 bool
+	DefaultNodeLabels          map[string]string
+	SpotDisabledPlatforms      []string
+	PricingFile                string
+	PricingEndpoint            string
+	PricingRefreshInterval     time.Duration
+	MaxConcurrentCreates       int
+	ProviderIDRetryTimeout     time.Duration
+	DuplicateGCGracePeriod     time.Duration
+	OrphanGCGracePeriod        time.Duration
+	CommittedDiscounts         map[string]float64
+	Region                     string
+	MultiRegion                bool
+
+	// regionSet is whether the region was set explicitly rather than defaulted
+	regionSet bool
+	// errors parsing the environment defaults of map options, reported by Validate unless the flag overrides them
+	defaultNodeLabelsErr  error
+	committedDiscountsErr error
 }
 
 func (o *Options) AddFlags(fs *coreoptions.FlagSet) {
 	fs.StringVar(&o.ClusterID, "cluster-name", env.WithDefaultString("CLUSTER_ID", ""), "[REQUIRED] The kubernetes cluster name for resource discovery.")
+	fs.StringVar(&o.Region, "region", env.WithDefaultString("REGION", yandex.RegionRU), "The Yandex Cloud region of the cluster, selecting the built-in instance configurations and prices. Only ru has them.")
+	_, o.regionSet = os.LookupEnv("REGION")
+	fs.BoolVarWithEnv(&o.MultiRegion, "multi-region", "MULTI_REGION", false, "Allow price tables quoted in different currencies to be loaded, with every region priced by its own table. Requires the region to be set explicitly.")
+	fs.StringVar(&o.FolderID, "folder-id", env.WithDefaultString("FOLDER_ID", ""), "A folder to look up node groups in next to the folder of the cluster, and to read quotas of instead of it.")
+	fs.StringVar(&o.ClusterConfigConfigMap, "cluster-config-configmap", env.WithDefaultString("CLUSTER_CONFIG_CONFIGMAP", ""), "A namespace/name ConfigMap read at startup for the clusterID and folderID keys. Explicit cluster-name and folder-id options take precedence.")
+	// Managed Kubernetes assigns pod IPs from the cluster IPv4 range rather than from the node subnets, with either
+	// Calico or Cilium, so a node only takes the IP of its VM from the subnet. Only raise it for nodes with extra
+	// network interfaces or pod networks routed through the node subnets.
+	fs.IntVar(&o.IPsPerNode, "ips-per-node", env.WithDefaultInt("IPS_PER_NODE", 1), "The number of subnet IPs reserved by every node, used to estimate how many nodes fit into a subnet. Pods get their IPs from the cluster IPv4 range, so a node only takes the IP of its VM by default.")
+	fs.DurationVar(&o.NodeRepairToleration, "node-repair-toleration", env.WithDefaultDuration("NODE_REPAIR_TOLERATION", 10*time.Minute), "How long an unhealthy node is tolerated before Karpenter repairs it, when Yandex Cloud auto-repair is disabled.")
+	fs.DurationVar(&o.AutoRepairRepairToleration, "auto-repair-node-repair-toleration", env.WithDefaultDuration("AUTO_REPAIR_NODE_REPAIR_TOLERATION", 30*time.Minute), "How long an unhealthy node is tolerated before Karpenter repairs it, when Yandex Cloud auto-repair is enabled. Should leave Yandex Cloud enough time to repair the node itself.")
+	fs.IntVar(&o.DefaultCoreFraction, "default-core-fraction", env.WithDefaultInt("DEFAULT_CORE_FRACTION", 100), "The core fraction used for nodeclasses that do not specify core_fractions. One of 5, 20, 50 or 100.")
+	o.DefaultNodeLabels = map[string]string{}
+	o.defaultNodeLabelsErr = (*nodeLabelsValue)(&o.DefaultNodeLabels).Set(env.WithDefaultString("DEFAULT_NODE_LABELS", ""))
+	fs.Var((*nodeLabelsValue)(&o.DefaultNodeLabels), "default-node-labels", "Comma-separated key=value labels added to the nodes of every nodeclass. Nodeclass nodeLabels take precedence.")
+	fs.BoolVarWithEnv(&o.SafeDelete, "safe-delete", "SAFE_DELETE", false, "Delete the node group of a NodeClaim only once its node is cordoned and drained of all but daemonset and static pods.")
+	fs.IntVar(&o.MaxConcurrentCreates, "max-concurrent-creates", env.WithDefaultInt("MAX_CONCURRENT_CREATES", 10), "The number of node groups created at the same time, further creates wait for one of them to finish.")
+	fs.DurationVar(&o.ProviderIDRetryTimeout, "provider-id-retry-timeout", env.WithDefaultDuration("PROVIDER_ID_RETRY_TIMEOUT", 10*time.Second), "How long getting a running node group waits for its node to report a provider id before failing. 0 disables waiting.")
+	fs.DurationVar(&o.DuplicateGCGracePeriod, "duplicate-gc-grace-period", env.WithDefaultDuration("DUPLICATE_GC_GRACE_PERIOD", 2*time.Minute), "How old a provisioning node group that failed with ALREADY_EXISTS must be before garbage collection deletes it as a duplicate.")
+	fs.DurationVar(&o.OrphanGCGracePeriod, "orphan-gc-grace-period", env.WithDefaultDuration("ORPHAN_GC_GRACE_PERIOD", 5*time.Minute), "How old a node group without a NodeClaim must be before garbage collection deletes it, covering a NodeClaim cache that lags behind.")
+	fs.StringVar(&o.PricingFile, "pricing-file", env.WithDefaultString("PRICING_FILE", ""), "A JSON price table, e.g. mounted from a ConfigMap, reloaded whenever it changes. The built-in prices are used while it is missing or invalid.")
+	fs.StringVar(&o.PricingEndpoint, "pricing-endpoint", env.WithDefaultString("PRICING_ENDPOINT", ""), "An HTTP endpoint serving a JSON price table in the format of pricing-file, e.g. a service quoting negotiated rates. Cannot be combined with pricing-file.")
+	fs.DurationVar(&o.PricingRefreshInterval, "pricing-refresh-interval", env.WithDefaultDuration("PRICING_REFRESH_INTERVAL", time.Hour), "How often the price table of pricing-endpoint is read again.")
+	o.CommittedDiscounts = map[string]float64{}
+	o.committedDiscountsErr = (*discountsValue)(&o.CommittedDiscounts).Set(env.WithDefaultString("COMMITTED_DISCOUNTS", ""))
+	fs.Var((*discountsValue)(&o.CommittedDiscounts), "committed-discounts", "Comma-separated platform=percent discounts of a committed volume of services, e.g. standard-v3=20, applied to the on-demand prices of the platforms.")
+	_ = (*listValue)(&o.SpotDisabledPlatforms).Set(env.WithDefaultString("SPOT_DISABLED_PLATFORMS", ""))
+	fs.Var((*listValue)(&o.SpotDisabledPlatforms), "spot-disabled-platforms", "Comma-separated platform ids, e.g. standard-v1, whose instance types are offered as on-demand only.")
 }
 
 func (o *Options) Parse(fs *coreoptions.FlagSet, args ...string) error {
@@ -46,12 +105,106 @@ func (o *Options) Parse(fs *coreoptions.FlagSet, args ...string) error {
 		}
 		return fmt.Errorf("parsing flags, %w", err)
 	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "default-node-labels":
+			o.defaultNodeLabelsErr = nil
+		case "committed-discounts":
+			o.committedDiscountsErr = nil
+		case "region":
+			o.regionSet = true
+		}
+	})
 	if err := o.Validate(); err != nil {
 		return fmt.Errorf("validating options, %w", err)
 	}
 	return nil
 }
 
+// nodeLabelsValue is a flag.Value of comma-separated key=value node labels
+type nodeLabelsValue map[string]string
+
+func (v *nodeLabelsValue) String() string {
+	pairs := make([]string, 0, len(*v))
+	for key, value := range *v {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (v *nodeLabelsValue) Set(s string) error {
+	labels := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("expected key=value, got %q", pair)
+		}
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid label key %q, %s", key, strings.Join(errs, ", "))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return fmt.Errorf("invalid label value %q, %s", value, strings.Join(errs, ", "))
+		}
+		labels[key] = value
+	}
+	*v = labels
+	return nil
+}
+
+// discountsValue is a flag.Value of comma-separated platform=percent discounts
+type discountsValue map[string]float64
+
+func (v *discountsValue) String() string {
+	pairs := make([]string, 0, len(*v))
+	for platform, percent := range *v {
+		pairs = append(pairs, platform+"="+strconv.FormatFloat(percent, 'f', -1, 64))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (v *discountsValue) Set(s string) error {
+	discounts := map[string]float64{}
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		platform, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("expected platform=percent, got %q", pair)
+		}
+		percent, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid discount of platform %q, %w", platform, err)
+		}
+		discounts[platform] = percent
+	}
+	*v = discounts
+	return nil
+}
+
+// listValue is a flag.Value of comma-separated values
+type listValue []string
+
+func (v *listValue) String() string {
+	return strings.Join(*v, ",")
+}
+
+func (v *listValue) Set(s string) error {
+	values := []string{}
+	for _, value := range strings.Split(s, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	*v = values
+	return nil
+}
+
 func (o *Options) ToContext(ctx context.Context) context.Context {
 	return ToContext(ctx, o)
 }
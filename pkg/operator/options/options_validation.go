@@ -16,19 +16,198 @@ package options
 
 import (
 	"fmt"
+	"net/url"
+	"sort"
+	"strings"
 
 	"go.uber.org/multierr"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+
+	// registers the labels of the provider as well-known and its label domain as restricted
+	_ "github.com/tufitko/karpenter-provider-yandex/pkg/apis/v1alpha1"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/providers/instancetype"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/providers/pricing"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/yandex"
 )
 
 func (o *Options) Validate() error {
 	return multierr.Combine(
 		o.validateRequiredFields(),
+		o.validateClusterConfigConfigMap(),
+		o.validateIPsPerNode(),
+		o.validateRepairTolerations(),
+		o.validateDefaultCoreFraction(),
+		o.validateMaxConcurrentCreates(),
+		o.validateProviderIDRetryTimeout(),
+		o.validateDuplicateGCGracePeriod(),
+		o.validateOrphanGCGracePeriod(),
+		o.validatePricingEndpoint(),
+		o.validateDefaultNodeLabels(),
+		o.validateSpotDisabledPlatforms(),
+		o.validateCommittedDiscounts(),
+		o.validateRegion(),
+		o.validateMultiRegion(),
 	)
 }
 
 func (o *Options) validateRequiredFields() error {
-	if o.ClusterID == "" {
+	// the cluster id may come from the cluster config ConfigMap, which is only read at startup
+	if o.ClusterID == "" && o.ClusterConfigConfigMap == "" {
 		return fmt.Errorf("missing field, cluster-id")
 	}
 	return nil
 }
+
+func (o *Options) validateClusterConfigConfigMap() error {
+	if o.ClusterConfigConfigMap == "" {
+		return nil
+	}
+	if _, _, ok := o.ClusterConfigConfigMapKey(); !ok {
+		return fmt.Errorf("cluster-config-configmap must be namespace/name, got %q", o.ClusterConfigConfigMap)
+	}
+	return nil
+}
+
+// ClusterConfigConfigMapKey returns the namespace and name of the cluster config ConfigMap
+func (o *Options) ClusterConfigConfigMapKey() (namespace, name string, ok bool) {
+	namespace, name, ok = strings.Cut(o.ClusterConfigConfigMap, "/")
+	return namespace, name, ok && namespace != "" && name != "" && !strings.Contains(name, "/")
+}
+
+func (o *Options) validateIPsPerNode() error {
+	if o.IPsPerNode < 1 {
+		return fmt.Errorf("ips-per-node must be at least 1, got %d", o.IPsPerNode)
+	}
+	return nil
+}
+
+func (o *Options) validateMaxConcurrentCreates() error {
+	if o.MaxConcurrentCreates < 1 {
+		return fmt.Errorf("max-concurrent-creates must be at least 1, got %d", o.MaxConcurrentCreates)
+	}
+	return nil
+}
+
+func (o *Options) validateProviderIDRetryTimeout() error {
+	if o.ProviderIDRetryTimeout < 0 {
+		return fmt.Errorf("provider-id-retry-timeout must not be negative, got %s", o.ProviderIDRetryTimeout)
+	}
+	return nil
+}
+
+func (o *Options) validateDuplicateGCGracePeriod() error {
+	if o.DuplicateGCGracePeriod < 0 {
+		return fmt.Errorf("duplicate-gc-grace-period must not be negative, got %s", o.DuplicateGCGracePeriod)
+	}
+	return nil
+}
+
+func (o *Options) validateOrphanGCGracePeriod() error {
+	if o.OrphanGCGracePeriod < 0 {
+		return fmt.Errorf("orphan-gc-grace-period must not be negative, got %s", o.OrphanGCGracePeriod)
+	}
+	return nil
+}
+
+func (o *Options) validatePricingEndpoint() error {
+	if o.PricingEndpoint == "" {
+		return nil
+	}
+	if o.PricingFile != "" {
+		return fmt.Errorf("pricing-endpoint cannot be combined with pricing-file")
+	}
+	if u, err := url.Parse(o.PricingEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("pricing-endpoint must be an http or https URL, got %q", o.PricingEndpoint)
+	}
+	if o.PricingRefreshInterval <= 0 {
+		return fmt.Errorf("pricing-refresh-interval must be positive, got %s", o.PricingRefreshInterval)
+	}
+	return nil
+}
+
+func (o *Options) validateRepairTolerations() error {
+	if o.NodeRepairToleration <= 0 {
+		return fmt.Errorf("node-repair-toleration must be positive, got %s", o.NodeRepairToleration)
+	}
+	if o.AutoRepairRepairToleration <= 0 {
+		return fmt.Errorf("auto-repair-node-repair-toleration must be positive, got %s", o.AutoRepairRepairToleration)
+	}
+	return nil
+}
+
+func (o *Options) validateDefaultCoreFraction() error {
+	switch o.DefaultCoreFraction {
+	case 5, 20, 50, 100:
+		return nil
+	default:
+		return fmt.Errorf("default-core-fraction must be one of 5, 20, 50 or 100, got %d", o.DefaultCoreFraction)
+	}
+}
+
+func (o *Options) validateDefaultNodeLabels() error {
+	if o.defaultNodeLabelsErr != nil {
+		return fmt.Errorf("parsing DEFAULT_NODE_LABELS, %w", o.defaultNodeLabelsErr)
+	}
+	keys := make([]string, 0, len(o.DefaultNodeLabels))
+	for key := range o.DefaultNodeLabels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var errs error
+	for _, key := range keys {
+		// well-known and restricted labels are set by Karpenter and the provider, a default would be overwritten
+		if karpv1.IsRestrictedNodeLabel(key) {
+			errs = multierr.Append(errs, fmt.Errorf("default-node-labels cannot set the restricted label %q", key))
+		}
+	}
+	return errs
+}
+
+func (o *Options) validateSpotDisabledPlatforms() error {
+	var errs error
+	for _, platform := range o.SpotDisabledPlatforms {
+		if yandex.PlatformId(platform).Name() == "" {
+			errs = multierr.Append(errs, fmt.Errorf("spot-disabled-platforms contains the unknown platform %q", platform))
+		}
+	}
+	return errs
+}
+
+func (o *Options) validateCommittedDiscounts() error {
+	if o.committedDiscountsErr != nil {
+		return fmt.Errorf("parsing COMMITTED_DISCOUNTS, %w", o.committedDiscountsErr)
+	}
+	platforms := make([]string, 0, len(o.CommittedDiscounts))
+	for platform := range o.CommittedDiscounts {
+		platforms = append(platforms, platform)
+	}
+	sort.Strings(platforms)
+	var errs error
+	for _, platform := range platforms {
+		if yandex.PlatformId(platform).Name() == "" {
+			errs = multierr.Append(errs, fmt.Errorf("committed-discounts contains the unknown platform %q", platform))
+		}
+		if percent := o.CommittedDiscounts[platform]; percent <= 0 || percent >= 100 {
+			errs = multierr.Append(errs, fmt.Errorf("committed-discounts of platform %q must be between 0 and 100 percent, got %v", platform, percent))
+		}
+	}
+	return errs
+}
+
+func (o *Options) validateRegion() error {
+	if !instancetype.HasRegion(o.Region) {
+		return fmt.Errorf("region must be one of %s, got %q", strings.Join(instancetype.Regions(), ", "), o.Region)
+	}
+	if !pricing.HasRegion(o.Region) && o.PricingFile == "" && o.PricingEndpoint == "" {
+		return fmt.Errorf("region %s has no built-in prices, set pricing-file or pricing-endpoint", o.Region)
+	}
+	return nil
+}
+
+func (o *Options) validateMultiRegion() error {
+	// mixed currencies are only comparable when the region that is priced is selected explicitly
+	if o.MultiRegion && !o.regionSet {
+		return fmt.Errorf("multi-region requires region to be set explicitly")
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package options
+
+import (
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	coreoptions "sigs.k8s.io/karpenter/pkg/operator/options"
+
+	"github.com/tufitko/karpenter-provider-yandex/pkg/yandex"
+)
+
+func newTestOptions() *Options {
+	return &Options{
+		ClusterID:                  "test-cluster",
+		IPsPerNode:                 1,
+		NodeRepairToleration:       10 * time.Minute,
+		AutoRepairRepairToleration: 30 * time.Minute,
+		DefaultCoreFraction:        100,
+		MaxConcurrentCreates:       10,
+		PricingRefreshInterval:     time.Hour,
+		ProviderIDRetryTimeout:     10 * time.Second,
+		DuplicateGCGracePeriod:     2 * time.Minute,
+		OrphanGCGracePeriod:        5 * time.Minute,
+		Region:                     yandex.RegionRU,
+	}
+}
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		name        string
+		modify      func(o *Options)
+		expectedErr []string
+	}{
+		{
+			name:   "Defaults",
+			modify: func(*Options) {},
+		},
+		{
+			name: "Cluster from the cluster config ConfigMap",
+			modify: func(o *Options) {
+				o.ClusterID = ""
+				o.ClusterConfigConfigMap = "kube-system/cluster-config"
+			},
+		},
+		{
+			name: "Pricing endpoint, default node labels and spot disabled platforms",
+			modify: func(o *Options) {
+				o.PricingEndpoint = "https://prices.example.com/table.json"
+				o.DefaultNodeLabels = map[string]string{"team": "platform", "example.com/tier": "batch"}
+				o.SpotDisabledPlatforms = []string{"standard-v1", "gpu-standard-v3"}
+			},
+		},
+		{
+			// the kz instance configurations are not generated yet
+			name:        "Region without instance configurations",
+			modify:      func(o *Options) { o.Region = "kz" },
+			expectedErr: []string{`region must be one of ru, got "kz"`},
+		},
+		{
+			name: "Multi-region with an explicit region",
+			modify: func(o *Options) {
+				o.MultiRegion = true
+				o.regionSet = true
+			},
+		},
+		{
+			name:        "Multi-region with the default region",
+			modify:      func(o *Options) { o.MultiRegion = true },
+			expectedErr: []string{"multi-region requires region to be set explicitly"},
+		},
+		{
+			name:        "Missing cluster",
+			modify:      func(o *Options) { o.ClusterID = "" },
+			expectedErr: []string{"missing field, cluster-id"},
+		},
+		{
+			name: "Pricing endpoint with pricing file",
+			modify: func(o *Options) {
+				o.PricingFile = "/etc/karpenter/prices.json"
+				o.PricingEndpoint = "https://prices.example.com/table.json"
+			},
+			expectedErr: []string{"pricing-endpoint cannot be combined with pricing-file"},
+		},
+		{
+			name: "Pricing endpoint without refresh interval",
+			modify: func(o *Options) {
+				o.PricingEndpoint = "https://prices.example.com/table.json"
+				o.PricingRefreshInterval = 0
+			},
+			expectedErr: []string{"pricing-refresh-interval must be positive"},
+		},
+		{
+			name:        "Default node label in a restricted domain",
+			modify:      func(o *Options) { o.DefaultNodeLabels = map[string]string{"karpenter.sh/nodepool": "default"} },
+			expectedErr: []string{`restricted label "karpenter.sh/nodepool"`},
+		},
+		{
+			name:        "Default node label set by the provider",
+			modify:      func(o *Options) { o.DefaultNodeLabels = map[string]string{"karpenter.yandex.cloud/instance-cpu": "2"} },
+			expectedErr: []string{`restricted label "karpenter.yandex.cloud/instance-cpu"`},
+		},
+		{
+			name:        "Unknown spot disabled platform",
+			modify:      func(o *Options) { o.SpotDisabledPlatforms = []string{"standard-v1", "standard-v9"} },
+			expectedErr: []string{`unknown platform "standard-v9"`},
+		},
+		{
+			name:        "Negative provider id retry timeout",
+			modify:      func(o *Options) { o.ProviderIDRetryTimeout = -time.Second },
+			expectedErr: []string{"provider-id-retry-timeout must not be negative"},
+		},
+		{
+			name:   "Committed discounts",
+			modify: func(o *Options) { o.CommittedDiscounts = map[string]float64{"standard-v3": 20, "highfreq-v3": 12.5} },
+		},
+		{
+			name:        "Committed discount of an unknown platform",
+			modify:      func(o *Options) { o.CommittedDiscounts = map[string]float64{"standard-v9": 20} },
+			expectedErr: []string{`committed-discounts contains the unknown platform "standard-v9"`},
+		},
+		{
+			name:        "Committed discount out of range",
+			modify:      func(o *Options) { o.CommittedDiscounts = map[string]float64{"standard-v3": 100} },
+			expectedErr: []string{`committed-discounts of platform "standard-v3" must be between 0 and 100 percent`},
+		},
+		{
+			name:        "Negative duplicate garbage collection grace period",
+			modify:      func(o *Options) { o.DuplicateGCGracePeriod = -time.Minute },
+			expectedErr: []string{"duplicate-gc-grace-period must not be negative"},
+		},
+		{
+			name:        "Negative orphan garbage collection grace period",
+			modify:      func(o *Options) { o.OrphanGCGracePeriod = -time.Minute },
+			expectedErr: []string{"orphan-gc-grace-period must not be negative"},
+		},
+		{
+			name: "Every invalid option is reported",
+			modify: func(o *Options) {
+				o.IPsPerNode = 0
+				o.DefaultCoreFraction = 30
+				o.MaxConcurrentCreates = 0
+			},
+			expectedErr: []string{"ips-per-node", "default-core-fraction", "max-concurrent-creates"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			o := newTestOptions()
+			tc.modify(o)
+
+			err := o.Validate()
+			if len(tc.expectedErr) == 0 {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Expected an error containing %q, got none", tc.expectedErr)
+			}
+			for _, expected := range tc.expectedErr {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("Expected error %q to contain %q", err, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestParse_MalformedEnvironment(t *testing.T) {
+	testCases := []struct {
+		name        string
+		env         map[string]string
+		args        []string
+		expectedErr []string
+	}{
+		{
+			name:        "Malformed default node labels",
+			env:         map[string]string{"DEFAULT_NODE_LABELS": "team"},
+			expectedErr: []string{"parsing DEFAULT_NODE_LABELS", `expected key=value, got "team"`},
+		},
+		{
+			name:        "Malformed committed discounts",
+			env:         map[string]string{"COMMITTED_DISCOUNTS": "standard-v3=twenty"},
+			expectedErr: []string{"parsing COMMITTED_DISCOUNTS", `invalid discount of platform "standard-v3"`},
+		},
+		{
+			name: "Flags override the malformed environment",
+			env:  map[string]string{"DEFAULT_NODE_LABELS": "team", "COMMITTED_DISCOUNTS": "standard-v3=twenty"},
+			args: []string{"--default-node-labels", "team=platform", "--committed-discounts", "standard-v3=20"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for key, value := range tc.env {
+				t.Setenv(key, value)
+			}
+			o := &Options{}
+			fs := &coreoptions.FlagSet{FlagSet: flag.NewFlagSet("karpenter", flag.ContinueOnError)}
+			o.AddFlags(fs)
+
+			err := o.Parse(fs, append([]string{"--cluster-name", "test-cluster"}, tc.args...)...)
+			if len(tc.expectedErr) == 0 {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Expected an error containing %q, got none", tc.expectedErr)
+			}
+			for _, expected := range tc.expectedErr {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("Expected error %q to contain %q", err, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestParse_MultiRegion(t *testing.T) {
+	testCases := []struct {
+		name        string
+		env         map[string]string
+		args        []string
+		expectError bool
+	}{
+		{
+			name:        "Default region",
+			args:        []string{"--multi-region"},
+			expectError: true,
+		},
+		{
+			name: "Region from the environment",
+			env:  map[string]string{"REGION": "ru"},
+			args: []string{"--multi-region"},
+		},
+		{
+			name: "Region from the flag",
+			args: []string{"--multi-region", "--region", "ru"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for key, value := range tc.env {
+				t.Setenv(key, value)
+			}
+			o := &Options{}
+			fs := &coreoptions.FlagSet{FlagSet: flag.NewFlagSet("karpenter", flag.ContinueOnError)}
+			o.AddFlags(fs)
+
+			err := o.Parse(fs, append([]string{"--cluster-name", "test-cluster"}, tc.args...)...)
+			if (err != nil) != tc.expectError {
+				t.Errorf("Expected error=%v, got %v", tc.expectError, err)
+			}
+		})
+	}
+}
@@ -18,15 +18,18 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/tufitko/karpenter-provider-yandex/pkg/apis/v1alpha1"
-	"github.com/tufitko/karpenter-provider-yandex/pkg/yandex"
-	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/util/sets"
+	"github.com/samber/lo"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
 	"sigs.k8s.io/karpenter/pkg/cloudprovider"
 	"sigs.k8s.io/karpenter/pkg/scheduling"
 
+	"github.com/tufitko/karpenter-provider-yandex/pkg/apis/v1alpha1"
 	"github.com/tufitko/karpenter-provider-yandex/pkg/providers/pricing"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/yandex"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 )
 
 type Provider interface {
@@ -34,15 +37,18 @@ type Provider interface {
 }
 
 type DefaultProvider struct {
-	pricingProvider pricing.Provider
+	pricingProvider      pricing.Provider
+	unavailableOfferings *UnavailableOfferings
 	// todo: reservations should be used here
 }
 
 func NewDefaultProvider(
 	pricingProvider pricing.Provider,
+	unavailableOfferings *UnavailableOfferings,
 ) *DefaultProvider {
 	return &DefaultProvider{
-		pricingProvider: pricingProvider,
+		pricingProvider:      pricingProvider,
+		unavailableOfferings: unavailableOfferings,
 	}
 }
 
@@ -54,9 +60,16 @@ func (p *DefaultProvider) InjectOfferings(
 ) []*cloudprovider.InstanceType {
 	var its []*cloudprovider.InstanceType
 	for _, it := range instanceTypes {
+		// offerings are priced by the instance type the name describes, there is nothing to price a malformed name by
+		var itName yandex.InstanceType
+		if err := itName.FromString(it.Name); err != nil {
+			log.FromContext(ctx).Error(err, "skipping instance type with a malformed name", "instanceType", it.Name)
+			continue
+		}
 		offerings := p.createOfferings(
 			ctx,
 			it,
+			itName,
 			allZones,
 			nodeClass,
 		)
@@ -65,7 +78,7 @@ func (p *DefaultProvider) InjectOfferings(
 		// once in the instance provider (filterReservedInstanceTypes)
 		its = append(its, &cloudprovider.InstanceType{
 			Name:         it.Name,
-			Requirements: it.Requirements,
+			Requirements: offeredRequirements(it.Requirements, offerings),
 			Offerings:    offerings,
 			Capacity:     it.Capacity,
 			Overhead:     it.Overhead,
@@ -74,10 +87,28 @@ func (p *DefaultProvider) InjectOfferings(
 	return its
 }
 
-// diskFromNodeClass extracts disk information from nodeClass
-func diskFromNodeClass(nodeClass *v1alpha1.YandexNodeClass) yandex.Disk {
+// offeredRequirements prunes the capacity types without an available offering from the requirements, so that the
+// instance type does not advertise a capacity type it cannot be launched with. An instance type without any available
+// offering keeps its requirements, it is not launched either way
+func offeredRequirements(requirements scheduling.Requirements, offerings cloudprovider.Offerings) scheduling.Requirements {
+	offered := sets.New(lo.Map(offerings.Available(), func(o *cloudprovider.Offering, _ int) string {
+		return o.CapacityType()
+	})...)
+	if offered.Len() == 0 || offered.IsSuperset(sets.New(requirements.Get(karpv1.CapacityTypeLabelKey).Values()...)) {
+		return requirements
+	}
+	// the requirements are shared with previous GetInstanceTypes calls, the pruned ones are a copy
+	pruned := scheduling.NewRequirements(requirements.Values()...)
+	pruned.Add(scheduling.NewRequirement(karpv1.CapacityTypeLabelKey, corev1.NodeSelectorOpIn, sets.List(offered)...))
+	return pruned
+}
+
+// diskFromNodeClass extracts the disk of nodes of the capacity type from nodeClass, an unsupported disk type is left
+// empty and has no price
+func diskFromNodeClass(nodeClass *v1alpha1.YandexNodeClass, capacityType string) yandex.Disk {
+	diskType, _ := yandex.DiskTypeFromCRD(nodeClass.Spec.DiskTypeFor(capacityType))
 	return yandex.Disk{
-		Type: yandex.DiskType(nodeClass.Spec.DiskType),
+		Type: diskType,
 		Size: nodeClass.Spec.DiskSize.Value() / (1024 * 1024 * 1024),
 	}
 }
@@ -86,16 +117,13 @@ func diskFromNodeClass(nodeClass *v1alpha1.YandexNodeClass) yandex.Disk {
 func (p *DefaultProvider) createOfferings(
 	_ context.Context,
 	it *cloudprovider.InstanceType,
+	itName yandex.InstanceType,
 	allZones sets.Set[string],
 	nodeClass *v1alpha1.YandexNodeClass,
 ) cloudprovider.Offerings {
 	var offerings []*cloudprovider.Offering
 	itZones := sets.New(it.Requirements.Get(corev1.LabelTopologyZone).Values()...)
 
-	itName := yandex.InstanceType{}
-	_ = itName.FromString(it.Name)
-	disk := diskFromNodeClass(nodeClass)
-
 	for zone := range allZones {
 		for _, capacityType := range it.Requirements.Get(karpv1.CapacityTypeLabelKey).Values() {
 			var price float64
@@ -104,12 +132,12 @@ func (p *DefaultProvider) createOfferings(
 			case karpv1.CapacityTypeOnDemand:
 				price, hasPrice = p.pricingProvider.OnDemandPrice(itName)
 			case karpv1.CapacityTypeSpot:
-				price, hasPrice = p.pricingProvider.SpotPrice(itName)
+				price, hasPrice = p.pricingProvider.SpotPrice(itName, zone)
 			default:
 				panic(fmt.Sprintf("invalid capacity type %q in requirements for instance type %q", capacityType, it.Name))
 			}
-			
-			diskPrice, hasDiskPrice := p.pricingProvider.DiskPrice(disk)
+
+			diskPrice, hasDiskPrice := p.pricingProvider.DiskPrice(diskFromNodeClass(nodeClass, capacityType))
 
 			if hasDiskPrice {
 				price += diskPrice
@@ -121,7 +149,7 @@ func (p *DefaultProvider) createOfferings(
 					scheduling.NewRequirement(corev1.LabelTopologyZone, corev1.NodeSelectorOpIn, zone),
 				),
 				Price:     price,
-				Available: hasPrice && itZones.Has(zone),
+				Available: hasPrice && itZones.Has(zone) && !p.unavailableOfferings.IsUnavailable(it.Name, zone, capacityType),
 			}
 			offerings = append(offerings, offering)
 		}
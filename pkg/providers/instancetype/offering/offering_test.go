@@ -0,0 +1,337 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package offering
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/apis/v1alpha1"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/providers/pricing"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/yandex"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/sets"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/cloudprovider"
+	"sigs.k8s.io/karpenter/pkg/scheduling"
+)
+
+// zonalPricingProvider prices spot capacity per zone and on-demand capacity flat
+type zonalPricingProvider struct {
+	onDemand float64
+	spot     map[string]float64
+}
+
+func (p zonalPricingProvider) OnDemandPrice(yandex.InstanceType) (float64, bool) {
+	return p.onDemand, true
+}
+
+func (p zonalPricingProvider) SpotPrice(_ yandex.InstanceType, zone string) (float64, bool) {
+	price, ok := p.spot[zone]
+	return price, ok
+}
+
+func (p zonalPricingProvider) DiskPrice(yandex.Disk) (float64, bool) {
+	return 0, false
+}
+
+func (p zonalPricingProvider) Currency() string {
+	return "RUB"
+}
+
+func TestInjectOfferings_SpotPriceByZone(t *testing.T) {
+	provider := NewDefaultProvider(zonalPricingProvider{
+		onDemand: 10,
+		spot: map[string]float64{
+			"ru-central1-a": 3,
+			"ru-central1-b": 5,
+		},
+	}, NewUnavailableOfferings())
+
+	info := yandex.InstanceType{
+		Platform:     yandex.PlatformIntelIceLake,
+		CPU:          resource.MustParse("2"),
+		Memory:       resource.MustParse("4Gi"),
+		CoreFraction: yandex.CoreFraction100,
+	}
+	zones := sets.New("ru-central1-a", "ru-central1-b")
+	it := &cloudprovider.InstanceType{
+		Name: info.String(),
+		Requirements: scheduling.NewRequirements(
+			scheduling.NewRequirement(karpv1.CapacityTypeLabelKey, corev1.NodeSelectorOpIn, karpv1.CapacityTypeSpot, karpv1.CapacityTypeOnDemand),
+			scheduling.NewRequirement(corev1.LabelTopologyZone, corev1.NodeSelectorOpIn, zones.UnsortedList()...),
+		),
+	}
+	nodeClass := &v1alpha1.YandexNodeClass{
+		Spec: v1alpha1.YandexNodeClassSpec{
+			DiskType: string(yandex.SSD),
+			DiskSize: resource.MustParse("30Gi"),
+		},
+	}
+
+	result := provider.InjectOfferings(context.Background(), []*cloudprovider.InstanceType{it}, zones, nodeClass)
+	if len(result) != 1 {
+		t.Fatalf("Expected 1 instance type, got %d", len(result))
+	}
+
+	expected := map[string]map[string]float64{
+		karpv1.CapacityTypeSpot:     {"ru-central1-a": 3, "ru-central1-b": 5},
+		karpv1.CapacityTypeOnDemand: {"ru-central1-a": 10, "ru-central1-b": 10},
+	}
+	for _, o := range result[0].Offerings {
+		capacityType := o.CapacityType()
+		if price := expected[capacityType][o.Zone()]; o.Price != price {
+			t.Errorf("Offering %s/%s: expected price %.2f, got %.2f", capacityType, o.Zone(), price, o.Price)
+		}
+		if !o.Available {
+			t.Errorf("Offering %s/%s: expected to be available", capacityType, o.Zone())
+		}
+	}
+}
+
+func TestInjectOfferings_SkipsMalformedInstanceTypeName(t *testing.T) {
+	provider := NewDefaultProvider(zonalPricingProvider{onDemand: 10}, NewUnavailableOfferings())
+
+	info := yandex.InstanceType{
+		Platform:     yandex.PlatformIntelIceLake,
+		CPU:          resource.MustParse("2"),
+		Memory:       resource.MustParse("4Gi"),
+		CoreFraction: yandex.CoreFraction100,
+	}
+	zones := sets.New("ru-central1-a")
+	requirements := scheduling.NewRequirements(
+		scheduling.NewRequirement(karpv1.CapacityTypeLabelKey, corev1.NodeSelectorOpIn, karpv1.CapacityTypeOnDemand),
+		scheduling.NewRequirement(corev1.LabelTopologyZone, corev1.NodeSelectorOpIn, zones.UnsortedList()...),
+	)
+	instanceTypes := []*cloudprovider.InstanceType{
+		{Name: "not-an-instance-type", Requirements: requirements},
+		{Name: info.String(), Requirements: requirements},
+	}
+	nodeClass := &v1alpha1.YandexNodeClass{
+		Spec: v1alpha1.YandexNodeClassSpec{
+			DiskType: string(yandex.SSD),
+			DiskSize: resource.MustParse("30Gi"),
+		},
+	}
+
+	result := provider.InjectOfferings(context.Background(), instanceTypes, zones, nodeClass)
+	if len(result) != 1 {
+		t.Fatalf("Expected the malformed instance type to be skipped, got %d instance types", len(result))
+	}
+	if result[0].Name != info.String() {
+		t.Errorf("Expected instance type %s, got %s", info.String(), result[0].Name)
+	}
+	if len(result[0].Offerings.Available()) != 1 {
+		t.Errorf("Expected 1 available offering, got %d", len(result[0].Offerings.Available()))
+	}
+}
+
+func TestInjectOfferings_UnavailableOfferings(t *testing.T) {
+	unavailable := NewUnavailableOfferings()
+	provider := NewDefaultProvider(zonalPricingProvider{onDemand: 10, spot: map[string]float64{"ru-central1-a": 3, "ru-central1-b": 3}}, unavailable)
+
+	info := yandex.InstanceType{
+		Platform:     yandex.PlatformIntelIceLake,
+		CPU:          resource.MustParse("2"),
+		Memory:       resource.MustParse("4Gi"),
+		CoreFraction: yandex.CoreFraction100,
+	}
+	zones := sets.New("ru-central1-a", "ru-central1-b")
+	instanceTypes := []*cloudprovider.InstanceType{{
+		Name: info.String(),
+		Requirements: scheduling.NewRequirements(
+			scheduling.NewRequirement(karpv1.CapacityTypeLabelKey, corev1.NodeSelectorOpIn, karpv1.CapacityTypeSpot, karpv1.CapacityTypeOnDemand),
+			scheduling.NewRequirement(corev1.LabelTopologyZone, corev1.NodeSelectorOpIn, zones.UnsortedList()...),
+		),
+	}}
+	nodeClass := &v1alpha1.YandexNodeClass{
+		Spec: v1alpha1.YandexNodeClassSpec{
+			DiskType: string(yandex.SSD),
+			DiskSize: resource.MustParse("30Gi"),
+		},
+	}
+
+	unavailable.MarkUnavailable(info.String(), "ru-central1-a", karpv1.CapacityTypeSpot)
+	offerings := provider.InjectOfferings(context.Background(), instanceTypes, zones, nodeClass)[0].Offerings
+	if len(offerings.Available()) != 3 {
+		t.Fatalf("Expected 3 available offerings, got %d", len(offerings.Available()))
+	}
+	for _, o := range offerings {
+		if o.Zone() == "ru-central1-a" && o.CapacityType() == karpv1.CapacityTypeSpot && o.Available {
+			t.Errorf("Expected the spot offering in ru-central1-a to be unavailable")
+		}
+	}
+}
+
+func TestSpotSavingsPercent(t *testing.T) {
+	info := yandex.InstanceType{
+		Platform:     yandex.PlatformIntelIceLake,
+		CPU:          resource.MustParse("2"),
+		Memory:       resource.MustParse("4Gi"),
+		CoreFraction: yandex.CoreFraction100,
+	}
+	zones := sets.New("ru-central1-a", "ru-central1-b")
+	newInstanceType := func(capacityTypes ...string) *cloudprovider.InstanceType {
+		return &cloudprovider.InstanceType{
+			Name: info.String(),
+			Requirements: scheduling.NewRequirements(
+				scheduling.NewRequirement(karpv1.CapacityTypeLabelKey, corev1.NodeSelectorOpIn, capacityTypes...),
+				scheduling.NewRequirement(corev1.LabelTopologyZone, corev1.NodeSelectorOpIn, zones.UnsortedList()...),
+			),
+		}
+	}
+	nodeClass := &v1alpha1.YandexNodeClass{
+		Spec: v1alpha1.YandexNodeClassSpec{
+			DiskType: string(yandex.SSD),
+			DiskSize: resource.MustParse("30Gi"),
+		},
+	}
+
+	t.Run("Built-in prices", func(t *testing.T) {
+		prices := pricing.NewDefaultProvider(yandex.RegionRU)
+		onDemand, _ := prices.OnDemandPrice(info)
+		spot, _ := prices.SpotPrice(info, "ru-central1-a")
+		disk, _ := prices.DiskPrice(yandex.Disk{Type: yandex.SSD, Size: 30})
+		expected := (onDemand - spot) / (onDemand + disk) * 100
+
+		it := NewDefaultProvider(prices, NewUnavailableOfferings()).InjectOfferings(context.Background(), []*cloudprovider.InstanceType{newInstanceType(karpv1.CapacityTypeSpot, karpv1.CapacityTypeOnDemand)}, zones, nodeClass)[0]
+		savings, ok := SpotSavingsPercent(it, "ru-central1-a")
+		if !ok || math.Abs(savings-expected) > 0.001 {
+			t.Errorf("Expected savings of %.2f%%, got %.2f%% (ok=%t)", expected, savings, ok)
+		}
+		if savings <= 0 || savings >= 100 {
+			t.Errorf("Expected spot %s to be cheaper than on-demand, got savings of %.2f%%", info.Platform, savings)
+		}
+	})
+
+	t.Run("Disk per capacity type", func(t *testing.T) {
+		prices := pricing.NewDefaultProvider(yandex.RegionRU)
+		onDemand, _ := prices.OnDemandPrice(info)
+		spot, _ := prices.SpotPrice(info, "ru-central1-a")
+		ssd, _ := prices.DiskPrice(yandex.Disk{Type: yandex.SSD, Size: 30})
+		hdd, _ := prices.DiskPrice(yandex.Disk{Type: yandex.HDD, Size: 30})
+		expected := ((onDemand + ssd) - (spot + hdd)) / (onDemand + ssd) * 100
+
+		spotOnHDD := nodeClass.DeepCopy()
+		spotOnHDD.Spec.SpotDiskType = string(yandex.HDD)
+		it := NewDefaultProvider(prices, NewUnavailableOfferings()).InjectOfferings(context.Background(), []*cloudprovider.InstanceType{newInstanceType(karpv1.CapacityTypeSpot, karpv1.CapacityTypeOnDemand)}, zones, spotOnHDD)[0]
+		savings, ok := SpotSavingsPercent(it, "ru-central1-a")
+		if !ok || math.Abs(savings-expected) > 0.001 {
+			t.Errorf("Expected savings of %.2f%%, got %.2f%% (ok=%t)", expected, savings, ok)
+		}
+	})
+
+	testCases := []struct {
+		name          string
+		capacityTypes []string
+		zone          string
+		expected      float64
+		expectedOK    bool
+	}{
+		{"Zone a", []string{karpv1.CapacityTypeSpot, karpv1.CapacityTypeOnDemand}, "ru-central1-a", 70, true},
+		{"Zone b", []string{karpv1.CapacityTypeSpot, karpv1.CapacityTypeOnDemand}, "ru-central1-b", 50, true},
+		{"Unknown zone", []string{karpv1.CapacityTypeSpot, karpv1.CapacityTypeOnDemand}, "ru-central1-d", 0, false},
+		{"On-demand only", []string{karpv1.CapacityTypeOnDemand}, "ru-central1-a", 0, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := NewDefaultProvider(zonalPricingProvider{onDemand: 10, spot: map[string]float64{"ru-central1-a": 3, "ru-central1-b": 5}}, NewUnavailableOfferings())
+			it := provider.InjectOfferings(context.Background(), []*cloudprovider.InstanceType{newInstanceType(tc.capacityTypes...)}, zones, nodeClass)[0]
+
+			savings, ok := SpotSavingsPercent(it, tc.zone)
+			if ok != tc.expectedOK || math.Abs(savings-tc.expected) > 0.001 {
+				t.Errorf("Expected savings of %.2f%% (ok=%t), got %.2f%% (ok=%t)", tc.expected, tc.expectedOK, savings, ok)
+			}
+		})
+	}
+}
+
+func TestInjectOfferings_DiskPricePerCapacityType(t *testing.T) {
+	info := yandex.InstanceType{
+		Platform:     yandex.PlatformIntelIceLake,
+		CPU:          resource.MustParse("2"),
+		Memory:       resource.MustParse("4Gi"),
+		CoreFraction: yandex.CoreFraction100,
+	}
+	zones := sets.New("ru-central1-a")
+	it := &cloudprovider.InstanceType{
+		Name: info.String(),
+		Requirements: scheduling.NewRequirements(
+			scheduling.NewRequirement(karpv1.CapacityTypeLabelKey, corev1.NodeSelectorOpIn, karpv1.CapacityTypeSpot, karpv1.CapacityTypeOnDemand),
+			scheduling.NewRequirement(corev1.LabelTopologyZone, corev1.NodeSelectorOpIn, zones.UnsortedList()...),
+		),
+	}
+	nodeClass := &v1alpha1.YandexNodeClass{
+		Spec: v1alpha1.YandexNodeClassSpec{
+			DiskType:     string(yandex.SSD),
+			SpotDiskType: string(yandex.HDD),
+			DiskSize:     resource.MustParse("30Gi"),
+		},
+	}
+	prices := pricing.NewDefaultProvider(yandex.RegionRU)
+	onDemand, _ := prices.OnDemandPrice(info)
+	spot, _ := prices.SpotPrice(info, "ru-central1-a")
+	ssd, _ := prices.DiskPrice(yandex.Disk{Type: yandex.SSD, Size: 30})
+	hdd, _ := prices.DiskPrice(yandex.Disk{Type: yandex.HDD, Size: 30})
+
+	offerings := NewDefaultProvider(prices, NewUnavailableOfferings()).InjectOfferings(context.Background(), []*cloudprovider.InstanceType{it}, zones, nodeClass)[0].Offerings
+	for _, off := range offerings {
+		expected := lo.Ternary(off.CapacityType() == karpv1.CapacityTypeSpot, spot+hdd, onDemand+ssd)
+		if math.Abs(off.Price-expected) > 0.001 {
+			t.Errorf("Expected %s price %v, got %v", off.CapacityType(), expected, off.Price)
+		}
+	}
+}
+
+func TestInjectOfferings_PrunesCapacityTypesWithoutOfferings(t *testing.T) {
+	// spot is required, but has no price in any zone
+	provider := NewDefaultProvider(zonalPricingProvider{onDemand: 10}, NewUnavailableOfferings())
+
+	info := yandex.InstanceType{
+		Platform:     yandex.PlatformIntelIceLake,
+		CPU:          resource.MustParse("2"),
+		Memory:       resource.MustParse("4Gi"),
+		CoreFraction: yandex.CoreFraction100,
+	}
+	zones := sets.New("ru-central1-a", "ru-central1-b")
+	requirements := scheduling.NewRequirements(
+		scheduling.NewRequirement(karpv1.CapacityTypeLabelKey, corev1.NodeSelectorOpIn, karpv1.CapacityTypeSpot, karpv1.CapacityTypeOnDemand),
+		scheduling.NewRequirement(corev1.LabelTopologyZone, corev1.NodeSelectorOpIn, zones.UnsortedList()...),
+	)
+	it := &cloudprovider.InstanceType{Name: info.String(), Requirements: requirements}
+	nodeClass := &v1alpha1.YandexNodeClass{
+		Spec: v1alpha1.YandexNodeClassSpec{
+			DiskType: string(yandex.SSD),
+			DiskSize: resource.MustParse("30Gi"),
+		},
+	}
+
+	result := provider.InjectOfferings(context.Background(), []*cloudprovider.InstanceType{it}, zones, nodeClass)
+	if len(result) != 1 {
+		t.Fatalf("Expected 1 instance type, got %d", len(result))
+	}
+	if capacityTypes := result[0].Requirements.Get(karpv1.CapacityTypeLabelKey).Values(); len(capacityTypes) != 1 || capacityTypes[0] != karpv1.CapacityTypeOnDemand {
+		t.Errorf("Expected only the on-demand capacity type to be required, got %v", capacityTypes)
+	}
+	if zoneValues := result[0].Requirements.Get(corev1.LabelTopologyZone).Values(); len(zoneValues) != 2 {
+		t.Errorf("Expected the zones to be kept, got %v", zoneValues)
+	}
+	if capacityTypes := requirements.Get(karpv1.CapacityTypeLabelKey).Values(); len(capacityTypes) != 2 {
+		t.Errorf("Expected the requirements of the input instance type to be left alone, got %v", capacityTypes)
+	}
+}
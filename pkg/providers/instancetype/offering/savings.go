@@ -0,0 +1,46 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package offering
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/cloudprovider"
+)
+
+// SpotSavingsPercent returns by how many percent the spot offering of the instance type in zone is cheaper than its
+// on-demand offering. Offering prices include the boot disk of their capacity type, which may differ with spotDiskType
+// and onDemandDiskType, so this is the saving on the whole node. false is returned when either offering is missing or
+// unpriced
+func SpotSavingsPercent(it *cloudprovider.InstanceType, zone string) (float64, bool) {
+	onDemand, ok := zonalOffering(it, karpv1.CapacityTypeOnDemand, zone)
+	if !ok || onDemand.Price <= 0 {
+		return 0, false
+	}
+	spot, ok := zonalOffering(it, karpv1.CapacityTypeSpot, zone)
+	if !ok {
+		return 0, false
+	}
+	return (onDemand.Price - spot.Price) / onDemand.Price * 100, true
+}
+
+func zonalOffering(it *cloudprovider.InstanceType, capacityType, zone string) (*cloudprovider.Offering, bool) {
+	for _, o := range it.Offerings {
+		if o.CapacityType() == capacityType && o.Requirements.Get(corev1.LabelTopologyZone).Has(zone) {
+			return o, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,50 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package offering
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+// UnavailableOfferingsTTL is how long an offering that ran out of capacity is not offered again
+const UnavailableOfferingsTTL = 3 * time.Minute
+
+// UnavailableOfferings remembers the offerings creates recently ran out of capacity with, so that the next NodeClaim
+// is launched with another instance type, zone or capacity type instead of the same one
+type UnavailableOfferings struct {
+	cache *cache.Cache
+}
+
+func NewUnavailableOfferings() *UnavailableOfferings {
+	return &UnavailableOfferings{cache: cache.New(UnavailableOfferingsTTL, time.Minute)}
+}
+
+// MarkUnavailable marks the offering of the instance type in the zone with the capacity type as unavailable
+func (u *UnavailableOfferings) MarkUnavailable(instanceType, zone, capacityType string) {
+	u.cache.SetDefault(unavailableOfferingKey(instanceType, zone, capacityType), struct{}{})
+}
+
+// IsUnavailable returns whether the offering was marked as unavailable within the TTL
+func (u *UnavailableOfferings) IsUnavailable(instanceType, zone, capacityType string) bool {
+	_, ok := u.cache.Get(unavailableOfferingKey(instanceType, zone, capacityType))
+	return ok
+}
+
+func unavailableOfferingKey(instanceType, zone, capacityType string) string {
+	return fmt.Sprintf("%s:%s:%s", capacityType, instanceType, zone)
+}
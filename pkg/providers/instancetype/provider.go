@@ -5,8 +5,11 @@ package instancetype
 import (
 	"context"
 	"fmt"
+	"math"
 	"sort"
+	"strconv"
 
+	"github.com/samber/lo"
 	"github.com/tufitko/karpenter-provider-yandex/pkg/apis/v1alpha1"
 	"github.com/tufitko/karpenter-provider-yandex/pkg/providers/instancetype/offering"
 	"github.com/tufitko/karpenter-provider-yandex/pkg/yandex"
@@ -16,7 +19,10 @@ import (
 )
 
 type Provider interface {
+	// List returns every instance type of the nodeclass, including those without available offerings
 	List(ctx context.Context, class *v1alpha1.YandexNodeClass) ([]*cloudprovider.InstanceType, error)
+	// ListAvailable returns the instance types of the nodeclass with at least one available offering
+	ListAvailable(ctx context.Context, class *v1alpha1.YandexNodeClass) ([]*cloudprovider.InstanceType, error)
 	GetInstanceType(ctx context.Context, class *v1alpha1.YandexNodeClass, instanceTypeName string) (*cloudprovider.InstanceType, error)
 }
 
@@ -26,6 +32,10 @@ type DefaultProvider struct {
 	resolver          Resolver
 	allZones          sets.Set[string]
 	namesInstanceType map[string]infoInstanceType
+	// defaultCoreFraction is used for nodeclasses that do not specify core fractions
+	defaultCoreFraction yandex.CoreFraction
+	// spotDisabledPlatforms are offered as on-demand only, even where their configuration can be preemptible
+	spotDisabledPlatforms sets.Set[yandex.PlatformId]
 }
 
 type infoInstanceType struct {
@@ -33,12 +43,32 @@ type infoInstanceType struct {
 	canBePreemptible bool
 }
 
-func NewDefaultProvider(resolver Resolver, offeringProvider *offering.DefaultProvider, allZones sets.Set[string]) *DefaultProvider {
+// availableConfigurations are the generated instance configurations by region
+var availableConfigurations = map[string]map[yandex.PlatformId][]InstanceConfiguration{
+	yandex.RegionRU: ruAvailableConfigurations,
+}
+
+// HasRegion returns whether there are generated instance configurations of region
+func HasRegion(region string) bool {
+	_, ok := availableConfigurations[region]
+	return ok
+}
+
+// Regions returns the regions with generated instance configurations, sorted
+func Regions() []string {
+	regions := lo.Keys(availableConfigurations)
+	sort.Strings(regions)
+	return regions
+}
+
+func NewDefaultProvider(region string, resolver Resolver, offeringProvider *offering.DefaultProvider, allZones sets.Set[string], defaultCoreFraction yandex.CoreFraction, spotDisabledPlatforms sets.Set[yandex.PlatformId]) *DefaultProvider {
 	p := &DefaultProvider{
-		configuration:    ruAvailableConfigurations,
-		resolver:         resolver,
-		offeringProvider: offeringProvider,
-		allZones:         allZones,
+		configuration:         availableConfigurations[region],
+		resolver:              resolver,
+		offeringProvider:      offeringProvider,
+		allZones:              allZones,
+		defaultCoreFraction:   defaultCoreFraction,
+		spotDisabledPlatforms: spotDisabledPlatforms,
 	}
 
 	p.namesInstanceType = p.buildNamesInstanceType()
@@ -46,6 +76,12 @@ func NewDefaultProvider(resolver Resolver, offeringProvider *offering.DefaultPro
 	return p
 }
 
+// PlatformAvailable returns whether the region has instance configurations of the platform
+func PlatformAvailable(region string, platform yandex.PlatformId) bool {
+	_, ok := availableConfigurations[region][platform]
+	return ok
+}
+
 func (p *DefaultProvider) List(ctx context.Context, class *v1alpha1.YandexNodeClass) ([]*cloudprovider.InstanceType, error) {
 	if class == nil {
 		return nil, fmt.Errorf("node class is required")
@@ -60,12 +96,78 @@ func (p *DefaultProvider) List(ctx context.Context, class *v1alpha1.YandexNodeCl
 		res = append(res, types...)
 	}
 
-	sort.Slice(res, func(i, j int) bool {
-		return res[i].Offerings.Cheapest().Price < res[j].Offerings.Cheapest().Price
-	})
+	SortByPrice(res, cheapestAvailablePrice, class.Spec.PlatformPreference)
 	return res, nil
 }
 
+func (p *DefaultProvider) ListAvailable(ctx context.Context, class *v1alpha1.YandexNodeClass) ([]*cloudprovider.InstanceType, error) {
+	instanceTypes, err := p.List(ctx, class)
+	if err != nil {
+		return nil, err
+	}
+	return lo.Filter(instanceTypes, func(it *cloudprovider.InstanceType, _ int) bool {
+		return len(it.Offerings.Available()) > 0
+	}), nil
+}
+
+// cheapestAvailablePrice returns the price of the cheapest available offering of an instance type, instance types
+// without available offerings are priced the highest so that they go last
+func cheapestAvailablePrice(it *cloudprovider.InstanceType) float64 {
+	return CheapestPrice(it.Offerings.Available())
+}
+
+// CheapestPrice returns the price of the cheapest of the offerings, or math.MaxFloat64 without any offerings.
+// Offerings.Cheapest has no offering to return for an empty list, prices should be read through CheapestPrice
+func CheapestPrice(offerings cloudprovider.Offerings) float64 {
+	if len(offerings) == 0 {
+		return math.MaxFloat64
+	}
+	return offerings.Cheapest().Price
+}
+
+// PlatformPreferencePriceTolerance is the relative price difference within which instance types are considered
+// equally priced, so that the nodeclass platform preference decides their order
+const PlatformPreferencePriceTolerance = 0.05
+
+// SortByPrice orders instance types from the cheapest one, equally priced instance types by name so the order does
+// not depend on the order they were listed in. Instance types priced within PlatformPreferencePriceTolerance of the
+// cheapest instance type of their group are then ordered by the position of their platform in preference, platforms
+// missing from preference go last
+func SortByPrice(instanceTypes []*cloudprovider.InstanceType, price func(*cloudprovider.InstanceType) float64, preference []string) {
+	prices := lo.SliceToMap(instanceTypes, func(it *cloudprovider.InstanceType) (*cloudprovider.InstanceType, float64) {
+		return it, price(it)
+	})
+	sort.SliceStable(instanceTypes, func(i, j int) bool {
+		if prices[instanceTypes[i]] != prices[instanceTypes[j]] {
+			return prices[instanceTypes[i]] < prices[instanceTypes[j]]
+		}
+		return instanceTypes[i].Name < instanceTypes[j].Name
+	})
+	if len(preference) == 0 {
+		return
+	}
+
+	rank := func(it *cloudprovider.InstanceType) int {
+		platform := lo.FirstOr(it.Requirements.Get(v1alpha1.LabelInstanceCPUPlatform).Values(), "")
+		if i := lo.IndexOf(preference, platform); i >= 0 {
+			return i
+		}
+		return len(preference)
+	}
+	for start := 0; start < len(instanceTypes); {
+		limit := prices[instanceTypes[start]] * (1 + PlatformPreferencePriceTolerance)
+		end := start + 1
+		for end < len(instanceTypes) && prices[instanceTypes[end]] <= limit {
+			end++
+		}
+		group := instanceTypes[start:end]
+		sort.SliceStable(group, func(i, j int) bool {
+			return rank(group[i]) < rank(group[j])
+		})
+		start = end
+	}
+}
+
 func (p *DefaultProvider) GetInstanceType(ctx context.Context, class *v1alpha1.YandexNodeClass, instanceTypeName string) (*cloudprovider.InstanceType, error) {
 	if class == nil {
 		return nil, fmt.Errorf("node class is required")
@@ -88,32 +190,60 @@ func (p *DefaultProvider) GetInstanceType(ctx context.Context, class *v1alpha1.Y
 }
 
 func (p *DefaultProvider) generateTypesFor(ctx context.Context, platform yandex.PlatformId, class *v1alpha1.YandexNodeClass) ([]*cloudprovider.InstanceType, error) {
+	if platforms := class.Spec.PlatformsOrAll(); len(platforms) > 0 && !lo.Contains(platforms, string(platform)) {
+		return nil, nil
+	}
+	// the GPU driver of the nodes cannot be installed on platforms without GPUs
+	if class.Spec.GPUDriverVersion != "" && !platform.IsGPU() {
+		return nil, nil
+	}
+	coreFractions := p.coreFractions(class)
 	res := make([]*cloudprovider.InstanceType, 0)
 	for _, configuration := range p.configuration[platform] {
+		if !coreFractions.Has(configuration.CoreFraction) {
+			continue
+		}
 		types := p.generateInstanceTypes(platform, configuration)
 
 		for _, t := range types {
-			res = append(res, p.resolver.Resolve(ctx, t, class, configuration.CanBePreemptible))
+			res = append(res, p.resolver.Resolve(ctx, t, class, p.canBePreemptible(platform, configuration)))
 		}
 	}
 	return p.offeringProvider.InjectOfferings(ctx, res, p.allZones, class), nil
 }
 
+// coreFractions returns the core fractions instance types are generated for
+func (p *DefaultProvider) coreFractions(class *v1alpha1.YandexNodeClass) sets.Set[yandex.CoreFraction] {
+	return sets.New(lo.FilterMap(class.Spec.CoreFractionsOrDefault(v1alpha1.CoreFraction(p.defaultCoreFraction.String())), func(cf v1alpha1.CoreFraction, _ int) (yandex.CoreFraction, bool) {
+		fraction, err := strconv.ParseInt(string(cf), 10, 64)
+		return yandex.CoreFraction(fraction), err == nil
+	})...)
+}
+
 func (p *DefaultProvider) generateInstanceTypes(platform yandex.PlatformId, configuration InstanceConfiguration) []yandex.InstanceType {
 	res := make([]yandex.InstanceType, 0)
 	for _, cpu := range configuration.VCPU {
 		for _, memPerCore := range configuration.MemoryPerCore {
+			// Yandex Cloud rejects shapes over the memory limit of the configuration
+			if configuration.MaxMemory > 0 && memPerCore*float64(cpu) > configuration.MaxMemory {
+				continue
+			}
 			res = append(res, yandex.InstanceType{
 				Platform:     platform,
 				CoreFraction: configuration.CoreFraction,
 				CPU:          resource.MustParse(fmt.Sprintf("%d", cpu)),
 				Memory:       resource.MustParse(fmt.Sprintf("%fGi", memPerCore*float64(cpu))),
+				NUMANodes:    configuration.numaNodes(cpu, memPerCore*float64(cpu)),
 			})
 		}
 	}
 	return res
 }
 
+func (p *DefaultProvider) canBePreemptible(platform yandex.PlatformId, configuration InstanceConfiguration) bool {
+	return configuration.CanBePreemptible && !p.spotDisabledPlatforms.Has(platform)
+}
+
 func (p *DefaultProvider) buildNamesInstanceType() map[string]infoInstanceType {
 	names := make(map[string]infoInstanceType)
 	for platform, configs := range p.configuration {
@@ -121,7 +251,7 @@ func (p *DefaultProvider) buildNamesInstanceType() map[string]infoInstanceType {
 			types := p.generateInstanceTypes(platform, configuration)
 			for _, t := range types {
 				name := t.String()
-				names[name] = infoInstanceType{info: t, canBePreemptible: configuration.CanBePreemptible}
+				names[name] = infoInstanceType{info: t, canBePreemptible: p.canBePreemptible(platform, configuration)}
 			}
 		}
 	}
@@ -0,0 +1,504 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package instancetype
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/apis/v1alpha1"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/providers/instancetype/offering"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/providers/pricing"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/yandex"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/sets"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+	"sigs.k8s.io/karpenter/pkg/cloudprovider"
+	"sigs.k8s.io/karpenter/pkg/scheduling"
+)
+
+func newPricedInstanceType(name string, platform yandex.PlatformId, price float64) *cloudprovider.InstanceType {
+	return &cloudprovider.InstanceType{
+		Name: name,
+		Requirements: scheduling.NewRequirements(
+			scheduling.NewRequirement(v1alpha1.LabelInstanceCPUPlatform, corev1.NodeSelectorOpIn, string(platform)),
+		),
+		Offerings: cloudprovider.Offerings{{Price: price, Available: true}},
+	}
+}
+
+func TestPlatformAvailable(t *testing.T) {
+	testCases := []struct {
+		name     string
+		region   string
+		platform yandex.PlatformId
+		expected bool
+	}{
+		{name: "Generated region", region: yandex.RegionRU, platform: yandex.PlatformIntelIceLake, expected: true},
+		{name: "Unknown platform", region: yandex.RegionRU, platform: "unknown-v1", expected: false},
+		{name: "Region without configurations", region: "kz", platform: yandex.PlatformIntelIceLake, expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := PlatformAvailable(tc.region, tc.platform); got != tc.expected {
+				t.Errorf("PlatformAvailable(%q, %q) = %v, want %v", tc.region, tc.platform, got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestSortByPrice(t *testing.T) {
+	testCases := []struct {
+		name       string
+		prices     map[yandex.PlatformId]float64
+		preference []string
+		expected   []string
+	}{
+		{
+			name:     "Cheapest first without preference",
+			prices:   map[yandex.PlatformId]float64{yandex.PlatformIntelCascadeLake: 1.00, yandex.PlatformIntelIceLake: 1.02, yandex.PlatformAMDZen4: 2},
+			expected: []string{"v2", "v3", "v4a"},
+		},
+		{
+			name:       "Preferred platform first when prices are near-equal",
+			prices:     map[yandex.PlatformId]float64{yandex.PlatformIntelCascadeLake: 1.00, yandex.PlatformIntelIceLake: 1.02, yandex.PlatformAMDZen4: 2},
+			preference: []string{string(yandex.PlatformIntelIceLake)},
+			expected:   []string{"v3", "v2", "v4a"},
+		},
+		{
+			name:       "Preference does not outweigh a real price difference",
+			prices:     map[yandex.PlatformId]float64{yandex.PlatformIntelCascadeLake: 1.00, yandex.PlatformIntelIceLake: 1.02, yandex.PlatformAMDZen4: 2},
+			preference: []string{string(yandex.PlatformAMDZen4), string(yandex.PlatformIntelIceLake)},
+			expected:   []string{"v3", "v2", "v4a"},
+		},
+		{
+			name:     "Equally priced instance types ordered by name",
+			prices:   map[yandex.PlatformId]float64{yandex.PlatformIntelCascadeLake: 1.00, yandex.PlatformIntelIceLake: 1.00, yandex.PlatformAMDZen4: 0.5},
+			expected: []string{"v4a", "v2", "v3"},
+		},
+		{
+			name:       "Unlisted platforms go after preferred ones",
+			prices:     map[yandex.PlatformId]float64{yandex.PlatformIntelCascadeLake: 1.00, yandex.PlatformIntelIceLake: 1.01, yandex.PlatformAMDZen4: 1.02},
+			preference: []string{string(yandex.PlatformAMDZen4), string(yandex.PlatformIntelIceLake)},
+			expected:   []string{"v4a", "v3", "v2"},
+		},
+	}
+
+	names := map[yandex.PlatformId]string{
+		yandex.PlatformIntelCascadeLake: "v2",
+		yandex.PlatformIntelIceLake:     "v3",
+		yandex.PlatformAMDZen4:          "v4a",
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var instanceTypes []*cloudprovider.InstanceType
+			for platform, price := range tc.prices {
+				instanceTypes = append(instanceTypes, newPricedInstanceType(names[platform], platform, price))
+			}
+
+			SortByPrice(instanceTypes, func(it *cloudprovider.InstanceType) float64 {
+				return CheapestPrice(it.Offerings)
+			}, tc.preference)
+
+			got := lo.Map(instanceTypes, func(it *cloudprovider.InstanceType, _ int) string { return it.Name })
+			if !slices.Equal(got, tc.expected) {
+				t.Errorf("Expected order %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestList_CoreFractions(t *testing.T) {
+	testCases := []struct {
+		name                string
+		defaultCoreFraction yandex.CoreFraction
+		coreFractions       []v1alpha1.CoreFraction
+		expected            []string
+	}{
+		{
+			name:                "Implicit default of 100",
+			defaultCoreFraction: yandex.CoreFraction100,
+			expected:            []string{"100"},
+		},
+		{
+			name:                "Operator default when the nodeclass is silent",
+			defaultCoreFraction: yandex.CoreFraction50,
+			expected:            []string{"50"},
+		},
+		{
+			name:                "Only 100",
+			defaultCoreFraction: yandex.CoreFraction100,
+			coreFractions:       []v1alpha1.CoreFraction{"100"},
+			expected:            []string{"100"},
+		},
+		{
+			name:                "Nodeclass overrides the operator default",
+			defaultCoreFraction: yandex.CoreFraction50,
+			coreFractions:       []v1alpha1.CoreFraction{"20", "100"},
+			expected:            []string{"20", "100"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := NewDefaultProvider(
+				yandex.RegionRU,
+				NewDefaultResolver(110),
+				offering.NewDefaultProvider(pricing.NewDefaultProvider(yandex.RegionRU), offering.NewUnavailableOfferings()),
+				sets.New("ru-central1-a"),
+				tc.defaultCoreFraction,
+				nil,
+			)
+			nodeClass := &v1alpha1.YandexNodeClass{Spec: v1alpha1.YandexNodeClassSpec{CoreFractions: tc.coreFractions}}
+
+			instanceTypes, err := provider.List(context.Background(), nodeClass)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(instanceTypes) == 0 {
+				t.Fatalf("Expected instance types to be generated")
+			}
+			got := sets.New[string]()
+			for _, it := range instanceTypes {
+				got.Insert(it.Requirements.Get(v1alpha1.LabelInstanceCPUFraction).Values()...)
+			}
+			if !got.Equal(sets.New(tc.expected...)) {
+				t.Errorf("Expected core fractions %v, got %v", tc.expected, sets.List(got))
+			}
+		})
+	}
+}
+
+func TestGenerateInstanceTypes_MaxMemory(t *testing.T) {
+	testCases := []struct {
+		name      string
+		maxMemory float64
+		expected  []string
+	}{
+		{
+			name:      "Shapes over the max memory are not generated",
+			maxMemory: 16,
+			expected:  []string{"2/2Gi", "2/8Gi", "4/4Gi", "4/16Gi", "8/8Gi"},
+		},
+		{
+			name:     "No limit without max memory",
+			expected: []string{"2/2Gi", "2/8Gi", "4/4Gi", "4/16Gi", "8/8Gi", "8/32Gi"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := &DefaultProvider{}
+			types := provider.generateInstanceTypes(yandex.PlatformIntelIceLake, InstanceConfiguration{
+				CoreFraction:  yandex.CoreFraction100,
+				VCPU:          []int{2, 4, 8},
+				MemoryPerCore: []float64{1, 4},
+				MaxMemory:     tc.maxMemory,
+			})
+
+			got := lo.Map(types, func(it yandex.InstanceType, _ int) string {
+				return it.CPU.String() + "/" + it.Memory.String()
+			})
+			if !slices.Equal(got, tc.expected) {
+				t.Errorf("Expected shapes %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestGenerateInstanceTypes_NUMANodes(t *testing.T) {
+	testCases := []struct {
+		name          string
+		configuration InstanceConfiguration
+		expected      map[string]int
+	}{
+		{
+			name: "Instances span a NUMA node per socket and per max memory of a node",
+			configuration: InstanceConfiguration{
+				CoreFraction:         yandex.CoreFraction100,
+				VCPU:                 []int{96, 192},
+				MemoryPerCore:        []float64{4, 8},
+				Sockets:              map[int]int{192: 2},
+				MaxMemoryPerNUMANode: 512,
+			},
+			expected: map[string]int{"96/384Gi": 1, "96/768Gi": 2, "192/768Gi": 2, "192/1536Gi": 3},
+		},
+		{
+			name: "Unknown NUMA topology",
+			configuration: InstanceConfiguration{
+				CoreFraction:  yandex.CoreFraction100,
+				VCPU:          []int{96},
+				MemoryPerCore: []float64{4},
+			},
+			expected: map[string]int{"96/384Gi": 0},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := &DefaultProvider{}
+			types := provider.generateInstanceTypes(yandex.PlatformAMDZen4, tc.configuration)
+			if len(types) != len(tc.expected) {
+				t.Fatalf("Expected %d shapes, got %d", len(tc.expected), len(types))
+			}
+
+			resolver := NewDefaultResolver(110)
+			nodeClass := &v1alpha1.YandexNodeClass{Spec: v1alpha1.YandexNodeClassSpec{DiskSize: resource.MustParse("30Gi")}}
+			for _, info := range types {
+				shape := info.CPU.String() + "/" + info.Memory.String()
+				expected, ok := tc.expected[shape]
+				if !ok {
+					t.Fatalf("Unexpected shape %s", shape)
+				}
+				if info.NUMANodes != expected {
+					t.Errorf("Expected %s to span %d NUMA nodes, got %d", shape, expected, info.NUMANodes)
+				}
+
+				it := resolver.Resolve(context.Background(), info, nodeClass, true)
+				if expected == 0 {
+					if op := it.Requirements.Get(v1alpha1.LabelNUMANodes).Operator(); op != corev1.NodeSelectorOpDoesNotExist {
+						t.Errorf("Expected %s of %s not to exist, got %s", v1alpha1.LabelNUMANodes, shape, op)
+					}
+					podRequirements := scheduling.NewRequirements(scheduling.NewRequirement(v1alpha1.LabelNUMANodes, corev1.NodeSelectorOpIn, "1"))
+					if err := it.Requirements.Compatible(podRequirements, scheduling.AllowUndefinedWellKnownLabels); err == nil {
+						t.Errorf("Expected %s with an unknown NUMA topology to be incompatible with %s=1", shape, v1alpha1.LabelNUMANodes)
+					}
+					continue
+				}
+				if values := it.Requirements.Get(v1alpha1.LabelNUMANodes).Values(); len(values) != 1 || values[0] != fmt.Sprint(expected) {
+					t.Errorf("Expected %s of %s to be exactly %d, got %v", v1alpha1.LabelNUMANodes, shape, expected, values)
+				}
+			}
+		})
+	}
+}
+
+func TestCheapestPrice(t *testing.T) {
+	testCases := []struct {
+		name      string
+		offerings cloudprovider.Offerings
+		expected  float64
+	}{
+		{name: "Nil offerings", expected: math.MaxFloat64},
+		{name: "Empty offerings", offerings: cloudprovider.Offerings{}, expected: math.MaxFloat64},
+		{name: "Cheapest offering", offerings: cloudprovider.Offerings{{Price: 3}, {Price: 1}, {Price: 2}}, expected: 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if price := CheapestPrice(tc.offerings); price != tc.expected {
+				t.Errorf("Expected price %v, got %v", tc.expected, price)
+			}
+		})
+	}
+
+	// instance types without offerings are sorted last instead of panicking
+	instanceTypes := []*cloudprovider.InstanceType{
+		{Name: "without-offerings", Offerings: cloudprovider.Offerings{}},
+		newPricedInstanceType("priced", yandex.PlatformIntelIceLake, 1),
+	}
+	SortByPrice(instanceTypes, cheapestAvailablePrice, nil)
+	if instanceTypes[0].Name != "priced" {
+		t.Errorf("Expected the priced instance type first, got %s", instanceTypes[0].Name)
+	}
+}
+
+func TestList_SpotDisabledPlatforms(t *testing.T) {
+	provider := NewDefaultProvider(
+		yandex.RegionRU,
+		NewDefaultResolver(110),
+		offering.NewDefaultProvider(pricing.NewDefaultProvider(yandex.RegionRU), offering.NewUnavailableOfferings()),
+		sets.New("ru-central1-a"),
+		yandex.CoreFraction100,
+		sets.New(yandex.PlatformIntelIceLake),
+	)
+
+	instanceTypes, err := provider.List(context.Background(), &v1alpha1.YandexNodeClass{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	spotPlatforms := sets.New[string]()
+	for _, it := range instanceTypes {
+		platform := it.Requirements.Get(v1alpha1.LabelInstanceCPUPlatform).Any()
+		spot := it.Requirements.Get(karpv1.CapacityTypeLabelKey).Has(karpv1.CapacityTypeSpot) ||
+			lo.SomeBy(it.Offerings, func(o *cloudprovider.Offering) bool { return o.CapacityType() == karpv1.CapacityTypeSpot })
+		if spot {
+			spotPlatforms.Insert(platform)
+		}
+	}
+	if spotPlatforms.Has(string(yandex.PlatformIntelIceLake)) {
+		t.Errorf("Expected %s to be on-demand only", yandex.PlatformIntelIceLake)
+	}
+	if !spotPlatforms.Has(string(yandex.PlatformIntelCascadeLake)) {
+		t.Errorf("Expected %s to keep spot, got spot platforms %v", yandex.PlatformIntelCascadeLake, sets.List(spotPlatforms))
+	}
+
+	name := lo.FindOrElse(instanceTypes, nil, func(it *cloudprovider.InstanceType) bool {
+		return it.Requirements.Get(v1alpha1.LabelInstanceCPUPlatform).Has(string(yandex.PlatformIntelIceLake))
+	}).Name
+	it, err := provider.GetInstanceType(context.Background(), &v1alpha1.YandexNodeClass{}, name)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if it.Requirements.Get(karpv1.CapacityTypeLabelKey).Has(karpv1.CapacityTypeSpot) {
+		t.Errorf("Expected %s to be on-demand only", name)
+	}
+}
+
+func TestList_GPUDriverVersion(t *testing.T) {
+	provider := NewDefaultProvider(
+		yandex.RegionRU,
+		NewDefaultResolver(110),
+		offering.NewDefaultProvider(pricing.NewDefaultProvider(yandex.RegionRU), offering.NewUnavailableOfferings()),
+		sets.New("ru-central1-a"),
+		yandex.CoreFraction100,
+		nil,
+	)
+
+	nodeClass := &v1alpha1.YandexNodeClass{Spec: v1alpha1.YandexNodeClassSpec{GPUDriverVersion: "535.104.05"}}
+	instanceTypes, err := provider.List(context.Background(), nodeClass)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(instanceTypes) == 0 {
+		t.Fatalf("Expected GPU instance types")
+	}
+	for _, it := range instanceTypes {
+		if platform := yandex.PlatformId(it.Requirements.Get(v1alpha1.LabelInstanceCPUPlatform).Any()); !platform.IsGPU() {
+			t.Errorf("Expected GPU instance types only, got %s", it.Name)
+		}
+	}
+}
+
+func TestList_Platforms(t *testing.T) {
+	testCases := []struct {
+		name      string
+		platform  string
+		platforms []string
+		expected  []yandex.PlatformId
+	}{
+		{
+			name:     "Platform",
+			platform: string(yandex.PlatformIntelIceLake),
+			expected: []yandex.PlatformId{yandex.PlatformIntelIceLake},
+		},
+		{
+			name:      "Platform and platforms",
+			platform:  string(yandex.PlatformIntelIceLake),
+			platforms: []string{string(yandex.PlatformIntelCascadeLake)},
+			expected:  []yandex.PlatformId{yandex.PlatformIntelCascadeLake, yandex.PlatformIntelIceLake},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := NewDefaultProvider(
+				yandex.RegionRU,
+				NewDefaultResolver(110),
+				offering.NewDefaultProvider(pricing.NewDefaultProvider(yandex.RegionRU), offering.NewUnavailableOfferings()),
+				sets.New("ru-central1-a"),
+				yandex.CoreFraction100,
+				nil,
+			)
+
+			nodeClass := &v1alpha1.YandexNodeClass{Spec: v1alpha1.YandexNodeClassSpec{Platform: tc.platform, Platforms: tc.platforms}}
+			instanceTypes, err := provider.List(context.Background(), nodeClass)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			platforms := sets.New[yandex.PlatformId]()
+			for _, it := range instanceTypes {
+				platforms.Insert(yandex.PlatformId(it.Requirements.Get(v1alpha1.LabelInstanceCPUPlatform).Any()))
+			}
+			if !platforms.Equal(sets.New(tc.expected...)) {
+				t.Errorf("Expected instance types of platforms %v, got %v", tc.expected, sets.List(platforms))
+			}
+		})
+	}
+}
+
+func TestListAvailable(t *testing.T) {
+	testCases := []struct {
+		name            string
+		zones           sets.Set[string]
+		expectAvailable bool
+	}{
+		{
+			name:            "Instance types with offerings",
+			zones:           sets.New("ru-central1-a"),
+			expectAvailable: true,
+		},
+		{
+			name:  "Instance types without offerings",
+			zones: sets.New[string](),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := NewDefaultProvider(
+				yandex.RegionRU,
+				NewDefaultResolver(110),
+				offering.NewDefaultProvider(pricing.NewDefaultProvider(yandex.RegionRU), offering.NewUnavailableOfferings()),
+				tc.zones,
+				yandex.CoreFraction100,
+				nil,
+			)
+			nodeClass := &v1alpha1.YandexNodeClass{Status: v1alpha1.YandexNodeClassStatus{
+				Subnets: []v1alpha1.Subnet{{ID: "subnet-a", ZoneID: "ru-central1-a"}},
+			}}
+
+			all, err := provider.List(context.Background(), nodeClass)
+			if err != nil {
+				t.Fatalf("List: unexpected error: %v", err)
+			}
+			if len(all) == 0 {
+				t.Fatalf("List: expected instance types regardless of offerings")
+			}
+			available, err := provider.ListAvailable(context.Background(), nodeClass)
+			if err != nil {
+				t.Fatalf("ListAvailable: unexpected error: %v", err)
+			}
+			names := sets.New(lo.Map(available, func(it *cloudprovider.InstanceType, _ int) string { return it.Name })...)
+			for _, it := range all {
+				if hasOfferings := len(it.Offerings.Available()) > 0; hasOfferings != names.Has(it.Name) {
+					t.Errorf("ListAvailable: expected %s listed=%t, got %t", it.Name, hasOfferings, names.Has(it.Name))
+				}
+			}
+			if tc.expectAvailable != (len(available) > 0) {
+				t.Errorf("ListAvailable: expected available instance types=%t, got %d", tc.expectAvailable, len(available))
+			}
+		})
+	}
+}
+
+func TestSortByPrice_InstanceTypesWithoutOfferingsGoLast(t *testing.T) {
+	withoutOfferings := &cloudprovider.InstanceType{Name: "a-without-offerings"}
+	unavailable := newPricedInstanceType("b-unavailable", yandex.PlatformIntelIceLake, 1)
+	unavailable.Offerings[0].Available = false
+	priced := newPricedInstanceType("c-priced", yandex.PlatformIntelIceLake, 10)
+
+	instanceTypes := []*cloudprovider.InstanceType{withoutOfferings, unavailable, priced}
+	SortByPrice(instanceTypes, cheapestAvailablePrice, nil)
+
+	if instanceTypes[0] != priced {
+		t.Errorf("Expected the instance type with an available offering first, got %v", lo.Map(instanceTypes, func(it *cloudprovider.InstanceType, _ int) string { return it.Name }))
+	}
+}
@@ -74,10 +74,13 @@ type AllowedGpuConfiguration struct {
 }
 
 type InstanceConfiguration struct {
-	CoreFraction     yandex.CoreFraction
-	VCPU             []int
-	MemoryPerCore    []float64
-	CanBePreemptible bool
+	CoreFraction         yandex.CoreFraction
+	VCPU                 []int
+	MemoryPerCore        []float64
+	CanBePreemptible     bool
+	MaxMemory            float64
+	Sockets              map[int]int
+	MaxMemoryPerNUMANode float64
 }
 
 type RegionConfig struct {
@@ -127,6 +130,15 @@ var {{.Region}}AvailableConfigurations = map[yandex.PlatformId][]InstanceConfigu
 			VCPU:             []int{ {{range $i, $cpu := $config.VCPU}}{{if $i}}, {{end}}{{$cpu}}{{end}} },
 			MemoryPerCore:    []float64{ {{range $i, $mem := $config.MemoryPerCore}}{{if $i}}, {{end}}{{printf "%.2f" $mem}}{{end}} },
 			CanBePreemptible: {{$config.CanBePreemptible}},
+{{- if $config.MaxMemory}}
+			MaxMemory:        {{printf "%.2f" $config.MaxMemory}},
+{{- end}}
+{{- if $config.Sockets}}
+			Sockets:          map[int]int{ {{range $cpu, $sockets := $config.Sockets}}{{$cpu}}: {{$sockets}}, {{end}}},
+{{- end}}
+{{- if $config.MaxMemoryPerNUMANode}}
+			MaxMemoryPerNUMANode: {{printf "%.2f" $config.MaxMemoryPerNUMANode}},
+{{- end}}
 		},
 {{end}}	},
 {{end}}}
@@ -242,8 +254,9 @@ func processPlatform(platform Platform, config *RegionConfig) {
 			continue
 		}
 
-		// Collect all available cores
+		// Collect all available cores, with the socket counts of those spanning several sockets
 		var vcpus []int
+		sockets := map[int]int{}
 
 		// Handle different core formats
 		switch cores := allowedConfig.Cores.(type) {
@@ -252,6 +265,13 @@ func processPlatform(platform Platform, config *RegionConfig) {
 				switch coreConfig := coreItem.(type) {
 				case map[string]interface{}:
 					// Handle CoreConfig format
+					socketCount := 1
+					if socketsStr, ok := coreConfig["sockets"].(string); ok {
+						if socketCount, err = strconv.Atoi(socketsStr); err != nil {
+							fmt.Printf("Invalid sockets value '%s' for platform %s\n", socketsStr, platform.ID)
+							socketCount = 1
+						}
+					}
 					if coresList, ok := coreConfig["cores"].([]interface{}); ok {
 						for _, coreStr := range coresList {
 							if coreStrVal, ok := coreStr.(string); ok {
@@ -261,6 +281,9 @@ func processPlatform(platform Platform, config *RegionConfig) {
 									continue
 								}
 								vcpus = append(vcpus, core)
+								if socketCount > 1 {
+									sockets[core] = socketCount
+								}
 							}
 						}
 					}
@@ -309,12 +332,40 @@ func processPlatform(platform Platform, config *RegionConfig) {
 		memoryPerCore = removeDuplicatesFloat(memoryPerCore)
 		sort.Float64s(memoryPerCore)
 
+		// Parse max memory (in bytes, convert to GB), a missing limit is left zero
+		var maxMemory float64
+		if allowedConfig.MaxMemory != "" {
+			maxMemBytes, err := strconv.ParseInt(allowedConfig.MaxMemory, 10, 64)
+			if err != nil {
+				fmt.Printf("Invalid max memory value '%s' for platform %s\n", allowedConfig.MaxMemory, platform.ID)
+			} else {
+				maxMemory = float64(maxMemBytes) / (1024 * 1024 * 1024)
+			}
+		}
+
+		// Parse max memory per NUMA node (in bytes, convert to GB), a missing limit is left zero
+		var maxMemoryPerNUMANode float64
+		if allowedConfig.MaxMemoryPerNumaNode != "" {
+			maxMemBytes, err := strconv.ParseInt(allowedConfig.MaxMemoryPerNumaNode, 10, 64)
+			if err != nil {
+				fmt.Printf("Invalid max memory per NUMA node value '%s' for platform %s\n", allowedConfig.MaxMemoryPerNumaNode, platform.ID)
+			} else {
+				maxMemoryPerNUMANode = float64(maxMemBytes) / (1024 * 1024 * 1024)
+			}
+		}
+		if len(sockets) == 0 {
+			sockets = nil
+		}
+
 		if len(vcpus) > 0 && len(memoryPerCore) > 0 {
 			configurations = append(configurations, InstanceConfiguration{
-				CoreFraction:     coreFraction,
-				VCPU:             vcpus,
-				MemoryPerCore:    memoryPerCore,
-				CanBePreemptible: !platform.RejectPreemptible,
+				CoreFraction:         coreFraction,
+				VCPU:                 vcpus,
+				MemoryPerCore:        memoryPerCore,
+				CanBePreemptible:     !platform.RejectPreemptible,
+				MaxMemory:            maxMemory,
+				Sockets:              sockets,
+				MaxMemoryPerNUMANode: maxMemoryPerNUMANode,
 			})
 		}
 	}
@@ -366,19 +417,25 @@ func generateConfigFile(config *RegionConfig) error {
 		Timestamp      string
 		Region         string
 		Configurations map[string][]struct {
-			CoreFraction     int
-			VCPU             []int
-			MemoryPerCore    []float64
-			CanBePreemptible bool
+			CoreFraction         int
+			VCPU                 []int
+			MemoryPerCore        []float64
+			CanBePreemptible     bool
+			MaxMemory            float64
+			Sockets              map[int]int
+			MaxMemoryPerNUMANode float64
 		}
 	}{
 		Timestamp: time.Now().Format("2006-01-02 15:04:05"),
 		Region:    config.Region,
 		Configurations: make(map[string][]struct {
-			CoreFraction     int
-			VCPU             []int
-			MemoryPerCore    []float64
-			CanBePreemptible bool
+			CoreFraction         int
+			VCPU                 []int
+			MemoryPerCore        []float64
+			CanBePreemptible     bool
+			MaxMemory            float64
+			Sockets              map[int]int
+			MaxMemoryPerNUMANode float64
 		}),
 	}
 
@@ -394,23 +451,32 @@ func generateConfigFile(config *RegionConfig) error {
 		configurations := config.Configurations[platformID]
 
 		var convertedConfigs []struct {
-			CoreFraction     int
-			VCPU             []int
-			MemoryPerCore    []float64
-			CanBePreemptible bool
+			CoreFraction         int
+			VCPU                 []int
+			MemoryPerCore        []float64
+			CanBePreemptible     bool
+			MaxMemory            float64
+			Sockets              map[int]int
+			MaxMemoryPerNUMANode float64
 		}
 
 		for _, config := range configurations {
 			convertedConfigs = append(convertedConfigs, struct {
-				CoreFraction     int
-				VCPU             []int
-				MemoryPerCore    []float64
-				CanBePreemptible bool
+				CoreFraction         int
+				VCPU                 []int
+				MemoryPerCore        []float64
+				CanBePreemptible     bool
+				MaxMemory            float64
+				Sockets              map[int]int
+				MaxMemoryPerNUMANode float64
 			}{
-				CoreFraction:     int(config.CoreFraction),
-				VCPU:             config.VCPU,
-				MemoryPerCore:    config.MemoryPerCore,
-				CanBePreemptible: config.CanBePreemptible,
+				CoreFraction:         int(config.CoreFraction),
+				VCPU:                 config.VCPU,
+				MemoryPerCore:        config.MemoryPerCore,
+				CanBePreemptible:     config.CanBePreemptible,
+				MaxMemory:            config.MaxMemory,
+				Sockets:              config.Sockets,
+				MaxMemoryPerNUMANode: config.MaxMemoryPerNUMANode,
 			})
 		}
 
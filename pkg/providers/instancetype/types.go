@@ -45,6 +45,29 @@ type InstanceConfiguration struct {
 	VCPU             []int
 	MemoryPerCore    []float64
 	CanBePreemptible bool
+	// MaxMemory is the most memory in GB an instance of the configuration can have, zero when unlimited.
+	// ru.configuration.go was generated before config_gen captured it and sets none, so no shape is skipped for
+	// its memory until the file is regenerated
+	MaxMemory float64
+	// Sockets are the socket counts of the vCPU counts whose instances span several sockets, a NUMA node each
+	Sockets map[int]int
+	// MaxMemoryPerNUMANode is the most memory in GB of a single NUMA node, zero when unknown.
+	// ru.configuration.go was generated before config_gen captured the NUMA topology, so no instance type has a
+	// NUMA node count until the file is regenerated
+	MaxMemoryPerNUMANode float64
+}
+
+// numaNodes returns the number of NUMA nodes an instance of the configuration with cpu vCPUs and memory GB spans,
+// zero when the configuration has no NUMA topology
+func (c InstanceConfiguration) numaNodes(cpu int, memory float64) int {
+	if len(c.Sockets) == 0 && c.MaxMemoryPerNUMANode == 0 {
+		return 0
+	}
+	nodes := max(c.Sockets[cpu], 1)
+	if c.MaxMemoryPerNUMANode > 0 {
+		nodes = max(nodes, int(math.Ceil(memory/c.MaxMemoryPerNUMANode)))
+	}
+	return nodes
 }
 
 type ZoneData struct {
@@ -91,9 +114,9 @@ func NewInstanceType(
 		Capacity:     computeCapacity(ctx, info, nodeClass.Spec.DiskSize, maxPods),
 		Offerings:    cloudprovider.Offerings{}, // Initialize empty offerings to prevent panic
 		Overhead: &cloudprovider.InstanceTypeOverhead{
-			KubeReserved:      kubeReservedResources(info.CPU, info.Memory),
+			KubeReserved:      kubeReservedResources(info.CPU, info.Memory, nodeClass.Spec.DiskSize),
 			SystemReserved:    corev1.ResourceList{},
-			EvictionThreshold: evictionThreshold(nodeClass.Spec.DiskSize),
+			EvictionThreshold: evictionThreshold(ephemeralStorage(nodeClass.Spec.DiskSize)),
 		},
 	}
 	return it
@@ -117,15 +140,14 @@ func computeRequirements(
 	requirements := scheduling.NewRequirements(
 		// Well Known Upstream
 
-		// hack, node will have this labels with platform_id only
-		// but karpenter adds instance types as requirement in node claim (taken from CloudProvider.GetInstanceTypes)
+		// Yandex Cloud labels nodes with the platform id only, the NodeClaim labels karpenter syncs to the node on
+		// registration replace it with the canonical instance type name, see CloudProvider.nodeGroupLabels
+		// beta labels are normalized to their stable counterparts, so only the stable ones are required
 		scheduling.NewRequirement(corev1.LabelInstanceTypeStable, corev1.NodeSelectorOpIn, info.String()),
-		scheduling.NewRequirement(corev1.LabelInstanceType, corev1.NodeSelectorOpIn, info.String()),
 
 		scheduling.NewRequirement(corev1.LabelArchStable, corev1.NodeSelectorOpIn, "amd64"),
 		scheduling.NewRequirement(corev1.LabelOSStable, corev1.NodeSelectorOpIn, "linux"),
 		scheduling.NewRequirement(corev1.LabelTopologyZone, corev1.NodeSelectorOpIn, availableZones...),
-		scheduling.NewRequirement(corev1.LabelFailureDomainBetaZone, corev1.NodeSelectorOpIn, availableZones...),
 		// Well Known to Karpenter
 		scheduling.NewRequirement(karpv1.CapacityTypeLabelKey, corev1.NodeSelectorOpIn, capacityTypes...),
 		scheduling.NewRequirement(v1alpha1.LabelInstanceCPUPlatform, corev1.NodeSelectorOpIn, string(info.Platform)),
@@ -140,6 +162,31 @@ func computeRequirements(
 		scheduling.NewRequirement("node.kubernetes.io/node-problem-detector-ds-ready", corev1.NodeSelectorOpIn, "true"),
 	)
 
+	// nodes of a platform without a human name are not labelled with one
+	if name := info.Platform.Name(); name != "" {
+		requirements.Add(scheduling.NewRequirement(v1alpha1.LabelInstancePlatformName, corev1.NodeSelectorOpIn, name))
+	} else {
+		requirements.Add(scheduling.NewRequirement(v1alpha1.LabelInstancePlatformName, corev1.NodeSelectorOpDoesNotExist))
+	}
+	// instance types without GPUs must not satisfy a GPU selector, their nodes have no GPU labels
+	if gpus := info.GPUs(); gpus > 0 {
+		requirements.Add(
+			scheduling.NewRequirement(v1alpha1.LabelInstanceGPUCount, corev1.NodeSelectorOpIn, fmt.Sprint(gpus)),
+			scheduling.NewRequirement(v1alpha1.LabelInstanceGPUType, corev1.NodeSelectorOpIn, info.Platform.GPUType()),
+		)
+	} else {
+		requirements.Add(
+			scheduling.NewRequirement(v1alpha1.LabelInstanceGPUCount, corev1.NodeSelectorOpDoesNotExist),
+			scheduling.NewRequirement(v1alpha1.LabelInstanceGPUType, corev1.NodeSelectorOpDoesNotExist),
+		)
+	}
+	// an unknown NUMA topology must not satisfy a selector on it, the node is not labelled with one
+	if info.NUMANodes > 0 {
+		requirements.Add(scheduling.NewRequirement(v1alpha1.LabelNUMANodes, corev1.NodeSelectorOpIn, fmt.Sprint(info.NUMANodes)))
+	} else {
+		requirements.Add(scheduling.NewRequirement(v1alpha1.LabelNUMANodes, corev1.NodeSelectorOpDoesNotExist))
+	}
+
 	// add nodeclass's labels
 	for k, v := range nodeClass.Spec.NodeLabels {
 		requirements.Add(
@@ -154,17 +201,23 @@ func computeCapacity(_ context.Context, info yandex.InstanceType, diskSize resou
 	resourceList := corev1.ResourceList{
 		corev1.ResourceCPU:              info.CPU,
 		corev1.ResourceMemory:           info.Memory,
-		corev1.ResourceEphemeralStorage: diskSize,
+		corev1.ResourceEphemeralStorage: ephemeralStorage(diskSize),
 		corev1.ResourcePods:             *resource.NewQuantity(int64(podsPerCore), resource.DecimalSI),
 	}
+	if gpus := info.GPUs(); gpus > 0 {
+		resourceList[v1alpha1.ResourceNVIDIAGPU] = *resource.NewQuantity(gpus, resource.DecimalSI)
+	}
 	return resourceList
 }
 
-func kubeReservedResources(cpu, memory resource.Quantity) corev1.ResourceList {
+// kubeReservedResources follows the kubelet reservations of Managed Service for Kubernetes, documented under
+// "Allocatable resources" of its node group concepts. The formula is the same for every platform, GPU platforms
+// included, so no GPU driver overhead is reserved on top of it.
+func kubeReservedResources(cpu, memory, diskSize resource.Quantity) corev1.ResourceList {
 	return corev1.ResourceList{
 		corev1.ResourceMemory:           kubeReservedMemory(memory),
 		corev1.ResourceCPU:              kubeReservedCPU(cpu),
-		corev1.ResourceEphemeralStorage: kubeReservedEphemeralStorage(),
+		corev1.ResourceEphemeralStorage: kubeReservedEphemeralStorage(diskSize),
 	}
 }
 
@@ -208,8 +261,26 @@ func kubeReservedCPU(cpu resource.Quantity) resource.Quantity {
 	return *resource.NewMilliQuantity(int64(math.Round(reserved*1000)), resource.DecimalSI)
 }
 
-func kubeReservedEphemeralStorage() resource.Quantity {
-	return resource.MustParse("15Gi") // fixed?
+var (
+	// bootDiskOSFootprint is taken up on the boot disk by the node image, the OS and the preloaded system images
+	bootDiskOSFootprint = resource.MustParse("6Gi")
+	// kube-reserved ephemeral storage is a share of the disk within these bounds
+	minKubeReservedEphemeralStorage = resource.MustParse("1Gi")
+	maxKubeReservedEphemeralStorage = resource.MustParse("15Gi")
+)
+
+// ephemeralStorage is the part of the boot disk left to the node filesystem once the node image is in place
+func ephemeralStorage(diskSize resource.Quantity) resource.Quantity {
+	return *resource.NewQuantity(max(diskSize.Value()-bootDiskOSFootprint.Value(), 0), resource.BinarySI)
+}
+
+// kubeReservedEphemeralStorage reserves 5% of the disk for the kubelet and the container runtime, at least 1Gi and
+// at most 15Gi
+func kubeReservedEphemeralStorage(diskSize resource.Quantity) resource.Quantity {
+	reserved := diskSize.Value() / 20
+	reserved = max(reserved, minKubeReservedEphemeralStorage.Value())
+	reserved = min(reserved, maxKubeReservedEphemeralStorage.Value())
+	return *resource.NewQuantity(reserved, resource.BinarySI)
 }
 
 func evictionThreshold(storage resource.Quantity) corev1.ResourceList {
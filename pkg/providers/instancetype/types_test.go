@@ -16,21 +16,24 @@ package instancetype
 
 import (
 	"context"
+	"fmt"
 	"testing"
 
 	"github.com/tufitko/karpenter-provider-yandex/pkg/apis/v1alpha1"
 	"github.com/tufitko/karpenter-provider-yandex/pkg/providers/instancetype/offering"
 	"github.com/tufitko/karpenter-provider-yandex/pkg/providers/pricing"
 	"github.com/tufitko/karpenter-provider-yandex/pkg/yandex"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/util/sets"
 	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
 	"sigs.k8s.io/karpenter/pkg/cloudprovider"
+	"sigs.k8s.io/karpenter/pkg/scheduling"
 )
 
 func TestNoSpotOfferingsForUnsupportedPlatform(t *testing.T) {
-	pricingProvider := pricing.NewDefaultProvider()
-	offeringProvider := offering.NewDefaultProvider(pricingProvider)
+	pricingProvider := pricing.NewDefaultProvider(yandex.RegionRU)
+	offeringProvider := offering.NewDefaultProvider(pricingProvider, offering.NewUnavailableOfferings())
 
 	resolver := NewDefaultResolver(10)
 
@@ -113,8 +116,8 @@ func TestNoSpotOfferingsForUnsupportedPlatform(t *testing.T) {
 }
 
 func TestSpotOfferingsForSupportedPlatform(t *testing.T) {
-	pricingProvider := pricing.NewDefaultProvider()
-	offeringProvider := offering.NewDefaultProvider(pricingProvider)
+	pricingProvider := pricing.NewDefaultProvider(yandex.RegionRU)
+	offeringProvider := offering.NewDefaultProvider(pricingProvider, offering.NewUnavailableOfferings())
 
 	resolver := NewDefaultResolver(10)
 
@@ -207,3 +210,289 @@ func TestSpotOfferingsForSupportedPlatform(t *testing.T) {
 	t.Logf("Platform %s with CanBePreemptible=true: %d on-demand offerings, %d spot offerings",
 		instanceTypeInfo.Platform, onDemandOfferings, spotOfferings)
 }
+
+func TestKubeReservedResources(t *testing.T) {
+	testCases := []struct {
+		name           string
+		cpu, memory    string
+		expectedCPU    string
+		expectedMemory string
+	}{
+		{name: "Below 1Gi", cpu: "2", memory: "512Mi", expectedCPU: "70m", expectedMemory: "255Mi"},
+		{name: "Small", cpu: "2", memory: "4Gi", expectedCPU: "70m", expectedMemory: "1Gi"},
+		// the sizes of a gpu-standard-v3 node with one GPU reserve the same as any other platform
+		{name: "GPU node sized", cpu: "8", memory: "96Gi", expectedCPU: "90m", expectedMemory: "7577Mi"},
+		{name: "Large", cpu: "64", memory: "256Gi", expectedCPU: "230m", expectedMemory: "12165Mi"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reserved := kubeReservedResources(resource.MustParse(tc.cpu), resource.MustParse(tc.memory), resource.MustParse("30Gi"))
+			if expected := resource.MustParse(tc.expectedCPU); !reserved.Cpu().Equal(expected) {
+				t.Errorf("Expected CPU reservation %s, got %s", expected.String(), reserved.Cpu().String())
+			}
+			if expected := resource.MustParse(tc.expectedMemory); reserved.Memory().Value()>>20 != expected.Value()>>20 {
+				t.Errorf("Expected memory reservation %s, got %s", expected.String(), reserved.Memory().String())
+			}
+		})
+	}
+}
+
+func TestNewInstanceType_OverheadOfGPUPlatform(t *testing.T) {
+	nodeClass := &v1alpha1.YandexNodeClass{
+		Spec:   v1alpha1.YandexNodeClassSpec{DiskSize: resource.MustParse("30Gi")},
+		Status: v1alpha1.YandexNodeClassStatus{Subnets: []v1alpha1.Subnet{{ZoneID: "ru-central1-a"}}},
+	}
+	resolve := func(platform yandex.PlatformId) *cloudprovider.InstanceType {
+		return NewDefaultResolver(10).Resolve(context.Background(), yandex.InstanceType{
+			Platform:     platform,
+			CPU:          resource.MustParse("8"),
+			Memory:       resource.MustParse("32Gi"),
+			CoreFraction: yandex.CoreFraction100,
+		}, nodeClass, true)
+	}
+
+	// MK8S reserves by the same formula on every platform, a GPU node reserves as much as a CPU node of its size
+	gpu, standard := resolve(yandex.PlatformIntelIceLakeNVIDIATeslaT4), resolve(yandex.PlatformIntelIceLake)
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory, corev1.ResourceEphemeralStorage} {
+		gpuReserved, standardReserved := gpu.Overhead.Total()[name], standard.Overhead.Total()[name]
+		if !gpuReserved.Equal(standardReserved) {
+			t.Errorf("Expected the %s overhead of the GPU platform to be %s, got %s", name, standardReserved.String(), gpuReserved.String())
+		}
+	}
+}
+
+func TestNewInstanceType_EphemeralStorage(t *testing.T) {
+	info := yandex.InstanceType{
+		Platform:     yandex.PlatformIntelIceLake,
+		CPU:          resource.MustParse("2"),
+		Memory:       resource.MustParse("4Gi"),
+		CoreFraction: yandex.CoreFraction100,
+	}
+
+	testCases := []struct {
+		diskSize            string
+		expectedCapacity    string
+		expectedKubeReserve string
+	}{
+		{"30Gi", "24Gi", "1536Mi"},
+		{"100Gi", "94Gi", "5Gi"},
+		{"1Ti", "1018Gi", "15Gi"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.diskSize, func(t *testing.T) {
+			diskSize := resource.MustParse(tc.diskSize)
+			nodeClass := &v1alpha1.YandexNodeClass{Spec: v1alpha1.YandexNodeClassSpec{DiskSize: diskSize}}
+
+			it := NewInstanceType(context.Background(), info, nodeClass, 110, false)
+
+			if got := it.Capacity[corev1.ResourceEphemeralStorage]; !got.Equal(resource.MustParse(tc.expectedCapacity)) {
+				t.Errorf("Expected ephemeral storage capacity %s, got %s", tc.expectedCapacity, got.String())
+			}
+			if got := it.Overhead.KubeReserved[corev1.ResourceEphemeralStorage]; !got.Equal(resource.MustParse(tc.expectedKubeReserve)) {
+				t.Errorf("Expected kube-reserved ephemeral storage %s, got %s", tc.expectedKubeReserve, got.String())
+			}
+			// at least two thirds of the disk is left to pods, and never more than the node filesystem
+			allocatable := it.Allocatable()[corev1.ResourceEphemeralStorage]
+			if allocatable.Value() < diskSize.Value()*2/3 || allocatable.Cmp(it.Capacity[corev1.ResourceEphemeralStorage]) >= 0 {
+				t.Errorf("Expected plausible allocatable ephemeral storage for a %s disk, got %s", tc.diskSize, allocatable.String())
+			}
+		})
+	}
+}
+
+func TestComputeRequirements_CanonicalInstanceType(t *testing.T) {
+	info := yandex.InstanceType{
+		Platform:     yandex.PlatformIntelIceLake,
+		CPU:          resource.MustParse("2"),
+		Memory:       resource.MustParse("4Gi"),
+		CoreFraction: yandex.CoreFraction100,
+	}
+	nodeClass := &v1alpha1.YandexNodeClass{
+		Status: v1alpha1.YandexNodeClassStatus{Subnets: []v1alpha1.Subnet{{ZoneID: "ru-central1-a"}}},
+	}
+
+	it := NewDefaultResolver(10).Resolve(context.Background(), info, nodeClass, true)
+
+	for _, key := range []string{corev1.LabelInstanceTypeStable, v1alpha1.LabelInstanceType} {
+		if values := it.Requirements.Get(key).Values(); len(values) != 1 || values[0] != it.Name {
+			t.Errorf("Expected %s to be exactly %s, got %v", key, it.Name, values)
+		}
+	}
+	if values := it.Requirements.Get(corev1.LabelArchStable).Values(); len(values) != 1 || values[0] != "amd64" {
+		t.Errorf("Expected %s to be exactly amd64, got %v", corev1.LabelArchStable, values)
+	}
+}
+
+func TestComputeRequirements_InstanceMemory(t *testing.T) {
+	nodeClass := &v1alpha1.YandexNodeClass{
+		Status: v1alpha1.YandexNodeClassStatus{Subnets: []v1alpha1.Subnet{{ZoneID: "ru-central1-a"}}},
+	}
+
+	for _, memory := range []string{"512Mi", "4Gi", "238Gi"} {
+		t.Run(memory, func(t *testing.T) {
+			info := yandex.InstanceType{
+				Platform:     yandex.PlatformIntelIceLake,
+				CPU:          resource.MustParse("2"),
+				Memory:       resource.MustParse(memory),
+				CoreFraction: yandex.CoreFraction100,
+			}
+
+			it := NewDefaultResolver(10).Resolve(context.Background(), info, nodeClass, true)
+
+			// the node label is set from the same quantity, so a nodeSelector on it matches the requirement
+			values := it.Requirements.Get(v1alpha1.LabelInstanceMemory).Values()
+			if len(values) != 1 || values[0] != info.Memory.String() {
+				t.Errorf("Expected %s to be exactly %s, got %v", v1alpha1.LabelInstanceMemory, info.Memory.String(), values)
+			}
+			if !karpv1.WellKnownLabels.Has(v1alpha1.LabelInstanceMemory) {
+				t.Errorf("Expected %s to be a well known label", v1alpha1.LabelInstanceMemory)
+			}
+		})
+	}
+}
+
+func TestComputeRequirements_CoreFraction(t *testing.T) {
+	nodeClass := &v1alpha1.YandexNodeClass{
+		Status: v1alpha1.YandexNodeClassStatus{Subnets: []v1alpha1.Subnet{{ZoneID: "ru-central1-a"}}},
+	}
+
+	for _, fraction := range []yandex.CoreFraction{yandex.CoreFraction20, yandex.CoreFraction50, yandex.CoreFraction100} {
+		t.Run(fmt.Sprint(fraction), func(t *testing.T) {
+			info := yandex.InstanceType{
+				Platform:     yandex.PlatformIntelIceLake,
+				CPU:          resource.MustParse("2"),
+				Memory:       resource.MustParse("4Gi"),
+				CoreFraction: fraction,
+			}
+
+			it := NewDefaultResolver(10).Resolve(context.Background(), info, nodeClass, true)
+
+			// burstable and dedicated nodes of the same size are told apart by the core fraction only
+			values := it.Requirements.Get(v1alpha1.LabelInstanceCPUFraction).Values()
+			if len(values) != 1 || values[0] != fmt.Sprint(fraction) {
+				t.Errorf("Expected %s to be exactly %d, got %v", v1alpha1.LabelInstanceCPUFraction, fraction, values)
+			}
+			if !karpv1.WellKnownLabels.Has(v1alpha1.LabelInstanceCPUFraction) {
+				t.Errorf("Expected %s to be a well known label", v1alpha1.LabelInstanceCPUFraction)
+			}
+		})
+	}
+}
+
+func TestComputeRequirements_PlatformName(t *testing.T) {
+	nodeClass := &v1alpha1.YandexNodeClass{
+		Status: v1alpha1.YandexNodeClassStatus{Subnets: []v1alpha1.Subnet{{ZoneID: "ru-central1-a"}}},
+	}
+	info := yandex.InstanceType{
+		Platform:     yandex.PlatformIntelIceLake,
+		CPU:          resource.MustParse("2"),
+		Memory:       resource.MustParse("4Gi"),
+		CoreFraction: yandex.CoreFraction100,
+	}
+
+	it := NewDefaultResolver(10).Resolve(context.Background(), info, nodeClass, true)
+
+	testCases := []struct {
+		name       string
+		platform   string
+		compatible bool
+	}{
+		{name: "Same platform", platform: "intel-ice-lake", compatible: true},
+		{name: "Other platform", platform: "amd-zen-4", compatible: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			podRequirements := scheduling.NewRequirements(
+				scheduling.NewRequirement(v1alpha1.LabelInstancePlatformName, corev1.NodeSelectorOpIn, tc.platform),
+			)
+			err := it.Requirements.Compatible(podRequirements, scheduling.AllowUndefinedWellKnownLabels)
+			if tc.compatible && err != nil {
+				t.Errorf("Expected %s=%s to be compatible, got %v", v1alpha1.LabelInstancePlatformName, tc.platform, err)
+			}
+			if !tc.compatible && err == nil {
+				t.Errorf("Expected %s=%s to be incompatible", v1alpha1.LabelInstancePlatformName, tc.platform)
+			}
+		})
+	}
+}
+
+func TestNewInstanceType_GPUs(t *testing.T) {
+	testCases := []struct {
+		name         string
+		info         yandex.InstanceType
+		expected     string
+		expectedType string
+	}{
+		{
+			name: "A100",
+			info: yandex.InstanceType{
+				Platform:     yandex.PlatformAMDEPYCNVIDIAAmpereA100,
+				CPU:          resource.MustParse("56"),
+				Memory:       resource.MustParse("238Gi"),
+				CoreFraction: yandex.CoreFraction100,
+			},
+			expected:     "2",
+			expectedType: "nvidia-ampere-a100",
+		},
+		{
+			name: "T4",
+			info: yandex.InstanceType{
+				Platform:     yandex.PlatformIntelIceLakeNVIDIATeslaT4,
+				CPU:          resource.MustParse("8"),
+				Memory:       resource.MustParse("32Gi"),
+				CoreFraction: yandex.CoreFraction100,
+			},
+			expected:     "1",
+			expectedType: "nvidia-tesla-t4",
+		},
+		{
+			name: "No GPUs",
+			info: yandex.InstanceType{
+				Platform:     yandex.PlatformIntelIceLake,
+				CPU:          resource.MustParse("8"),
+				Memory:       resource.MustParse("32Gi"),
+				CoreFraction: yandex.CoreFraction100,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeClass := &v1alpha1.YandexNodeClass{
+				Spec:   v1alpha1.YandexNodeClassSpec{DiskSize: resource.MustParse("30Gi")},
+				Status: v1alpha1.YandexNodeClassStatus{Subnets: []v1alpha1.Subnet{{ZoneID: "ru-central1-a"}}},
+			}
+			it := NewDefaultResolver(10).Resolve(context.Background(), tc.info, nodeClass, true)
+
+			gpus, ok := it.Capacity[v1alpha1.ResourceNVIDIAGPU]
+			if tc.expected == "" {
+				if ok {
+					t.Errorf("Expected no %s capacity, got %s", v1alpha1.ResourceNVIDIAGPU, gpus.String())
+				}
+				for _, key := range []string{v1alpha1.LabelInstanceGPUCount, v1alpha1.LabelInstanceGPUType} {
+					if op := it.Requirements.Get(key).Operator(); op != corev1.NodeSelectorOpDoesNotExist {
+						t.Errorf("Expected %s not to exist, got %s", key, op)
+					}
+				}
+				podRequirements := scheduling.NewRequirements(
+					scheduling.NewRequirement(v1alpha1.LabelInstanceGPUType, corev1.NodeSelectorOpIn, "nvidia-tesla-t4"),
+				)
+				if err := it.Requirements.Compatible(podRequirements, scheduling.AllowUndefinedWellKnownLabels); err == nil {
+					t.Errorf("Expected an instance type without GPUs to be incompatible with %s=nvidia-tesla-t4", v1alpha1.LabelInstanceGPUType)
+				}
+				return
+			}
+			if gpus.String() != tc.expected {
+				t.Errorf("Expected %s capacity %s, got %s", v1alpha1.ResourceNVIDIAGPU, tc.expected, gpus.String())
+			}
+			if values := it.Requirements.Get(v1alpha1.LabelInstanceGPUCount).Values(); len(values) != 1 || values[0] != tc.expected {
+				t.Errorf("Expected %s to be exactly %s, got %v", v1alpha1.LabelInstanceGPUCount, tc.expected, values)
+			}
+			if values := it.Requirements.Get(v1alpha1.LabelInstanceGPUType).Values(); len(values) != 1 || values[0] != tc.expectedType {
+				t.Errorf("Expected %s to be exactly %s, got %v", v1alpha1.LabelInstanceGPUType, tc.expectedType, values)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pricing
+
+import "github.com/tufitko/karpenter-provider-yandex/pkg/yandex"
+
+var _ Provider = (*CommittedProvider)(nil)
+
+// CommittedProvider discounts the on-demand prices of the platforms covered by a committed volume of services (CVoS),
+// so that instance types of committed platforms are preferred over equally sized ones of other platforms. Spot prices
+// are not discounted
+type CommittedProvider struct {
+	Provider
+
+	// discounts are the discounts of the committed platforms in percent
+	discounts map[yandex.PlatformId]float64
+}
+
+func NewCommittedProvider(provider Provider, discounts map[yandex.PlatformId]float64) *CommittedProvider {
+	return &CommittedProvider{
+		Provider:  provider,
+		discounts: discounts,
+	}
+}
+
+// OnDemandPrice returns the discounted on-demand price of instance types of committed platforms. The discounted price
+// is never lower than the spot price of the instance type, so spot capacity stays preferred where it is allowed
+func (p *CommittedProvider) OnDemandPrice(instanceType yandex.InstanceType) (float64, bool) {
+	price, ok := p.Provider.OnDemandPrice(instanceType)
+	discount, committed := p.discounts[instanceType.Platform]
+	if !ok || !committed {
+		return price, ok
+	}
+	price *= 1 - discount/100
+	if spotPrice, ok := p.Provider.SpotPrice(instanceType, ""); ok && price < spotPrice {
+		return spotPrice, true
+	}
+	return price, true
+}
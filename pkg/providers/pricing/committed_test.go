@@ -0,0 +1,77 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pricing
+
+import (
+	"testing"
+
+	"github.com/tufitko/karpenter-provider-yandex/pkg/yandex"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func newTestInstanceType(platform yandex.PlatformId) yandex.InstanceType {
+	return yandex.InstanceType{
+		Platform:     platform,
+		CPU:          resource.MustParse("2"),
+		Memory:       resource.MustParse("4Gi"),
+		CoreFraction: yandex.CoreFraction100,
+	}
+}
+
+func TestCommittedProvider_RanksCommittedPlatformsFirst(t *testing.T) {
+	list := NewDefaultProvider(yandex.RegionRU)
+	cascadeLake, _ := list.OnDemandPrice(newTestInstanceType(yandex.PlatformIntelCascadeLake))
+	iceLake, _ := list.OnDemandPrice(newTestInstanceType(yandex.PlatformIntelIceLake))
+	if iceLake >= cascadeLake {
+		t.Fatalf("Expected standard-v3 to be listed cheaper than standard-v2, got %.4f and %.4f", iceLake, cascadeLake)
+	}
+
+	// a commitment on standard-v2 that outweighs the list price difference
+	discount := (1-iceLake/cascadeLake)*100 + 5
+	provider := NewCommittedProvider(list, map[yandex.PlatformId]float64{yandex.PlatformIntelCascadeLake: discount})
+
+	committed, ok := provider.OnDemandPrice(newTestInstanceType(yandex.PlatformIntelCascadeLake))
+	if !ok {
+		t.Fatalf("Expected an on-demand price of standard-v2")
+	}
+	if expected := cascadeLake * (1 - discount/100); committed != expected {
+		t.Errorf("Expected the discounted price %.4f, got %.4f", expected, committed)
+	}
+	uncommitted, _ := provider.OnDemandPrice(newTestInstanceType(yandex.PlatformIntelIceLake))
+	if uncommitted != iceLake {
+		t.Errorf("Expected the list price %.4f of the uncommitted platform, got %.4f", iceLake, uncommitted)
+	}
+	if committed >= uncommitted {
+		t.Errorf("Expected the committed platform to be cheaper, got %.4f and %.4f", committed, uncommitted)
+	}
+}
+
+func TestCommittedProvider_SpotPrices(t *testing.T) {
+	list := NewDefaultProvider(yandex.RegionRU)
+	provider := NewCommittedProvider(list, map[yandex.PlatformId]float64{yandex.PlatformIntelIceLake: 99})
+	it := newTestInstanceType(yandex.PlatformIntelIceLake)
+
+	listSpot, _ := list.SpotPrice(it, "ru-central1-a")
+	spot, ok := provider.SpotPrice(it, "ru-central1-a")
+	if !ok || spot != listSpot {
+		t.Errorf("Expected the spot price %.4f to be left alone, got %.4f", listSpot, spot)
+	}
+
+	// the discount would take the on-demand price below the spot price
+	onDemand, _ := provider.OnDemandPrice(it)
+	if onDemand != listSpot {
+		t.Errorf("Expected the discounted on-demand price to be floored at the spot price %.4f, got %.4f", listSpot, onDemand)
+	}
+}
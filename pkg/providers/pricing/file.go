@@ -0,0 +1,134 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pricing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/yandex"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// priceFile is the JSON format of price tables read from a file or an endpoint, prices are in the same units as the
+// generated tables:
+//
+//	{
+//	  "currency": "RUB",
+//	  "platforms": {
+//	    "standard-v3": {"perFraction": {"100": 1.12}, "preemptiblePerFraction": {"100": 0.34}, "ram": 0.3, "preemptibleRam": 0.08}
+//	  },
+//	  "disks": {"network-ssd": 0.0154}
+//	}
+type priceFile struct {
+	Currency  string                                  `json:"currency"`
+	Platforms map[yandex.PlatformId]priceFilePlatform `json:"platforms"`
+	Disks     map[yandex.DiskType]float64             `json:"disks"`
+}
+
+type priceFilePlatform struct {
+	PerFraction            map[yandex.CoreFraction]float64 `json:"perFraction"`
+	PreemptiblePerFraction map[yandex.CoreFraction]float64 `json:"preemptiblePerFraction"`
+	RAM                    float64                         `json:"ram"`
+	PreemptibleRAM         float64                         `json:"preemptibleRam"`
+}
+
+func readPriceFile(path string) (priceTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return priceTable{}, err
+	}
+	return parsePriceTable(data, path)
+}
+
+// parsePriceTable parses a price table in the priceFile format read from source
+func parsePriceTable(data []byte, source string) (priceTable, error) {
+	var file priceFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return priceTable{}, fmt.Errorf("parsing %s, %w", source, err)
+	}
+	if file.Currency == "" {
+		return priceTable{}, fmt.Errorf("%s has no currency", source)
+	}
+	if len(file.Platforms) == 0 {
+		return priceTable{}, fmt.Errorf("%s has no platform prices", source)
+	}
+
+	table := priceTable{
+		region:    source,
+		currency:  file.Currency,
+		platforms: make(map[yandex.PlatformId]pricingPlatform, len(file.Platforms)),
+		disks:     file.Disks,
+	}
+	for id, platform := range file.Platforms {
+		table.platforms[id] = pricingPlatform{
+			perFraction:            platform.PerFraction,
+			preemptiblePerFraction: platform.PreemptiblePerFraction,
+			ram:                    platform.RAM,
+			preemptibleRAM:         platform.PreemptibleRAM,
+		}
+	}
+	return table, nil
+}
+
+// WatchFile prices from the price table at path and reloads it whenever it changes until ctx is done. The built-in
+// price table is used while the file is missing or invalid. The directory of the file is watched rather than the
+// file itself, so that files mounted from a ConfigMap, which are replaced through a symlink, are reloaded too
+func (p *DefaultProvider) WatchFile(ctx context.Context, path string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("creating price file watcher, %w", err)
+	}
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		_ = watcher.Close()
+		return fmt.Errorf("watching price file %s, %w", path, err)
+	}
+	p.loadFile(ctx, path)
+
+	go func() {
+		defer func() { _ = watcher.Close() }()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				p.loadFile(ctx, path)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.FromContext(ctx).Error(err, "watching price file", "path", path)
+			}
+		}
+	}()
+	return nil
+}
+
+// loadFile switches pricing to the price table at path, or back to the built-in one when it cannot be read
+func (p *DefaultProvider) loadFile(ctx context.Context, path string) {
+	table, err := readPriceFile(path)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "failed to load price file, using built-in prices", "path", path)
+		table = p.tables[0]
+	}
+	p.setTable(table)
+}
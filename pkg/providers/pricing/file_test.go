@@ -0,0 +1,134 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pricing
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tufitko/karpenter-provider-yandex/pkg/yandex"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func writePriceFile(t *testing.T, path string, ram float64) {
+	t.Helper()
+	data := fmt.Sprintf(`{
+  "currency": "KZT",
+  "platforms": {
+    "standard-v3": {"perFraction": {"100": 1}, "preemptiblePerFraction": {"100": 0.5}, "ram": %v, "preemptibleRam": 0.1}
+  },
+  "disks": {"network-ssd": 0.1}
+}`, ram)
+	// written next to the file and renamed over it, so that the change is observed as a whole
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(data), 0o644); err != nil {
+		t.Fatalf("Failed to write price file: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatalf("Failed to replace price file: %v", err)
+	}
+}
+
+func TestWatchFile(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "prices.json")
+	iceLake := yandex.InstanceType{
+		Platform:     yandex.PlatformIntelIceLake,
+		CPU:          resource.MustParse("2"),
+		Memory:       resource.MustParse("4Gi"),
+		CoreFraction: yandex.CoreFraction100,
+	}
+	builtIn, _ := NewDefaultProvider(yandex.RegionRU).OnDemandPrice(iceLake)
+
+	provider := NewDefaultProvider(yandex.RegionRU)
+	if err := provider.WatchFile(ctx, path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expectPrice := func(expected float64, currency string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			price, ok := provider.OnDemandPrice(iceLake)
+			if ok && math.Abs(price-expected) < 0.001 && provider.Currency() == currency {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected price %v %s, got %v %s", expected, currency, price, provider.Currency())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// the file is missing
+	expectPrice(builtIn, "RUB")
+
+	writePriceFile(t, path, 1)
+	expectPrice(2*1+4*1, "KZT")
+
+	writePriceFile(t, path, 2)
+	expectPrice(2*1+4*2, "KZT")
+
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatalf("Failed to write price file: %v", err)
+	}
+	expectPrice(builtIn, "RUB")
+}
+
+func TestReadPriceFile(t *testing.T) {
+	testCases := []struct {
+		name        string
+		data        string
+		expectError bool
+	}{
+		{
+			name: "Valid",
+			data: `{"currency": "RUB", "platforms": {"standard-v3": {"perFraction": {"100": 1}, "ram": 1}}}`,
+		},
+		{
+			name:        "Malformed",
+			data:        `{"currency": "RUB"`,
+			expectError: true,
+		},
+		{
+			name:        "No currency",
+			data:        `{"platforms": {"standard-v3": {"perFraction": {"100": 1}, "ram": 1}}}`,
+			expectError: true,
+		},
+		{
+			name:        "No platforms",
+			data:        `{"currency": "RUB"}`,
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "prices.json")
+			if err := os.WriteFile(path, []byte(tc.data), 0o644); err != nil {
+				t.Fatalf("Failed to write price file: %v", err)
+			}
+			_, err := readPriceFile(path)
+			if (err != nil) != tc.expectError {
+				t.Errorf("Expected error=%v, got %v", tc.expectError, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pricing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/samber/lo"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// maxPriceTableSize bounds the size of a price table read from an endpoint
+const maxPriceTableSize = 8 << 20
+
+var _ Provider = (*HTTPProvider)(nil)
+
+// HTTPProvider prices from a price table an endpoint serves in the priceFile format, e.g. a service quoting
+// negotiated rates. The endpoint must be readable at startup, afterwards the last read prices are kept while the
+// endpoint is unavailable or serves prices in another currency than the built-in ones
+type HTTPProvider struct {
+	*DefaultProvider
+
+	endpoint string
+	client   *http.Client
+	// multiRegion allows the endpoint to quote prices in another currency than the built-in ones
+	multiRegion bool
+}
+
+func NewHTTPProvider(endpoint, region string, multiRegion bool) *HTTPProvider {
+	return &HTTPProvider{
+		DefaultProvider: NewDefaultProvider(region),
+		endpoint:        endpoint,
+		client:          &http.Client{Timeout: 30 * time.Second},
+		multiRegion:     multiRegion,
+	}
+}
+
+// Start reads the price table and refreshes it every interval until ctx is done. It fails when the price table cannot
+// be read the first time, so that a misconfigured endpoint is noticed at startup
+func (p *HTTPProvider) Start(ctx context.Context, interval time.Duration) error {
+	if err := p.refresh(ctx); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := p.refresh(ctx); err != nil {
+					log.FromContext(ctx).Error(err, "failed to refresh prices, keeping the last read prices", "endpoint", p.endpoint)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (p *HTTPProvider) refresh(ctx context.Context) error {
+	table, err := p.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	if !p.multiRegion {
+		// a region without built-in prices has a table without a currency, there is nothing to compare to
+		builtIn := lo.Filter(p.tables, func(t priceTable, _ int) bool { return t.currency != "" })
+		if err := validateCurrencies(append(builtIn, table)); err != nil {
+			return fmt.Errorf("validating prices from %s, %w", p.endpoint, err)
+		}
+	}
+	p.setTable(table)
+	return nil
+}
+
+func (p *HTTPProvider) fetch(ctx context.Context) (priceTable, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.endpoint, nil)
+	if err != nil {
+		return priceTable{}, fmt.Errorf("creating price request, %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return priceTable{}, fmt.Errorf("fetching prices from %s, %w", p.endpoint, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return priceTable{}, fmt.Errorf("fetching prices from %s, unexpected status %s", p.endpoint, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxPriceTableSize))
+	if err != nil {
+		return priceTable{}, fmt.Errorf("reading prices from %s, %w", p.endpoint, err)
+	}
+	return parsePriceTable(data, p.endpoint)
+}
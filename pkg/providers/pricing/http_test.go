@@ -0,0 +1,192 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pricing
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tufitko/karpenter-provider-yandex/pkg/yandex"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestHTTPProvider(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	iceLake := yandex.InstanceType{
+		Platform:     yandex.PlatformIntelIceLake,
+		CPU:          resource.MustParse("2"),
+		Memory:       resource.MustParse("4Gi"),
+		CoreFraction: yandex.CoreFraction100,
+	}
+
+	// the price of a GB of RAM the endpoint serves, zero makes it fail
+	var ram atomic.Int64
+	ram.Store(1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if ram.Load() == 0 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{
+  "currency": "RUB",
+  "platforms": {
+    "standard-v3": {"perFraction": {"100": 1}, "preemptiblePerFraction": {"100": 0.5}, "ram": %d, "preemptibleRam": 0.1}
+  },
+  "disks": {"network-ssd": 0.1}
+}`, ram.Load())
+	}))
+	defer server.Close()
+
+	provider := NewHTTPProvider(server.URL, yandex.RegionRU, false)
+	if err := provider.Start(ctx, 10*time.Millisecond); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expectPrice := func(expected float64) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			price, ok := provider.OnDemandPrice(iceLake)
+			if ok && math.Abs(price-expected) < 0.001 && provider.Currency() == "RUB" {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected price %v RUB, got %v %s", expected, price, provider.Currency())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// read on start
+	if price, _ := provider.OnDemandPrice(iceLake); math.Abs(price-(2*1+4*1)) > 0.001 {
+		t.Errorf("Expected price %v right after start, got %v", 2*1+4*1, price)
+	}
+
+	ram.Store(2)
+	expectPrice(2*1 + 4*2)
+
+	// the last read prices are kept while the endpoint fails
+	ram.Store(0)
+	time.Sleep(50 * time.Millisecond)
+	expectPrice(2*1 + 4*2)
+	if price, ok := provider.DiskPrice(yandex.Disk{Type: yandex.SSD, Size: 30}); !ok || math.Abs(price-3) > 0.001 {
+		t.Errorf("Expected disk price 3, got %v", price)
+	}
+}
+
+func TestHTTPProvider_StartFails(t *testing.T) {
+	testCases := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{
+			name: "Unavailable",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			},
+		},
+		{
+			name: "Malformed",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = fmt.Fprint(w, `{"currency": "RUB"`)
+			},
+		},
+		{
+			name: "Other currency than the built-in prices",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = fmt.Fprint(w, `{"currency": "KZT", "platforms": {"standard-v3": {"perFraction": {"100": 1}, "ram": 1}}}`)
+			},
+		},
+		{
+			name: "No platforms",
+			handler: func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = fmt.Fprint(w, `{"currency": "RUB"}`)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(tc.handler)
+			defer server.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if err := NewHTTPProvider(server.URL, yandex.RegionRU, false).Start(ctx, time.Hour); err == nil {
+				t.Errorf("Expected an error")
+			}
+		})
+	}
+}
+
+func TestHTTPProvider_Currency(t *testing.T) {
+	iceLake := yandex.InstanceType{
+		Platform:     yandex.PlatformIntelIceLake,
+		CPU:          resource.MustParse("2"),
+		Memory:       resource.MustParse("4Gi"),
+		CoreFraction: yandex.CoreFraction100,
+	}
+	// the endpoint switches to another currency than the built-in prices after the first read
+	var currency atomic.Value
+	currency.Store("RUB")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"currency": %q, "platforms": {"standard-v3": {"perFraction": {"100": 1}, "ram": 1}}}`, currency.Load())
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name             string
+		multiRegion      bool
+		expectedCurrency string
+	}{
+		{
+			name:             "Kept",
+			expectedCurrency: "RUB",
+		},
+		{
+			name:             "Multi-region",
+			multiRegion:      true,
+			expectedCurrency: "KZT",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			currency.Store("RUB")
+			provider := NewHTTPProvider(server.URL, yandex.RegionRU, tc.multiRegion)
+			if err := provider.refresh(context.Background()); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			currency.Store("KZT")
+			err := provider.refresh(context.Background())
+			if tc.multiRegion != (err == nil) {
+				t.Errorf("Expected an error only without multi-region mode, got %v", err)
+			}
+			if provider.Currency() != tc.expectedCurrency {
+				t.Errorf("Expected currency %s, got %s", tc.expectedCurrency, provider.Currency())
+			}
+			if price, ok := provider.OnDemandPrice(iceLake); !ok || math.Abs(price-(2*1+4*1)) > 0.001 {
+				t.Errorf("Expected the prices of the endpoint to be kept, got %v", price)
+			}
+		})
+	}
+}
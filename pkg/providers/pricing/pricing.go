@@ -17,30 +17,99 @@ limitations under the License.
 package pricing
 
 import (
+	"fmt"
+	"sync"
+
+	"github.com/samber/lo"
 	"github.com/tufitko/karpenter-provider-yandex/pkg/yandex"
 )
 
+// priceTables lists the generated price tables, one per region
+var priceTables = []priceTable{
+	{region: yandex.RegionRU, currency: ruCurrency, platforms: ruPricing, disks: ruDiskPricing},
+}
+
 type Provider interface {
 	OnDemandPrice(yandex.InstanceType) (float64, bool)
-	SpotPrice(yandex.InstanceType) (float64, bool)
+	SpotPrice(yandex.InstanceType, string) (float64, bool)
 	DiskPrice(yandex.Disk) (float64, bool)
+	// Currency returns the ISO 4217 code of the currency all prices are quoted in
+	Currency() string
 }
 
 type DefaultProvider struct {
-	mapping map[yandex.PlatformId]pricingPlatform
+	tables []priceTable
+
+	// mu guards the prices in use, which are swapped when a price file is reloaded
+	mu       sync.RWMutex
+	currency string
+	mapping  map[yandex.PlatformId]pricingPlatform
+	disks    map[yandex.DiskType]float64
+	// zonalSpotMapping holds per-zone spot pricing, zones or platforms missing here fall back to mapping
+	zonalSpotMapping map[string]map[yandex.PlatformId]pricingPlatform
+}
+
+// NewDefaultProvider prices from the generated price table of region. Without one no instance type has a price until
+// a price table is loaded from a file or an endpoint
+func NewDefaultProvider(region string) *DefaultProvider {
+	return newDefaultProvider(lo.Filter(priceTables, func(t priceTable, _ int) bool { return t.region == region }))
+}
+
+// HasRegion returns whether there is a generated price table of region
+func HasRegion(region string) bool {
+	return lo.ContainsBy(priceTables, func(t priceTable) bool { return t.region == region })
+}
+
+func newDefaultProvider(tables []priceTable) *DefaultProvider {
+	if len(tables) == 0 {
+		tables = []priceTable{{}}
+	}
+	return &DefaultProvider{
+		tables:   tables,
+		currency: tables[0].currency,
+		mapping:  tables[0].platforms,
+		disks:    tables[0].disks,
+	}
 }
 
-func NewDefaultProvider() *DefaultProvider {
-	p := &DefaultProvider{
-		mapping: ruPricing,
+// setTable prices from table, keeping the zonal spot pricing
+func (p *DefaultProvider) setTable(table priceTable) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.currency = table.currency
+	p.mapping = table.platforms
+	p.disks = table.disks
+}
+
+func (p *DefaultProvider) Currency() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.currency
+}
+
+// ValidateCurrencies ensures all loaded price tables are quoted in the same currency, as comparing prices across
+// currencies is meaningless. Mixed currencies are only allowed in multi-region mode, where every region is priced
+// with its own table
+func (p *DefaultProvider) ValidateCurrencies(multiRegion bool) error {
+	if multiRegion {
+		return nil
 	}
+	return validateCurrencies(p.tables)
+}
 
-	return p
+func validateCurrencies(tables []priceTable) error {
+	currencies := lo.Uniq(lo.Map(tables, func(t priceTable, _ int) string { return t.currency }))
+	if len(currencies) > 1 {
+		return fmt.Errorf("price tables use different currencies %v, enable multi-region mode to price every region separately", currencies)
+	}
+	return nil
 }
 
 // OnDemandPrice returns the last known on-demand price for a given instance type, returning an error if there is no
 // known on-demand pricing for the instance type.
 func (p *DefaultProvider) OnDemandPrice(instanceType yandex.InstanceType) (float64, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
 	platform, ok := p.mapping[instanceType.Platform]
 	if !ok {
 		return 0, false
@@ -55,10 +124,16 @@ func (p *DefaultProvider) OnDemandPrice(instanceType yandex.InstanceType) (float
 	return cpuPrice*instanceType.CPU.AsApproximateFloat64() + memPrice*(float64(instanceType.Memory.Value())/1024/1024/1024), true
 }
 
-// SpotPrice returns the last known spot price for a given instance type, returning an error
-// if there is no known spot pricing for that instance type or zone
-func (p *DefaultProvider) SpotPrice(instanceType yandex.InstanceType) (float64, bool) {
-	platform, ok := p.mapping[instanceType.Platform]
+// SpotPrice returns the last known spot price for a given instance type in a zone, returning an error
+// if there is no known spot pricing for that instance type. When there is no zonal pricing for the zone,
+// the zone-agnostic price is used
+func (p *DefaultProvider) SpotPrice(instanceType yandex.InstanceType, zone string) (float64, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	platform, ok := p.zonalSpotMapping[zone][instanceType.Platform]
+	if !ok {
+		platform, ok = p.mapping[instanceType.Platform]
+	}
 	if !ok {
 		return 0, false
 	}
@@ -72,10 +147,19 @@ func (p *DefaultProvider) SpotPrice(instanceType yandex.InstanceType) (float64,
 	return cpuPrice*instanceType.CPU.AsApproximateFloat64() + memPrice*(float64(instanceType.Memory.Value())/1024/1024/1024), true
 }
 
+// minBillableDiskSize is the size in GB below which disks of a type are still billed as if they had that size,
+// non-replicated and io-m3 disks are allocated in 93GB chunks
+var minBillableDiskSize = map[yandex.DiskType]int64{
+	yandex.SSDNonreplicated: 93,
+	yandex.SSDIo:            93,
+}
+
 func (p *DefaultProvider) DiskPrice(disk yandex.Disk) (float64, bool) {
-	price, ok := ruDiskPricing[disk.Type]
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	price, ok := p.disks[disk.Type]
 	if !ok {
 		return 0, false
 	}
-	return price * float64(disk.Size), true
+	return price * float64(max(disk.Size, minBillableDiskSize[disk.Type])), true
 }
@@ -22,10 +22,10 @@ import (
 )
 
 func TestNewDefaultProvider(t *testing.T) {
-	provider := NewDefaultProvider()
+	provider := NewDefaultProvider(yandex.RegionRU)
 
 	if provider == nil {
-		t.Fatal("NewDefaultProvider() returned nil")
+		t.Fatal("NewDefaultProvider(yandex.RegionRU) returned nil")
 	}
 
 	if provider.mapping == nil {
@@ -33,8 +33,31 @@ func TestNewDefaultProvider(t *testing.T) {
 	}
 }
 
+func TestNewDefaultProvider_RegionWithoutPrices(t *testing.T) {
+	provider := NewDefaultProvider("kz")
+	instanceType := yandex.InstanceType{
+		Platform:     yandex.PlatformIntelIceLake,
+		CPU:          resource.MustParse("2"),
+		Memory:       resource.MustParse("4Gi"),
+		CoreFraction: yandex.CoreFraction100,
+	}
+
+	if HasRegion("kz") {
+		t.Fatal(`HasRegion("kz") = true, want false`)
+	}
+	if !HasRegion(yandex.RegionRU) {
+		t.Fatal("HasRegion(yandex.RegionRU) = false, want true")
+	}
+	if price, ok := provider.OnDemandPrice(instanceType); ok {
+		t.Errorf("OnDemandPrice() = %v, want no price", price)
+	}
+	if price, ok := provider.SpotPrice(instanceType, "ru-central1-a"); ok {
+		t.Errorf("SpotPrice() = %v, want no price", price)
+	}
+}
+
 func TestOnDemandPrice(t *testing.T) {
-	provider := NewDefaultProvider()
+	provider := NewDefaultProvider(yandex.RegionRU)
 
 	testCases := []struct {
 		name          string
@@ -79,6 +102,18 @@ func TestOnDemandPrice(t *testing.T) {
 			expectedPrice: 0.3193*4 + 0.4017*8, // cpuPrice * cores + ramPrice * GB
 			tolerance:     0.001,
 		},
+		{
+			name: "Intel Ice Lake 1500m CPU 100% 3Gi RAM priced by fractional cores",
+			instanceType: yandex.InstanceType{
+				Platform:     yandex.PlatformIntelIceLake,
+				CPU:          resource.MustParse("1500m"),
+				Memory:       resource.MustParse("3Gi"),
+				CoreFraction: yandex.CoreFraction100,
+			},
+			expectPrice:   true,
+			expectedPrice: 1.134*1.5 + 0.3024*3, // cpuPrice * cores + ramPrice * GB
+			tolerance:     0.001,
+		},
 		{
 			name: "Unknown platform",
 			instanceType: yandex.InstanceType{
@@ -135,7 +170,7 @@ func TestOnDemandPrice(t *testing.T) {
 }
 
 func TestSpotPrice(t *testing.T) {
-	provider := NewDefaultProvider()
+	provider := NewDefaultProvider(yandex.RegionRU)
 
 	testCases := []struct {
 		name          string
@@ -184,7 +219,7 @@ func TestSpotPrice(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			price, ok := provider.SpotPrice(tc.instanceType)
+			price, ok := provider.SpotPrice(tc.instanceType, "ru-central1-a")
 
 			if ok != tc.expectPrice {
 				t.Fatalf("Expected spot price availability: %v, got: %v", tc.expectPrice, ok)
@@ -211,8 +246,78 @@ func TestSpotPrice(t *testing.T) {
 	}
 }
 
+func TestSpotPriceByZone(t *testing.T) {
+	provider := NewDefaultProvider(yandex.RegionRU)
+	provider.zonalSpotMapping = map[string]map[yandex.PlatformId]pricingPlatform{
+		"ru-central1-b": {
+			yandex.PlatformIntelIceLake: {
+				preemptiblePerFraction: map[yandex.CoreFraction]float64{
+					yandex.CoreFraction100: 0.5,
+				},
+				preemptibleRAM: 0.1,
+			},
+		},
+	}
+
+	iceLake := yandex.InstanceType{
+		Platform:     yandex.PlatformIntelIceLake,
+		CPU:          resource.MustParse("2"),
+		Memory:       resource.MustParse("4Gi"),
+		CoreFraction: yandex.CoreFraction100,
+	}
+	amd := yandex.InstanceType{
+		Platform:     yandex.PlatformAMDZen3,
+		CPU:          resource.MustParse("2"),
+		Memory:       resource.MustParse("4Gi"),
+		CoreFraction: yandex.CoreFraction100,
+	}
+
+	testCases := []struct {
+		name          string
+		instanceType  yandex.InstanceType
+		zone          string
+		expectedPrice float64
+	}{
+		{
+			name:          "Zone without zonal pricing falls back to zone-agnostic price",
+			instanceType:  iceLake,
+			zone:          "ru-central1-a",
+			expectedPrice: 0.3132*2 + 0.0756*4,
+		},
+		{
+			name:          "Zone with zonal pricing uses zonal price",
+			instanceType:  iceLake,
+			zone:          "ru-central1-b",
+			expectedPrice: 0.5*2 + 0.1*4,
+		},
+		{
+			name:          "Platform without zonal pricing falls back to zone-agnostic price",
+			instanceType:  amd,
+			zone:          "ru-central1-b",
+			expectedPrice: 0.3132*2 + 0.0756*4,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			price, ok := provider.SpotPrice(tc.instanceType, tc.zone)
+			if !ok {
+				t.Fatalf("Expected spot price to be available")
+			}
+
+			diff := price - tc.expectedPrice
+			if diff < 0 {
+				diff = -diff
+			}
+			if diff > 0.001 {
+				t.Errorf("Spot price %.6f differs from expected %.6f", price, tc.expectedPrice)
+			}
+		})
+	}
+}
+
 func TestPriceComparison(t *testing.T) {
-	provider := NewDefaultProvider()
+	provider := NewDefaultProvider(yandex.RegionRU)
 
 	instanceType := yandex.InstanceType{
 		Platform:     yandex.PlatformIntelIceLake,
@@ -222,7 +327,7 @@ func TestPriceComparison(t *testing.T) {
 	}
 
 	onDemandPrice, onDemandOk := provider.OnDemandPrice(instanceType)
-	spotPrice, spotOk := provider.SpotPrice(instanceType)
+	spotPrice, spotOk := provider.SpotPrice(instanceType, "ru-central1-a")
 
 	if !onDemandOk {
 		t.Fatal("Expected on-demand price to be available")
@@ -241,7 +346,7 @@ func TestPriceComparison(t *testing.T) {
 }
 
 func TestResourceQuantityParsing(t *testing.T) {
-	provider := NewDefaultProvider()
+	provider := NewDefaultProvider(yandex.RegionRU)
 
 	testCases := []struct {
 		name     string
@@ -282,7 +387,7 @@ func TestResourceQuantityParsing(t *testing.T) {
 }
 
 func TestPricingConsistency(t *testing.T) {
-	provider := NewDefaultProvider()
+	provider := NewDefaultProvider(yandex.RegionRU)
 
 	// Test that doubling resources approximately doubles the price
 	instanceType1 := yandex.InstanceType{
@@ -319,7 +424,7 @@ func TestPricingConsistency(t *testing.T) {
 }
 
 func BenchmarkOnDemandPrice(b *testing.B) {
-	provider := NewDefaultProvider()
+	provider := NewDefaultProvider(yandex.RegionRU)
 
 	instanceType := yandex.InstanceType{
 		Platform:     yandex.PlatformIntelIceLake,
@@ -335,7 +440,7 @@ func BenchmarkOnDemandPrice(b *testing.B) {
 }
 
 func BenchmarkSpotPrice(b *testing.B) {
-	provider := NewDefaultProvider()
+	provider := NewDefaultProvider(yandex.RegionRU)
 
 	instanceType := yandex.InstanceType{
 		Platform:     yandex.PlatformIntelIceLake,
@@ -346,12 +451,12 @@ func BenchmarkSpotPrice(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, _ = provider.SpotPrice(instanceType)
+		_, _ = provider.SpotPrice(instanceType, "ru-central1-a")
 	}
 }
 
 func TestDiskPrice(t *testing.T) {
-	provider := NewDefaultProvider()
+	provider := NewDefaultProvider(yandex.RegionRU)
 
 	testCases := []struct {
 		name          string
@@ -400,6 +505,26 @@ func TestDiskPrice(t *testing.T) {
 			expectedPrice: 0.0044 * 200,
 			tolerance:     0.001,
 		},
+		{
+			name: "SSDIO below the minimum billable size",
+			disk: yandex.Disk{
+				Type: yandex.SSDIo,
+				Size: 30,
+			},
+			expectPrice:   true,
+			expectedPrice: 0.0297 * 93,
+			tolerance:     0.001,
+		},
+		{
+			name: "SSD non-replicated below the minimum billable size",
+			disk: yandex.Disk{
+				Type: yandex.SSDNonreplicated,
+				Size: 50,
+			},
+			expectPrice:   true,
+			expectedPrice: 0.0132 * 93,
+			tolerance:     0.001,
+		},
 		{
 			name: "Unknown disk type",
 			disk: yandex.Disk{
@@ -442,7 +567,7 @@ func TestDiskPrice(t *testing.T) {
 }
 
 func TestDiskPriceWithInstanceType(t *testing.T) {
-	provider := NewDefaultProvider()
+	provider := NewDefaultProvider(yandex.RegionRU)
 
 	testCases := []struct {
 		name          string
@@ -556,7 +681,7 @@ func TestDiskPriceWithInstanceType(t *testing.T) {
 }
 
 func TestDiskPriceComparison(t *testing.T) {
-	provider := NewDefaultProvider()
+	provider := NewDefaultProvider(yandex.RegionRU)
 
 	// Test that larger disks cost more
 	smallDisk := yandex.Disk{Type: yandex.SSD, Size: 30}
@@ -578,7 +703,7 @@ func TestDiskPriceComparison(t *testing.T) {
 }
 
 func TestDiskPriceByType(t *testing.T) {
-	provider := NewDefaultProvider()
+	provider := NewDefaultProvider(yandex.RegionRU)
 
 	// Test that different disk types have different prices for the same size
 	size := int64(100)
@@ -605,3 +730,50 @@ func TestDiskPriceByType(t *testing.T) {
 	t.Logf("100GB disk prices - HDD: %.4f, SSD: %.4f, SSD-non-replicated: %.4f, SSDIO: %.4f RUB/hour",
 		hddPrice, ssdPrice, ssdNonrepPrice, ssdIoPrice)
 }
+
+func TestCurrency(t *testing.T) {
+	provider := NewDefaultProvider(yandex.RegionRU)
+
+	if provider.Currency() != "RUB" {
+		t.Errorf("Expected prices in RUB, got %s", provider.Currency())
+	}
+	if err := provider.ValidateCurrencies(false); err != nil {
+		t.Errorf("Expected generated price tables to share a currency, got %v", err)
+	}
+}
+
+func TestValidateCurrencies(t *testing.T) {
+	ru := priceTable{region: "ru", currency: "RUB", platforms: ruPricing, disks: ruDiskPricing}
+	kz := priceTable{region: "kz", currency: "KZT", platforms: ruPricing, disks: ruDiskPricing}
+
+	testCases := []struct {
+		name        string
+		tables      []priceTable
+		multiRegion bool
+		expectError bool
+	}{
+		{
+			name:   "Single currency",
+			tables: []priceTable{ru, ru},
+		},
+		{
+			name:        "Mixed currencies without multi-region mode",
+			tables:      []priceTable{ru, kz},
+			expectError: true,
+		},
+		{
+			name:        "Mixed currencies in multi-region mode",
+			tables:      []priceTable{ru, kz},
+			multiRegion: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := newDefaultProvider(tc.tables).ValidateCurrencies(tc.multiRegion)
+			if (err != nil) != tc.expectError {
+				t.Errorf("Expected error=%v, got %v", tc.expectError, err)
+			}
+		})
+	}
+}
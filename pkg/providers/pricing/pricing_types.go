@@ -9,5 +9,12 @@ type pricingPlatform struct {
 	preemptibleRAM         float64
 
 	// todo: add pricing per gpu
-	// todo: add CVoS support
+}
+
+// priceTable is a generated set of prices for a single region, all quoted in currency
+type priceTable struct {
+	region    string
+	currency  string
+	platforms map[yandex.PlatformId]pricingPlatform
+	disks     map[yandex.DiskType]float64
 }
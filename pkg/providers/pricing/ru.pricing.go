@@ -17,6 +17,8 @@ package pricing
 
 import "github.com/tufitko/karpenter-provider-yandex/pkg/yandex"
 
+const ruCurrency = "RUB"
+
 var ruPricing = map[yandex.PlatformId]pricingPlatform{
 	yandex.PlatformAMDZen3: {
 		perFraction: map[yandex.CoreFraction]float64{
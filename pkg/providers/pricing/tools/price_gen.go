@@ -122,6 +122,8 @@ package pricing
 
 import "github.com/tufitko/karpenter-provider-yandex/pkg/yandex"
 
+const {{.Region}}Currency = "{{.Currency}}"
+
 var {{.Region}}Pricing = map[yandex.PlatformId]pricingPlatform{
 {{range $platformId, $platform := .Platforms}}	yandex.{{$platformId}}: {
 		perFraction: map[yandex.CoreFraction]float64{
@@ -24,9 +24,11 @@ import (
 
 	"github.com/tufitko/karpenter-provider-yandex/pkg/apis/v1alpha1"
 	"github.com/tufitko/karpenter-provider-yandex/pkg/yandex"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
 
 	"github.com/mitchellh/hashstructure/v2"
 	"github.com/patrickmn/go-cache"
+	"github.com/samber/lo"
 )
 
 type Provider interface {
@@ -35,20 +37,24 @@ type Provider interface {
 
 type DefaultProvider struct {
 	sync.Mutex
-	api   yandex.SDK
-	cache *cache.Cache
+	api        yandex.SDK
+	cache      *cache.Cache
+	ipsPerNode int
 }
 
 type Subnet struct {
 	ID                      string
 	ZoneID                  string
 	AvailableIPAddressCount int
+	// AvailableNodeSlots is the estimated number of nodes that still fit into the subnet
+	AvailableNodeSlots int
 }
 
-func NewDefaultProvider(api yandex.SDK, cache *cache.Cache) *DefaultProvider {
+func NewDefaultProvider(api yandex.SDK, cache *cache.Cache, ipsPerNode int) *DefaultProvider {
 	return &DefaultProvider{
-		api:   api,
-		cache: cache,
+		api:        api,
+		cache:      cache,
+		ipsPerNode: ipsPerNode,
 	}
 }
 
@@ -56,7 +62,10 @@ func (p *DefaultProvider) List(ctx context.Context, nodeClass *v1alpha1.YandexNo
 	p.Lock()
 	defer p.Unlock()
 
-	hash, err := hashstructure.Hash(nodeClass.Spec.SubnetSelectorTerms, hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true})
+	hash, err := hashstructure.Hash(struct {
+		Terms        []v1alpha1.SubnetSelectorTerm
+		AutoDiscover bool
+	}{nodeClass.Spec.SubnetSelectorTerms, nodeClass.Spec.AutoDiscoverSubnets}, hashstructure.FormatV2, &hashstructure.HashOptions{SlicesAsSets: true})
 	if err != nil {
 		return nil, err
 	}
@@ -70,27 +79,17 @@ func (p *DefaultProvider) List(ctx context.Context, nodeClass *v1alpha1.YandexNo
 		return nil, fmt.Errorf("failed to list subnets: %w", err)
 	}
 
-	subs := make([]Subnet, 0)
+	selected := lo.Filter(subnets, func(subnet *vpc.Subnet, _ int) bool {
+		return MatchesSelectorTerms(subnet, nodeClass.Spec.SubnetSelectorTerms)
+	})
+	autoDiscovered := len(selected) == 0 && nodeClass.Spec.AutoDiscoverSubnets
+	if autoDiscovered {
+		selected = subnets
+	}
 
-	for _, subnet := range subnets {
-		keep := false
-		for _, term := range nodeClass.Spec.SubnetSelectorTerms {
-			if term.ID != "" && subnet.Id == term.ID {
-				keep = true
-				break
-			}
-			if len(term.Labels) == 0 {
-				continue
-			}
-			if yandex.MatchLabels(subnet.Labels, term.Labels) {
-				keep = true
-				break
-			}
-		}
-		if !keep {
-			continue
-		}
+	subs := make([]Subnet, 0)
 
+	for _, subnet := range selected {
 		var inUseIPs int
 		inUseIPs, err = p.api.UsedIPsInSubnet(ctx, subnet.Id)
 		if err != nil {
@@ -111,20 +110,52 @@ func (p *DefaultProvider) List(ctx context.Context, nodeClass *v1alpha1.YandexNo
 			ID:                      subnet.Id,
 			ZoneID:                  subnet.ZoneId,
 			AvailableIPAddressCount: totalIPs - inUseIPs,
+			AvailableNodeSlots:      nodeSlots(totalIPs-inUseIPs, p.ipsPerNode),
 		})
 	}
 
 	sort.Slice(subs, func(i, j int) bool {
-		if subs[i].AvailableIPAddressCount == subs[j].AvailableIPAddressCount {
+		if subs[i].AvailableNodeSlots == subs[j].AvailableNodeSlots {
 			return subs[i].ZoneID < subs[j].ZoneID
 		}
-		return subs[i].AvailableIPAddressCount > subs[j].AvailableIPAddressCount
+		return subs[i].AvailableNodeSlots > subs[j].AvailableNodeSlots
 	})
+	if autoDiscovered {
+		// subnets are sorted by node slots, so the first subnet of every zone has room for the most nodes
+		subs = lo.UniqBy(subs, func(s Subnet) string { return s.ZoneID })
+	}
 
 	p.cache.SetDefault(fmt.Sprint(hash), subs)
 	return subs, nil
 }
 
+// MatchesSelectorTerms returns whether the subnet is selected by any of the terms
+func MatchesSelectorTerms(subnet *vpc.Subnet, terms []v1alpha1.SubnetSelectorTerm) bool {
+	for _, term := range terms {
+		if term.ID != "" && subnet.Id == term.ID {
+			return true
+		}
+		if len(term.Labels) == 0 {
+			continue
+		}
+		if yandex.MatchLabels(subnet.Labels, term.Labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeSlots estimates how many nodes fit into the available IPs when every node reserves ipsPerNode addresses
+func nodeSlots(availableIPs, ipsPerNode int) int {
+	if availableIPs <= 0 {
+		return 0
+	}
+	if ipsPerNode < 1 {
+		ipsPerNode = 1
+	}
+	return availableIPs / ipsPerNode
+}
+
 // calculateIPs calculates the number of IP addresses that can be used in a CIDR subnet.
 func calculateIPs(cidr string) (int, error) {
 	_, ipv4Net, err := net.ParseCIDR(cidr)
@@ -0,0 +1,145 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package subnet
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/samber/lo"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/apis/v1alpha1"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/fake"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
+)
+
+func TestNodeSlots(t *testing.T) {
+	testCases := []struct {
+		name         string
+		availableIPs int
+		ipsPerNode   int
+		expected     int
+	}{
+		{name: "One IP per node", availableIPs: 251, ipsPerNode: 1, expected: 251},
+		{name: "Rounds down partial nodes", availableIPs: 251, ipsPerNode: 4, expected: 62},
+		{name: "Fewer IPs than a single node needs", availableIPs: 3, ipsPerNode: 4, expected: 0},
+		{name: "No available IPs", availableIPs: 0, ipsPerNode: 1, expected: 0},
+		{name: "Overcommitted subnet", availableIPs: -5, ipsPerNode: 1, expected: 0},
+		{name: "Invalid reservation treated as one IP", availableIPs: 10, ipsPerNode: 0, expected: 10},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := nodeSlots(tc.availableIPs, tc.ipsPerNode); got != tc.expected {
+				t.Errorf("Expected %d node slots, got %d", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestList_AvailableNodeSlots(t *testing.T) {
+	sdk := fake.NewSDK()
+	sdk.Subnets = []*vpc.Subnet{
+		{Id: "subnet-a", ZoneId: "ru-central1-a", V4CidrBlocks: []string{"10.0.0.0/24"}},
+		{Id: "subnet-b", ZoneId: "ru-central1-b", V4CidrBlocks: []string{"10.0.1.0/28"}},
+	}
+	sdk.UsedIPs["subnet-a"] = 14
+	sdk.UsedIPs["subnet-b"] = 2
+
+	provider := NewDefaultProvider(sdk, cache.New(time.Minute, time.Minute), 4)
+	nodeClass := &v1alpha1.YandexNodeClass{
+		Spec: v1alpha1.YandexNodeClassSpec{
+			SubnetSelectorTerms: []v1alpha1.SubnetSelectorTerm{{ID: "subnet-a"}, {ID: "subnet-b"}},
+		},
+	}
+
+	subnets, err := provider.List(context.Background(), nodeClass)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(subnets) != 2 {
+		t.Fatalf("Expected 2 subnets, got %d", len(subnets))
+	}
+
+	expected := map[string]struct{ ips, slots int }{
+		"subnet-a": {ips: 254 - 14, slots: 60},
+		"subnet-b": {ips: 14 - 2, slots: 3},
+	}
+	for _, s := range subnets {
+		if s.AvailableIPAddressCount != expected[s.ID].ips {
+			t.Errorf("Subnet %s: expected %d available IPs, got %d", s.ID, expected[s.ID].ips, s.AvailableIPAddressCount)
+		}
+		if s.AvailableNodeSlots != expected[s.ID].slots {
+			t.Errorf("Subnet %s: expected %d node slots, got %d", s.ID, expected[s.ID].slots, s.AvailableNodeSlots)
+		}
+	}
+}
+
+func TestList_AutoDiscoverSubnets(t *testing.T) {
+	testCases := []struct {
+		name         string
+		terms        []v1alpha1.SubnetSelectorTerm
+		autoDiscover bool
+		expected     []string
+	}{
+		{
+			name:         "Falls back to the subnet with the most free IPs in every zone",
+			terms:        []v1alpha1.SubnetSelectorTerm{{Labels: map[string]string{"karpenter": "true"}}},
+			autoDiscover: true,
+			expected:     []string{"subnet-a-large", "subnet-b"},
+		},
+		{
+			name:         "Explicit selectors take precedence",
+			terms:        []v1alpha1.SubnetSelectorTerm{{ID: "subnet-a-small"}},
+			autoDiscover: true,
+			expected:     []string{"subnet-a-small"},
+		},
+		{
+			name:     "No fallback unless enabled",
+			terms:    []v1alpha1.SubnetSelectorTerm{{Labels: map[string]string{"karpenter": "true"}}},
+			expected: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sdk := fake.NewSDK()
+			sdk.Subnets = []*vpc.Subnet{
+				{Id: "subnet-a-small", ZoneId: "ru-central1-a", V4CidrBlocks: []string{"10.0.0.0/28"}},
+				{Id: "subnet-a-large", ZoneId: "ru-central1-a", V4CidrBlocks: []string{"10.0.1.0/24"}},
+				{Id: "subnet-b", ZoneId: "ru-central1-b", V4CidrBlocks: []string{"10.0.2.0/28"}},
+			}
+
+			provider := NewDefaultProvider(sdk, cache.New(time.Minute, time.Minute), 1)
+			nodeClass := &v1alpha1.YandexNodeClass{
+				Spec: v1alpha1.YandexNodeClassSpec{
+					SubnetSelectorTerms: tc.terms,
+					AutoDiscoverSubnets: tc.autoDiscover,
+				},
+			}
+
+			subnets, err := provider.List(context.Background(), nodeClass)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			got := lo.Map(subnets, func(s Subnet, _ int) string { return s.ID })
+			if !slices.Equal(got, tc.expected) {
+				t.Errorf("Expected subnets %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodeclaim
+
+import (
+	"context"
+
+	"github.com/tufitko/karpenter-provider-yandex/pkg/apis/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+// NodeGroupIDIndex is the field index of NodeClaims by the id of the node group backing them
+const NodeGroupIDIndex = "metadata.labels." + v1alpha1.LabelYandexNodeGroupID
+
+// NodeGroupIDIndexFunc indexes a NodeClaim by its node group id label, NodeClaims not launched yet are not indexed
+func NodeGroupIDIndexFunc(o client.Object) []string {
+	nodeGroupID := o.GetLabels()[v1alpha1.LabelYandexNodeGroupID]
+	if nodeGroupID == "" {
+		return nil
+	}
+	return []string{nodeGroupID}
+}
+
+// ListByNodeGroupID returns the NodeClaims backed by the node group, the client must have NodeGroupIDIndex registered
+func ListByNodeGroupID(ctx context.Context, kubeClient client.Reader, nodeGroupID string) ([]karpv1.NodeClaim, error) {
+	nodeClaims := &karpv1.NodeClaimList{}
+	if err := kubeClient.List(ctx, nodeClaims, client.MatchingFields{NodeGroupIDIndex: nodeGroupID}); err != nil {
+		return nil, err
+	}
+	return nodeClaims.Items, nil
+}
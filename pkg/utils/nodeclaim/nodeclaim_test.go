@@ -0,0 +1,66 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodeclaim
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/apis/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+func TestListByNodeGroupID(t *testing.T) {
+	newNodeClaim := func(name, nodeGroupID string) client.Object {
+		nodeClaim := &karpv1.NodeClaim{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{}}}
+		if nodeGroupID != "" {
+			nodeClaim.Labels[v1alpha1.LabelYandexNodeGroupID] = nodeGroupID
+		}
+		return nodeClaim
+	}
+	// karpenter registers its types into the client-go scheme on init
+	kubeClient := fakeclient.NewClientBuilder().WithScheme(scheme.Scheme).
+		WithObjects(newNodeClaim("default-a", "ng-1"), newNodeClaim("default-b", "ng-2"), newNodeClaim("default-c", "")).
+		WithIndex(&karpv1.NodeClaim{}, NodeGroupIDIndex, NodeGroupIDIndexFunc).
+		Build()
+
+	testCases := []struct {
+		nodeGroupID string
+		expected    []string
+	}{
+		{nodeGroupID: "ng-1", expected: []string{"default-a"}},
+		{nodeGroupID: "ng-2", expected: []string{"default-b"}},
+		{nodeGroupID: "ng-3", expected: []string{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.nodeGroupID, func(t *testing.T) {
+			nodeClaims, err := ListByNodeGroupID(context.Background(), kubeClient, tc.nodeGroupID)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			got := lo.Map(nodeClaims, func(nc karpv1.NodeClaim, _ int) string { return nc.Name })
+			if !slices.Equal(got, tc.expected) {
+				t.Errorf("Expected nodeclaims %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
@@ -9,12 +9,18 @@ import (
 	"github.com/patrickmn/go-cache"
 	"github.com/samber/lo"
 	"github.com/tufitko/karpenter-provider-yandex/pkg/apis/v1alpha1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/k8s/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 const (
 	CacheTTL        = 10 * time.Minute
 	CacheCleanupTTL = time.Minute
+	// QuotaCacheTTL is short since quota usage changes with every node created or deleted
+	QuotaCacheTTL = time.Minute
+	// ProviderIDCacheTTL is short since the node of a node group is replaced when Yandex Cloud repairs it
+	ProviderIDCacheTTL = 30 * time.Second
 )
 
 type CachedSDK struct {
@@ -32,8 +38,10 @@ func NewCachedSDK(sdk SDK) CachedSDK {
 func (c CachedSDK) CreateFixedNodeGroup(
 	ctx context.Context,
 	name string,
+	idempotencyKey string,
 	labels map[string]string,
 	nodeLabels map[string]string,
+	taints []corev1.Taint,
 	platformId PlatformId,
 	coreFraction CoreFraction,
 	cpu resource.Quantity,
@@ -44,20 +52,21 @@ func (c CachedSDK) CreateFixedNodeGroup(
 	nodeclass *v1alpha1.YandexNodeClass,
 	diskType string,
 	diskSize int64,
-) (string, error) {
+	userData string,
+) (string, string, error) {
 	var methodName = "CreateFixedNodeGroup"
 	var key = c.generateMD5CacheKey(methodName, name)
 
 	value, exist := c.cache.Get(key)
 	if exist {
-		return value.(lo.Tuple2[string, error]).Unpack()
+		return value.(lo.Tuple3[string, string, error]).Unpack()
 	}
 
-	resp, err := c.SDK.CreateFixedNodeGroup(ctx, name, labels, nodeLabels, platformId, coreFraction, cpu, mem, preemptible, zoneId, subnetId, nodeclass, diskType, diskSize)
+	resp, operationId, err := c.SDK.CreateFixedNodeGroup(ctx, name, idempotencyKey, labels, nodeLabels, taints, platformId, coreFraction, cpu, mem, preemptible, zoneId, subnetId, nodeclass, diskType, diskSize, userData)
 
-	c.cache.Set(key, lo.Tuple2[string, error]{A: resp, B: err}, CacheTTL)
+	c.cache.Set(key, lo.Tuple3[string, string, error]{A: resp, B: operationId, C: err}, CacheTTL)
 
-	return resp, err
+	return resp, operationId, err
 }
 
 func (c CachedSDK) DeleteNodeGroup(ctx context.Context, nodeGroupId string) error {
@@ -75,11 +84,94 @@ func (c CachedSDK) DeleteNodeGroup(ctx context.Context, nodeGroupId string) erro
 	err := c.SDK.DeleteNodeGroup(ctx, nodeGroupId)
 
 	c.cache.Set(key, err, CacheTTL)
+	if err == nil {
+		c.cache.Delete(c.providerIDCacheKey(nodeGroupId))
+	}
 
 	return err
 
 }
 
+func (c CachedSDK) Quotas(ctx context.Context) (map[string]Quota, error) {
+	var methodName = "Quotas"
+	var key = c.generateMD5CacheKey(methodName)
+
+	value, exist := c.cache.Get(key)
+	if exist {
+		return value.(map[string]Quota), nil
+	}
+
+	quotas, err := c.SDK.Quotas(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.cache.Set(key, quotas, QuotaCacheTTL)
+
+	return quotas, nil
+}
+
+func (c CachedSDK) ProviderIdFor(ctx context.Context, nodeGroupId string) (string, error) {
+	var key = c.providerIDCacheKey(nodeGroupId)
+
+	value, exist := c.cache.Get(key)
+	if exist {
+		return value.(string), nil
+	}
+
+	// failures are not cached, a node group waited on for the provider id of its node is read again on every attempt
+	providerId, err := c.SDK.ProviderIdFor(ctx, nodeGroupId)
+	if err != nil {
+		return "", err
+	}
+
+	c.cache.Set(key, providerId, ProviderIDCacheTTL)
+
+	return providerId, nil
+}
+
+func (c CachedSDK) GetNodeGroup(ctx context.Context, nodeGroupId string) (*k8s.NodeGroup, error) {
+	ng, err := c.SDK.GetNodeGroup(ctx, nodeGroupId)
+	if err != nil {
+		return nil, err
+	}
+	c.forgetTerminatingProviderIds(ng)
+	return ng, nil
+}
+
+func (c CachedSDK) GetNodeGroupByProviderId(ctx context.Context, providerId string) (*k8s.NodeGroup, error) {
+	ng, err := c.SDK.GetNodeGroupByProviderId(ctx, providerId)
+	if err != nil {
+		return nil, err
+	}
+	c.forgetTerminatingProviderIds(ng)
+	return ng, nil
+}
+
+func (c CachedSDK) ListNodeGroups(ctx context.Context) ([]*k8s.NodeGroup, error) {
+	ngs, err := c.SDK.ListNodeGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.forgetTerminatingProviderIds(ngs...)
+	return ngs, nil
+}
+
+// forgetTerminatingProviderIds drops the cached provider ids of the node groups that are stopping or being deleted,
+// their nodes are going away
+func (c CachedSDK) forgetTerminatingProviderIds(ngs ...*k8s.NodeGroup) {
+	for _, ng := range ngs {
+		switch ng.GetStatus() {
+		case k8s.NodeGroup_STOPPING, k8s.NodeGroup_STOPPED, k8s.NodeGroup_DELETING:
+			c.cache.Delete(c.providerIDCacheKey(ng.GetId()))
+		}
+	}
+}
+
+func (c CachedSDK) providerIDCacheKey(nodeGroupId string) string {
+	return c.generateMD5CacheKey("ProviderIdFor", nodeGroupId)
+}
+
 func (c CachedSDK) generateMD5CacheKey(method string, args ...string) string {
 	key := method
 	for _, arg := range args {
@@ -0,0 +1,114 @@
+package yandex
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/k8s/v1"
+)
+
+// nodesSDK serves the node of a single node group and counts how often the nodes of node groups are listed
+type nodesSDK struct {
+	SDK
+	providerId string
+	status     k8s.NodeGroup_Status
+	listNodes  int
+}
+
+func (s *nodesSDK) ProviderIdFor(context.Context, string) (string, error) {
+	s.listNodes++
+	if s.providerId == "" {
+		return "", fmt.Errorf("not found")
+	}
+	return s.providerId, nil
+}
+
+func (s *nodesSDK) GetNodeGroup(_ context.Context, nodeGroupId string) (*k8s.NodeGroup, error) {
+	return &k8s.NodeGroup{Id: nodeGroupId, Status: s.status}, nil
+}
+
+func (s *nodesSDK) DeleteNodeGroup(context.Context, string) error {
+	return nil
+}
+
+func TestCachedSDK_ProviderIdFor(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Cached within the TTL", func(t *testing.T) {
+		sdk := &nodesSDK{providerId: "yandex://instance-1", status: k8s.NodeGroup_RUNNING}
+		cached := NewCachedSDK(sdk)
+		for range 3 {
+			providerId, err := cached.ProviderIdFor(ctx, "ng-1")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if providerId != "yandex://instance-1" {
+				t.Errorf("Expected provider id yandex://instance-1, got %s", providerId)
+			}
+		}
+		// a running node group keeps its cached provider id
+		if _, err := cached.GetNodeGroup(ctx, "ng-1"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := cached.ProviderIdFor(ctx, "ng-1"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if sdk.listNodes != 1 {
+			t.Errorf("Expected the nodes to be listed once, got %d", sdk.listNodes)
+		}
+	})
+
+	t.Run("Failures are read again", func(t *testing.T) {
+		sdk := &nodesSDK{status: k8s.NodeGroup_PROVISIONING}
+		cached := NewCachedSDK(sdk)
+		if _, err := cached.ProviderIdFor(ctx, "ng-1"); err == nil {
+			t.Fatalf("Expected an error before the node reports its cloud status")
+		}
+		sdk.providerId = "yandex://instance-1"
+		providerId, err := cached.ProviderIdFor(ctx, "ng-1")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if providerId != "yandex://instance-1" {
+			t.Errorf("Expected provider id yandex://instance-1, got %s", providerId)
+		}
+		if sdk.listNodes != 2 {
+			t.Errorf("Expected the nodes to be listed twice, got %d", sdk.listNodes)
+		}
+	})
+
+	t.Run("Forgotten once the node group terminates", func(t *testing.T) {
+		sdk := &nodesSDK{providerId: "yandex://instance-1", status: k8s.NodeGroup_DELETING}
+		cached := NewCachedSDK(sdk)
+		if _, err := cached.ProviderIdFor(ctx, "ng-1"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := cached.GetNodeGroup(ctx, "ng-1"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := cached.ProviderIdFor(ctx, "ng-1"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if sdk.listNodes != 2 {
+			t.Errorf("Expected the nodes to be listed again after the node group terminated, got %d", sdk.listNodes)
+		}
+	})
+
+	t.Run("Forgotten once the node group is deleted", func(t *testing.T) {
+		sdk := &nodesSDK{providerId: "yandex://instance-1", status: k8s.NodeGroup_RUNNING}
+		cached := NewCachedSDK(sdk)
+		if _, err := cached.ProviderIdFor(ctx, "ng-1"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := cached.DeleteNodeGroup(ctx, "ng-1"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := cached.ProviderIdFor(ctx, "ng-1"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if sdk.listNodes != 2 {
+			t.Errorf("Expected the nodes to be listed again after the node group was deleted, got %d", sdk.listNodes)
+		}
+	})
+}
@@ -0,0 +1,94 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yandex
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tufitko/karpenter-provider-yandex/pkg/apis/v1alpha1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/k8s/v1"
+	"google.golang.org/genproto/googleapis/type/dayofweek"
+	"google.golang.org/genproto/googleapis/type/timeofday"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	minMaintenanceWindowDuration = time.Hour
+	maxMaintenanceWindowDuration = 24 * time.Hour
+)
+
+var maintenanceDays = map[string]dayofweek.DayOfWeek{
+	"monday":    dayofweek.DayOfWeek_MONDAY,
+	"tuesday":   dayofweek.DayOfWeek_TUESDAY,
+	"wednesday": dayofweek.DayOfWeek_WEDNESDAY,
+	"thursday":  dayofweek.DayOfWeek_THURSDAY,
+	"friday":    dayofweek.DayOfWeek_FRIDAY,
+	"saturday":  dayofweek.DayOfWeek_SATURDAY,
+	"sunday":    dayofweek.DayOfWeek_SUNDAY,
+}
+
+// NewMaintenanceWindow converts a nodeclass maintenance window to a node group one. A window without days is daily,
+// without a window at all Yandex Cloud maintains the nodes at any time
+func NewMaintenanceWindow(window *v1alpha1.MaintenanceWindow) (*k8s.MaintenanceWindow, error) {
+	if window == nil {
+		return nil, nil
+	}
+
+	start, err := time.Parse("15:04", window.StartTime)
+	if err != nil {
+		return nil, fmt.Errorf("start time %q is not in HH:MM format", window.StartTime)
+	}
+	if d := window.Duration.Duration; d < minMaintenanceWindowDuration || d > maxMaintenanceWindowDuration {
+		return nil, fmt.Errorf("duration %s must be between %s and %s", d, minMaintenanceWindowDuration, maxMaintenanceWindowDuration)
+	}
+	startTime := &timeofday.TimeOfDay{Hours: int32(start.Hour()), Minutes: int32(start.Minute())}
+	duration := durationpb.New(window.Duration.Duration)
+
+	if len(window.Days) == 0 {
+		return &k8s.MaintenanceWindow{
+			Policy: &k8s.MaintenanceWindow_DailyMaintenanceWindow{
+				DailyMaintenanceWindow: &k8s.DailyMaintenanceWindow{StartTime: startTime, Duration: duration},
+			},
+		}, nil
+	}
+
+	days := make([]dayofweek.DayOfWeek, 0, len(window.Days))
+	for _, day := range window.Days {
+		d, ok := maintenanceDays[day]
+		if !ok {
+			return nil, fmt.Errorf("unknown day of week %q", day)
+		}
+		days = append(days, d)
+	}
+	return &k8s.MaintenanceWindow{
+		Policy: &k8s.MaintenanceWindow_WeeklyMaintenanceWindow{
+			WeeklyMaintenanceWindow: &k8s.WeeklyMaintenanceWindow{
+				DaysOfWeek: []*k8s.DaysOfWeekMaintenanceWindow{{Days: days, StartTime: startTime, Duration: duration}},
+			},
+		},
+	}, nil
+}
+
+// nodeMaintenancePolicy returns the maintenance policy of the node groups of the nodeclass, its maintenance window is
+// expected to be valid
+func nodeMaintenancePolicy(spec v1alpha1.YandexNodeClassSpec) *k8s.NodeGroupMaintenancePolicy {
+	window, _ := NewMaintenanceWindow(spec.MaintenanceWindow())
+	return &k8s.NodeGroupMaintenancePolicy{
+		AutoRepair:        spec.AutoRepairEnabled(),
+		AutoUpgrade:       spec.AutoUpgradeEnabled(),
+		MaintenanceWindow: window,
+	}
+}
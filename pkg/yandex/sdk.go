@@ -2,9 +2,11 @@ package yandex
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"maps"
 	"math"
+	"strconv"
 	"strings"
 
 	"github.com/samber/lo"
@@ -12,10 +14,15 @@ import (
 	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
 	"github.com/yandex-cloud/go-genproto/yandex/cloud/k8s/v1"
 	"github.com/yandex-cloud/go-genproto/yandex/cloud/operation"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/quotamanager/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/resourcemanager/v1"
 	"github.com/yandex-cloud/go-genproto/yandex/cloud/vpc/v1"
 	ycsdk "github.com/yandex-cloud/go-sdk"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	grpcstatus "google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
 )
@@ -25,11 +32,14 @@ type SDK interface {
 	ListNetworkSubnets(ctx context.Context) ([]*vpc.Subnet, error)
 	UsedIPsInSubnet(ctx context.Context, subnetId string) (int, error)
 	MaxPodsPerNode(ctx context.Context) (int, error)
+	GetCluster(ctx context.Context) (*k8s.Cluster, error)
 	CreateFixedNodeGroup(
 		ctx context.Context,
 		name string,
+		idempotencyKey string,
 		labels map[string]string,
 		nodeLabels map[string]string,
+		taints []corev1.Taint,
 		platformId PlatformId,
 		coreFraction CoreFraction,
 		cpu resource.Quantity,
@@ -40,22 +50,30 @@ type SDK interface {
 		nodeclass *v1alpha1.YandexNodeClass,
 		diskType string,
 		diskSize int64,
-	) (string, error)
+		userData string,
+	) (nodeGroupId string, operationId string, err error)
 	DeleteNodeGroup(ctx context.Context, nodeGroupId string) error
 	GetNodeGroup(ctx context.Context, nodeGroupId string) (*k8s.NodeGroup, error)
 	ProviderIdFor(ctx context.Context, nodeGroupId string) (string, error)
 	GetNodeGroupByProviderId(ctx context.Context, providerId string) (*k8s.NodeGroup, error)
+	InstanceZone(ctx context.Context, providerId string) (string, error)
 	ListNodeGroups(ctx context.Context) ([]*k8s.NodeGroup, error)
 	GetNodeFromNodeGroup(ctx context.Context, nodeGroupId string) (*k8s.Node, error)
 	SecurityGroupExists(ctx context.Context, securityGroupId string) (bool, error)
+	Quotas(ctx context.Context) (map[string]Quota, error)
 }
 
+// idempotencyKeyMetadataKey is the gRPC metadata key Yandex Cloud uses to deduplicate retried mutating calls
+const idempotencyKeyMetadataKey = "idempotency-key"
+
 type YCSDK struct {
 	*ycsdk.SDK
 	clusterID string
+	// folderID overrides the folder of the cluster when listing node groups
+	folderID string
 }
 
-func NewSDK(ctx context.Context, clusterID string) (*YCSDK, error) {
+func NewSDK(ctx context.Context, clusterID, folderID string) (*YCSDK, error) {
 	sdk, err := buildSDK(ctx)
 	if err != nil {
 		return nil, err
@@ -64,6 +82,21 @@ func NewSDK(ctx context.Context, clusterID string) (*YCSDK, error) {
 	return &YCSDK{
 		SDK:       sdk,
 		clusterID: clusterID,
+		folderID:  folderID,
+	}, nil
+}
+
+// NewSDKWithConfig is NewSDK against an explicitly configured API, such as a local replay of Yandex Cloud in tests
+func NewSDKWithConfig(ctx context.Context, config ycsdk.Config, clusterID, folderID string) (*YCSDK, error) {
+	sdk, err := ycsdk.Build(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &YCSDK{
+		SDK:       sdk,
+		clusterID: clusterID,
+		folderID:  folderID,
 	}, nil
 }
 
@@ -107,10 +140,14 @@ func (p *YCSDK) UsedIPsInSubnet(ctx context.Context, subnetId string) (int, erro
 	return res, nil
 }
 
-func (p *YCSDK) MaxPodsPerNode(ctx context.Context) (int, error) {
-	cluster, err := p.SDK.Kubernetes().Cluster().Get(ctx, &k8s.GetClusterRequest{
+func (p *YCSDK) GetCluster(ctx context.Context) (*k8s.Cluster, error) {
+	return p.SDK.Kubernetes().Cluster().Get(ctx, &k8s.GetClusterRequest{
 		ClusterId: p.clusterID,
 	})
+}
+
+func (p *YCSDK) MaxPodsPerNode(ctx context.Context) (int, error) {
+	cluster, err := p.GetCluster(ctx)
 	if err != nil {
 		return 0, err
 	}
@@ -126,8 +163,10 @@ func (p *YCSDK) MaxPodsPerNode(ctx context.Context) (int, error) {
 func (p *YCSDK) CreateFixedNodeGroup(
 	ctx context.Context,
 	name string,
+	idempotencyKey string,
 	labels map[string]string,
 	nodeLabels map[string]string,
+	taints []corev1.Taint,
 	platformId PlatformId,
 	coreFraction CoreFraction,
 	cpu resource.Quantity,
@@ -138,26 +177,140 @@ func (p *YCSDK) CreateFixedNodeGroup(
 	nodeclass *v1alpha1.YandexNodeClass,
 	diskType string,
 	diskSize int64,
-) (string, error) {
+	userData string,
+) (string, string, error) {
+	// fail before any call instead of with an opaque API error
+	if n := len(nodeGroupLabels(labels, nodeLabels)); n > MaxNodeGroupLabels {
+		return "", "", grpcstatus.Errorf(codes.InvalidArgument, "node group %s would have %d labels, Yandex Cloud allows at most %d, reduce the labels of the nodeclass", name, n, MaxNodeGroupLabels)
+	}
+
+	// the metadata of the node VMs holds user-data as is
+	decodedUserData, err := base64.StdEncoding.DecodeString(userData)
+	if err != nil {
+		return "", "", grpcstatus.Errorf(codes.InvalidArgument, "user-data of node group %s is not valid base64, %s", name, err)
+	}
+
+	if nodeclass.Spec.GPUDriverVersion != "" && !platformId.IsGPU() {
+		return "", "", grpcstatus.Errorf(codes.InvalidArgument, "node group %s has GPU driver version %s, but platform %s has no GPUs", name, nodeclass.Spec.GPUDriverVersion, platformId)
+	}
+
+	if _, err := NewMaintenanceWindow(nodeclass.Spec.MaintenanceWindow()); err != nil {
+		return "", "", grpcstatus.Errorf(codes.InvalidArgument, "maintenance window of node group %s is invalid, %s", name, err)
+	}
+
 	// guard against duplicated node groups
 	// this can be removed after stabilization of api and karpenter
 	existedNodeGroups, err := p.ListNodeGroups(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to list node groups: %w", err)
+		return "", "", fmt.Errorf("failed to list node groups: %w", err)
 	}
 	for _, existedNodeGroup := range existedNodeGroups {
 		if existedNodeGroup.Name == name {
-			return existedNodeGroup.Id, nil
+			// the operation that created the node group is not known anymore
+			return existedNodeGroup.Id, "", nil
 		}
 	}
 
+	// retries of the same create are deduplicated by Yandex Cloud instead of creating another node group
+	ctx = metadata.AppendToOutgoingContext(ctx, idempotencyKeyMetadataKey, idempotencyKey)
+	req := p.newCreateNodeGroupRequest(name, labels, nodeLabels, taints, platformId, coreFraction, cpu, mem, preemptible, zoneId, subnetId, nodeclass, diskType, diskSize, string(decodedUserData))
+	op, err := p.SDK.WrapOperation(p.SDK.Kubernetes().NodeGroup().Create(ctx, req))
+	if err != nil {
+		return "", "", err
+	}
+
+	protoMetadata, err := op.Metadata()
+	if err != nil {
+		return "", op.Id(), fmt.Errorf("error while get Kubernetes node group create operation %s metadata: %s", op.Id(), err)
+	}
+
+	md, ok := protoMetadata.(*k8s.CreateNodeGroupMetadata)
+	if !ok {
+		return "", op.Id(), fmt.Errorf("could not get Instance ID from create operation %s metadata", op.Id())
+	}
+
+	return md.GetNodeGroupId(), op.Id(), nil
+}
+
+// gpuDriverVersionMetadataKey is the metadata key the bootstrap of GPU nodes reads the NVIDIA driver version from
+const gpuDriverVersionMetadataKey = "gpu-driver-version"
+
+// nodeMetadata returns the metadata of the node VMs, userData and gpuDriverVersion are omitted when empty
+func nodeMetadata(userData string, options *v1alpha1.MetadataOptions, gpuDriverVersion string) map[string]string {
+	md := map[string]string{
+		"enable-oslogin": strconv.FormatBool(options.OSLoginEnabled()),
+	}
+	if userData != "" {
+		md["user-data"] = userData
+	}
+	if gpuDriverVersion != "" {
+		md[gpuDriverVersionMetadataKey] = gpuDriverVersion
+	}
+	return md
+}
+
+// nodeAddressSpec returns the primary IPv4 address spec of the nodes, with one-to-one NAT when they get a public IP
+func nodeAddressSpec(assignPublicIP bool) *k8s.NodeAddressSpec {
+	if !assignPublicIP {
+		return &k8s.NodeAddressSpec{}
+	}
+	return &k8s.NodeAddressSpec{
+		OneToOneNatSpec: &k8s.OneToOneNatSpec{IpVersion: k8s.IpVersion_IPV4},
+	}
+}
+
+// MaxNodeGroupLabels is the number of labels Yandex Cloud allows on a node group
+const MaxNodeGroupLabels = 64
+
+// nodeGroupLabels merges the labels of a node group and its node template. These are cloud labels, whose values
+// Yandex Cloud only accepts in lower case, the node labels themselves are passed on as is in NodeLabels
+func nodeGroupLabels(labels map[string]string, nodeLabels map[string]string) map[string]string {
 	labels = maps.Clone(labels)
 	labels["managed-by"] = "karpenter"
 	for k, v := range nodeLabels {
 		labels[k] = strings.ToLower(v)
 	}
+	return labels
+}
+
+// bootDiskType returns the disk type id of a nodeclass disk type, an empty one defaults to network-ssd and unknown
+// ones are passed on for Yandex Cloud to reject
+func bootDiskType(diskType string) string {
+	if t, ok := DiskTypeFromCRD(diskType); ok {
+		return string(t)
+	}
+	return diskType
+}
 
-	op, err := p.SDK.WrapOperation(p.SDK.Kubernetes().NodeGroup().Create(ctx, &k8s.CreateNodeGroupRequest{
+// containerRuntimeType returns the container runtime of a nodeclass container runtime, defaulting to containerd
+func containerRuntimeType(runtime string) k8s.NodeTemplate_ContainerRuntimeSettings_Type {
+	if runtime == "docker" {
+		return k8s.NodeTemplate_ContainerRuntimeSettings_DOCKER
+	}
+	return k8s.NodeTemplate_ContainerRuntimeSettings_CONTAINERD
+}
+
+// newCreateNodeGroupRequest builds the request for a fixed-size node group backing a single NodeClaim
+func (p *YCSDK) newCreateNodeGroupRequest(
+	name string,
+	labels map[string]string,
+	nodeLabels map[string]string,
+	taints []corev1.Taint,
+	platformId PlatformId,
+	coreFraction CoreFraction,
+	cpu resource.Quantity,
+	mem resource.Quantity,
+	preemptible bool,
+	zoneId string,
+	subnetId string,
+	nodeclass *v1alpha1.YandexNodeClass,
+	diskType string,
+	diskSize int64,
+	userData string,
+) *k8s.CreateNodeGroupRequest {
+	labels = nodeGroupLabels(labels, nodeLabels)
+
+	return &k8s.CreateNodeGroupRequest{
 		ClusterId:   p.clusterID,
 		Name:        name,
 		Description: "karpenter node group",
@@ -170,22 +323,20 @@ func (p *YCSDK) CreateFixedNodeGroup(
 				CoreFraction: int64(coreFraction),
 				Cores:        cpu.Value(),
 				Memory:       mem.Value(),
-				// todo: gpu
+				Gpus:         (&InstanceType{Platform: platformId, CPU: cpu}).GPUs(),
 			},
 			BootDiskSpec: &k8s.DiskSpec{
-				DiskTypeId: diskType,
+				DiskTypeId: bootDiskType(diskType),
 				DiskSize:   diskSize,
 			},
-			Metadata: map[string]string{ // todo: configurable
-				"enable-oslogin": "true",
-			},
+			Metadata: nodeMetadata(userData, nodeclass.Spec.MetadataOptions, nodeclass.Spec.GPUDriverVersion),
 			SchedulingPolicy: &k8s.SchedulingPolicy{
 				Preemptible: preemptible,
 			},
 			NetworkInterfaceSpecs: []*k8s.NetworkInterfaceSpec{
 				{
 					SubnetIds:            []string{subnetId},
-					PrimaryV4AddressSpec: &k8s.NodeAddressSpec{},
+					PrimaryV4AddressSpec: nodeAddressSpec(nodeclass.Spec.AssignPublicIP),
 					SecurityGroupIds:     nodeclass.Spec.SecurityGroups,
 				},
 			},
@@ -195,9 +346,13 @@ func (p *YCSDK) CreateFixedNodeGroup(
 				).Else(k8s.NodeTemplate_NetworkSettings_STANDARD),
 			},
 			ContainerRuntimeSettings: &k8s.NodeTemplate_ContainerRuntimeSettings{
-				Type: k8s.NodeTemplate_ContainerRuntimeSettings_CONTAINERD,
+				Type: containerRuntimeType(nodeclass.Spec.ContainerRuntime),
 			},
 		},
+		// Every NodeClaim gets a node group of its own. Batching identical NodeClaims into one larger group would save
+		// node group objects and speed up bursts, but a node group cannot shed a chosen node: shrinking it lets Yandex
+		// Cloud pick the node that goes, and deleting the instance makes the instance group recreate it. Consolidation
+		// or expiry of one NodeClaim could then terminate the node of another, so the group size stays 1.
 		ScalePolicy: &k8s.ScalePolicy{
 			ScaleType: &k8s.ScalePolicy_FixedScale_{
 				FixedScale: &k8s.ScalePolicy_FixedScale{
@@ -212,37 +367,50 @@ func (p *YCSDK) CreateFixedNodeGroup(
 				},
 			},
 		},
+		// node groups are deleted outright instead of being scaled to zero, deletion is not bound by the deploy policy,
+		// so MaxUnavailable only guards updates and never has to let the only node of the group go
 		DeployPolicy: &k8s.DeployPolicy{
 			MaxUnavailable: 0,
 			MaxExpansion:   1,
 		},
-		MaintenancePolicy: &k8s.NodeGroupMaintenancePolicy{
-			AutoRepair:  true,
-			AutoUpgrade: false,
-		},
+		MaintenancePolicy:    nodeMaintenancePolicy(nodeclass.Spec),
 		AllowedUnsafeSysctls: nil,
-		NodeTaints: []*k8s.Taint{{
-			Key:    karpv1.UnregisteredNoExecuteTaint.Key,
-			Value:  karpv1.UnregisteredNoExecuteTaint.Value,
-			Effect: k8s.Taint_NO_EXECUTE,
-		}},
-		NodeLabels: nodeLabels,
-	}))
-	if err != nil {
-		return "", err
+		NodeTaints:           nodeTaints(taints),
+		NodeLabels:           nodeLabels,
 	}
+}
 
-	protoMetadata, err := op.Metadata()
-	if err != nil {
-		return "", fmt.Errorf("error while get Kubernetes node group create operation metadata: %s", err)
-	}
+var taintEffects = map[corev1.TaintEffect]k8s.Taint_Effect{
+	corev1.TaintEffectNoSchedule:       k8s.Taint_NO_SCHEDULE,
+	corev1.TaintEffectPreferNoSchedule: k8s.Taint_PREFER_NO_SCHEDULE,
+	corev1.TaintEffectNoExecute:        k8s.Taint_NO_EXECUTE,
+}
 
-	md, ok := protoMetadata.(*k8s.CreateNodeGroupMetadata)
-	if !ok {
-		return "", fmt.Errorf("could not get Instance ID from create operation metadata")
-	}
+// TaintEffectSupported returns whether node group taints can have the effect
+func TaintEffectSupported(effect corev1.TaintEffect) bool {
+	_, ok := taintEffects[effect]
+	return ok
+}
 
-	return md.GetNodeGroupId(), nil
+// nodeTaints converts the taints to node group taints, so they are on the node before kubelet registers it.
+// The unregistered taint is always added, Karpenter removes it once the node is registered
+func nodeTaints(taints []corev1.Taint) []*k8s.Taint {
+	res := []*k8s.Taint{{
+		Key:    karpv1.UnregisteredNoExecuteTaint.Key,
+		Value:  karpv1.UnregisteredNoExecuteTaint.Value,
+		Effect: k8s.Taint_NO_EXECUTE,
+	}}
+	for _, taint := range taints {
+		if taint.MatchTaint(&karpv1.UnregisteredNoExecuteTaint) {
+			continue
+		}
+		res = append(res, &k8s.Taint{
+			Key:    taint.Key,
+			Value:  taint.Value,
+			Effect: taintEffects[taint.Effect],
+		})
+	}
+	return res
 }
 
 func (p *YCSDK) DeleteNodeGroup(ctx context.Context, nodeGroupId string) error {
@@ -296,14 +464,77 @@ func (p *YCSDK) GetNodeGroupByProviderId(ctx context.Context, providerId string)
 	if err != nil {
 		return nil, err
 	}
-	nodeGroupId := instance.Labels["managed-kubernetes-node-group-id"]
-	if nodeGroupId == "" {
-		return nil, fmt.Errorf("could not get node group id")
+	nodeGroupId, err := nodeGroupIdFromInstance(instance)
+	if err != nil {
+		return nil, err
 	}
 
 	return p.GetNodeGroup(ctx, nodeGroupId)
 }
 
+// InstanceZone returns the zone the instance with the given provider id runs in
+func (p *YCSDK) InstanceZone(ctx context.Context, providerId string) (string, error) {
+	instance, err := p.SDK.Compute().Instance().Get(ctx, &compute.GetInstanceRequest{
+		InstanceId: strings.TrimPrefix(providerId, "yandex://"),
+		View:       compute.InstanceView_BASIC,
+	})
+	if err != nil {
+		return "", err
+	}
+	return instance.GetZoneId(), nil
+}
+
+// nodeGroupIdFromInstance returns the managed kubernetes node group of an instance. An instance that is not a managed
+// kubernetes node has no node group, which is reported as not found like a missing instance
+func nodeGroupIdFromInstance(instance *compute.Instance) (string, error) {
+	nodeGroupId := instance.GetLabels()["managed-kubernetes-node-group-id"]
+	if nodeGroupId == "" {
+		return "", grpcstatus.Errorf(codes.NotFound, "instance %s is not a managed kubernetes node", instance.GetId())
+	}
+	return nodeGroupId, nil
+}
+
+// IsNotFound returns whether err reports a missing Yandex Cloud resource
+func IsNotFound(err error) bool {
+	return grpcstatus.Code(err) == codes.NotFound
+}
+
+// IsInsufficientCapacity returns whether err reports exhausted quotas or capacity, which another instance type or
+// zone may still have. Yandex Cloud also throttles requests with RESOURCE_EXHAUSTED, those are retried as is instead
+func IsInsufficientCapacity(err error) bool {
+	return grpcstatus.Code(err) == codes.ResourceExhausted && !IsRateLimited(err)
+}
+
+// IsRateLimited returns whether err reports throttled requests. They come with RetryInfo details, exhausted quotas
+// come with QuotaFailure details instead
+func IsRateLimited(err error) bool {
+	st, ok := grpcstatus.FromError(err)
+	if !ok || st.Code() != codes.ResourceExhausted {
+		return false
+	}
+	var retry, quota bool
+	for _, detail := range st.Details() {
+		switch detail.(type) {
+		case *errdetails.RetryInfo:
+			retry = true
+		case *errdetails.QuotaFailure:
+			quota = true
+		}
+	}
+	return retry && !quota
+}
+
+// IsPermanent returns whether err reports a request Yandex Cloud rejects however many times it is retried, such as
+// an invalid configuration or a missing permission
+func IsPermanent(err error) bool {
+	switch grpcstatus.Code(err) {
+	case codes.InvalidArgument, codes.PermissionDenied, codes.NotFound:
+		return true
+	default:
+		return false
+	}
+}
+
 func (p *YCSDK) ListNodeGroups(ctx context.Context) ([]*k8s.NodeGroup, error) {
 	cluster, err := p.SDK.Kubernetes().Cluster().Get(ctx, &k8s.GetClusterRequest{
 		ClusterId: p.clusterID,
@@ -312,16 +543,37 @@ func (p *YCSDK) ListNodeGroups(ctx context.Context) ([]*k8s.NodeGroup, error) {
 		return nil, err
 	}
 
-	ngs, err := p.SDK.Kubernetes().NodeGroup().NodeGroupIterator(ctx, &k8s.ListNodeGroupsRequest{
-		FolderId: cluster.FolderId,
-	}).TakeAll()
-	if err != nil {
-		return nil, err
+	// node groups are listed by folder, without one the request would not be scoped to the cluster at all
+	folderIDs := nodeGroupFolders(p.folderID, cluster.GetFolderId())
+	if len(folderIDs) == 0 {
+		return nil, fmt.Errorf("cluster %s has no folder id, set folder-id to list its node groups", p.clusterID)
+	}
+
+	var ngs []*k8s.NodeGroup
+	for _, folderID := range folderIDs {
+		folderNGs, err := p.SDK.Kubernetes().NodeGroup().NodeGroupIterator(ctx, &k8s.ListNodeGroupsRequest{
+			FolderId: folderID,
+		}).TakeAll()
+		if err != nil {
+			return nil, fmt.Errorf("listing node groups of folder %s, %w", folderID, err)
+		}
+		ngs = append(ngs, folderNGs...)
 	}
+	return karpenterNodeGroups(p.clusterID, ngs), nil
+}
 
-	return lo.Filter(ngs, func(item *k8s.NodeGroup, _ int) bool {
-		return item.ClusterId == p.clusterID && item.Labels["managed-by"] == "karpenter"
-	}), nil
+// nodeGroupFolders returns the folders to list node groups in. Node groups created before folder-id was set, or
+// by another deployment without it, stay in the folder of the cluster, so it is listed next to the override
+func nodeGroupFolders(folderID, clusterFolderID string) []string {
+	return lo.Uniq(lo.Compact([]string{folderID, clusterFolderID}))
+}
+
+// karpenterNodeGroups returns the node groups Karpenter manages in the cluster, once each
+func karpenterNodeGroups(clusterID string, ngs []*k8s.NodeGroup) []*k8s.NodeGroup {
+	ngs = lo.Filter(ngs, func(item *k8s.NodeGroup, _ int) bool {
+		return item.ClusterId == clusterID && item.Labels["managed-by"] == "karpenter"
+	})
+	return lo.UniqBy(ngs, func(item *k8s.NodeGroup) string { return item.Id })
 }
 
 func (p *YCSDK) GetNodeFromNodeGroup(ctx context.Context, nodeGroupId string) (*k8s.Node, error) {
@@ -352,8 +604,76 @@ func (p *YCSDK) SecurityGroupExists(ctx context.Context, securityGroupId string)
 		return true, nil
 	}
 
-	if grpcstatus.Code(err) == codes.NotFound {
+	if IsNotFound(err) {
 		return false, nil
 	}
 	return false, err
 }
+
+// Quota is the limit of a Yandex Cloud quota and how much of it is used
+type Quota struct {
+	Limit float64
+	Usage float64
+}
+
+// Available returns how much of the quota is left, never less than zero
+func (q Quota) Available() float64 {
+	return math.Max(q.Limit-q.Usage, 0)
+}
+
+// Compute quotas relevant to provisioning nodes
+const (
+	QuotaInstances            = "compute.instances.count"
+	QuotaInstanceCores        = "compute.instanceCores.count"
+	QuotaInstanceMemory       = "compute.instanceMemory.size"
+	QuotaInstanceGPUs         = "compute.instanceGpus.count"
+	QuotaSSDDisksSize         = "compute.ssdDisks.size"
+	QuotaHDDDisksSize         = "compute.hddDisks.size"
+	QuotaNonReplicatedSSDSize = "compute.ssdNonReplicatedDisks.size"
+	QuotaSSDFilesystemsSize   = "compute.ssdFilesystems.size"
+)
+
+// quotaService is the quota manager service owning compute, GPU and disk quotas
+const quotaService = "compute"
+
+// Quotas returns the compute quotas of the cloud the cluster's folder belongs to, keyed by quota id. Yandex Cloud
+// accounts compute quotas per cloud, so usage includes every folder of that cloud
+func (p *YCSDK) Quotas(ctx context.Context) (map[string]Quota, error) {
+	cluster, err := p.GetCluster(ctx)
+	if err != nil {
+		return nil, err
+	}
+	folder, err := p.SDK.ResourceManager().Folder().Get(ctx, &resourcemanager.GetFolderRequest{
+		FolderId: lo.CoalesceOrEmpty(p.folderID, cluster.FolderId),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting folder, %w", err)
+	}
+
+	limits, err := p.SDK.QuotaManager().QuotaLimit().QuotaLimitIterator(ctx, &quotamanager.ListQuotaLimitsRequest{
+		Resource: &quotamanager.Resource{
+			Id:   folder.CloudId,
+			Type: "resource-manager.cloud",
+		},
+		Service: quotaService,
+	}).TakeAll()
+	if err != nil {
+		return nil, fmt.Errorf("listing quota limits, %w", err)
+	}
+	return quotasFromLimits(limits), nil
+}
+
+// quotasFromLimits indexes quota limits by quota id. A limit without a value is reported as zero
+func quotasFromLimits(limits []*quotamanager.QuotaLimit) map[string]Quota {
+	quotas := make(map[string]Quota, len(limits))
+	for _, limit := range limits {
+		if limit.GetQuotaId() == "" {
+			continue
+		}
+		quotas[limit.GetQuotaId()] = Quota{
+			Limit: limit.GetLimit().GetValue(),
+			Usage: limit.GetUsage().GetValue(),
+		}
+	}
+	return quotas
+}
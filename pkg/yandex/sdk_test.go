@@ -0,0 +1,657 @@
+package yandex
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/tufitko/karpenter-provider-yandex/pkg/apis/v1alpha1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/compute/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/k8s/v1"
+	"github.com/yandex-cloud/go-genproto/yandex/cloud/quotamanager/v1"
+	"google.golang.org/genproto/googleapis/type/dayofweek"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	karpv1 "sigs.k8s.io/karpenter/pkg/apis/v1"
+)
+
+func newTestCreateRequest(nodeClass *v1alpha1.YandexNodeClass, taints []corev1.Taint) *k8s.CreateNodeGroupRequest {
+	return newTestCreateRequestWithDisk(nodeClass, taints, string(SSD), 30<<30)
+}
+
+func newTestCreateRequestWithDisk(nodeClass *v1alpha1.YandexNodeClass, taints []corev1.Taint, diskType string, diskSize int64) *k8s.CreateNodeGroupRequest {
+	p := &YCSDK{clusterID: "test-cluster"}
+	return p.newCreateNodeGroupRequest(
+		"test-nodeclaim",
+		map[string]string{},
+		map[string]string{},
+		taints,
+		PlatformIntelIceLake,
+		CoreFraction100,
+		resource.MustParse("2"),
+		resource.MustParse("4Gi"),
+		false,
+		"ru-central1-a",
+		"subnet-a",
+		nodeClass,
+		diskType,
+		diskSize,
+		"",
+	)
+}
+
+func TestNewCreateNodeGroupRequest_AutoRepair(t *testing.T) {
+	testCases := []struct {
+		name       string
+		autoRepair *bool
+		expected   bool
+	}{
+		{
+			name:       "Defaults to enabled when unset",
+			autoRepair: nil,
+			expected:   true,
+		},
+		{
+			name:       "Explicitly enabled",
+			autoRepair: lo.ToPtr(true),
+			expected:   true,
+		},
+		{
+			name:       "Explicitly disabled",
+			autoRepair: lo.ToPtr(false),
+			expected:   false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeClass := &v1alpha1.YandexNodeClass{
+				Spec: v1alpha1.YandexNodeClassSpec{
+					AutoRepair: tc.autoRepair,
+				},
+			}
+
+			req := newTestCreateRequest(nodeClass, nil)
+
+			if req.GetMaintenancePolicy().GetAutoRepair() != tc.expected {
+				t.Errorf("AutoRepair: expected %v, got %v", tc.expected, req.GetMaintenancePolicy().GetAutoRepair())
+			}
+			if req.GetMaintenancePolicy().GetAutoUpgrade() {
+				t.Errorf("AutoUpgrade: expected false, got true")
+			}
+		})
+	}
+}
+
+func TestNewCreateNodeGroupRequest_MaintenancePolicy(t *testing.T) {
+	nodeClass := &v1alpha1.YandexNodeClass{
+		Spec: v1alpha1.YandexNodeClassSpec{
+			AutoRepair: lo.ToPtr(false),
+			MaintenancePolicy: &v1alpha1.MaintenancePolicy{
+				AutoUpgrade: lo.ToPtr(true),
+				MaintenanceWindow: &v1alpha1.MaintenanceWindow{
+					Days:      []string{"saturday", "sunday"},
+					StartTime: "03:30",
+					Duration:  metav1.Duration{Duration: 4 * time.Hour},
+				},
+			},
+		},
+	}
+
+	policy := newTestCreateRequest(nodeClass, nil).GetMaintenancePolicy()
+
+	if !policy.GetAutoUpgrade() {
+		t.Errorf("AutoUpgrade: expected true, got false")
+	}
+	if policy.GetAutoRepair() {
+		t.Errorf("AutoRepair: expected spec.autoRepair to apply along with the maintenance policy, got true")
+	}
+	weekly := policy.GetMaintenanceWindow().GetWeeklyMaintenanceWindow().GetDaysOfWeek()
+	if len(weekly) != 1 {
+		t.Fatalf("Expected a weekly maintenance window, got %v", policy.GetMaintenanceWindow())
+	}
+	if days := weekly[0].GetDays(); !slices.Equal(days, []dayofweek.DayOfWeek{dayofweek.DayOfWeek_SATURDAY, dayofweek.DayOfWeek_SUNDAY}) {
+		t.Errorf("Expected the window on weekends, got %v", days)
+	}
+	if start := weekly[0].GetStartTime(); start.GetHours() != 3 || start.GetMinutes() != 30 {
+		t.Errorf("Expected the window to start at 03:30, got %v", start)
+	}
+	if d := weekly[0].GetDuration().AsDuration(); d != 4*time.Hour {
+		t.Errorf("Expected the window to last 4h, got %s", d)
+	}
+}
+
+func TestNewMaintenanceWindow(t *testing.T) {
+	daily, err := NewMaintenanceWindow(&v1alpha1.MaintenanceWindow{StartTime: "22:00", Duration: metav1.Duration{Duration: 24 * time.Hour}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if daily.GetDailyMaintenanceWindow().GetStartTime().GetHours() != 22 {
+		t.Errorf("Expected a daily window at 22:00, got %v", daily)
+	}
+
+	anytime, err := NewMaintenanceWindow(nil)
+	if err != nil || anytime != nil {
+		t.Errorf("Expected no window without one in the nodeclass, got %v, %v", anytime, err)
+	}
+
+	if _, err := NewMaintenanceWindow(&v1alpha1.MaintenanceWindow{StartTime: "24:00", Duration: metav1.Duration{Duration: time.Hour}}); err == nil {
+		t.Errorf("Expected an error for start time 24:00")
+	}
+	if _, err := NewMaintenanceWindow(&v1alpha1.MaintenanceWindow{StartTime: "03:00", Duration: metav1.Duration{Duration: 25 * time.Hour}}); err == nil {
+		t.Errorf("Expected an error for a window longer than a day")
+	}
+}
+
+func TestNewCreateNodeGroupRequest_AssignPublicIP(t *testing.T) {
+	for _, assignPublicIP := range []bool{false, true} {
+		t.Run(fmt.Sprintf("AssignPublicIP=%v", assignPublicIP), func(t *testing.T) {
+			nodeClass := &v1alpha1.YandexNodeClass{
+				Spec: v1alpha1.YandexNodeClassSpec{AssignPublicIP: assignPublicIP},
+			}
+
+			req := newTestCreateRequest(nodeClass, nil)
+
+			nat := req.GetNodeTemplate().GetNetworkInterfaceSpecs()[0].GetPrimaryV4AddressSpec().GetOneToOneNatSpec()
+			if !assignPublicIP {
+				if nat != nil {
+					t.Errorf("Expected no one-to-one NAT, got %v", nat)
+				}
+				return
+			}
+			if nat.GetIpVersion() != k8s.IpVersion_IPV4 {
+				t.Errorf("Expected one-to-one NAT over IPv4, got %v", nat)
+			}
+		})
+	}
+}
+
+func TestNewCreateNodeGroupRequest_SoftwareAcceleratedNetwork(t *testing.T) {
+	testCases := []struct {
+		coreFraction CoreFraction
+		requested    bool
+		expected     k8s.NodeTemplate_NetworkSettings_Type
+	}{
+		{CoreFraction100, true, k8s.NodeTemplate_NetworkSettings_SOFTWARE_ACCELERATED},
+		{CoreFraction100, false, k8s.NodeTemplate_NetworkSettings_STANDARD},
+		{CoreFraction50, true, k8s.NodeTemplate_NetworkSettings_STANDARD},
+		{CoreFraction50, false, k8s.NodeTemplate_NetworkSettings_STANDARD},
+		{CoreFraction20, true, k8s.NodeTemplate_NetworkSettings_STANDARD},
+		{CoreFraction5, true, k8s.NodeTemplate_NetworkSettings_STANDARD},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("fraction=%d,requested=%v", tc.coreFraction, tc.requested), func(t *testing.T) {
+			p := &YCSDK{clusterID: "test-cluster"}
+			nodeClass := &v1alpha1.YandexNodeClass{
+				Spec: v1alpha1.YandexNodeClassSpec{SoftwareAcceleratedNetworkSettings: tc.requested},
+			}
+
+			req := p.newCreateNodeGroupRequest("test-nodeclaim", map[string]string{}, map[string]string{}, nil, PlatformIntelIceLake, tc.coreFraction,
+				resource.MustParse("2"), resource.MustParse("4Gi"), false, "ru-central1-a", "subnet-a", nodeClass, string(SSD), 30<<30, "")
+
+			if got := req.GetNodeTemplate().GetNetworkSettings().GetType(); got != tc.expected {
+				t.Errorf("Expected network type %s, got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestNewCreateNodeGroupRequest_BootDisk(t *testing.T) {
+	testCases := []struct {
+		name             string
+		diskType         string
+		diskSize         resource.Quantity
+		expectedDiskType string
+		expectedDiskSize int64
+	}{
+		{
+			name:             "Default disk type",
+			diskSize:         resource.MustParse("64Gi"),
+			expectedDiskType: "network-ssd",
+			expectedDiskSize: 64 << 30,
+		},
+		{
+			name:             "HDD",
+			diskType:         "network-hdd",
+			diskSize:         resource.MustParse("100Gi"),
+			expectedDiskType: "network-hdd",
+			expectedDiskSize: 100 << 30,
+		},
+		{
+			name:             "Non-replicated SSD",
+			diskType:         "network-ssd-nonreplicated",
+			diskSize:         resource.MustParse("93Gi"),
+			expectedDiskType: "network-ssd-nonreplicated",
+			expectedDiskSize: 93 << 30,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeClass := &v1alpha1.YandexNodeClass{Spec: v1alpha1.YandexNodeClassSpec{DiskType: tc.diskType, DiskSize: tc.diskSize}}
+			req := newTestCreateRequestWithDisk(nodeClass, nil, nodeClass.Spec.DiskType, nodeClass.Spec.DiskSize.Value())
+
+			disk := req.GetNodeTemplate().GetBootDiskSpec()
+			if disk.GetDiskTypeId() != tc.expectedDiskType {
+				t.Errorf("Expected disk type %s, got %s", tc.expectedDiskType, disk.GetDiskTypeId())
+			}
+			if disk.GetDiskSize() != tc.expectedDiskSize {
+				t.Errorf("Expected disk size %d, got %d", tc.expectedDiskSize, disk.GetDiskSize())
+			}
+		})
+	}
+}
+
+func TestNewCreateNodeGroupRequest_GPUs(t *testing.T) {
+	testCases := []struct {
+		name     string
+		platform PlatformId
+		cpu      string
+		expected int64
+	}{
+		{"A100", PlatformAMDEPYCNVIDIAAmpereA100, "28", 1},
+		{"T4", PlatformIntelIceLakeNVIDIATeslaT4, "16", 1},
+		{"No GPUs", PlatformIntelIceLake, "2", 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &YCSDK{clusterID: "test-cluster"}
+			req := p.newCreateNodeGroupRequest("test-nodeclaim", map[string]string{}, map[string]string{}, nil, tc.platform, CoreFraction100,
+				resource.MustParse(tc.cpu), resource.MustParse("32Gi"), false, "ru-central1-a", "subnet-a", &v1alpha1.YandexNodeClass{}, string(SSD), 30<<30, "")
+
+			if gpus := req.GetNodeTemplate().GetResourcesSpec().GetGpus(); gpus != tc.expected {
+				t.Errorf("Expected %d GPUs, got %d", tc.expected, gpus)
+			}
+		})
+	}
+}
+
+func TestNodeMetadata(t *testing.T) {
+	testCases := []struct {
+		name             string
+		userData         string
+		options          *v1alpha1.MetadataOptions
+		gpuDriverVersion string
+		expected         map[string]string
+	}{
+		{
+			name:     "Defaults",
+			expected: map[string]string{"enable-oslogin": "true"},
+		},
+		{
+			name:     "User-data",
+			userData: "#cloud-config\n",
+			options:  &v1alpha1.MetadataOptions{UserData: "I2Nsb3VkLWNvbmZpZwo="},
+			expected: map[string]string{"enable-oslogin": "true", "user-data": "#cloud-config\n"},
+		},
+		{
+			name:     "OS Login disabled",
+			options:  &v1alpha1.MetadataOptions{EnableOSLogin: lo.ToPtr(false)},
+			expected: map[string]string{"enable-oslogin": "false"},
+		},
+		{
+			name:             "GPU driver version",
+			gpuDriverVersion: "535.104.05",
+			expected:         map[string]string{"enable-oslogin": "true", "gpu-driver-version": "535.104.05"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			md := nodeMetadata(tc.userData, tc.options, tc.gpuDriverVersion)
+			if !maps.Equal(md, tc.expected) {
+				t.Errorf("Expected metadata %v, got %v", tc.expected, md)
+			}
+		})
+	}
+}
+
+func TestNodeTaints(t *testing.T) {
+	unregistered := &k8s.Taint{
+		Key:    karpv1.UnregisteredNoExecuteTaint.Key,
+		Value:  karpv1.UnregisteredNoExecuteTaint.Value,
+		Effect: k8s.Taint_NO_EXECUTE,
+	}
+
+	testCases := []struct {
+		name     string
+		taints   []corev1.Taint
+		expected []*k8s.Taint
+	}{
+		{
+			name:     "Only the unregistered taint without nodepool taints",
+			expected: []*k8s.Taint{unregistered},
+		},
+		{
+			name: "Nodepool taints follow the unregistered taint",
+			taints: []corev1.Taint{
+				{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule},
+				{Key: "spot", Effect: corev1.TaintEffectPreferNoSchedule},
+				{Key: "draining", Value: "true", Effect: corev1.TaintEffectNoExecute},
+			},
+			expected: []*k8s.Taint{
+				unregistered,
+				{Key: "dedicated", Value: "gpu", Effect: k8s.Taint_NO_SCHEDULE},
+				{Key: "spot", Effect: k8s.Taint_PREFER_NO_SCHEDULE},
+				{Key: "draining", Value: "true", Effect: k8s.Taint_NO_EXECUTE},
+			},
+		},
+		{
+			name:     "Unregistered taint is not duplicated",
+			taints:   []corev1.Taint{karpv1.UnregisteredNoExecuteTaint},
+			expected: []*k8s.Taint{unregistered},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := newTestCreateRequest(&v1alpha1.YandexNodeClass{}, tc.taints)
+
+			if len(req.GetNodeTaints()) != len(tc.expected) {
+				t.Fatalf("Expected %d taints, got %d: %v", len(tc.expected), len(req.GetNodeTaints()), req.GetNodeTaints())
+			}
+			for i, taint := range req.GetNodeTaints() {
+				if !proto.Equal(taint, tc.expected[i]) {
+					t.Errorf("Taint %d: expected %v, got %v", i, tc.expected[i], taint)
+				}
+			}
+		})
+	}
+}
+
+func TestNewCreateNodeGroupRequest_NodeClassTaints(t *testing.T) {
+	nodeClass := &v1alpha1.YandexNodeClass{Spec: v1alpha1.YandexNodeClassSpec{StartupTaints: []corev1.Taint{
+		{Key: "example.com/no-schedule", Value: "a", Effect: corev1.TaintEffectNoSchedule},
+		{Key: "example.com/prefer-no-schedule", Effect: corev1.TaintEffectPreferNoSchedule},
+		{Key: "example.com/no-execute", Value: "c", Effect: corev1.TaintEffectNoExecute},
+	}}}
+
+	req := newTestCreateRequest(nodeClass, nodeClass.Spec.StartupTaints)
+
+	expected := []*k8s.Taint{
+		{Key: karpv1.UnregisteredNoExecuteTaint.Key, Value: karpv1.UnregisteredNoExecuteTaint.Value, Effect: k8s.Taint_NO_EXECUTE},
+		{Key: "example.com/no-schedule", Value: "a", Effect: k8s.Taint_NO_SCHEDULE},
+		{Key: "example.com/prefer-no-schedule", Effect: k8s.Taint_PREFER_NO_SCHEDULE},
+		{Key: "example.com/no-execute", Value: "c", Effect: k8s.Taint_NO_EXECUTE},
+	}
+	if len(req.GetNodeTaints()) != len(expected) {
+		t.Fatalf("Expected %d taints, got %d: %v", len(expected), len(req.GetNodeTaints()), req.GetNodeTaints())
+	}
+	for i, taint := range req.GetNodeTaints() {
+		if !proto.Equal(taint, expected[i]) {
+			t.Errorf("Taint %d: expected %v, got %v", i, expected[i], taint)
+		}
+	}
+}
+
+func TestNewCreateNodeGroupRequest_NodeLabels(t *testing.T) {
+	p := &YCSDK{clusterID: "test-cluster"}
+	req := p.newCreateNodeGroupRequest(
+		"test-nodeclaim",
+		map[string]string{},
+		map[string]string{"example.com/owner": "Team-A"},
+		nil,
+		PlatformIntelIceLake,
+		CoreFraction100,
+		resource.MustParse("2"),
+		resource.MustParse("4Gi"),
+		false,
+		"ru-central1-a",
+		"subnet-a",
+		&v1alpha1.YandexNodeClass{},
+		string(SSD),
+		30<<30,
+		"",
+	)
+
+	if v := req.GetNodeLabels()["example.com/owner"]; v != "Team-A" {
+		t.Errorf("Expected node label example.com/owner=Team-A, got %q", v)
+	}
+	// cloud label values are lower case only
+	if v := req.GetLabels()["example.com/owner"]; v != "team-a" {
+		t.Errorf("Expected cloud label example.com/owner=team-a, got %q", v)
+	}
+}
+
+func TestNodeGroupIdFromInstance(t *testing.T) {
+	testCases := []struct {
+		name             string
+		labels           map[string]string
+		expected         string
+		expectedNotFound bool
+	}{
+		{
+			name:     "Managed kubernetes node",
+			labels:   map[string]string{"managed-kubernetes-node-group-id": "ng-1"},
+			expected: "ng-1",
+		},
+		{
+			name:             "Not a managed kubernetes node",
+			labels:           map[string]string{"env": "prod"},
+			expectedNotFound: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeGroupId, err := nodeGroupIdFromInstance(&compute.Instance{Id: "instance-1", Labels: tc.labels})
+			if nodeGroupId != tc.expected {
+				t.Errorf("Expected node group id %q, got %q", tc.expected, nodeGroupId)
+			}
+			if IsNotFound(err) != tc.expectedNotFound {
+				t.Errorf("Expected not found=%t, got error %v", tc.expectedNotFound, err)
+			}
+		})
+	}
+}
+
+func TestQuotasFromLimits(t *testing.T) {
+	limits := []*quotamanager.QuotaLimit{
+		{QuotaId: QuotaInstanceCores, Limit: wrapperspb.Double(64), Usage: wrapperspb.Double(24)},
+		{QuotaId: QuotaInstanceGPUs, Limit: wrapperspb.Double(2)},
+		{QuotaId: QuotaSSDDisksSize, Limit: wrapperspb.Double(1 << 40), Usage: wrapperspb.Double(1 << 41)},
+		{Limit: wrapperspb.Double(10)},
+	}
+
+	quotas := quotasFromLimits(limits)
+	if len(quotas) != 3 {
+		t.Fatalf("Expected 3 quotas, got %v", quotas)
+	}
+
+	testCases := []struct {
+		quotaId   string
+		expected  Quota
+		available float64
+	}{
+		{quotaId: QuotaInstanceCores, expected: Quota{Limit: 64, Usage: 24}, available: 40},
+		{quotaId: QuotaInstanceGPUs, expected: Quota{Limit: 2}, available: 2},
+		{quotaId: QuotaSSDDisksSize, expected: Quota{Limit: 1 << 40, Usage: 1 << 41}, available: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.quotaId, func(t *testing.T) {
+			quota, ok := quotas[tc.quotaId]
+			if !ok {
+				t.Fatalf("Expected quota %s to be listed", tc.quotaId)
+			}
+			if quota != tc.expected {
+				t.Errorf("Expected %+v, got %+v", tc.expected, quota)
+			}
+			if quota.Available() != tc.available {
+				t.Errorf("Expected %v available, got %v", tc.available, quota.Available())
+			}
+		})
+	}
+}
+
+func TestNodeGroupFolders(t *testing.T) {
+	testCases := []struct {
+		name            string
+		folderID        string
+		clusterFolderID string
+		expected        []string
+	}{
+		{name: "Cluster folder", clusterFolderID: "folder-cluster", expected: []string{"folder-cluster"}},
+		{name: "Override of the cluster folder", folderID: "folder-nodes", clusterFolderID: "folder-cluster", expected: []string{"folder-nodes", "folder-cluster"}},
+		{name: "Override equal to the cluster folder", folderID: "folder-cluster", clusterFolderID: "folder-cluster", expected: []string{"folder-cluster"}},
+		{name: "Override of a cluster without folder", folderID: "folder-nodes", expected: []string{"folder-nodes"}},
+		{name: "No folder", expected: []string{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := nodeGroupFolders(tc.folderID, tc.clusterFolderID); !slices.Equal(got, tc.expected) {
+				t.Errorf("Expected folders %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestKarpenterNodeGroups(t *testing.T) {
+	karpenterLabels := map[string]string{"managed-by": "karpenter"}
+	ngs := []*k8s.NodeGroup{
+		{Id: "ng-1", ClusterId: "cluster", Labels: karpenterLabels},
+		{Id: "ng-2", ClusterId: "cluster"},
+		{Id: "ng-3", ClusterId: "other-cluster", Labels: karpenterLabels},
+		// listed again from the other folder
+		{Id: "ng-1", ClusterId: "cluster", Labels: karpenterLabels},
+		{Id: "ng-4", ClusterId: "cluster", Labels: karpenterLabels},
+	}
+
+	got := lo.Map(karpenterNodeGroups("cluster", ngs), func(ng *k8s.NodeGroup, _ int) string { return ng.Id })
+	if expected := []string{"ng-1", "ng-4"}; !slices.Equal(got, expected) {
+		t.Errorf("Expected node groups %v, got %v", expected, got)
+	}
+}
+
+func TestCreateFixedNodeGroup_TooManyLabels(t *testing.T) {
+	labels := map[string]string{}
+	for i := range 70 {
+		labels[fmt.Sprintf("label-%d", i)] = "value"
+	}
+
+	// the SDK has no client, the labels must be rejected before any call
+	p := &YCSDK{clusterID: "test-cluster"}
+	_, _, err := p.CreateFixedNodeGroup(
+		context.Background(),
+		"test-nodeclaim",
+		"key",
+		labels,
+		map[string]string{},
+		nil,
+		PlatformIntelIceLake,
+		CoreFraction100,
+		resource.MustParse("2"),
+		resource.MustParse("4Gi"),
+		false,
+		"ru-central1-a",
+		"subnet-a",
+		&v1alpha1.YandexNodeClass{},
+		string(SSD),
+		30<<30,
+		"",
+	)
+	if err == nil || !strings.Contains(err.Error(), "71 labels") {
+		t.Errorf("Expected an error about 71 labels, got %v", err)
+	}
+}
+
+func TestCreateFixedNodeGroup_MalformedUserData(t *testing.T) {
+	// the SDK has no client, the user-data must be rejected before any call
+	p := &YCSDK{clusterID: "test-cluster"}
+	_, _, err := p.CreateFixedNodeGroup(
+		context.Background(),
+		"test-nodeclaim",
+		"key",
+		map[string]string{},
+		map[string]string{},
+		nil,
+		PlatformIntelIceLake,
+		CoreFraction100,
+		resource.MustParse("2"),
+		resource.MustParse("4Gi"),
+		false,
+		"ru-central1-a",
+		"subnet-a",
+		&v1alpha1.YandexNodeClass{},
+		string(SSD),
+		30<<30,
+		"#cloud-config",
+	)
+	if !IsPermanent(err) || !strings.Contains(err.Error(), "not valid base64") {
+		t.Errorf("Expected a permanent error about base64, got %v", err)
+	}
+}
+
+func TestNewCreateNodeGroupRequest_GPUDriverVersion(t *testing.T) {
+	nodeClass := &v1alpha1.YandexNodeClass{Spec: v1alpha1.YandexNodeClassSpec{GPUDriverVersion: "535.104.05"}}
+	p := &YCSDK{clusterID: "test-cluster"}
+	req := p.newCreateNodeGroupRequest("test-nodeclaim", map[string]string{}, map[string]string{}, nil, PlatformAMDEPYCNVIDIAAmpereA100, CoreFraction100,
+		resource.MustParse("28"), resource.MustParse("119Gi"), false, "ru-central1-a", "subnet-a", nodeClass, string(SSD), 30<<30, "")
+
+	if version := req.GetNodeTemplate().GetMetadata()["gpu-driver-version"]; version != "535.104.05" {
+		t.Errorf("Expected gpu-driver-version 535.104.05, got %q", version)
+	}
+}
+
+func TestCreateFixedNodeGroup_GPUDriverVersionWithoutGPUs(t *testing.T) {
+	// the SDK has no client, the driver version must be rejected before any call
+	p := &YCSDK{clusterID: "test-cluster"}
+	_, _, err := p.CreateFixedNodeGroup(
+		context.Background(),
+		"test-nodeclaim",
+		"key",
+		map[string]string{},
+		map[string]string{},
+		nil,
+		PlatformIntelIceLake,
+		CoreFraction100,
+		resource.MustParse("2"),
+		resource.MustParse("4Gi"),
+		false,
+		"ru-central1-a",
+		"subnet-a",
+		&v1alpha1.YandexNodeClass{Spec: v1alpha1.YandexNodeClassSpec{GPUDriverVersion: "535.104.05"}},
+		string(SSD),
+		30<<30,
+		"",
+	)
+	if !IsPermanent(err) || !strings.Contains(err.Error(), "has no GPUs") {
+		t.Errorf("Expected a permanent error about the platform without GPUs, got %v", err)
+	}
+}
+
+func TestNewCreateNodeGroupRequest_ContainerRuntime(t *testing.T) {
+	testCases := []struct {
+		name     string
+		runtime  string
+		expected k8s.NodeTemplate_ContainerRuntimeSettings_Type
+	}{
+		{"Default", "", k8s.NodeTemplate_ContainerRuntimeSettings_CONTAINERD},
+		{"Containerd", "containerd", k8s.NodeTemplate_ContainerRuntimeSettings_CONTAINERD},
+		{"Docker", "docker", k8s.NodeTemplate_ContainerRuntimeSettings_DOCKER},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nodeClass := &v1alpha1.YandexNodeClass{Spec: v1alpha1.YandexNodeClassSpec{ContainerRuntime: tc.runtime}}
+			p := &YCSDK{clusterID: "test-cluster"}
+			req := p.newCreateNodeGroupRequest("test-nodeclaim", map[string]string{}, map[string]string{}, nil, PlatformIntelIceLake, CoreFraction100,
+				resource.MustParse("2"), resource.MustParse("4Gi"), false, "ru-central1-a", "subnet-a", nodeClass, string(SSD), 30<<30, "")
+
+			if runtime := req.GetNodeTemplate().GetContainerRuntimeSettings().GetType(); runtime != tc.expected {
+				t.Errorf("Expected container runtime %s, got %s", tc.expected, runtime)
+			}
+		})
+	}
+}
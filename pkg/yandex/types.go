@@ -8,6 +8,9 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 )
 
+// RegionRU is the Yandex Cloud region with built-in instance configurations and prices, every region has its own
+const RegionRU = "ru"
+
 type PlatformId string
 
 const (
@@ -33,6 +36,72 @@ const (
 	PlatformIntelIceLakeNVIDIATeslaT4i      PlatformId = "standard-v3-t4i"
 )
 
+// platformNames are the human names of the platforms, as label values
+var platformNames = map[PlatformId]string{
+	PlatformIntelBroadwell:                  "intel-broadwell",
+	PlatformIntelCascadeLake:                "intel-cascade-lake",
+	PlatformIntelIceLake:                    "intel-ice-lake",
+	PlatformAMDZen3:                         "amd-zen-3",
+	PlatformAMDZen4:                         "amd-zen-4",
+	PlatformIntelIceLakeComputeOptimized:    "intel-ice-lake-compute-optimized",
+	PlatformAmdZen4ComputeOptimized:         "amd-zen-4-compute-optimized",
+	PlatformIntelBroadwellNVIDIATeslaV100:   "intel-broadwell-nvidia-tesla-v100",
+	PlatformIntelCascadeLakeNVIDIATeslaV100: "intel-cascade-lake-nvidia-tesla-v100",
+	PlatformAMDEPYCNVIDIAAmpereA100:         "amd-epyc-nvidia-ampere-a100",
+	PlatformAMDEPYC9474FGen2:                "amd-epyc-9474f-gen2",
+	PlatformIntelIceLakeNVIDIATeslaT4:       "intel-ice-lake-nvidia-tesla-t4",
+	PlatformIntelIceLakeNVIDIATeslaT4i:      "intel-ice-lake-nvidia-tesla-t4i",
+}
+
+// Name returns the human name of the platform, e.g. intel-ice-lake for standard-v3, or an empty string for
+// unknown platforms
+func (p PlatformId) Name() string {
+	return platformNames[p]
+}
+
+// IsGPU returns whether the platform comes with GPUs
+func (p PlatformId) IsGPU() bool {
+	switch p {
+	case PlatformIntelBroadwellNVIDIATeslaV100,
+		PlatformIntelCascadeLakeNVIDIATeslaV100,
+		PlatformAMDEPYCNVIDIAAmpereA100,
+		PlatformAMDEPYC9474FGen2,
+		PlatformIntelIceLakeNVIDIATeslaT4,
+		PlatformIntelIceLakeNVIDIATeslaT4i:
+		return true
+	default:
+		return false
+	}
+}
+
+// platformGPUs are the GPU counts of the GPU platforms by vCPU count, every GPU configuration comes with a fixed
+// number of vCPUs
+var platformGPUs = map[PlatformId]map[int64]int64{
+	PlatformIntelBroadwellNVIDIATeslaV100:   {8: 1, 16: 2, 32: 4},
+	PlatformIntelCascadeLakeNVIDIATeslaV100: {8: 1, 16: 2, 32: 4, 64: 8},
+	PlatformAMDEPYCNVIDIAAmpereA100:         {28: 1, 56: 2, 112: 4, 224: 8},
+	PlatformAMDEPYC9474FGen2:                {18: 1, 36: 2, 72: 4, 180: 8},
+	PlatformIntelIceLakeNVIDIATeslaT4:       {4: 1, 8: 1, 16: 1, 32: 1},
+	PlatformIntelIceLakeNVIDIATeslaT4i:      {4: 1, 8: 1, 16: 1, 32: 1},
+}
+
+// platformGPUTypes are the GPU models of the GPU platforms, as label values
+var platformGPUTypes = map[PlatformId]string{
+	PlatformIntelBroadwellNVIDIATeslaV100:   "nvidia-tesla-v100",
+	PlatformIntelCascadeLakeNVIDIATeslaV100: "nvidia-tesla-v100",
+	PlatformAMDEPYCNVIDIAAmpereA100:         "nvidia-ampere-a100",
+	// Yandex Cloud names the GPU of the platform after its generation only
+	PlatformAMDEPYC9474FGen2:           "gen2",
+	PlatformIntelIceLakeNVIDIATeslaT4:  "nvidia-tesla-t4",
+	PlatformIntelIceLakeNVIDIATeslaT4i: "nvidia-tesla-t4i",
+}
+
+// GPUType returns the GPU model of the platform, e.g. nvidia-ampere-a100 for gpu-standard-v3, or an empty string for
+// platforms without GPUs
+func (p PlatformId) GPUType() string {
+	return platformGPUTypes[p]
+}
+
 type CoreFraction int64
 
 const (
@@ -55,22 +124,54 @@ const (
 	SSDIo            DiskType = "network-ssd-io-m3"
 )
 
+// DiskTypeFromCRD maps a nodeclass diskType to a DiskType. An empty diskType maps to SSD, the nodeclass default,
+// false is returned for disk types Yandex Cloud does not support
+func DiskTypeFromCRD(diskType string) (DiskType, bool) {
+	switch t := DiskType(diskType); t {
+	case "":
+		return SSD, true
+	case SSD, HDD, SSDNonreplicated, SSDIo:
+		return t, true
+	default:
+		return "", false
+	}
+}
+
 type Disk struct {
 	Type DiskType
 	Size int64
 }
 
 type InstanceType struct {
-	Platform     PlatformId
+	Platform PlatformId
+	// CPU is the number of vCPUs. Yandex Cloud only allocates whole vCPUs, names and prices of fractional
+	// values are still well-defined, but such instance types cannot be launched, see Validate
 	CPU          resource.Quantity
 	Memory       resource.Quantity
 	CoreFraction CoreFraction
+	// NUMANodes is the number of NUMA nodes the instance spans, zero when unknown. It is not part of the name, so
+	// instance types parsed with FromString leave it zero
+	NUMANodes int
 }
 
 func (r *InstanceType) String() string {
 	return fmt.Sprintf("%s_%s_%s_%d", r.Platform, r.CPU.String(), r.Memory.String(), r.CoreFraction)
 }
 
+// Validate ensures the instance type can be launched, a fractional CPU would be rounded up to whole vCPUs
+// by the node group resources spec and run on more cores than it was priced for
+func (r *InstanceType) Validate() error {
+	if r.CPU.MilliValue()%1000 != 0 {
+		return fmt.Errorf("instance type %s has a fractional vCPU count %s, only whole vCPUs are supported", r.String(), r.CPU.String())
+	}
+	return nil
+}
+
+// GPUs returns the number of GPUs of the instance type, zero for platforms without GPUs
+func (r *InstanceType) GPUs() int64 {
+	return platformGPUs[r.Platform][r.CPU.Value()]
+}
+
 func (r *InstanceType) FromString(str string) error {
 	parts := strings.Split(str, "_")
 	if len(parts) != 4 {
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 func TestInstanceType_String(t *testing.T) {
@@ -180,6 +181,12 @@ func TestInstanceType_RoundTrip(t *testing.T) {
 			Memory:       resource.MustParse("1G"),
 			CoreFraction: CoreFraction5,
 		},
+		{
+			Platform:     PlatformIntelIceLake,
+			CPU:          resource.MustParse("1500m"),
+			Memory:       resource.MustParse("3Gi"),
+			CoreFraction: CoreFraction100,
+		},
 	}
 
 	for i, original := range testCases {
@@ -211,6 +218,32 @@ func TestInstanceType_RoundTrip(t *testing.T) {
 	}
 }
 
+func TestInstanceType_Validate(t *testing.T) {
+	testCases := []struct {
+		cpu         string
+		expectError bool
+	}{
+		{cpu: "1", expectError: false},
+		{cpu: "2000m", expectError: false},
+		{cpu: "500m", expectError: true},
+		{cpu: "1500m", expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.cpu, func(t *testing.T) {
+			it := InstanceType{
+				Platform:     PlatformIntelIceLake,
+				CPU:          resource.MustParse(tc.cpu),
+				Memory:       resource.MustParse("4Gi"),
+				CoreFraction: CoreFraction100,
+			}
+			if err := it.Validate(); (err != nil) != tc.expectError {
+				t.Errorf("Expected error=%v, got %v", tc.expectError, err)
+			}
+		})
+	}
+}
+
 func TestCoreFraction_String(t *testing.T) {
 	testCases := []struct {
 		fraction CoreFraction
@@ -231,3 +264,103 @@ func TestCoreFraction_String(t *testing.T) {
 		})
 	}
 }
+
+func TestPlatformId_Name(t *testing.T) {
+	testCases := []struct {
+		platform PlatformId
+		expected string
+	}{
+		{PlatformIntelCascadeLake, "intel-cascade-lake"},
+		{PlatformIntelIceLake, "intel-ice-lake"},
+		{PlatformAMDZen4, "amd-zen-4"},
+		{PlatformIntelIceLakeComputeOptimized, "intel-ice-lake-compute-optimized"},
+		{PlatformIntelIceLakeNVIDIATeslaT4, "intel-ice-lake-nvidia-tesla-t4"},
+		{"standard-v9", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.platform), func(t *testing.T) {
+			if result := tc.platform.Name(); result != tc.expected {
+				t.Errorf("Expected: %q, got: %q", tc.expected, result)
+			}
+		})
+	}
+}
+
+// every platform name is used as a label value
+func TestPlatformId_NameIsLabelValue(t *testing.T) {
+	for platform, name := range platformNames {
+		if errs := validation.IsValidLabelValue(name); len(errs) > 0 {
+			t.Errorf("Name of %s is not a valid label value: %v", platform, errs)
+		}
+	}
+}
+
+func TestInstanceType_GPUs(t *testing.T) {
+	testCases := []struct {
+		name     string
+		platform PlatformId
+		cpu      string
+		expected int64
+	}{
+		{"A100 with 2 GPUs", PlatformAMDEPYCNVIDIAAmpereA100, "56", 2},
+		{"A100 with 8 GPUs", PlatformAMDEPYCNVIDIAAmpereA100, "224", 8},
+		{"T4", PlatformIntelIceLakeNVIDIATeslaT4, "8", 1},
+		{"V100", PlatformIntelCascadeLakeNVIDIATeslaV100, "32", 4},
+		{"No GPU platform", PlatformIntelIceLake, "8", 0},
+		{"Unknown GPU configuration", PlatformAMDEPYCNVIDIAAmpereA100, "8", 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			it := InstanceType{Platform: tc.platform, CPU: resource.MustParse(tc.cpu)}
+			if result := it.GPUs(); result != tc.expected {
+				t.Errorf("Expected: %d, got: %d", tc.expected, result)
+			}
+		})
+	}
+}
+
+// every GPU configuration is on a GPU platform
+func TestPlatformGPUsAreGPUPlatforms(t *testing.T) {
+	for platform := range platformGPUs {
+		if !platform.IsGPU() {
+			t.Errorf("Platform %s has GPU configurations, but is not a GPU platform", platform)
+		}
+	}
+}
+
+func TestPlatformGPUTypes(t *testing.T) {
+	for platform := range platformGPUs {
+		if platform.GPUType() == "" {
+			t.Errorf("GPU platform %s has no GPU type", platform)
+		}
+	}
+	if gpuType := PlatformIntelIceLake.GPUType(); gpuType != "" {
+		t.Errorf("Expected no GPU type for %s, got %q", PlatformIntelIceLake, gpuType)
+	}
+}
+
+func TestDiskTypeFromCRD(t *testing.T) {
+	testCases := []struct {
+		diskType   string
+		expected   DiskType
+		expectedOk bool
+	}{
+		{"network-ssd", SSD, true},
+		{"network-hdd", HDD, true},
+		{"network-ssd-nonreplicated", SSDNonreplicated, true},
+		{"network-ssd-io-m3", SSDIo, true},
+		{"", SSD, true},
+		{"network-nvme", "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.diskType, func(t *testing.T) {
+			result, ok := DiskTypeFromCRD(tc.diskType)
+			if result != tc.expected || ok != tc.expectedOk {
+				t.Errorf("Expected: %q/%t, got: %q/%t", tc.expected, tc.expectedOk, result, ok)
+			}
+		})
+	}
+}
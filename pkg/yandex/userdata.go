@@ -0,0 +1,54 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yandex
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"text/template"
+)
+
+// UserDataVariables are the node-specific values available to a nodeclass user-data template
+type UserDataVariables struct {
+	// Zone is the zone the node is launched in
+	Zone string
+	// InstanceType is the name of the instance type of the node
+	InstanceType string
+	// NodeName is the name of the NodeClaim, the node group of the node is named after it
+	NodeName string
+}
+
+// ParseUserDataTemplate parses a nodeclass user-data template
+func ParseUserDataTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("userData").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing user-data template, %w", err)
+	}
+	return tmpl, nil
+}
+
+// RenderUserData renders a nodeclass user-data template against vars and returns it base64-encoded
+func RenderUserData(text string, vars UserDataVariables) (string, error) {
+	tmpl, err := ParseUserDataTemplate(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("rendering user-data template, %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}